@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/letera1/huhems-exam-system/backend/internal/audit"
 	"github.com/letera1/huhems-exam-system/backend/internal/config"
 	"github.com/letera1/huhems-exam-system/backend/internal/db"
 	"github.com/letera1/huhems-exam-system/backend/internal/routes"
@@ -32,8 +34,16 @@ func main() {
 		// We log but don't fail, as the app might still be functional or it might be a transient error
 	}
 
+	// Periodically sign the audit chain head so truncation can be detected.
+	go audit.RunCheckpointer(context.Background(), database, []byte(cfg.AuditSigningKey), cfg.AuditCheckpointInterval)
+
 	router := gin.New()
 	router.Use(gin.Logger(), gin.Recovery())
+	// Login throttling and session records key on the client IP, so only
+	// the configured proxies may set it through X-Forwarded-For.
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("TRUSTED_PROXIES: %v", err)
+	}
 
 	// Add CORS middleware
 	router.Use(func(c *gin.Context) {
@@ -54,7 +64,7 @@ func main() {
 		c.Next()
 	})
 
-	routes.Register(router, database, cfg.JWTSecret)
+	routes.Register(router, database, cfg)
 
 	addr := ":" + cfg.Port
 	log.Printf("backend listening on %s", addr)
@@ -25,15 +25,13 @@ func main() {
 		log.Fatalf("failed to load users: %v", err)
 	}
 
-	fmt.Println("\n=== User Default Password Status ===")
+	fmt.Println("\n=== User Password Status ===")
 	for _, u := range users {
 		fmt.Printf("\nUsername: %s\n", u.Username)
 		fmt.Printf("Email: %s\n", u.Email)
 		fmt.Printf("Role: %s\n", u.Role.Name)
-		fmt.Printf("Default Password: '%s'\n", u.DefaultPassword)
-		fmt.Printf("Default Password Length: %d\n", len(u.DefaultPassword))
 		fmt.Printf("Password Changed At: %v\n", u.PasswordChangedAt)
-		fmt.Printf("Should Show Default: %v\n", u.PasswordChangedAt == nil && u.DefaultPassword != "")
+		fmt.Printf("Must Change Password: %v\n", u.MustChangePassword)
 	}
 	fmt.Println("\n=== End ===")
 }
@@ -97,11 +97,11 @@ func upsertUserReturning(db *gorm.DB, username, email, password string, roleID u
 
 	if err == gorm.ErrRecordNotFound {
 		user = models.User{
-			Username:        username,
-			Email:           email,
-			PasswordHash:    hash,
-			DefaultPassword: password, // Store the default password
-			RoleID:          roleID,
+			Username:           username,
+			Email:              email,
+			PasswordHash:       hash,
+			MustChangePassword: true,
+			RoleID:             roleID,
 		}
 		if err := db.Create(&user).Error; err != nil {
 			return models.User{}, err
@@ -113,7 +113,7 @@ func upsertUserReturning(db *gorm.DB, username, email, password string, roleID u
 	user.Username = username
 	user.Email = email
 	user.PasswordHash = hash
-	user.DefaultPassword = password // Store the default password
+	user.MustChangePassword = true
 	user.RoleID = roleID
 	// Force reset "PasswordChangedAt" to nil so they appear as default users
 	user.PasswordChangedAt = nil
@@ -13,16 +13,16 @@ import (
 )
 
 type adminStudentView struct {
-	ID                uuid.UUID  `json:"id"`
-	UserID            uuid.UUID  `json:"userId"`
-	Username          string     `json:"username"`
-	Email             string     `json:"email"`
-	FullName          string     `json:"fullName"`
-	Year              int        `json:"year"`
-	Department        string     `json:"department"`
-	CreatedAt         time.Time  `json:"createdAt"`
-	PasswordChangedAt *time.Time `json:"passwordChangedAt"`
-	DefaultPassword   string     `json:"defaultPassword,omitempty"`
+	ID                 uuid.UUID  `json:"id"`
+	UserID             uuid.UUID  `json:"userId"`
+	Username           string     `json:"username"`
+	Email              string     `json:"email"`
+	FullName           string     `json:"fullName"`
+	Year               int        `json:"year"`
+	Department         string     `json:"department"`
+	CreatedAt          time.Time  `json:"createdAt"`
+	PasswordChangedAt  *time.Time `json:"passwordChangedAt"`
+	MustChangePassword bool       `json:"mustChangePassword"`
 }
 
 func main() {
@@ -44,19 +44,16 @@ func main() {
 	resp := make([]adminStudentView, 0, len(students))
 	for _, s := range students {
 		view := adminStudentView{
-			ID:                s.ID,
-			UserID:            s.UserID,
-			Username:          s.User.Username,
-			Email:             s.User.Email,
-			FullName:          s.FullName,
-			Year:              s.Year,
-			Department:        s.Department,
-			CreatedAt:         s.CreatedAt,
-			PasswordChangedAt: s.User.PasswordChangedAt,
-		}
-		// Only show default password if it hasn't been changed
-		if s.User.PasswordChangedAt == nil {
-			view.DefaultPassword = s.User.DefaultPassword
+			ID:                 s.ID,
+			UserID:             s.UserID,
+			Username:           s.User.Username,
+			Email:              s.User.Email,
+			FullName:           s.FullName,
+			Year:               s.Year,
+			Department:         s.Department,
+			CreatedAt:          s.CreatedAt,
+			PasswordChangedAt:  s.User.PasswordChangedAt,
+			MustChangePassword: s.User.MustChangePassword,
 		}
 		resp = append(resp, view)
 	}
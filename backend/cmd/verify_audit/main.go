@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/letera1/huhems-exam-system/backend/internal/audit"
+	"github.com/letera1/huhems-exam-system/backend/internal/config"
+	"github.com/letera1/huhems-exam-system/backend/internal/db"
+)
+
+// verify_audit walks the audit log hash chain end to end and checks every
+// signed checkpoint. It exits with status 1 when the chain is broken.
+func main() {
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	checkpoint := flag.Bool("checkpoint", false, "sign the current chain head after a successful verification")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+
+	database, err := db.Connect(cfg.DBURL)
+	if err != nil {
+		log.Fatalf("failed to connect database: %v", err)
+	}
+
+	key := []byte(cfg.AuditSigningKey)
+	report, err := audit.Verify(database, key)
+	if err != nil {
+		log.Fatalf("verification failed: %v", err)
+	}
+
+	if *asJSON {
+		out, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(out))
+	} else {
+		fmt.Println("\n=== Audit Chain Verification ===")
+		fmt.Printf("Chained entries:    %d\n", report.Entries)
+		fmt.Printf("Unchained (legacy): %d\n", report.Unchained)
+		fmt.Printf("Checkpoints:        %d\n", report.Checkpoints)
+		fmt.Printf("Head:               seq=%d hash=%s\n", report.HeadSeq, report.HeadHash)
+		if report.FirstBreak != nil {
+			fmt.Printf("FIRST BROKEN LINK:  seq=%d id=%s: %s\n", report.FirstBreak.Seq, report.FirstBreak.ID, report.FirstBreak.Reason)
+		}
+		if report.CheckpointErr != nil {
+			fmt.Printf("CHECKPOINT ERROR:   seq=%d id=%s: %s\n", report.CheckpointErr.Seq, report.CheckpointErr.ID, report.CheckpointErr.Reason)
+		}
+		if report.OK() {
+			fmt.Println("Result:             OK")
+		} else {
+			fmt.Println("Result:             BROKEN")
+		}
+		fmt.Println("=== End ===")
+	}
+
+	if !report.OK() {
+		os.Exit(1)
+	}
+
+	if *checkpoint {
+		cp, err := audit.Checkpoint(database, key)
+		if err != nil {
+			log.Fatalf("failed to write checkpoint: %v", err)
+		}
+		if cp != nil {
+			log.Printf("signed checkpoint at seq=%d", cp.Seq)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+// Package audit records who changed what through the admin API.
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/letera1/huhems-exam-system/backend/internal/middleware"
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// Actions recorded by the admin API.
+const (
+	ActionExamCreate  = "exam.create"
+	ActionExamUpdate  = "exam.update"
+	ActionExamDelete  = "exam.delete"
+	ActionExamPublish = "exam.publish"
+
+	ActionSimilarityRun      = "exam.similarity_run"
+	ActionAnnouncementCreate = "exam.announcement_create"
+	ActionExamTargetsSet     = "exam.targets_set"
+
+	ActionQuestionCreate = "question.create"
+	ActionQuestionUpdate = "question.update"
+	ActionQuestionDelete = "question.delete"
+	ActionQuestionImport = "question.import"
+
+	ActionStudentCreate = "student.create"
+	ActionStudentUpdate = "student.update"
+	ActionStudentDelete = "student.delete"
+	ActionStudentImport = "student.import"
+
+	ActionGroupCreate        = "group.create"
+	ActionGroupUpdate        = "group.update"
+	ActionGroupDelete        = "group.delete"
+	ActionGroupMembersAdd    = "group.members_add"
+	ActionGroupMembersRemove = "group.members_remove"
+	ActionGroupImport        = "group.import"
+
+	ActionCourseCreate         = "course.create"
+	ActionCourseUpdate         = "course.update"
+	ActionCourseDelete         = "course.delete"
+	ActionCourseInstructorsSet = "course.instructors_set"
+	ActionCourseStudentsAdd    = "course.students_add"
+	ActionCourseStudentsRemove = "course.students_remove"
+
+	ActionInstructorCreate = "user.instructor_create"
+	ActionUserRolesSet     = "user.roles_set"
+	ActionSessionsRevoke   = "user.sessions_revoke"
+	ActionLockoutRelease   = "user.unlock"
+
+	ActionRoleCreate         = "role.create"
+	ActionRolePermissionsSet = "role.permissions_set"
+	ActionRoleDelete         = "role.delete"
+
+	ActionAccommodationSet    = "student.accommodation_set"
+	ActionAccommodationDelete = "student.accommodation_delete"
+
+	ActionAttemptReview      = "attempt.review"
+	ActionAttemptTransfer    = "attempt.transfer"
+	ActionAttemptPause       = "attempt.pause"
+	ActionAttemptResume      = "attempt.resume"
+	ActionAttemptExtend      = "attempt.extend"
+	ActionAttemptForceSubmit = "attempt.force_submit"
+	ActionAttemptInvalidate  = "attempt.invalidate"
+
+	ActionPasswordChange = "user.password_change"
+	ActionPasswordReset  = "user.password_reset"
+
+	ActionPasswordAdminReset    = "user.password_admin_reset"
+	ActionStudentPasswordsReset = "student.passwords_reset"
+
+	ActionMFAEnable        = "user.mfa_enable"
+	ActionMFADisable       = "user.mfa_disable"
+	ActionMFARecoveryCodes = "user.mfa_recovery_codes"
+	ActionMFAAdminReset    = "user.mfa_admin_reset"
+
+	ActionUserProvision    = "user.provision"
+	ActionUserExternalSync = "user.external_sync"
+)
+
+// Entity types recorded alongside actions.
+const (
+	EntityExam     = "exam"
+	EntityQuestion = "question"
+	EntityStudent  = "student"
+	EntityAttempt  = "attempt"
+	EntityGroup    = "group"
+	EntityCourse   = "course"
+	EntityUser     = "user"
+	EntityRole     = "role"
+)
+
+// Entry describes a single mutation. Before and After may be any value that
+// marshals to a JSON object (or nil); only the top-level fields that differ
+// between them are stored.
+type Entry struct {
+	Action     string
+	EntityType string
+	EntityID   uuid.UUID
+	Before     any
+	After      any
+}
+
+// Record writes an audit log entry for the authenticated user of c. Pass the
+// transaction that makes the change, so the entry commits or rolls back with
+// it.
+func Record(db *gorm.DB, c *gin.Context, e Entry) error {
+	v, ok := c.Get(string(middleware.ContextUserID))
+	if !ok {
+		return errors.New("audit: no authenticated user")
+	}
+	actorID, ok := v.(uuid.UUID)
+	if !ok {
+		return errors.New("audit: no authenticated user")
+	}
+	return RecordAs(db, c, actorID, e)
+}
+
+// RecordAs writes an audit log entry for actorID, for requests that are not
+// authenticated but whose actor is known, such as a password reset through
+// an emailed link or a sign-in that provisions an account.
+func RecordAs(db *gorm.DB, c *gin.Context, actorID uuid.UUID, e Entry) error {
+	before, after, err := diff(e.Before, e.After)
+	if err != nil {
+		return err
+	}
+
+	entry := models.AuditLog{
+		UserID:     actorID,
+		Action:     e.Action,
+		EntityType: e.EntityType,
+		Before:     before,
+		After:      after,
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	}
+	if e.EntityID != uuid.Nil {
+		id := e.EntityID
+		entry.EntityID = &id
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		return appendToChain(tx, &entry)
+	})
+}
+
+func toMap(v any) (map[string]any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// diff returns JSON snapshots restricted to the top-level keys whose values
+// differ between before and after. When one side is nil the other side is
+// stored in full.
+func diff(before, after any) (*string, *string, error) {
+	b, err := toMap(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	a, err := toMap(after)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if b != nil && a != nil {
+		for k, bv := range b {
+			if av, ok := a[k]; ok && reflect.DeepEqual(av, bv) {
+				delete(a, k)
+				delete(b, k)
+			}
+		}
+	}
+
+	encode := func(m map[string]any) (*string, error) {
+		if m == nil {
+			return nil, nil
+		}
+		raw, err := json.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+		s := string(raw)
+		return &s, nil
+	}
+
+	bs, err := encode(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	as, err := encode(a)
+	if err != nil {
+		return nil, nil, err
+	}
+	return bs, as, nil
+}
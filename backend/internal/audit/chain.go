@@ -0,0 +1,318 @@
+package audit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// GenesisHash is the PrevHash of the first chained entry.
+const GenesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// chainLockKey serializes appends to the chain across API replicas using a
+// Postgres transaction-level advisory lock.
+const chainLockKey = 0x48554845 // "HUHE"
+
+// canonicalJSON re-encodes a JSON document with sorted keys and no
+// insignificant whitespace. Postgres jsonb does not preserve the original
+// text, so hashes are always computed over this form.
+func canonicalJSON(s *string) (string, error) {
+	if s == nil {
+		return "", nil
+	}
+	var v any
+	if err := json.Unmarshal([]byte(*s), &v); err != nil {
+		return "", err
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
+
+// ComputeHash returns the hex SHA-256 of the entry's chained fields.
+func ComputeHash(e models.AuditLog) (string, error) {
+	before, err := canonicalJSON(e.Before)
+	if err != nil {
+		return "", fmt.Errorf("before: %w", err)
+	}
+	after, err := canonicalJSON(e.After)
+	if err != nil {
+		return "", fmt.Errorf("after: %w", err)
+	}
+	entityID := ""
+	if e.EntityID != nil {
+		entityID = e.EntityID.String()
+	}
+
+	// A JSON array gives an unambiguous encoding of the fields.
+	payload, err := json.Marshal([]string{
+		strconv.FormatInt(e.Seq, 10),
+		e.PrevHash,
+		e.UserID.String(),
+		e.Action,
+		e.EntityType,
+		entityID,
+		before,
+		after,
+		e.IP,
+		e.UserAgent,
+		e.Timestamp.UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// appendToChain assigns Seq, PrevHash and Hash to entry and inserts it. It must
+// be called inside a transaction.
+func appendToChain(tx *gorm.DB, entry *models.AuditLog) error {
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", chainLockKey).Error; err != nil {
+		return err
+	}
+
+	var head []models.AuditLog
+	if err := tx.Unscoped().Select("seq", "hash").Where("seq > 0").Order("seq desc").Limit(1).Find(&head).Error; err != nil {
+		return err
+	}
+
+	entry.Seq = 1
+	entry.PrevHash = GenesisHash
+	if len(head) > 0 {
+		entry.Seq = head[0].Seq + 1
+		entry.PrevHash = head[0].Hash
+	}
+	// Postgres stores microsecond precision; truncate so the hash can be
+	// recomputed from the stored row.
+	entry.Timestamp = time.Now().UTC().Truncate(time.Microsecond)
+
+	hash, err := ComputeHash(*entry)
+	if err != nil {
+		return err
+	}
+	entry.Hash = hash
+
+	return tx.Create(entry).Error
+}
+
+func signCheckpoint(key []byte, seq int64, hash string) string {
+	mac := hmac.New(sha256.New, key)
+	_, _ = fmt.Fprintf(mac, "%d:%s", seq, hash)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Checkpoint signs the current head of the chain. It does nothing if the head
+// has not moved since the last checkpoint.
+func Checkpoint(db *gorm.DB, key []byte) (*models.AuditCheckpoint, error) {
+	var created *models.AuditCheckpoint
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", chainLockKey).Error; err != nil {
+			return err
+		}
+
+		var head []models.AuditLog
+		if err := tx.Unscoped().Select("seq", "hash").Where("seq > 0").Order("seq desc").Limit(1).Find(&head).Error; err != nil {
+			return err
+		}
+		if len(head) == 0 {
+			return nil
+		}
+
+		var last []models.AuditCheckpoint
+		if err := tx.Order("seq desc").Limit(1).Find(&last).Error; err != nil {
+			return err
+		}
+		if len(last) > 0 && last[0].Seq >= head[0].Seq {
+			return nil
+		}
+
+		cp := models.AuditCheckpoint{
+			Seq:       head[0].Seq,
+			Hash:      head[0].Hash,
+			Signature: signCheckpoint(key, head[0].Seq, head[0].Hash),
+		}
+		if err := tx.Create(&cp).Error; err != nil {
+			return err
+		}
+		created = &cp
+		return nil
+	})
+	return created, err
+}
+
+// RunCheckpointer writes a signed checkpoint every interval until ctx is done.
+func RunCheckpointer(ctx context.Context, db *gorm.DB, key []byte, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := Checkpoint(db, key); err != nil {
+				log.Printf("audit: checkpoint failed: %v", err)
+			}
+		}
+	}
+}
+
+// BrokenLink describes the first inconsistency found by Verify.
+type BrokenLink struct {
+	Seq    int64  `json:"seq"`
+	ID     string `json:"id,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// VerifyReport summarizes a chain verification.
+type VerifyReport struct {
+	Entries       int64       `json:"entries"`
+	Unchained     int64       `json:"unchained"`
+	Checkpoints   int         `json:"checkpoints"`
+	HeadSeq       int64       `json:"headSeq"`
+	HeadHash      string      `json:"headHash"`
+	FirstBreak    *BrokenLink `json:"firstBreak"`
+	CheckpointErr *BrokenLink `json:"checkpointError"`
+}
+
+// OK reports whether the chain and all checkpoints verified.
+func (r VerifyReport) OK() bool {
+	return r.FirstBreak == nil && r.CheckpointErr == nil
+}
+
+// Verify walks the whole chain in sequence order, recomputing every hash, and
+// then checks every checkpoint signature against the chain. Soft-deleted
+// entries are included: deleting an audit entry is itself tampering.
+func Verify(db *gorm.DB, key []byte) (VerifyReport, error) {
+	var report VerifyReport
+
+	if err := db.Unscoped().Model(&models.AuditLog{}).Where("seq = 0").Count(&report.Unchained).Error; err != nil {
+		return report, err
+	}
+
+	walk := newChainWalk()
+	const batchSize = 1000
+
+	for report.FirstBreak == nil {
+		var batch []models.AuditLog
+		// Overlap by one so duplicates of the last checked seq are seen.
+		from := walk.expectedSeq - 1
+		if from < 1 {
+			from = 1
+		}
+		if err := db.Unscoped().Where("seq >= ?", from).Order("seq asc, id asc").Limit(batchSize).Find(&batch).Error; err != nil {
+			return report, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		for _, e := range batch {
+			if !walk.next(&report, e) {
+				break
+			}
+		}
+
+		if len(batch) < batchSize {
+			break
+		}
+	}
+
+	var checkpoints []models.AuditCheckpoint
+	if err := db.Unscoped().Order("seq asc").Find(&checkpoints).Error; err != nil {
+		return report, err
+	}
+	walk.checkpoints(&report, key, checkpoints)
+
+	return report, nil
+}
+
+// chainWalk holds the state of a verification pass over the chain.
+type chainWalk struct {
+	hashes      map[int64]string
+	expectedSeq int64
+	prevHash    string
+	lastID      string
+}
+
+func newChainWalk() *chainWalk {
+	return &chainWalk{hashes: map[int64]string{}, expectedSeq: 1, prevHash: GenesisHash}
+}
+
+// next checks the entry that follows the ones already walked and records it
+// in report. It returns false, with report.FirstBreak set, if the chain
+// breaks at e. Entries arrive in seq order; the entry checked last may be
+// seen again and is skipped.
+func (w *chainWalk) next(report *VerifyReport, e models.AuditLog) bool {
+	id := e.ID.String()
+	if id == w.lastID {
+		return true
+	}
+	switch {
+	case e.DeletedAt.Valid:
+		report.FirstBreak = &BrokenLink{Seq: e.Seq, ID: id, Reason: "entry was soft-deleted"}
+	case e.Seq < w.expectedSeq:
+		report.FirstBreak = &BrokenLink{Seq: e.Seq, ID: id, Reason: "duplicate sequence number"}
+	case e.Seq != w.expectedSeq:
+		report.FirstBreak = &BrokenLink{Seq: w.expectedSeq, Reason: fmt.Sprintf("missing entry (next present seq is %d)", e.Seq)}
+	case e.PrevHash != w.prevHash:
+		report.FirstBreak = &BrokenLink{Seq: e.Seq, ID: id, Reason: "prevHash does not match the previous entry"}
+	}
+	if report.FirstBreak != nil {
+		return false
+	}
+	computed, err := ComputeHash(e)
+	if err != nil {
+		report.FirstBreak = &BrokenLink{Seq: e.Seq, ID: id, Reason: "cannot hash entry: " + err.Error()}
+		return false
+	}
+	if computed != e.Hash {
+		report.FirstBreak = &BrokenLink{Seq: e.Seq, ID: id, Reason: "entry content does not match its hash"}
+		return false
+	}
+
+	w.hashes[e.Seq] = e.Hash
+	report.Entries++
+	report.HeadSeq = e.Seq
+	report.HeadHash = e.Hash
+	w.prevHash = e.Hash
+	w.lastID = id
+	w.expectedSeq++
+	return true
+}
+
+// checkpoints checks every signed checkpoint, in seq order, against the
+// entries walked so far and records the first failure in report.
+func (w *chainWalk) checkpoints(report *VerifyReport, key []byte, checkpoints []models.AuditCheckpoint) {
+	report.Checkpoints = len(checkpoints)
+	for _, cp := range checkpoints {
+		if !hmac.Equal([]byte(signCheckpoint(key, cp.Seq, cp.Hash)), []byte(cp.Signature)) {
+			report.CheckpointErr = &BrokenLink{Seq: cp.Seq, ID: cp.ID.String(), Reason: "checkpoint signature is invalid"}
+			return
+		}
+		if report.FirstBreak != nil && cp.Seq >= report.FirstBreak.Seq {
+			// Already reported as a chain break.
+			return
+		}
+		h, ok := w.hashes[cp.Seq]
+		if !ok {
+			report.CheckpointErr = &BrokenLink{Seq: cp.Seq, ID: cp.ID.String(), Reason: "chain is shorter than a signed checkpoint (entries were removed)"}
+			return
+		}
+		if h != cp.Hash {
+			report.CheckpointErr = &BrokenLink{Seq: cp.Seq, ID: cp.ID.String(), Reason: "chain hash differs from signed checkpoint"}
+			return
+		}
+	}
+}
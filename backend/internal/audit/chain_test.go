@@ -0,0 +1,173 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+)
+
+// testChain builds a valid chain of n entries.
+func testChain(t *testing.T, n int) []models.AuditLog {
+	t.Helper()
+	after := `{"b": 2, "a": 1}`
+	chain := make([]models.AuditLog, n)
+	prev := GenesisHash
+	for i := range chain {
+		e := models.AuditLog{
+			Seq:        int64(i + 1),
+			PrevHash:   prev,
+			UserID:     uuid.New(),
+			Action:     "exam.update",
+			EntityType: "exam",
+			After:      &after,
+			Timestamp:  time.Date(2025, 1, 1, 0, 0, i, 0, time.UTC),
+		}
+		e.ID = uuid.New()
+		hash, err := ComputeHash(e)
+		if err != nil {
+			t.Fatalf("ComputeHash: %v", err)
+		}
+		e.Hash = hash
+		prev = hash
+		chain[i] = e
+	}
+	return chain
+}
+
+func TestComputeHashIgnoresJSONFormatting(t *testing.T) {
+	e := testChain(t, 1)[0]
+	compact := `{"a":1,"b":2}`
+	e.After = &compact
+	if got, _ := ComputeHash(e); got != e.Hash {
+		t.Errorf("hash changed when the JSON was reformatted")
+	}
+}
+
+func TestChainWalk(t *testing.T) {
+	tests := []struct {
+		name       string
+		tamper     func(chain []models.AuditLog) []models.AuditLog
+		wantSeq    int64
+		wantReason string
+	}{
+		{"intact", func(c []models.AuditLog) []models.AuditLog { return c }, 0, ""},
+		{"overlapping batches", func(c []models.AuditLog) []models.AuditLog {
+			return append(c[:3:3], c[2:]...)
+		}, 0, ""},
+		{"edited entry", func(c []models.AuditLog) []models.AuditLog {
+			c[2].Action = "exam.delete"
+			return c
+		}, 3, "entry content does not match its hash"},
+		{"rehashed entry", func(c []models.AuditLog) []models.AuditLog {
+			c[2].Action = "exam.delete"
+			c[2].Hash, _ = ComputeHash(c[2])
+			return c
+		}, 4, "prevHash does not match the previous entry"},
+		{"removed entry", func(c []models.AuditLog) []models.AuditLog {
+			return append(c[:2:2], c[3:]...)
+		}, 3, "missing entry (next present seq is 4)"},
+		{"duplicate entry", func(c []models.AuditLog) []models.AuditLog {
+			dup := c[1]
+			dup.ID = uuid.New()
+			return append(c[:2:2], append([]models.AuditLog{dup}, c[2:]...)...)
+		}, 2, "duplicate sequence number"},
+		{"soft-deleted entry", func(c []models.AuditLog) []models.AuditLog {
+			c[1].DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+			return c
+		}, 2, "entry was soft-deleted"},
+		{"bad JSON", func(c []models.AuditLog) []models.AuditLog {
+			bad := "{"
+			c[0].After = &bad
+			return c
+		}, 1, "cannot hash entry: after: unexpected end of JSON input"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var report VerifyReport
+			w := newChainWalk()
+			for _, e := range tt.tamper(testChain(t, 5)) {
+				if !w.next(&report, e) {
+					break
+				}
+			}
+			switch {
+			case tt.wantReason == "" && report.FirstBreak != nil:
+				t.Fatalf("unexpected break: %+v", report.FirstBreak)
+			case tt.wantReason == "":
+				if report.Entries != 5 || report.HeadSeq != 5 {
+					t.Errorf("walked %d entries to seq %d, want 5 to 5", report.Entries, report.HeadSeq)
+				}
+			case report.FirstBreak == nil:
+				t.Fatalf("no break, want %q at seq %d", tt.wantReason, tt.wantSeq)
+			case report.FirstBreak.Seq != tt.wantSeq || report.FirstBreak.Reason != tt.wantReason:
+				t.Errorf("break = %d %q, want %d %q", report.FirstBreak.Seq, report.FirstBreak.Reason, tt.wantSeq, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestChainWalkCheckpoints(t *testing.T) {
+	key := []byte("checkpoint key")
+	chain := testChain(t, 5)
+	sign := func(seq int64, hash string) models.AuditCheckpoint {
+		return models.AuditCheckpoint{Seq: seq, Hash: hash, Signature: signCheckpoint(key, seq, hash)}
+	}
+
+	tests := []struct {
+		name        string
+		walked      int
+		checkpoints []models.AuditCheckpoint
+		wantReason  string
+	}{
+		{"none", 5, nil, ""},
+		{"matching", 5, []models.AuditCheckpoint{sign(2, chain[1].Hash), sign(5, chain[4].Hash)}, ""},
+		{"forged signature", 5, []models.AuditCheckpoint{{Seq: 5, Hash: chain[4].Hash, Signature: "00"}}, "checkpoint signature is invalid"},
+		{"truncated chain", 3, []models.AuditCheckpoint{sign(5, chain[4].Hash)}, "chain is shorter than a signed checkpoint (entries were removed)"},
+		{"rewritten chain", 5, []models.AuditCheckpoint{sign(5, GenesisHash)}, "chain hash differs from signed checkpoint"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var report VerifyReport
+			w := newChainWalk()
+			for _, e := range chain[:tt.walked] {
+				w.next(&report, e)
+			}
+			w.checkpoints(&report, key, tt.checkpoints)
+			got := ""
+			if report.CheckpointErr != nil {
+				got = report.CheckpointErr.Reason
+			}
+			if got != tt.wantReason {
+				t.Errorf("checkpoint error = %q, want %q", got, tt.wantReason)
+			}
+			if report.Checkpoints != len(tt.checkpoints) {
+				t.Errorf("Checkpoints = %d, want %d", report.Checkpoints, len(tt.checkpoints))
+			}
+		})
+	}
+}
+
+func TestChainWalkCheckpointPastBreak(t *testing.T) {
+	key := []byte("checkpoint key")
+	chain := testChain(t, 5)
+	chain[3].Action = "exam.delete"
+
+	var report VerifyReport
+	w := newChainWalk()
+	for _, e := range chain {
+		if !w.next(&report, e) {
+			break
+		}
+	}
+	w.checkpoints(&report, key, []models.AuditCheckpoint{{Seq: 5, Hash: chain[4].Hash, Signature: signCheckpoint(key, 5, chain[4].Hash)}})
+	if report.FirstBreak == nil || report.CheckpointErr != nil {
+		t.Errorf("got break %+v and checkpoint error %+v, want only the chain break", report.FirstBreak, report.CheckpointErr)
+	}
+	if report.OK() {
+		t.Error("OK() = true for a broken chain")
+	}
+}
@@ -0,0 +1,54 @@
+package auth
+
+import "strings"
+
+// ExternalIdentity is a user as asserted by an external identity provider.
+// Empty fields were not provided.
+type ExternalIdentity struct {
+	// Provider is the models.AuthProvider* value of the provider.
+	Provider string
+	// Subject identifies the user at the provider and never changes.
+	Subject       string
+	Username      string
+	Email         string
+	EmailVerified bool
+	FullName      string
+	Department    string
+	Year          int
+	// Role is the role the provider's claims map to; empty if none do.
+	Role string
+}
+
+// RoleRule grants Role to users whose role claim or groups include Value.
+type RoleRule struct {
+	Value string
+	Role  string
+}
+
+// ParseRoleRules parses "value=role" pairs separated by commas, as used in
+// the role map settings.
+func ParseRoleRules(s string) []RoleRule {
+	var rules []RoleRule
+	for _, pair := range strings.Split(s, ",") {
+		value, role, ok := strings.Cut(pair, "=")
+		value, role = strings.TrimSpace(value), strings.TrimSpace(role)
+		if !ok || value == "" || role == "" {
+			continue
+		}
+		rules = append(rules, RoleRule{Value: value, Role: role})
+	}
+	return rules
+}
+
+// MapRole returns the role of the first rule matching one of values, or
+// fallback if none does. Values are compared case-insensitively.
+func MapRole(values []string, rules []RoleRule, fallback string) string {
+	for _, r := range rules {
+		for _, v := range values {
+			if strings.EqualFold(v, r.Value) {
+				return r.Role
+			}
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRoleRules(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []RoleRule
+	}{
+		{"empty", "", nil},
+		{"one rule", "staff=instructor", []RoleRule{{"staff", "instructor"}}},
+		{"keeps order", "admins=admin, staff = instructor", []RoleRule{{"admins", "admin"}, {"staff", "instructor"}}},
+		{"skips malformed pairs", "admins, =admin, staff=, ,proctors=proctor", []RoleRule{{"proctors", "proctor"}}},
+		{"splits on the first equals sign", "a=b=c", []RoleRule{{"a", "b=c"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseRoleRules(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRoleRules(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapRole(t *testing.T) {
+	rules := []RoleRule{{"exam-admins", "admin"}, {"staff", "instructor"}, {"students", "student"}}
+	tests := []struct {
+		name     string
+		values   []string
+		fallback string
+		want     string
+	}{
+		{"match", []string{"staff"}, "", "instructor"},
+		{"ignores case", []string{"STAFF"}, "", "instructor"},
+		{"first rule wins over value order", []string{"students", "exam-admins"}, "", "admin"},
+		{"no match uses fallback", []string{"guests"}, "student", "student"},
+		{"no match and no fallback", []string{"guests"}, "", ""},
+		{"no values", nil, "student", "student"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MapRole(tt.values, rules, tt.fallback); got != tt.want {
+				t.Errorf("MapRole(%v) = %q, want %q", tt.values, got, tt.want)
+			}
+		})
+	}
+}
@@ -11,9 +11,11 @@ import (
 type Claims struct {
 	jwt.RegisteredClaims
 	Role string `json:"role"`
+	// SessionID is the server-side session the token belongs to.
+	SessionID string `json:"sid"`
 }
 
-func SignToken(userID uuid.UUID, role string, secret string, ttl time.Duration) (string, error) {
+func SignToken(userID uuid.UUID, role string, sessionID uuid.UUID, secret string, ttl time.Duration) (string, error) {
 	now := time.Now()
 	claims := Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
@@ -21,7 +23,8 @@ func SignToken(userID uuid.UUID, role string, secret string, ttl time.Duration)
 			IssuedAt:  jwt.NewNumericDate(now),
 			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
 		},
-		Role: role,
+		Role:      role,
+		SessionID: sessionID.String(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -43,6 +46,43 @@ func ParseToken(tokenString string, secret string) (Claims, error) {
 	if !ok || !parsed.Valid {
 		return Claims{}, errors.New("invalid token")
 	}
+	for _, aud := range claims.Audience {
+		if aud == mfaAudience {
+			return Claims{}, errors.New("invalid token")
+		}
+	}
 
 	return *claims, nil
 }
+
+// mfaAudience marks the short-lived token that carries a login from the
+// password step to the second-factor step. It is not an access token.
+const mfaAudience = "mfa"
+
+// SignMFAToken returns a token showing that userID has passed the password
+// step of a login.
+func SignMFAToken(userID uuid.UUID, secret string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Subject:   userID.String(),
+		Audience:  jwt.ClaimStrings{mfaAudience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+}
+
+// ParseMFAToken returns the user a token from SignMFAToken was issued to.
+func ParseMFAToken(tokenString, secret string) (uuid.UUID, error) {
+	var claims jwt.RegisteredClaims
+	parsed, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (any, error) {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, errors.New("unexpected signing method")
+		}
+		return []byte(secret), nil
+	}, jwt.WithAudience(mfaAudience))
+	if err != nil || !parsed.Valid {
+		return uuid.Nil, errors.New("invalid token")
+	}
+	return uuid.Parse(claims.Subject)
+}
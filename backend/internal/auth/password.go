@@ -1,6 +1,11 @@
 package auth
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/rand"
+	"math/big"
+
+	"golang.org/x/crypto/bcrypt"
+)
 
 func HashPassword(password string) (string, error) {
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -13,3 +18,23 @@ func HashPassword(password string) (string, error) {
 func ComparePassword(hash string, password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }
+
+// temporaryPasswordAlphabet leaves out characters that are easy to misread
+// on a printed handout (0/O, 1/l/I).
+const temporaryPasswordAlphabet = "abcdefghjkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// NewTemporaryPassword returns a random password for a new account. It is
+// shown to the administrator once and must be changed at first login.
+func NewTemporaryPassword() (string, error) {
+	const length = 12
+	max := big.NewInt(int64(len(temporaryPasswordAlphabet)))
+	b := make([]byte, length)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = temporaryPasswordAlphabet[n.Int64()]
+	}
+	return string(b), nil
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+//go:embed common_passwords.txt
+var commonPasswords string
+
+// PasswordPolicy is what a password chosen by a user must satisfy.
+type PasswordPolicy struct {
+	MinLength int
+	// MinClasses is how many of lowercase, uppercase, digits and symbols the
+	// password must mix.
+	MinClasses int
+	// History is how many recent passwords, including the current one, may
+	// not be reused.
+	History int
+	// MaxAge forces a change once a password is this old. Zero disables
+	// expiry.
+	MaxAge time.Duration
+
+	blocked map[string]bool
+}
+
+// NewPasswordPolicy returns a policy that also rejects the built-in list of
+// common passwords and any extra ones given.
+func NewPasswordPolicy(minLength, minClasses, history int, maxAge time.Duration, extraBlocked []string) PasswordPolicy {
+	p := PasswordPolicy{MinLength: minLength, MinClasses: minClasses, History: history, MaxAge: maxAge, blocked: map[string]bool{}}
+	sc := bufio.NewScanner(strings.NewReader(commonPasswords))
+	for sc.Scan() {
+		p.block(sc.Text())
+	}
+	for _, b := range extraBlocked {
+		p.block(b)
+	}
+	return p
+}
+
+func (p PasswordPolicy) block(password string) {
+	password = strings.ToLower(strings.TrimSpace(password))
+	if password != "" && !strings.HasPrefix(password, "#") {
+		p.blocked[password] = true
+	}
+}
+
+// Check returns an error describing why the password is not acceptable for
+// the given user, or nil. Reuse is checked separately, against stored hashes.
+func (p PasswordPolicy) Check(password, username, email string) error {
+	if len([]rune(password)) < p.MinLength {
+		return fmt.Errorf("password must be at least %d characters", p.MinLength)
+	}
+	if n := characterClasses(password); n < p.MinClasses {
+		return fmt.Errorf("password must mix at least %d of lowercase letters, uppercase letters, digits and symbols", p.MinClasses)
+	}
+	lower := strings.ToLower(password)
+	if p.blocked[lower] {
+		return fmt.Errorf("password is too common")
+	}
+	for _, s := range []string{username, strings.SplitN(email, "@", 2)[0]} {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if len(s) >= 3 && strings.Contains(lower, s) {
+			return fmt.Errorf("password must not contain your username or email")
+		}
+	}
+	return nil
+}
+
+// Expired reports whether a password set at setAt has outlived MaxAge.
+func (p PasswordPolicy) Expired(setAt, now time.Time) bool {
+	return p.MaxAge > 0 && now.Sub(setAt) > p.MaxAge
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	n := 0
+	for _, ok := range []bool{lower, upper, digit, symbol} {
+		if ok {
+			n++
+		}
+	}
+	return n
+}
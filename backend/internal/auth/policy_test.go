@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	p := NewPasswordPolicy(10, 3, 5, 0, []string{"Huhems-Exam-2025"})
+
+	tests := []struct {
+		name     string
+		password string
+		username string
+		email    string
+		wantErr  string
+	}{
+		{"acceptable", "Tr1cky-Horse", "abebe", "abebe@example.edu", ""},
+		{"too short", "Sh0rt-pw", "abebe", "abebe@example.edu", "password must be at least 10 characters"},
+		{"length counts characters not bytes", "Ünïcödé-1ab", "abebe", "", ""},
+		{"too few classes", "alllowercase1", "abebe", "", "password must mix at least 3 of lowercase letters, uppercase letters, digits and symbols"},
+		{"symbols count as a class", "lowercase-and-1", "abebe", "", ""},
+		{"built-in blocklist", "Password1!", "abebe", "", "password is too common"},
+		{"blocklist ignores case", "pASSWORD1!", "abebe", "", "password is too common"},
+		{"extra blocklist", "Huhems-Exam-2025", "abebe", "", "password is too common"},
+		{"extra blocklist ignores case", "HUHEMS-EXAM-2025", "abebe", "", "password is too common"},
+		{"contains username", "Xx-Abebe-2025", "abebe", "", "password must not contain your username or email"},
+		{"contains email name", "Xx-Kebede-2025", "abebe", "kebede@example.edu", "password must not contain your username or email"},
+		{"short username ignored", "Tr1cky-Horse-ab", "ab", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.Check(tt.password, tt.username, tt.email)
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tt.wantErr {
+				t.Errorf("Check(%q) = %q, want %q", tt.password, got, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyExpired(t *testing.T) {
+	setAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		maxAge time.Duration
+		now    time.Time
+		want   bool
+	}{
+		{"no expiry", 0, setAt.AddDate(10, 0, 0), false},
+		{"within max age", 90 * 24 * time.Hour, setAt.AddDate(0, 0, 89), false},
+		{"past max age", 90 * 24 * time.Hour, setAt.AddDate(0, 0, 91), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := PasswordPolicy{MaxAge: tt.maxAge}
+			if got := p.Expired(setAt, tt.now); got != tt.want {
+				t.Errorf("Expired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
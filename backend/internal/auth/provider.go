@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidCredentials is returned by a PasswordProvider that does not
+// accept the username and password.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// PasswordProvider checks a username-or-email and password at login.
+// Providers are tried in the configured order until one accepts.
+type PasswordProvider interface {
+	// Name is the provider's name in the AUTH_PROVIDERS setting.
+	Name() string
+	// Authenticate checks the password. localHash is the password hash of
+	// the local account the identifier names, or empty if there is none.
+	// A provider that accepts returns the identity it vouches for, or nil
+	// if the local account itself is the one signing in. Errors other than
+	// ErrInvalidCredentials mean the provider could not be asked.
+	Authenticate(ctx context.Context, identifier, password, localHash string) (*ExternalIdentity, error)
+}
+
+// LocalProvider accepts the password of the user's local account.
+type LocalProvider struct{}
+
+func (LocalProvider) Name() string { return "local" }
+
+func (LocalProvider) Authenticate(_ context.Context, _, password, localHash string) (*ExternalIdentity, error) {
+	if localHash == "" || ComparePassword(localHash, password) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return nil, nil
+}
+
+// AuthenticatePassword asks each provider in turn and returns the answer of
+// the first to accept. If none accepts and one of them failed, that failure
+// is returned rather than ErrInvalidCredentials so an outage is not
+// reported as a wrong password.
+func AuthenticatePassword(ctx context.Context, providers []PasswordProvider, identifier, password, localHash string) (*ExternalIdentity, error) {
+	var failure error
+	for _, p := range providers {
+		id, err := p.Authenticate(ctx, identifier, password, localHash)
+		if err == nil {
+			return id, nil
+		}
+		if !errors.Is(err, ErrInvalidCredentials) && failure == nil {
+			failure = fmt.Errorf("%s: %w", p.Name(), err)
+		}
+	}
+	if failure != nil {
+		return nil, failure
+	}
+	return nil, ErrInvalidCredentials
+}
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
+
+// TokenConfig controls how sessions are signed and how long tokens live.
+type TokenConfig struct {
+	Secret     string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+// NewRefreshToken returns a random refresh token and the hash to store.
+func NewRefreshToken() (token, hash string, err error) {
+	return newOpaqueToken()
+}
+
+// HashRefreshToken returns the stored form of a refresh token.
+func HashRefreshToken(token string) string {
+	return hashOpaqueToken(token)
+}
+
+// NewResetToken returns a random password reset token and the hash to store.
+func NewResetToken() (token, hash string, err error) {
+	return newOpaqueToken()
+}
+
+// HashResetToken returns the stored form of a password reset token.
+func HashResetToken(token string) string {
+	return hashOpaqueToken(token)
+}
+
+func newOpaqueToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashOpaqueToken(token), nil
+}
+
+// hashOpaqueToken hashes a random token for storage. The tokens carry 256
+// bits of entropy, so an unsalted fast hash is enough.
+func hashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
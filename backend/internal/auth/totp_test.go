@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the RFC 6238 SHA-1 test key "12345678901234567890" in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestVerifyTOTP(t *testing.T) {
+	// RFC 6238 appendix B codes, cut to six digits.
+	at59 := time.Unix(59, 0)
+	at1111111109 := time.Unix(1111111109, 0)
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		now      time.Time
+		lastStep int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{"rfc vector", rfcSecret, "287082", at59, 0, 1, true},
+		{"rfc vector later", rfcSecret, "081804", at1111111109, 0, 37037036, true},
+		{"previous step within skew", rfcSecret, "081804", at1111111109.Add(30 * time.Second), 0, 37037036, true},
+		{"next step within skew", rfcSecret, "081804", at1111111109.Add(-30 * time.Second), 0, 37037036, true},
+		{"outside skew", rfcSecret, "081804", at1111111109.Add(90 * time.Second), 0, 0, false},
+		{"replayed step", rfcSecret, "081804", at1111111109, 37037036, 0, false},
+		{"earlier step used", rfcSecret, "081804", at1111111109, 37037035, 37037036, true},
+		{"spaces ignored", rfcSecret, " 081 804 ", at1111111109, 0, 37037036, true},
+		{"lowercase secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "081804", at1111111109, 0, 37037036, true},
+		{"wrong code", rfcSecret, "081805", at1111111109, 0, 0, false},
+		{"too short", rfcSecret, "81804", at1111111109, 0, 0, false},
+		{"too long", rfcSecret, "0081804", at1111111109, 0, 0, false},
+		{"bad secret", "not base32!", "081804", at1111111109, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := VerifyTOTP(tt.secret, tt.code, tt.now, tt.lastStep)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("VerifyTOTP() = (%d, %v), want (%d, %v)", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestSealSecret(t *testing.T) {
+	sealed, err := SealSecret("key", rfcSecret)
+	if err != nil {
+		t.Fatalf("SealSecret: %v", err)
+	}
+	if got, err := OpenSecret("key", sealed); err != nil || got != rfcSecret {
+		t.Errorf("OpenSecret() = (%q, %v), want (%q, nil)", got, err, rfcSecret)
+	}
+	if _, err := OpenSecret("other key", sealed); err == nil {
+		t.Error("OpenSecret with the wrong key succeeded")
+	}
+}
@@ -3,6 +3,9 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -11,15 +14,138 @@ type Config struct {
 	DBURL     string
 	JWTSecret string
 	Port      string
+
+	// AuditSigningKey signs audit chain checkpoints. It must differ from
+	// JWTSecret, so that a leaked token key cannot re-sign the chain.
+	AuditSigningKey string
+	// AuditCheckpointInterval is how often the API signs the audit chain head.
+	AuditCheckpointInterval time.Duration
+
+	// AccessTokenTTL is how long an access token is valid; clients renew it
+	// with their refresh token.
+	AccessTokenTTL time.Duration
+	// RefreshTokenTTL is how long a session may stay idle before its refresh
+	// token expires.
+	RefreshTokenTTL time.Duration
+
+	// RateLimitStore is "memory" (single node) or "postgres" (shared by
+	// replicas).
+	RateLimitStore string
+	// LoginMaxFailures failed logins on one account within LoginLockout lock
+	// it for LoginLockout; LoginIPMaxFailures do the same for one IP.
+	LoginMaxFailures   int
+	LoginIPMaxFailures int
+	LoginLockout       time.Duration
+	// TrustedProxies are the addresses or CIDR ranges, such as the
+	// frontend's, whose X-Forwarded-For is believed for the client IP. With
+	// none, the client IP is always the connecting address.
+	TrustedProxies []string
+
+	// Password policy for passwords users choose themselves.
+	PasswordMinLength  int
+	PasswordMinClasses int
+	// PasswordHistory recent passwords may not be reused; 0 only forbids
+	// keeping the current one.
+	PasswordHistory int
+	// PasswordMaxAge forces a change once a password is this old; 0 never
+	// expires passwords.
+	PasswordMaxAge time.Duration
+	// PasswordBlocklist extends the built-in list of common passwords, read
+	// from PASSWORD_BLOCKLIST_FILE (one per line).
+	PasswordBlocklist []string
+
+	// Mailer is "log" (development: messages go to MailDir or the log) or
+	// "smtp".
+	Mailer   string
+	MailFrom string
+	MailDir  string
+	SMTPHost string
+	SMTPPort int
+	SMTPUser string
+	SMTPPass string
+
+	// PasswordResetURL is the frontend page reset links point to; the token
+	// is appended as ?token=.
+	PasswordResetURL string
+	// PasswordResetTTL is how long a reset link stays valid.
+	PasswordResetTTL time.Duration
+
+	// TOTPIssuer names the service in authenticator apps.
+	TOTPIssuer string
+	// TOTPEncryptionKey encrypts TOTP secrets at rest. It must differ from
+	// JWTSecret, so that a leaked token key does not expose the secrets.
+	TOTPEncryptionKey string
+	// AdminMFARequired makes every admin enroll in two-factor
+	// authentication before using the API.
+	AdminMFARequired bool
+
+	// OIDCIssuer enables single sign-on through an OpenID Connect provider.
+	OIDCIssuer       string
+	OIDCClientID     string
+	OIDCClientSecret string
+	// OIDCRedirectURL is the frontend callback page registered with the
+	// provider.
+	OIDCRedirectURL string
+	OIDCScopes      []string
+	// OIDCLabel names the provider on the login page.
+	OIDCLabel string
+	// Claims holding the user's details, and how the role claim maps to
+	// roles: OIDCRoleMap is "value=role" pairs, and users matching none get
+	// OIDCDefaultRole, or are refused if that is empty.
+	OIDCUsernameClaim   string
+	OIDCEmailClaim      string
+	OIDCNameClaim       string
+	OIDCDepartmentClaim string
+	OIDCYearClaim       string
+	OIDCRoleClaim       string
+	OIDCRoleMap         string
+	OIDCDefaultRole     string
+
+	// PasswordLoginDisabledRoles may not sign in with a local password, for
+	// example students once single sign-on is in place.
+	PasswordLoginDisabledRoles []string
+
+	// AuthProviders are the password checks tried at login, in order:
+	// "local" for the password stored here and "ldap" for the directory.
+	AuthProviders []string
+	// LDAPURL is ldap://host:port or ldaps://host:port. LDAPBindDN and
+	// LDAPBindPassword are the service account used to look users up.
+	LDAPURL                string
+	LDAPStartTLS           bool
+	LDAPInsecureSkipVerify bool
+	LDAPBindDN             string
+	LDAPBindPassword       string
+	LDAPBaseDN             string
+	// LDAPUserFilter finds the user signing in; {identifier} is replaced
+	// with what they typed.
+	LDAPUserFilter string
+	// Attributes holding the user's details, and how their groups map to
+	// roles: groups come from LDAPGroupAttr on the user's entry, or from a
+	// search with LDAPGroupFilter ({dn} and {username} are replaced), and
+	// LDAPRoleMap is "group=role" pairs matched against group names.
+	LDAPIDAttr         string
+	LDAPUsernameAttr   string
+	LDAPEmailAttr      string
+	LDAPNameAttr       string
+	LDAPDepartmentAttr string
+	LDAPYearAttr       string
+	// LDAPTrustEmail lets directory email addresses link existing accounts.
+	LDAPTrustEmail  bool
+	LDAPGroupAttr   string
+	LDAPGroupBaseDN string
+	LDAPGroupFilter string
+	LDAPRoleMap     string
+	LDAPDefaultRole string
 }
 
 func Load() (Config, error) {
 	_ = godotenv.Load()
 
 	cfg := Config{
-		DBURL:     os.Getenv("DB_URL"),
-		JWTSecret: os.Getenv("JWT_SECRET"),
-		Port:      os.Getenv("PORT"),
+		DBURL:           os.Getenv("DB_URL"),
+		JWTSecret:       os.Getenv("JWT_SECRET"),
+		Port:            os.Getenv("PORT"),
+		AuditSigningKey: os.Getenv("AUDIT_SIGNING_KEY"),
 	}
 
 	if cfg.Port == "" {
@@ -31,6 +157,247 @@ func Load() (Config, error) {
 	if cfg.JWTSecret == "" {
 		return Config{}, fmt.Errorf("JWT_SECRET is required")
 	}
+	if cfg.AuditSigningKey == "" {
+		return Config{}, fmt.Errorf("AUDIT_SIGNING_KEY is required")
+	}
+	if cfg.AuditSigningKey == cfg.JWTSecret {
+		return Config{}, fmt.Errorf("AUDIT_SIGNING_KEY must differ from JWT_SECRET")
+	}
+
+	interval, err := durationEnv("AUDIT_CHECKPOINT_INTERVAL", time.Hour)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.AuditCheckpointInterval = interval
+
+	if cfg.AccessTokenTTL, err = durationEnv("ACCESS_TOKEN_TTL", 15*time.Minute); err != nil {
+		return Config{}, err
+	}
+	if cfg.RefreshTokenTTL, err = durationEnv("REFRESH_TOKEN_TTL", 14*24*time.Hour); err != nil {
+		return Config{}, err
+	}
+
+	cfg.RateLimitStore = os.Getenv("RATE_LIMIT_STORE")
+	switch cfg.RateLimitStore {
+	case "":
+		cfg.RateLimitStore = "memory"
+	case "memory", "postgres":
+	default:
+		return Config{}, fmt.Errorf("RATE_LIMIT_STORE must be memory or postgres")
+	}
+	if cfg.LoginMaxFailures, err = intEnv("LOGIN_MAX_FAILURES", 5); err != nil {
+		return Config{}, err
+	}
+	if cfg.LoginIPMaxFailures, err = intEnv("LOGIN_IP_MAX_FAILURES", 50); err != nil {
+		return Config{}, err
+	}
+	if cfg.LoginLockout, err = durationEnv("LOGIN_LOCKOUT", 15*time.Minute); err != nil {
+		return Config{}, err
+	}
+	cfg.TrustedProxies = listEnv("TRUSTED_PROXIES")
+
+	if cfg.PasswordMinLength, err = intEnv("PASSWORD_MIN_LENGTH", 10); err != nil {
+		return Config{}, err
+	}
+	if cfg.PasswordMinClasses, err = intEnv("PASSWORD_MIN_CLASSES", 3); err != nil {
+		return Config{}, err
+	}
+	if cfg.PasswordMinClasses > 4 {
+		return Config{}, fmt.Errorf("PASSWORD_MIN_CLASSES must be between 1 and 4")
+	}
+	if cfg.PasswordHistory, err = countEnv("PASSWORD_HISTORY", 5); err != nil {
+		return Config{}, err
+	}
+	if os.Getenv("PASSWORD_MAX_AGE") != "" {
+		if cfg.PasswordMaxAge, err = durationEnv("PASSWORD_MAX_AGE", 0); err != nil {
+			return Config{}, err
+		}
+	}
+	if path := os.Getenv("PASSWORD_BLOCKLIST_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("PASSWORD_BLOCKLIST_FILE: %w", err)
+		}
+		cfg.PasswordBlocklist = strings.Split(string(data), "\n")
+	}
+
+	cfg.Mailer = os.Getenv("MAILER")
+	switch cfg.Mailer {
+	case "":
+		cfg.Mailer = "log"
+	case "log", "smtp":
+	default:
+		return Config{}, fmt.Errorf("MAILER must be log or smtp")
+	}
+	cfg.MailFrom = os.Getenv("MAIL_FROM")
+	if cfg.MailFrom == "" {
+		cfg.MailFrom = "HUHEMS <no-reply@huhems.local>"
+	}
+	cfg.MailDir = os.Getenv("MAIL_DIR")
+	cfg.SMTPHost = os.Getenv("SMTP_HOST")
+	cfg.SMTPUser = os.Getenv("SMTP_USERNAME")
+	cfg.SMTPPass = os.Getenv("SMTP_PASSWORD")
+	if cfg.SMTPPort, err = intEnv("SMTP_PORT", 587); err != nil {
+		return Config{}, err
+	}
+	if cfg.Mailer == "smtp" && cfg.SMTPHost == "" {
+		return Config{}, fmt.Errorf("SMTP_HOST is required when MAILER=smtp")
+	}
+
+	cfg.PasswordResetURL = os.Getenv("PASSWORD_RESET_URL")
+	if cfg.PasswordResetURL == "" {
+		cfg.PasswordResetURL = "http://localhost:3000/auth/reset-password"
+	}
+	if cfg.PasswordResetTTL, err = durationEnv("PASSWORD_RESET_TTL", time.Hour); err != nil {
+		return Config{}, err
+	}
+
+	cfg.TOTPIssuer = os.Getenv("TOTP_ISSUER")
+	if cfg.TOTPIssuer == "" {
+		cfg.TOTPIssuer = "HUHEMS"
+	}
+	cfg.TOTPEncryptionKey = os.Getenv("TOTP_ENCRYPTION_KEY")
+	if cfg.TOTPEncryptionKey == "" {
+		return Config{}, fmt.Errorf("TOTP_ENCRYPTION_KEY is required")
+	}
+	if cfg.TOTPEncryptionKey == cfg.JWTSecret {
+		return Config{}, fmt.Errorf("TOTP_ENCRYPTION_KEY must differ from JWT_SECRET")
+	}
+	if v := os.Getenv("ADMIN_2FA_REQUIRED"); v != "" {
+		if cfg.AdminMFARequired, err = strconv.ParseBool(v); err != nil {
+			return Config{}, fmt.Errorf("ADMIN_2FA_REQUIRED must be true or false")
+		}
+	}
+
+	cfg.OIDCIssuer = os.Getenv("OIDC_ISSUER")
+	cfg.OIDCClientID = os.Getenv("OIDC_CLIENT_ID")
+	cfg.OIDCClientSecret = os.Getenv("OIDC_CLIENT_SECRET")
+	if cfg.OIDCIssuer != "" && cfg.OIDCClientID == "" {
+		return Config{}, fmt.Errorf("OIDC_CLIENT_ID is required when OIDC_ISSUER is set")
+	}
+	cfg.OIDCRedirectURL = stringEnv("OIDC_REDIRECT_URL", "http://localhost:3000/auth/oidc/callback")
+	cfg.OIDCScopes = strings.Fields(stringEnv("OIDC_SCOPES", "openid profile email"))
+	cfg.OIDCLabel = stringEnv("OIDC_LABEL", "University account")
+	cfg.OIDCUsernameClaim = stringEnv("OIDC_USERNAME_CLAIM", "preferred_username")
+	cfg.OIDCEmailClaim = stringEnv("OIDC_EMAIL_CLAIM", "email")
+	cfg.OIDCNameClaim = stringEnv("OIDC_NAME_CLAIM", "name")
+	cfg.OIDCDepartmentClaim = os.Getenv("OIDC_DEPARTMENT_CLAIM")
+	cfg.OIDCYearClaim = os.Getenv("OIDC_YEAR_CLAIM")
+	cfg.OIDCRoleClaim = stringEnv("OIDC_ROLE_CLAIM", "groups")
+	cfg.OIDCRoleMap = os.Getenv("OIDC_ROLE_MAP")
+	cfg.OIDCDefaultRole = os.Getenv("OIDC_DEFAULT_ROLE")
+
+	cfg.PasswordLoginDisabledRoles = listEnv("PASSWORD_LOGIN_DISABLED_ROLES")
+
+	cfg.AuthProviders = listEnv("AUTH_PROVIDERS")
+	if len(cfg.AuthProviders) == 0 {
+		cfg.AuthProviders = []string{"local"}
+	}
+	ldapEnabled := false
+	for _, p := range cfg.AuthProviders {
+		switch p {
+		case "local":
+		case "ldap":
+			ldapEnabled = true
+		default:
+			return Config{}, fmt.Errorf("AUTH_PROVIDERS: unknown provider %q", p)
+		}
+	}
+	cfg.LDAPURL = os.Getenv("LDAP_URL")
+	cfg.LDAPBaseDN = os.Getenv("LDAP_BASE_DN")
+	if ldapEnabled && (cfg.LDAPURL == "" || cfg.LDAPBaseDN == "") {
+		return Config{}, fmt.Errorf("LDAP_URL and LDAP_BASE_DN are required when AUTH_PROVIDERS includes ldap")
+	}
+	if cfg.LDAPStartTLS, err = boolEnv("LDAP_START_TLS"); err != nil {
+		return Config{}, err
+	}
+	if cfg.LDAPInsecureSkipVerify, err = boolEnv("LDAP_INSECURE_SKIP_VERIFY"); err != nil {
+		return Config{}, err
+	}
+	cfg.LDAPBindDN = os.Getenv("LDAP_BIND_DN")
+	cfg.LDAPBindPassword = os.Getenv("LDAP_BIND_PASSWORD")
+	cfg.LDAPUserFilter = stringEnv("LDAP_USER_FILTER", "(&(objectClass=inetOrgPerson)(|(uid={identifier})(mail={identifier})))")
+	cfg.LDAPIDAttr = stringEnv("LDAP_ID_ATTR", "entryUUID")
+	cfg.LDAPUsernameAttr = stringEnv("LDAP_USERNAME_ATTR", "uid")
+	cfg.LDAPEmailAttr = stringEnv("LDAP_EMAIL_ATTR", "mail")
+	cfg.LDAPNameAttr = stringEnv("LDAP_NAME_ATTR", "cn")
+	cfg.LDAPDepartmentAttr = os.Getenv("LDAP_DEPARTMENT_ATTR")
+	cfg.LDAPYearAttr = os.Getenv("LDAP_YEAR_ATTR")
+	if cfg.LDAPTrustEmail, err = boolEnv("LDAP_TRUST_EMAIL"); err != nil {
+		return Config{}, err
+	}
+	cfg.LDAPGroupAttr = stringEnv("LDAP_GROUP_ATTR", "memberOf")
+	cfg.LDAPGroupBaseDN = os.Getenv("LDAP_GROUP_BASE_DN")
+	cfg.LDAPGroupFilter = os.Getenv("LDAP_GROUP_FILTER")
+	cfg.LDAPRoleMap = os.Getenv("LDAP_ROLE_MAP")
+	cfg.LDAPDefaultRole = os.Getenv("LDAP_DEFAULT_ROLE")
 
 	return cfg, nil
 }
+
+func stringEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// listEnv reads a comma-separated list.
+func listEnv(key string) []string {
+	var out []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func boolEnv(key string) (bool, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false", key)
+	}
+	return b, nil
+}
+
+func intEnv(key string, fallback int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%s must be a positive integer", key)
+	}
+	return n, nil
+}
+
+// countEnv is like intEnv but also accepts zero.
+func countEnv(key string, fallback int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer", key)
+	}
+	return n, nil
+}
+
+func durationEnv(key string, fallback time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s must be a positive duration (e.g. 30m, 1h)", key)
+	}
+	return d, nil
+}
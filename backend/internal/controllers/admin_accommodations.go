@@ -0,0 +1,329 @@
+package controllers
+
+import (
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/letera1/huhems-exam-system/backend/internal/audit"
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxAccommodationMultiplier    = 4.0
+	maxAccommodationExtraMinutes  = 24 * 60
+	maxAccommodationExtraAttempts = 20
+)
+
+// effectiveAccommodation returns the student's accommodation for an exam: the
+// per-exam override if there is one, otherwise the student's default. It
+// returns nil if neither exists.
+func effectiveAccommodation(db *gorm.DB, studentID, examID uuid.UUID) (*models.Accommodation, error) {
+	var rows []models.Accommodation
+	if err := db.Where("student_id = ? AND (exam_id = ? OR exam_id IS NULL)", studentID, examID).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	var def *models.Accommodation
+	for i := range rows {
+		if rows[i].ExamID != nil {
+			return &rows[i], nil
+		}
+		def = &rows[i]
+	}
+	return def, nil
+}
+
+// accommodationMinutes is the extra time an accommodation grants on an exam of
+// the given duration, rounded up to whole minutes.
+func accommodationMinutes(acc *models.Accommodation, durationMinutes int) int {
+	if acc == nil || durationMinutes <= 0 {
+		return 0
+	}
+	extra := acc.ExtraMinutes
+	if acc.TimeMultiplier > 1 {
+		extra += int(math.Ceil(float64(durationMinutes) * (acc.TimeMultiplier - 1)))
+	}
+	return extra
+}
+
+// examAvailability returns the window in which the student may start the
+// exam. Bounds set on the accommodation replace the exam's.
+func examAvailability(exam models.Exam, acc *models.Accommodation) (from, until *time.Time) {
+	from, until = exam.StartTime, exam.EndTime
+	if acc != nil {
+		if acc.AvailableFrom != nil {
+			from = acc.AvailableFrom
+		}
+		if acc.AvailableUntil != nil {
+			until = acc.AvailableUntil
+		}
+	}
+	return from, until
+}
+
+// effectiveMaxAttempts returns the attempt limit including extra attempts, or
+// 0 for unlimited.
+func effectiveMaxAttempts(exam models.Exam, acc *models.Accommodation) int {
+	if exam.MaxAttempts <= 0 {
+		return 0
+	}
+	if acc == nil {
+		return exam.MaxAttempts
+	}
+	return exam.MaxAttempts + acc.ExtraAttempts
+}
+
+type adminAccommodationView struct {
+	ID             uuid.UUID  `json:"id"`
+	StudentID      uuid.UUID  `json:"studentId"`
+	ExamID         *uuid.UUID `json:"examId"`
+	TimeMultiplier float64    `json:"timeMultiplier"`
+	ExtraMinutes   int        `json:"extraMinutes"`
+	ExtraAttempts  int        `json:"extraAttempts"`
+	AvailableFrom  *time.Time `json:"availableFrom"`
+	AvailableUntil *time.Time `json:"availableUntil"`
+	Notes          string     `json:"notes"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
+
+func toAccommodationView(a models.Accommodation) adminAccommodationView {
+	return adminAccommodationView{
+		ID:             a.ID,
+		StudentID:      a.StudentID,
+		ExamID:         a.ExamID,
+		TimeMultiplier: a.TimeMultiplier,
+		ExtraMinutes:   a.ExtraMinutes,
+		ExtraAttempts:  a.ExtraAttempts,
+		AvailableFrom:  a.AvailableFrom,
+		AvailableUntil: a.AvailableUntil,
+		Notes:          a.Notes,
+		UpdatedAt:      a.UpdatedAt,
+	}
+}
+
+type adminAccommodationRequest struct {
+	TimeMultiplier float64    `json:"timeMultiplier"`
+	ExtraMinutes   int        `json:"extraMinutes"`
+	ExtraAttempts  int        `json:"extraAttempts"`
+	AvailableFrom  *time.Time `json:"availableFrom"`
+	AvailableUntil *time.Time `json:"availableUntil"`
+	Notes          string     `json:"notes"`
+}
+
+func (r *adminAccommodationRequest) validate() error {
+	if r.TimeMultiplier == 0 {
+		r.TimeMultiplier = 1
+	}
+	if r.TimeMultiplier < 1 || r.TimeMultiplier > maxAccommodationMultiplier {
+		return errInvalid("timeMultiplier must be between 1 and 4")
+	}
+	if r.ExtraMinutes < 0 || r.ExtraMinutes > maxAccommodationExtraMinutes {
+		return errInvalid("extraMinutes must be between 0 and 1440")
+	}
+	if r.ExtraAttempts < 0 || r.ExtraAttempts > maxAccommodationExtraAttempts {
+		return errInvalid("extraAttempts must be between 0 and 20")
+	}
+	if r.AvailableFrom != nil && r.AvailableUntil != nil && !r.AvailableUntil.After(*r.AvailableFrom) {
+		return errInvalid("availableUntil must be after availableFrom")
+	}
+	r.Notes = strings.TrimSpace(r.Notes)
+	return nil
+}
+
+// AdminStudentAccommodationsList returns a student's default accommodation
+// and per-exam overrides.
+func AdminStudentAccommodationsList(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		studentID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid student id"})
+			return
+		}
+
+		var rows []models.Accommodation
+		if err := db.Where("student_id = ?", studentID).Order("exam_id asc nulls first").Find(&rows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load accommodations"})
+			return
+		}
+
+		resp := make([]adminAccommodationView, 0, len(rows))
+		for _, a := range rows {
+			resp = append(resp, toAccommodationView(a))
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// accommodationScope parses the student id and the optional examId route
+// parameter. A nil exam id addresses the student's default.
+func accommodationScope(c *gin.Context, db *gorm.DB) (uuid.UUID, *uuid.UUID, bool) {
+	studentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid student id"})
+		return uuid.Nil, nil, false
+	}
+	var student models.Student
+	if err := db.Select("id").First(&student, "id = ?", studentID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "student not found"})
+			return uuid.Nil, nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load student"})
+		return uuid.Nil, nil, false
+	}
+
+	v := c.Param("examId")
+	if v == "" {
+		return studentID, nil, true
+	}
+	examID, err := uuid.Parse(v)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid exam id"})
+		return uuid.Nil, nil, false
+	}
+	var exam models.Exam
+	if err := db.Select("id").First(&exam, "id = ?", examID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "exam not found"})
+			return uuid.Nil, nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load exam"})
+		return uuid.Nil, nil, false
+	}
+	return studentID, &examID, true
+}
+
+// accommodationUpsert updates the existing accommodation on conflict with the
+// unique index for the scope: the student's default or one exam.
+func accommodationUpsert(examID *uuid.UUID) clause.OnConflict {
+	columns := []clause.Column{{Name: "student_id"}}
+	where := "exam_id IS NULL AND deleted_at IS NULL"
+	if examID != nil {
+		columns = append(columns, clause.Column{Name: "exam_id"})
+		where = "deleted_at IS NULL"
+	}
+	return clause.OnConflict{
+		Columns:     columns,
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: where}}},
+		DoUpdates:   clause.AssignmentColumns([]string{"time_multiplier", "extra_minutes", "extra_attempts", "available_from", "available_until", "notes", "updated_at"}),
+	}
+}
+
+func findAccommodation(db *gorm.DB, studentID uuid.UUID, examID *uuid.UUID) ([]models.Accommodation, error) {
+	q := db.Where("student_id = ?", studentID)
+	if examID == nil {
+		q = q.Where("exam_id IS NULL")
+	} else {
+		q = q.Where("exam_id = ?", *examID)
+	}
+	var rows []models.Accommodation
+	err := q.Limit(1).Find(&rows).Error
+	return rows, err
+}
+
+// AdminStudentAccommodationPut creates or replaces the student's default
+// accommodation, or the override for one exam when the route has :examId.
+// Changes apply to attempts started afterwards.
+func AdminStudentAccommodationPut(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		studentID, examID, ok := accommodationScope(c, db)
+		if !ok {
+			return
+		}
+
+		var req adminAccommodationRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
+			return
+		}
+		if err := req.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		acc := models.Accommodation{
+			StudentID:      studentID,
+			ExamID:         examID,
+			TimeMultiplier: req.TimeMultiplier,
+			ExtraMinutes:   req.ExtraMinutes,
+			ExtraAttempts:  req.ExtraAttempts,
+			AvailableFrom:  req.AvailableFrom,
+			AvailableUntil: req.AvailableUntil,
+			Notes:          req.Notes,
+		}
+
+		var view adminAccommodationView
+		err := db.Transaction(func(tx *gorm.DB) error {
+			existing, err := findAccommodation(tx, studentID, examID)
+			if err != nil {
+				return err
+			}
+			var before any
+			if len(existing) > 0 {
+				before = toAccommodationView(existing[0])
+			}
+			// Concurrent puts for the same scope update one row instead of
+			// creating two.
+			if err := tx.Clauses(accommodationUpsert(examID)).Create(&acc).Error; err != nil {
+				return err
+			}
+			view = toAccommodationView(acc)
+			return audit.Record(tx, c, audit.Entry{
+				Action:     audit.ActionAccommodationSet,
+				EntityType: audit.EntityStudent,
+				EntityID:   studentID,
+				Before:     before,
+				After:      view,
+			})
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to save accommodation"})
+			return
+		}
+
+		c.JSON(http.StatusOK, view)
+	}
+}
+
+// AdminStudentAccommodationDelete removes the student's default
+// accommodation, or the override for one exam when the route has :examId.
+func AdminStudentAccommodationDelete(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		studentID, examID, ok := accommodationScope(c, db)
+		if !ok {
+			return
+		}
+
+		existing, err := findAccommodation(db, studentID, examID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load accommodation"})
+			return
+		}
+		if len(existing) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"message": "accommodation not found"})
+			return
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Delete(&existing[0]).Error; err != nil {
+				return err
+			}
+			return audit.Record(tx, c, audit.Entry{
+				Action:     audit.ActionAccommodationDelete,
+				EntityType: audit.EntityStudent,
+				EntityID:   studentID,
+				Before:     toAccommodationView(existing[0]),
+			})
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to delete accommodation"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	}
+}
@@ -0,0 +1,558 @@
+package controllers
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// A student is considered "falling behind" when their average percentile rank
+// or most recent percentile rank drops below this value, or when their score
+// trend declines faster than fallingBehindSlopePerWeek.
+const (
+	fallingBehindPercentile   = 25.0
+	fallingBehindSlopePerWeek = -2.0
+)
+
+type analyticsAttemptRow struct {
+	AttemptID  uuid.UUID
+	StudentID  uuid.UUID
+	FullName   string
+	Department string
+	Year       int
+	ExamID     uuid.UUID
+	ExamTitle  string
+	CourseID   *uuid.UUID
+	Tags       pq.StringArray `gorm:"type:text[]"`
+	Score      float64
+	StartTime  time.Time
+	EndTime    *time.Time
+
+	// Percentile is the attempt's percentile rank on its exam; see
+	// analyticsPercentileSQL.
+	Percentile float64
+}
+
+func (r analyticsAttemptRow) takenAt() time.Time {
+	if r.EndTime != nil {
+		return *r.EndTime
+	}
+	return r.StartTime
+}
+
+type analyticsFilter struct {
+	department string
+	year       int
+	examID     uuid.UUID
+	tag        string
+	groupID    uuid.UUID
+	// studentID limits the attempts to one student.
+	studentID uuid.UUID
+	// courses limits callers without course:all to the exams of their
+	// courses; nil when every course is visible.
+	courses map[uuid.UUID]bool
+	from    *time.Time
+	to      *time.Time
+}
+
+func parseAnalyticsFilter(c *gin.Context, db *gorm.DB) (analyticsFilter, bool) {
+	f := analyticsFilter{
+		department: strings.TrimSpace(c.Query("department")),
+		tag:        strings.ToLower(strings.TrimSpace(c.Query("tag"))),
+	}
+
+	if v := strings.TrimSpace(c.Query("year")); v != "" {
+		year, err := strconv.Atoi(v)
+		if err != nil || year <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid year"})
+			return analyticsFilter{}, false
+		}
+		f.year = year
+	}
+	if v := strings.TrimSpace(c.Query("examId")); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid examId"})
+			return analyticsFilter{}, false
+		}
+		f.examID = id
+	}
+	if v := strings.TrimSpace(c.Query("groupId")); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid groupId"})
+			return analyticsFilter{}, false
+		}
+		f.groupID = id
+	}
+	for _, p := range []struct {
+		key string
+		dst **time.Time
+	}{{"from", &f.from}, {"to", &f.to}} {
+		v := strings.TrimSpace(c.Query(p.key))
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			if t, err = time.Parse("2006-01-02", v); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "invalid " + p.key + " (use RFC3339 or YYYY-MM-DD)"})
+				return analyticsFilter{}, false
+			}
+		}
+		*p.dst = &t
+	}
+
+	if !seesAllCourses(c) {
+		ids, err := taughtCourseIDs(db, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load courses"})
+			return analyticsFilter{}, false
+		}
+		f.courses = make(map[uuid.UUID]bool, len(ids))
+		for _, id := range ids {
+			f.courses[id] = true
+		}
+	}
+
+	return f, true
+}
+
+// analyticsPercentileSQL ranks an attempt against the best submitted score
+// of every student who sat the same exam, so students who retook an exam
+// count once. Ties count as half.
+const analyticsPercentileSQL = `LEFT JOIN LATERAL (
+	SELECT COUNT(*) FILTER (WHERE b.score < exam_attempts.score) AS below,
+		COUNT(*) FILTER (WHERE b.score = exam_attempts.score) AS equal,
+		COUNT(*) AS total
+	FROM (
+		SELECT MAX(a.score) AS score
+		FROM exam_attempts a
+		JOIN students s ON s.id = a.student_id AND s.deleted_at IS NULL
+		WHERE a.exam_id = exam_attempts.exam_id AND a.submitted = true AND a.invalidated = false AND a.deleted_at IS NULL
+		GROUP BY a.student_id
+	) b
+) pr ON true`
+
+// loadAnalyticsAttempts loads the submitted attempts matching the filter,
+// oldest first. Percentile ranks are computed in the database against
+// everyone who sat the same exam, not just the filtered students.
+func loadAnalyticsAttempts(db *gorm.DB, f analyticsFilter) ([]analyticsAttemptRow, error) {
+	q := db.Model(&models.ExamAttempt{}).
+		Select(
+			"exam_attempts.id as attempt_id, exam_attempts.student_id as student_id, students.full_name as full_name, students.department as department, students.year as year, exam_attempts.exam_id as exam_id, exams.title as exam_title, exams.course_id as course_id, exams.tags as tags, exam_attempts.score as score, exam_attempts.start_time as start_time, exam_attempts.end_time as end_time, " +
+				"CASE WHEN pr.total = 0 THEN 0 ELSE (pr.below + 0.5 * pr.equal) * 100.0 / pr.total END as percentile",
+		).
+		Joins("JOIN students ON students.id = exam_attempts.student_id AND students.deleted_at IS NULL").
+		Joins("JOIN exams ON exams.id = exam_attempts.exam_id AND exams.deleted_at IS NULL").
+		Joins(analyticsPercentileSQL).
+		Where("exam_attempts.submitted = true AND exam_attempts.invalidated = false")
+
+	if f.studentID != uuid.Nil {
+		q = q.Where("exam_attempts.student_id = ?", f.studentID)
+	}
+	if f.department != "" {
+		q = q.Where("LOWER(students.department) = LOWER(?)", f.department)
+	}
+	if f.year > 0 {
+		q = q.Where("students.year = ?", f.year)
+	}
+	if f.examID != uuid.Nil {
+		q = q.Where("exam_attempts.exam_id = ?", f.examID)
+	}
+	if f.groupID != uuid.Nil {
+		q = q.Where("exam_attempts.student_id IN (SELECT student_id FROM student_group_members WHERE group_id = ? AND deleted_at IS NULL)", f.groupID)
+	}
+	if f.courses != nil {
+		ids := make([]uuid.UUID, 0, len(f.courses))
+		for id := range f.courses {
+			ids = append(ids, id)
+		}
+		if len(ids) == 0 {
+			return []analyticsAttemptRow{}, nil
+		}
+		q = q.Where("exams.course_id IN ?", ids)
+	}
+	if f.tag != "" {
+		q = q.Where("? = ANY(exams.tags)", f.tag)
+	}
+	if f.from != nil {
+		q = q.Where("COALESCE(exam_attempts.end_time, exam_attempts.start_time) >= ?", *f.from)
+	}
+	if f.to != nil {
+		q = q.Where("COALESCE(exam_attempts.end_time, exam_attempts.start_time) <= ?", *f.to)
+	}
+
+	var rows []analyticsAttemptRow
+	if err := q.Order("COALESCE(exam_attempts.end_time, exam_attempts.start_time) ASC").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+type analyticsTrend struct {
+	// SlopePerWeek is the least-squares change in score per week.
+	SlopePerWeek float64 `json:"slopePerWeek"`
+	// Intercept is the fitted score at the first data point.
+	Intercept float64 `json:"intercept"`
+	// Start and End are the fitted scores at the first and last data points,
+	// convenient for drawing the trend line.
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// linearTrend fits score = intercept + slope*weeks over the given rows, which
+// must be sorted by time. It returns nil when fewer than two distinct points
+// in time are available.
+func linearTrend(rows []analyticsAttemptRow) *analyticsTrend {
+	if len(rows) < 2 {
+		return nil
+	}
+	origin := rows[0].takenAt()
+	n := float64(len(rows))
+	var sumX, sumY, sumXY, sumXX float64
+	for _, r := range rows {
+		x := r.takenAt().Sub(origin).Hours() / (24 * 7)
+		sumX += x
+		sumY += r.Score
+		sumXY += x * r.Score
+		sumXX += x * x
+	}
+	denom := n*sumXX - sumX*sumX
+	if math.Abs(denom) < 1e-9 {
+		return nil
+	}
+	slope := (n*sumXY - sumX*sumY) / denom
+	intercept := (sumY - slope*sumX) / n
+	lastX := rows[len(rows)-1].takenAt().Sub(origin).Hours() / (24 * 7)
+	return &analyticsTrend{
+		SlopePerWeek: slope,
+		Intercept:    intercept,
+		Start:        intercept,
+		End:          intercept + slope*lastX,
+	}
+}
+
+func truncateToInterval(t time.Time, interval string) time.Time {
+	t = t.UTC()
+	switch interval {
+	case "day":
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		// ISO weeks start on Monday.
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	}
+}
+
+type analyticsSeriesPoint struct {
+	PeriodStart       time.Time `json:"periodStart"`
+	Attempts          int       `json:"attempts"`
+	AverageScore      float64   `json:"averageScore"`
+	AveragePercentile float64   `json:"averagePercentile"`
+}
+
+type analyticsSeries struct {
+	Key               string                 `json:"key"`
+	Label             string                 `json:"label"`
+	Attempts          int                    `json:"attempts"`
+	Students          int                    `json:"students"`
+	AverageScore      float64                `json:"averageScore"`
+	AveragePercentile float64                `json:"averagePercentile"`
+	Trend             *analyticsTrend        `json:"trend"`
+	Points            []analyticsSeriesPoint `json:"points"`
+}
+
+type analyticsProgressResponse struct {
+	GroupBy  string            `json:"groupBy"`
+	Interval string            `json:"interval"`
+	Series   []analyticsSeries `json:"series"`
+}
+
+func buildAnalyticsSeries(key, label string, rows []analyticsAttemptRow, interval string) analyticsSeries {
+	s := analyticsSeries{Key: key, Label: label, Attempts: len(rows), Points: []analyticsSeriesPoint{}}
+
+	students := map[uuid.UUID]struct{}{}
+	type bucket struct {
+		n              int
+		sumScore, sumP float64
+	}
+	buckets := map[time.Time]*bucket{}
+	var sumScore, sumP float64
+	for _, r := range rows {
+		students[r.StudentID] = struct{}{}
+		sumScore += r.Score
+		sumP += r.Percentile
+
+		period := truncateToInterval(r.takenAt(), interval)
+		b := buckets[period]
+		if b == nil {
+			b = &bucket{}
+			buckets[period] = b
+		}
+		b.n++
+		b.sumScore += r.Score
+		b.sumP += r.Percentile
+	}
+	s.Students = len(students)
+	if len(rows) > 0 {
+		s.AverageScore = sumScore / float64(len(rows))
+		s.AveragePercentile = sumP / float64(len(rows))
+	}
+
+	for period, b := range buckets {
+		s.Points = append(s.Points, analyticsSeriesPoint{
+			PeriodStart:       period,
+			Attempts:          b.n,
+			AverageScore:      b.sumScore / float64(b.n),
+			AveragePercentile: b.sumP / float64(b.n),
+		})
+	}
+	sort.Slice(s.Points, func(i, j int) bool { return s.Points[i].PeriodStart.Before(s.Points[j].PeriodStart) })
+
+	s.Trend = linearTrend(rows)
+	return s
+}
+
+// AdminAnalyticsProgress aggregates submitted attempt scores over time.
+//
+// Query parameters:
+//
+//	groupBy   department | year | exam | tag (default department)
+//	interval  day | week | month (default week)
+//	department, year, examId, tag, from, to  optional filters
+func AdminAnalyticsProgress(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupBy := strings.ToLower(strings.TrimSpace(c.DefaultQuery("groupBy", "department")))
+		switch groupBy {
+		case "department", "year", "exam", "tag":
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"message": "groupBy must be one of department, year, exam, tag"})
+			return
+		}
+		interval := strings.ToLower(strings.TrimSpace(c.DefaultQuery("interval", "week")))
+		switch interval {
+		case "day", "week", "month":
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"message": "interval must be one of day, week, month"})
+			return
+		}
+
+		f, ok := parseAnalyticsFilter(c, db)
+		if !ok {
+			return
+		}
+
+		rows, err := loadAnalyticsAttempts(db, f)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load attempts"})
+			return
+		}
+
+		grouped := map[string][]analyticsAttemptRow{}
+		labels := map[string]string{}
+		for _, r := range rows {
+			switch groupBy {
+			case "department":
+				grouped[r.Department] = append(grouped[r.Department], r)
+				labels[r.Department] = r.Department
+			case "year":
+				k := strconv.Itoa(r.Year)
+				grouped[k] = append(grouped[k], r)
+				labels[k] = "Year " + k
+			case "exam":
+				k := r.ExamID.String()
+				grouped[k] = append(grouped[k], r)
+				labels[k] = r.ExamTitle
+			case "tag":
+				for _, t := range r.Tags {
+					if f.tag != "" && t != f.tag {
+						continue
+					}
+					grouped[t] = append(grouped[t], r)
+					labels[t] = t
+				}
+			}
+		}
+
+		resp := analyticsProgressResponse{GroupBy: groupBy, Interval: interval, Series: make([]analyticsSeries, 0, len(grouped))}
+		for k, rs := range grouped {
+			resp.Series = append(resp.Series, buildAnalyticsSeries(k, labels[k], rs, interval))
+		}
+		sort.Slice(resp.Series, func(i, j int) bool { return resp.Series[i].Label < resp.Series[j].Label })
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+type studentProgressAttempt struct {
+	AttemptID  uuid.UUID `json:"attemptId"`
+	ExamID     uuid.UUID `json:"examId"`
+	ExamTitle  string    `json:"examTitle"`
+	Tags       []string  `json:"tags"`
+	Score      float64   `json:"score"`
+	Percentile float64   `json:"percentile"`
+	TakenAt    time.Time `json:"takenAt"`
+}
+
+type studentProgress struct {
+	StudentID         uuid.UUID                `json:"studentId"`
+	FullName          string                   `json:"fullName"`
+	Department        string                   `json:"department"`
+	Year              int                      `json:"year"`
+	ExamsTaken        int                      `json:"examsTaken"`
+	AverageScore      float64                  `json:"averageScore"`
+	AveragePercentile float64                  `json:"averagePercentile"`
+	LatestPercentile  float64                  `json:"latestPercentile"`
+	Trend             *analyticsTrend          `json:"trend"`
+	FallingBehind     bool                     `json:"fallingBehind"`
+	Reasons           []string                 `json:"reasons"`
+	Attempts          []studentProgressAttempt `json:"attempts,omitempty"`
+}
+
+func buildStudentProgress(rows []analyticsAttemptRow, withAttempts bool) studentProgress {
+	first := rows[0]
+	p := studentProgress{
+		StudentID:  first.StudentID,
+		FullName:   first.FullName,
+		Department: first.Department,
+		Year:       first.Year,
+		Reasons:    []string{},
+	}
+
+	exams := map[uuid.UUID]struct{}{}
+	var sumScore, sumP float64
+	for _, r := range rows {
+		exams[r.ExamID] = struct{}{}
+		sumScore += r.Score
+		sumP += r.Percentile
+		if withAttempts {
+			p.Attempts = append(p.Attempts, studentProgressAttempt{
+				AttemptID:  r.AttemptID,
+				ExamID:     r.ExamID,
+				ExamTitle:  r.ExamTitle,
+				Tags:       []string(r.Tags),
+				Score:      r.Score,
+				Percentile: r.Percentile,
+				TakenAt:    r.takenAt(),
+			})
+		}
+	}
+	p.ExamsTaken = len(exams)
+	p.AverageScore = sumScore / float64(len(rows))
+	p.AveragePercentile = sumP / float64(len(rows))
+	p.LatestPercentile = rows[len(rows)-1].Percentile
+	p.Trend = linearTrend(rows)
+
+	if p.AveragePercentile < fallingBehindPercentile {
+		p.Reasons = append(p.Reasons, "average percentile below 25")
+	}
+	if len(rows) > 1 && p.LatestPercentile < fallingBehindPercentile {
+		p.Reasons = append(p.Reasons, "latest percentile below 25")
+	}
+	if p.Trend != nil && p.Trend.SlopePerWeek < fallingBehindSlopePerWeek {
+		p.Reasons = append(p.Reasons, "declining score trend")
+	}
+	p.FallingBehind = len(p.Reasons) > 0
+	return p
+}
+
+// AdminAnalyticsStudents returns per-student longitudinal progress, with
+// students who appear to be falling behind listed first. Pass
+// fallingBehind=true to list only those students.
+func AdminAnalyticsStudents(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		f, ok := parseAnalyticsFilter(c, db)
+		if !ok {
+			return
+		}
+		onlyFallingBehind := c.Query("fallingBehind") == "true"
+
+		rows, err := loadAnalyticsAttempts(db, f)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load attempts"})
+			return
+		}
+
+		byStudent := map[uuid.UUID][]analyticsAttemptRow{}
+		for _, r := range rows {
+			byStudent[r.StudentID] = append(byStudent[r.StudentID], r)
+		}
+
+		resp := make([]studentProgress, 0, len(byStudent))
+		for _, rs := range byStudent {
+			p := buildStudentProgress(rs, false)
+			if onlyFallingBehind && !p.FallingBehind {
+				continue
+			}
+			resp = append(resp, p)
+		}
+		sort.Slice(resp, func(i, j int) bool {
+			if resp[i].FallingBehind != resp[j].FallingBehind {
+				return resp[i].FallingBehind
+			}
+			return resp[i].AveragePercentile < resp[j].AveragePercentile
+		})
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// AdminAnalyticsStudent returns the full attempt history of a single student
+// along with trend and percentile information.
+func AdminAnalyticsStudent(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		studentID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid student id"})
+			return
+		}
+
+		f, ok := parseAnalyticsFilter(c, db)
+		if !ok {
+			return
+		}
+
+		var student models.Student
+		if err := db.First(&student, "id = ?", studentID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"message": "student not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load student"})
+			return
+		}
+
+		f.studentID = studentID
+		own, err := loadAnalyticsAttempts(db, f)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load attempts"})
+			return
+		}
+		if len(own) == 0 {
+			c.JSON(http.StatusOK, studentProgress{
+				StudentID:  student.ID,
+				FullName:   student.FullName,
+				Department: student.Department,
+				Year:       student.Year,
+				Reasons:    []string{},
+				Attempts:   []studentProgressAttempt{},
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, buildStudentProgress(own, true))
+	}
+}
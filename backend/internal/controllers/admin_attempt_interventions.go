@@ -0,0 +1,251 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/letera1/huhems-exam-system/backend/internal/audit"
+	"github.com/letera1/huhems-exam-system/backend/internal/live"
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+const maxExtraMinutesPerGrant = 24 * 60
+
+type adminAttemptInterventionRequest struct {
+	Minutes int    `json:"minutes"`
+	Reason  string `json:"reason"`
+}
+
+type adminAttemptInterventionResponse struct {
+	AttemptID         uuid.UUID  `json:"attemptId"`
+	Paused            bool       `json:"paused"`
+	PausedSeconds     int64      `json:"pausedSeconds"`
+	ExtraMinutes      int        `json:"extraMinutes"`
+	Deadline          *time.Time `json:"deadline"`
+	Submitted         bool       `json:"submitted"`
+	Score             float64    `json:"score"`
+	Invalidated       bool       `json:"invalidated"`
+	InvalidatedReason string     `json:"invalidatedReason"`
+}
+
+// interventionSnapshot is what the audit log records for every intervention.
+func interventionSnapshot(a models.ExamAttempt) gin.H {
+	return gin.H{
+		"paused":            a.PausedAt != nil,
+		"pausedSeconds":     a.PausedSeconds,
+		"extraMinutes":      a.ExtraMinutes,
+		"submitted":         a.Submitted,
+		"invalidated":       a.Invalidated,
+		"invalidatedReason": a.InvalidatedReason,
+	}
+}
+
+// interventionColumns are the attempt columns an intervention may change.
+var interventionColumns = []string{"paused_at", "paused_seconds", "extra_minutes", "invalidated", "invalidated_at", "invalidated_reason"}
+
+// adminAttemptIntervention runs apply on the locked attempt, saves it, records
+// the action in the audit log and pushes the new state to live dashboards.
+// apply returns errInvalid(...) for requests that do not fit the attempt's
+// current state.
+func adminAttemptIntervention(db *gorm.DB, hub *live.Hub, action string, apply func(tx *gorm.DB, attempt *models.ExamAttempt, deadline *time.Time, req adminAttemptInterventionRequest, now time.Time) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		attemptID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid attempt id"})
+			return
+		}
+
+		var req adminAttemptInterventionRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
+				return
+			}
+		}
+		req.Reason = strings.TrimSpace(req.Reason)
+
+		var before, attempt models.ExamAttempt
+		var exam models.Exam
+		err = db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if attempt, err = lockAttempt(tx, attemptID); err != nil {
+				return err
+			}
+			if err := tx.Select("duration_minutes").First(&exam, "id = ?", attempt.ExamID).Error; err != nil {
+				return err
+			}
+			before = attempt
+
+			var deadline *time.Time
+			if d, ok := attemptDeadline(attempt, exam.DurationMinutes); ok {
+				deadline = &d
+			}
+			if err := apply(tx, &attempt, deadline, req, time.Now().UTC()); err != nil {
+				return err
+			}
+			// Write only the columns interventions change; closing the
+			// attempt has already written its own.
+			if err := tx.Model(&attempt).Select(interventionColumns).Updates(&attempt).Error; err != nil {
+				return err
+			}
+
+			after := interventionSnapshot(attempt)
+			if req.Reason != "" {
+				after["reason"] = req.Reason
+			}
+			return audit.Record(tx, c, audit.Entry{
+				Action:     action,
+				EntityType: audit.EntityAttempt,
+				EntityID:   attempt.ID,
+				Before:     interventionSnapshot(before),
+				After:      after,
+			})
+		})
+		if err != nil {
+			var invalid invalidError
+			switch {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"message": "attempt not found"})
+			case errors.As(err, &invalid):
+				c.JSON(http.StatusBadRequest, gin.H{"message": invalid.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to update attempt"})
+			}
+			return
+		}
+
+		eventType := liveAttemptUpdated
+		if attempt.Submitted && !before.Submitted {
+			eventType = liveAttemptSubmitted
+		}
+		publishAttemptState(db, hub, attempt.ExamID, attempt.ID, eventType)
+		if eventType == liveAttemptSubmitted {
+			publishAttemptClosed(hub, attempt, submittedByProctor)
+		} else {
+			publishAttemptChanged(hub, attempt.ID)
+		}
+
+		resp := adminAttemptInterventionResponse{
+			AttemptID:         attempt.ID,
+			Paused:            attempt.PausedAt != nil,
+			PausedSeconds:     attempt.PausedSeconds,
+			ExtraMinutes:      attempt.ExtraMinutes,
+			Submitted:         attempt.Submitted,
+			Score:             attempt.Score,
+			Invalidated:       attempt.Invalidated,
+			InvalidatedReason: attempt.InvalidatedReason,
+		}
+		if !attempt.Submitted {
+			if d, ok := attemptDeadline(attempt, exam.DurationMinutes); ok {
+				resp.Deadline = &d
+			}
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+func requireOpenAttempt(attempt *models.ExamAttempt) error {
+	if attempt.Submitted {
+		return errInvalid("attempt already submitted")
+	}
+	return nil
+}
+
+// AdminAttemptPause stops the clock of an in-progress attempt. The student
+// cannot answer until it is resumed.
+func AdminAttemptPause(db *gorm.DB, hub *live.Hub) gin.HandlerFunc {
+	return adminAttemptIntervention(db, hub, audit.ActionAttemptPause, func(tx *gorm.DB, attempt *models.ExamAttempt, deadline *time.Time, req adminAttemptInterventionRequest, now time.Time) error {
+		if err := requireOpenAttempt(attempt); err != nil {
+			return err
+		}
+		if attempt.PausedAt != nil {
+			return errInvalid("attempt is already paused")
+		}
+		if deadline != nil && now.After(*deadline) {
+			return errInvalid("time is up")
+		}
+		attempt.PausedAt = &now
+		return nil
+	})
+}
+
+// AdminAttemptResume restarts the clock of a paused attempt. The time spent
+// paused is added to the deadline.
+func AdminAttemptResume(db *gorm.DB, hub *live.Hub) gin.HandlerFunc {
+	return adminAttemptIntervention(db, hub, audit.ActionAttemptResume, func(tx *gorm.DB, attempt *models.ExamAttempt, deadline *time.Time, req adminAttemptInterventionRequest, now time.Time) error {
+		if err := requireOpenAttempt(attempt); err != nil {
+			return err
+		}
+		if attempt.PausedAt == nil {
+			return errInvalid("attempt is not paused")
+		}
+		if paused := int64(now.Sub(*attempt.PausedAt).Seconds()); paused > 0 {
+			attempt.PausedSeconds += paused
+		}
+		attempt.PausedAt = nil
+		return nil
+	})
+}
+
+// AdminAttemptExtend grants extra minutes to an in-progress attempt.
+func AdminAttemptExtend(db *gorm.DB, hub *live.Hub) gin.HandlerFunc {
+	return adminAttemptIntervention(db, hub, audit.ActionAttemptExtend, func(tx *gorm.DB, attempt *models.ExamAttempt, deadline *time.Time, req adminAttemptInterventionRequest, now time.Time) error {
+		if err := requireOpenAttempt(attempt); err != nil {
+			return err
+		}
+		if req.Minutes < 1 || req.Minutes > maxExtraMinutesPerGrant {
+			return errInvalid("minutes must be between 1 and 1440")
+		}
+		if deadline == nil {
+			return errInvalid("exam has no time limit")
+		}
+		attempt.ExtraMinutes += req.Minutes
+		return nil
+	})
+}
+
+// AdminAttemptForceSubmit submits an in-progress attempt as it stands.
+func AdminAttemptForceSubmit(db *gorm.DB, hub *live.Hub) gin.HandlerFunc {
+	return adminAttemptIntervention(db, hub, audit.ActionAttemptForceSubmit, func(tx *gorm.DB, attempt *models.ExamAttempt, deadline *time.Time, req adminAttemptInterventionRequest, now time.Time) error {
+		if err := requireOpenAttempt(attempt); err != nil {
+			return err
+		}
+		end := now
+		if deadline != nil && now.After(*deadline) {
+			end = *deadline
+		}
+		_, err := closeAttempt(tx, attempt, end, false)
+		return err
+	})
+}
+
+// AdminAttemptInvalidate closes the attempt (if still open) and marks it
+// invalid, excluding it from reports. A reason is required.
+func AdminAttemptInvalidate(db *gorm.DB, hub *live.Hub) gin.HandlerFunc {
+	return adminAttemptIntervention(db, hub, audit.ActionAttemptInvalidate, func(tx *gorm.DB, attempt *models.ExamAttempt, deadline *time.Time, req adminAttemptInterventionRequest, now time.Time) error {
+		if attempt.Invalidated {
+			return errInvalid("attempt is already invalidated")
+		}
+		if req.Reason == "" {
+			return errInvalid("reason is required")
+		}
+		if !attempt.Submitted {
+			end := now
+			if deadline != nil && now.After(*deadline) {
+				end = *deadline
+			}
+			if _, err := closeAttempt(tx, attempt, end, false); err != nil {
+				return err
+			}
+		}
+		attempt.Invalidated = true
+		attempt.InvalidatedAt = &now
+		attempt.InvalidatedReason = req.Reason
+		return nil
+	})
+}
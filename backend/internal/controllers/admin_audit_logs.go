@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 200
+)
+
+type adminAuditLogView struct {
+	ID            uuid.UUID       `json:"id"`
+	ActorID       uuid.UUID       `json:"actorId"`
+	ActorUsername string          `json:"actorUsername"`
+	Action        string          `json:"action"`
+	EntityType    string          `json:"entityType"`
+	EntityID      *uuid.UUID      `json:"entityId"`
+	Before        json.RawMessage `json:"before"`
+	After         json.RawMessage `json:"after"`
+	IP            string          `json:"ip"`
+	UserAgent     string          `json:"userAgent"`
+	Timestamp     time.Time       `json:"timestamp"`
+}
+
+type adminAuditLogsResponse struct {
+	Items    []adminAuditLogView `json:"items"`
+	Total    int64               `json:"total"`
+	Page     int                 `json:"page"`
+	PageSize int                 `json:"pageSize"`
+}
+
+func rawJSON(s *string) json.RawMessage {
+	if s == nil {
+		return nil
+	}
+	return json.RawMessage(*s)
+}
+
+// AdminAuditLogsList returns audit log entries, newest first.
+//
+// Query parameters:
+//
+//	page, pageSize                 pagination (pageSize max 200)
+//	actorId, action, entityType, entityId, from, to  optional filters
+func AdminAuditLogsList(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page := 1
+		if v := strings.TrimSpace(c.Query("page")); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "invalid page"})
+				return
+			}
+			page = n
+		}
+		pageSize := defaultAuditPageSize
+		if v := strings.TrimSpace(c.Query("pageSize")); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxAuditPageSize {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "pageSize must be between 1 and 200"})
+				return
+			}
+			pageSize = n
+		}
+
+		q := db.Model(&models.AuditLog{})
+		if v := strings.TrimSpace(c.Query("actorId")); v != "" {
+			id, err := uuid.Parse(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "invalid actorId"})
+				return
+			}
+			q = q.Where("user_id = ?", id)
+		}
+		if v := strings.TrimSpace(c.Query("entityId")); v != "" {
+			id, err := uuid.Parse(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "invalid entityId"})
+				return
+			}
+			q = q.Where("entity_id = ?", id)
+		}
+		if v := strings.TrimSpace(c.Query("action")); v != "" {
+			q = q.Where("action = ?", v)
+		}
+		if v := strings.TrimSpace(c.Query("entityType")); v != "" {
+			q = q.Where("entity_type = ?", v)
+		}
+		for _, p := range []struct {
+			key string
+			op  string
+		}{{"from", ">="}, {"to", "<="}} {
+			v := strings.TrimSpace(c.Query(p.key))
+			if v == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "invalid " + p.key + " (use RFC3339)"})
+				return
+			}
+			q = q.Where("timestamp "+p.op+" ?", t)
+		}
+
+		// New session so the filters can be reused for both count and page.
+		q = q.Session(&gorm.Session{})
+
+		var total int64
+		if err := q.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load audit logs"})
+			return
+		}
+
+		var logs []models.AuditLog
+		if err := q.Preload("User").
+			Order("timestamp desc, id desc").
+			Offset((page - 1) * pageSize).
+			Limit(pageSize).
+			Find(&logs).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load audit logs"})
+			return
+		}
+
+		resp := adminAuditLogsResponse{Items: make([]adminAuditLogView, 0, len(logs)), Total: total, Page: page, PageSize: pageSize}
+		for _, l := range logs {
+			resp.Items = append(resp.Items, adminAuditLogView{
+				ID:            l.ID,
+				ActorID:       l.UserID,
+				ActorUsername: l.User.Username,
+				Action:        l.Action,
+				EntityType:    l.EntityType,
+				EntityID:      l.EntityID,
+				Before:        rawJSON(l.Before),
+				After:         rawJSON(l.After),
+				IP:            l.IP,
+				UserAgent:     l.UserAgent,
+				Timestamp:     l.Timestamp,
+			})
+		}
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
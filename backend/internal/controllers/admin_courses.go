@@ -0,0 +1,623 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/letera1/huhems-exam-system/backend/internal/audit"
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxCourseCodeLength = 32
+
+type adminCourseView struct {
+	ID            uuid.UUID `json:"id"`
+	Code          string    `json:"code"`
+	Title         string    `json:"title"`
+	Description   string    `json:"description"`
+	InstructorIDs []string  `json:"instructorIds"`
+	StudentCount  int       `json:"studentCount"`
+	ExamCount     int       `json:"examCount"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+type adminCourseInstructorView struct {
+	UserID   uuid.UUID `json:"userId"`
+	Username string    `json:"username"`
+	Email    string    `json:"email"`
+}
+
+type adminCourseExamView struct {
+	ID        uuid.UUID `json:"id"`
+	Title     string    `json:"title"`
+	Published bool      `json:"published"`
+}
+
+type adminCourseDetailResponse struct {
+	adminCourseView
+	Instructors []adminCourseInstructorView `json:"instructors"`
+	Students    []adminGroupMemberView      `json:"students"`
+	Exams       []adminCourseExamView       `json:"exams"`
+}
+
+type adminCourseRequest struct {
+	Code        string `json:"code"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+func (r *adminCourseRequest) validate() error {
+	r.Code = strings.ToUpper(strings.TrimSpace(r.Code))
+	r.Title = strings.TrimSpace(r.Title)
+	r.Description = strings.TrimSpace(r.Description)
+	if r.Code == "" {
+		return errInvalid("code is required")
+	}
+	if len(r.Code) > maxCourseCodeLength {
+		return errInvalid("code is too long (max 32 characters)")
+	}
+	if r.Title == "" {
+		return errInvalid("title is required")
+	}
+	return nil
+}
+
+type adminCourseInstructorsRequest struct {
+	UserIDs []uuid.UUID `json:"userIds"`
+}
+
+func courseSnapshot(c models.Course) gin.H {
+	return gin.H{"id": c.ID, "code": c.Code, "title": c.Title, "description": c.Description}
+}
+
+// loadCourseViews builds the list view of the given courses.
+func loadCourseViews(db *gorm.DB, courses []models.Course) ([]adminCourseView, error) {
+	out := make([]adminCourseView, 0, len(courses))
+	if len(courses) == 0 {
+		return out, nil
+	}
+	ids := make([]uuid.UUID, 0, len(courses))
+	for _, c := range courses {
+		ids = append(ids, c.ID)
+	}
+
+	var instructors []models.CourseInstructor
+	if err := db.Where("course_id IN ?", ids).Find(&instructors).Error; err != nil {
+		return nil, err
+	}
+	instructorIDs := map[uuid.UUID][]string{}
+	for _, i := range instructors {
+		instructorIDs[i.CourseID] = append(instructorIDs[i.CourseID], i.UserID.String())
+	}
+
+	type countRow struct {
+		CourseID uuid.UUID
+		Cnt      int
+	}
+	var students, exams []countRow
+	if err := db.Model(&models.CourseEnrollment{}).Select("course_id, COUNT(*) AS cnt").Where("course_id IN ?", ids).Group("course_id").Scan(&students).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&models.Exam{}).Select("course_id, COUNT(*) AS cnt").Where("course_id IN ?", ids).Group("course_id").Scan(&exams).Error; err != nil {
+		return nil, err
+	}
+	studentCount := map[uuid.UUID]int{}
+	for _, r := range students {
+		studentCount[r.CourseID] = r.Cnt
+	}
+	examCount := map[uuid.UUID]int{}
+	for _, r := range exams {
+		examCount[r.CourseID] = r.Cnt
+	}
+
+	for _, c := range courses {
+		ids := instructorIDs[c.ID]
+		if ids == nil {
+			ids = []string{}
+		}
+		sort.Strings(ids)
+		out = append(out, adminCourseView{
+			ID:            c.ID,
+			Code:          c.Code,
+			Title:         c.Title,
+			Description:   c.Description,
+			InstructorIDs: ids,
+			StudentCount:  studentCount[c.ID],
+			ExamCount:     examCount[c.ID],
+			CreatedAt:     c.CreatedAt,
+		})
+	}
+	return out, nil
+}
+
+func findCourse(c *gin.Context, db *gorm.DB) (models.Course, bool) {
+	courseID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid course id"})
+		return models.Course{}, false
+	}
+	var course models.Course
+	if err := db.First(&course, "id = ?", courseID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "course not found"})
+			return models.Course{}, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load course"})
+		return models.Course{}, false
+	}
+	return course, true
+}
+
+func courseCodeTaken(db *gorm.DB, code string, except uuid.UUID) (bool, error) {
+	var n int64
+	err := db.Model(&models.Course{}).Where("code = ? AND id <> ?", code, except).Count(&n).Error
+	return n > 0, err
+}
+
+// AdminCoursesList lists the courses the caller may access.
+func AdminCoursesList(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q := db.Order("code asc")
+		if !seesAllCourses(c) {
+			ids, err := taughtCourseIDs(db, currentUserID(c))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load courses"})
+				return
+			}
+			if len(ids) == 0 {
+				c.JSON(http.StatusOK, []adminCourseView{})
+				return
+			}
+			q = q.Where("id IN ?", ids)
+		}
+		var courses []models.Course
+		if err := q.Find(&courses).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load courses"})
+			return
+		}
+		resp, err := loadCourseViews(db, courses)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load courses"})
+			return
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// AdminCoursesGet returns a course with its instructors, students and exams.
+func AdminCoursesGet(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		course, ok := findCourse(c, db)
+		if !ok {
+			return
+		}
+		views, err := loadCourseViews(db, []models.Course{course})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load course"})
+			return
+		}
+
+		var instructors []models.CourseInstructor
+		if err := db.Preload("User").Where("course_id = ?", course.ID).Find(&instructors).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load instructors"})
+			return
+		}
+		var enrollments []models.CourseEnrollment
+		if err := db.Preload("Student.User").Where("course_id = ?", course.ID).Find(&enrollments).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load students"})
+			return
+		}
+		var exams []models.Exam
+		if err := db.Select("id", "title", "published").Where("course_id = ?", course.ID).Order("created_at desc").Find(&exams).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load exams"})
+			return
+		}
+
+		resp := adminCourseDetailResponse{
+			adminCourseView: views[0],
+			Instructors:     make([]adminCourseInstructorView, 0, len(instructors)),
+			Students:        make([]adminGroupMemberView, 0, len(enrollments)),
+			Exams:           make([]adminCourseExamView, 0, len(exams)),
+		}
+		for _, i := range instructors {
+			resp.Instructors = append(resp.Instructors, adminCourseInstructorView{UserID: i.UserID, Username: i.User.Username, Email: i.User.Email})
+		}
+		for _, e := range enrollments {
+			resp.Students = append(resp.Students, adminGroupMemberView{
+				StudentID:  e.StudentID,
+				Username:   e.Student.User.Username,
+				FullName:   e.Student.FullName,
+				Department: e.Student.Department,
+				Year:       e.Student.Year,
+			})
+		}
+		sort.Slice(resp.Students, func(i, j int) bool { return resp.Students[i].FullName < resp.Students[j].FullName })
+		for _, e := range exams {
+			resp.Exams = append(resp.Exams, adminCourseExamView{ID: e.ID, Title: e.Title, Published: e.Published})
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+func AdminCoursesCreate(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req adminCourseRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
+			return
+		}
+		if err := req.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		taken, err := courseCodeTaken(db, req.Code, uuid.Nil)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to check course code"})
+			return
+		}
+		if taken {
+			c.JSON(http.StatusConflict, gin.H{"message": "a course with this code already exists"})
+			return
+		}
+
+		course := models.Course{Code: req.Code, Title: req.Title, Description: req.Description}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&course).Error; err != nil {
+				return err
+			}
+			return audit.Record(tx, c, audit.Entry{Action: audit.ActionCourseCreate, EntityType: audit.EntityCourse, EntityID: course.ID, After: courseSnapshot(course)})
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to create course"})
+			return
+		}
+
+		views, _ := loadCourseViews(db, []models.Course{course})
+		c.JSON(http.StatusCreated, views[0])
+	}
+}
+
+func AdminCoursesUpdate(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		course, ok := findCourse(c, db)
+		if !ok {
+			return
+		}
+		var req adminCourseRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
+			return
+		}
+		if err := req.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		taken, err := courseCodeTaken(db, req.Code, course.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to check course code"})
+			return
+		}
+		if taken {
+			c.JSON(http.StatusConflict, gin.H{"message": "a course with this code already exists"})
+			return
+		}
+
+		before := courseSnapshot(course)
+		course.Code = req.Code
+		course.Title = req.Title
+		course.Description = req.Description
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&course).Error; err != nil {
+				return err
+			}
+			return audit.Record(tx, c, audit.Entry{Action: audit.ActionCourseUpdate, EntityType: audit.EntityCourse, EntityID: course.ID, Before: before, After: courseSnapshot(course)})
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to update course"})
+			return
+		}
+
+		views, err := loadCourseViews(db, []models.Course{course})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load course"})
+			return
+		}
+		c.JSON(http.StatusOK, views[0])
+	}
+}
+
+// AdminCoursesDelete deletes a course with no exams, along with its
+// instructor and enrollment links.
+func AdminCoursesDelete(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		course, ok := findCourse(c, db)
+		if !ok {
+			return
+		}
+		var exams int64
+		if err := db.Model(&models.Exam{}).Where("course_id = ?", course.ID).Count(&exams).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to check course exams"})
+			return
+		}
+		if exams > 0 {
+			c.JSON(http.StatusConflict, gin.H{"message": "course still has exams; move or delete them first"})
+			return
+		}
+
+		// Hard delete so the code can be reused.
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Unscoped().Where("course_id = ?", course.ID).Delete(&models.CourseInstructor{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("course_id = ?", course.ID).Delete(&models.CourseEnrollment{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Delete(&models.Course{}, "id = ?", course.ID).Error; err != nil {
+				return err
+			}
+			return audit.Record(tx, c, audit.Entry{Action: audit.ActionCourseDelete, EntityType: audit.EntityCourse, EntityID: course.ID, Before: courseSnapshot(course)})
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to delete course"})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// AdminCourseInstructorsPut replaces the staff assigned to a course. Any
+// non-student account may be assigned; what it can do in the course follows
+// from its permissions.
+func AdminCourseInstructorsPut(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		course, ok := findCourse(c, db)
+		if !ok {
+			return
+		}
+		var req adminCourseInstructorsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
+			return
+		}
+		seen := map[uuid.UUID]bool{}
+		ids := make([]uuid.UUID, 0, len(req.UserIDs))
+		for _, id := range req.UserIDs {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) > 0 {
+			var n int64
+			if err := db.Model(&models.User{}).
+				Joins("JOIN roles ON roles.id = users.role_id").
+				Where("users.id IN ? AND roles.name <> ?", ids, models.RoleStudent).
+				Count(&n).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load users"})
+				return
+			}
+			if int(n) != len(ids) {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "every user must be a staff account"})
+				return
+			}
+		}
+
+		var before []uuid.UUID
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&models.CourseInstructor{}).Where("course_id = ?", course.ID).Pluck("user_id", &before).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("course_id = ?", course.ID).Delete(&models.CourseInstructor{}).Error; err != nil {
+				return err
+			}
+			if len(ids) > 0 {
+				rows := make([]models.CourseInstructor, 0, len(ids))
+				for _, id := range ids {
+					rows = append(rows, models.CourseInstructor{CourseID: course.ID, UserID: id})
+				}
+				if err := tx.Create(&rows).Error; err != nil {
+					return err
+				}
+			}
+			return audit.Record(tx, c, audit.Entry{
+				Action:     audit.ActionCourseInstructorsSet,
+				EntityType: audit.EntityCourse,
+				EntityID:   course.ID,
+				Before:     gin.H{"userIds": before},
+				After:      gin.H{"userIds": ids},
+			})
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to save instructors"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"userIds": ids})
+	}
+}
+
+// AdminCourseStudentsAdd enrolls students in a course. Students already
+// enrolled are skipped.
+func AdminCourseStudentsAdd(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		course, ok := findCourse(c, db)
+		if !ok {
+			return
+		}
+		ids, ok := bindGroupMembersRequest(c)
+		if !ok {
+			return
+		}
+		var n int64
+		if err := db.Model(&models.Student{}).Where("id IN ?", ids).Count(&n).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load students"})
+			return
+		}
+		if int(n) != len(ids) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "unknown studentId"})
+			return
+		}
+
+		rows := make([]models.CourseEnrollment, 0, len(ids))
+		for _, id := range ids {
+			rows = append(rows, models.CourseEnrollment{CourseID: course.ID, StudentID: id})
+		}
+		var added int64
+		err := db.Transaction(func(tx *gorm.DB) error {
+			res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows)
+			if res.Error != nil {
+				return res.Error
+			}
+			added = res.RowsAffected
+			return audit.Record(tx, c, audit.Entry{
+				Action:     audit.ActionCourseStudentsAdd,
+				EntityType: audit.EntityCourse,
+				EntityID:   course.ID,
+				After:      gin.H{"studentIds": ids, "added": added},
+			})
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to enroll students"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"added": added})
+	}
+}
+
+// AdminCourseStudentsRemove unenrolls students from a course.
+func AdminCourseStudentsRemove(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		course, ok := findCourse(c, db)
+		if !ok {
+			return
+		}
+		ids, ok := bindGroupMembersRequest(c)
+		if !ok {
+			return
+		}
+		var removed int64
+		err := db.Transaction(func(tx *gorm.DB) error {
+			res := tx.Unscoped().Where("course_id = ? AND student_id IN ?", course.ID, ids).Delete(&models.CourseEnrollment{})
+			if res.Error != nil {
+				return res.Error
+			}
+			removed = res.RowsAffected
+			return audit.Record(tx, c, audit.Entry{
+				Action:     audit.ActionCourseStudentsRemove,
+				EntityType: audit.EntityCourse,
+				EntityID:   course.ID,
+				Before:     gin.H{"studentIds": ids, "removed": removed},
+			})
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to remove students"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"removed": removed})
+	}
+}
+
+type adminInstructorView struct {
+	ID                 uuid.UUID  `json:"id"`
+	Username           string     `json:"username"`
+	Email              string     `json:"email"`
+	CreatedAt          time.Time  `json:"createdAt"`
+	PasswordChangedAt  *time.Time `json:"passwordChangedAt"`
+	MustChangePassword bool       `json:"mustChangePassword"`
+	// TemporaryPassword is only set in the response that creates the
+	// account.
+	TemporaryPassword string `json:"temporaryPassword,omitempty"`
+}
+
+type adminInstructorCreateRequest struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+// AdminInstructorsList lists users with the instructor role.
+func AdminInstructorsList(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var users []models.User
+		if err := db.Joins("JOIN roles ON roles.id = users.role_id").Where("roles.name = ?", models.RoleInstructor).Order("users.username asc").Find(&users).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load instructors"})
+			return
+		}
+		resp := make([]adminInstructorView, 0, len(users))
+		for _, u := range users {
+			resp = append(resp, adminInstructorView{ID: u.ID, Username: u.Username, Email: u.Email, CreatedAt: u.CreatedAt, PasswordChangedAt: u.PasswordChangedAt, MustChangePassword: u.MustChangePassword})
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// AdminInstructorsCreate creates an instructor account.
+func AdminInstructorsCreate(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req adminInstructorCreateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
+			return
+		}
+		req.Username = strings.TrimSpace(req.Username)
+		req.Email = strings.TrimSpace(strings.ToLower(req.Email))
+		if req.Username == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "username is required"})
+			return
+		}
+		if _, err := mail.ParseAddress(req.Email); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid email"})
+			return
+		}
+
+		var role models.Role
+		if err := db.First(&role, "name = ?", models.RoleInstructor).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "instructor role not configured"})
+			return
+		}
+		password, hash, err := newTemporaryCredential()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to generate password"})
+			return
+		}
+
+		user := models.User{
+			Username:           req.Username,
+			Email:              req.Email,
+			PasswordHash:       hash,
+			MustChangePassword: true,
+			RoleID:             role.ID,
+		}
+		var view adminInstructorView
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&user).Error; err != nil {
+				return errInvalid("failed to create instructor (username/email may already exist)")
+			}
+			view = adminInstructorView{ID: user.ID, Username: user.Username, Email: user.Email, CreatedAt: user.CreatedAt, MustChangePassword: true}
+			return audit.Record(tx, c, audit.Entry{Action: audit.ActionInstructorCreate, EntityType: audit.EntityUser, EntityID: user.ID, After: view})
+		})
+		if err != nil {
+			var invalid invalidError
+			if errors.As(err, &invalid) {
+				c.JSON(http.StatusBadRequest, gin.H{"message": invalid.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to create instructor"})
+			return
+		}
+
+		view.TemporaryPassword = password
+		noStore(c)
+		c.JSON(http.StatusCreated, view)
+	}
+}
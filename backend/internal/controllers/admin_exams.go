@@ -5,9 +5,11 @@ import (
 	"strings"
 	"time"
 
+	"github.com/letera1/huhems-exam-system/backend/internal/audit"
 	"github.com/letera1/huhems-exam-system/backend/internal/models"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"gorm.io/gorm"
 )
 
@@ -19,6 +21,13 @@ type adminExamCreateRequest struct {
 	DurationMinutes  int        `json:"durationMinutes"`
 	MaxAttempts      int        `json:"maxAttempts"`
 	QuestionsPerPage int        `json:"questionsPerPage"`
+	Tags             []string   `json:"tags"`
+
+	// CourseID is required unless the caller has course:all, and must be a
+	// course the caller is assigned to.
+	CourseID *uuid.UUID `json:"courseId"`
+
+	IntegrityThresholds map[string]int `json:"integrityThresholds"`
 }
 
 type adminExamUpdateRequest struct {
@@ -30,12 +39,73 @@ type adminExamUpdateRequest struct {
 	MaxAttempts      *int       `json:"maxAttempts"`
 	QuestionsPerPage *int       `json:"questionsPerPage"`
 	Published        *bool      `json:"published"`
+	Tags             []string   `json:"tags"`
+	CourseID         *uuid.UUID `json:"courseId"`
+
+	// IntegrityThresholds replaces the exam's thresholds when present; an
+	// empty object disables automatic flagging.
+	IntegrityThresholds map[string]int `json:"integrityThresholds"`
+}
+
+// normalizeExamTags trims, lowercases and de-duplicates exam tags.
+func normalizeExamTags(tags []string) pq.StringArray {
+	out := pq.StringArray{}
+	seen := map[string]struct{}{}
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" {
+			continue
+		}
+		if _, ok := seen[t]; ok {
+			continue
+		}
+		seen[t] = struct{}{}
+		out = append(out, t)
+	}
+	return out
+}
+
+// checkExamCourse validates the course an exam is created in or moved to. It
+// writes the error response itself.
+func checkExamCourse(c *gin.Context, db *gorm.DB, courseID *uuid.UUID) bool {
+	if courseID == nil {
+		if !seesAllCourses(c) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "courseId is required"})
+			return false
+		}
+		return true
+	}
+	var n int64
+	if err := db.Model(&models.Course{}).Where("id = ?", *courseID).Count(&n).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load course"})
+		return false
+	}
+	if n == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "unknown courseId"})
+		return false
+	}
+	ok, err := callerTeaches(c, db, *courseID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to check course access"})
+		return false
+	}
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"message": "you do not teach this course"})
+		return false
+	}
+	return true
 }
 
+// AdminExamsList lists the exams the caller may access.
 func AdminExamsList(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		q, err := scopeExamsToCaller(c, db, db.Preload("CreatedBy").Order("created_at desc"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load exams"})
+			return
+		}
 		var exams []models.Exam
-		if err := db.Preload("CreatedBy").Order("created_at desc").Find(&exams).Error; err != nil {
+		if err := q.Find(&exams).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load exams"})
 			return
 		}
@@ -73,20 +143,38 @@ func AdminExamsCreate(db *gorm.DB) gin.HandlerFunc {
 			// Default to 30 minutes if not specified.
 			req.DurationMinutes = 30
 		}
+		thresholds, err := validateIntegrityThresholds(req.IntegrityThresholds)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		if !checkExamCourse(c, db, req.CourseID) {
+			return
+		}
 
 		exam := models.Exam{
 			Title:            req.Title,
 			Description:      strings.TrimSpace(req.Description),
 			CreatedByID:      createdBy,
+			CourseID:         req.CourseID,
 			Published:        false,
 			StartTime:        req.StartTime,
 			EndTime:          req.EndTime,
 			DurationMinutes:  req.DurationMinutes,
 			MaxAttempts:      req.MaxAttempts,
 			QuestionsPerPage: req.QuestionsPerPage,
+			Tags:             normalizeExamTags(req.Tags),
+
+			IntegrityThresholds: thresholds,
 		}
 
-		if err := db.Create(&exam).Error; err != nil {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&exam).Error; err != nil {
+				return err
+			}
+			return audit.Record(tx, c, audit.Entry{Action: audit.ActionExamCreate, EntityType: audit.EntityExam, EntityID: exam.ID, After: exam})
+		})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to create exam"})
 			return
 		}
@@ -171,6 +259,7 @@ func AdminExamsUpdate(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load exam"})
 			return
 		}
+		before := exam
 
 		if req.Title != nil {
 			t := strings.TrimSpace(*req.Title)
@@ -210,6 +299,23 @@ func AdminExamsUpdate(db *gorm.DB) gin.HandlerFunc {
 			}
 			exam.QuestionsPerPage = *req.QuestionsPerPage
 		}
+		if req.Tags != nil {
+			exam.Tags = normalizeExamTags(req.Tags)
+		}
+		if req.CourseID != nil {
+			if !checkExamCourse(c, db, req.CourseID) {
+				return
+			}
+			exam.CourseID = req.CourseID
+		}
+		if req.IntegrityThresholds != nil {
+			thresholds, err := validateIntegrityThresholds(req.IntegrityThresholds)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+				return
+			}
+			exam.IntegrityThresholds = thresholds
+		}
 		if req.Published != nil {
 			// Only allow unpublish here. Publishing requires validation via /publish.
 			if *req.Published && !exam.Published {
@@ -219,7 +325,13 @@ func AdminExamsUpdate(db *gorm.DB) gin.HandlerFunc {
 			exam.Published = *req.Published
 		}
 
-		if err := db.Save(&exam).Error; err != nil {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&exam).Error; err != nil {
+				return err
+			}
+			return audit.Record(tx, c, audit.Entry{Action: audit.ActionExamUpdate, EntityType: audit.EntityExam, EntityID: exam.ID, Before: before, After: exam})
+		})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to update exam"})
 			return
 		}
@@ -236,6 +348,13 @@ func AdminExamsDelete(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		// Loaded only for the audit snapshot; deleting a missing exam is a no-op.
+		var exams []models.Exam
+		if err := db.Where("id = ?", examID).Limit(1).Find(&exams).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load exam"})
+			return
+		}
+
 		err = db.Transaction(func(tx *gorm.DB) error {
 			var questions []models.Question
 			if err := tx.Where("exam_id = ?", examID).Find(&questions).Error; err != nil {
@@ -254,10 +373,16 @@ func AdminExamsDelete(db *gorm.DB) gin.HandlerFunc {
 				}
 			}
 
+			if err := tx.Where("exam_id = ?", examID).Delete(&models.ExamTarget{}).Error; err != nil {
+				return err
+			}
 			if err := tx.Delete(&models.Exam{}, "id = ?", examID).Error; err != nil {
 				return err
 			}
-			return nil
+			if len(exams) == 0 {
+				return nil
+			}
+			return audit.Record(tx, c, audit.Entry{Action: audit.ActionExamDelete, EntityType: audit.EntityExam, EntityID: examID, Before: exams[0]})
 		})
 
 		if err != nil {
@@ -348,8 +473,15 @@ func AdminExamsPublish(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		before := exam
 		exam.Published = true
-		if err := db.Save(&exam).Error; err != nil {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&exam).Error; err != nil {
+				return err
+			}
+			return audit.Record(tx, c, audit.Entry{Action: audit.ActionExamPublish, EntityType: audit.EntityExam, EntityID: exam.ID, Before: before, After: exam})
+		})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to publish exam"})
 			return
 		}
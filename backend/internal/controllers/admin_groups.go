@@ -0,0 +1,617 @@
+package controllers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/letera1/huhems-exam-system/backend/internal/audit"
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxGroupNameLength  = 100
+	maxGroupImportRows  = 5000
+	maxGroupMembersEdit = 1000
+)
+
+type adminGroupView struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	MemberCount int       `json:"memberCount"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type adminGroupMemberView struct {
+	StudentID  uuid.UUID `json:"studentId"`
+	Username   string    `json:"username"`
+	FullName   string    `json:"fullName"`
+	Department string    `json:"department"`
+	Year       int       `json:"year"`
+}
+
+type adminGroupDetailResponse struct {
+	adminGroupView
+	Members []adminGroupMemberView `json:"members"`
+}
+
+type adminGroupRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func (r *adminGroupRequest) validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Description = strings.TrimSpace(r.Description)
+	if r.Name == "" {
+		return errInvalid("name is required")
+	}
+	if len(r.Name) > maxGroupNameLength {
+		return errInvalid("name is too long (max 100 characters)")
+	}
+	return nil
+}
+
+type adminGroupMembersRequest struct {
+	StudentIDs []uuid.UUID `json:"studentIds"`
+}
+
+// studentIDsInGroup is a subquery for the students of a group, for filtering
+// student lists and reports.
+func studentIDsInGroup(db *gorm.DB, groupID uuid.UUID) *gorm.DB {
+	return db.Model(&models.StudentGroupMember{}).Select("student_id").Where("group_id = ?", groupID)
+}
+
+// parseGroupFilter reads the optional groupId query parameter. It writes the
+// error response itself.
+func parseGroupFilter(c *gin.Context) (uuid.UUID, bool) {
+	v := strings.TrimSpace(c.Query("groupId"))
+	if v == "" {
+		return uuid.Nil, true
+	}
+	id, err := uuid.Parse(v)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid groupId"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func loadGroupView(db *gorm.DB, group models.StudentGroup) (adminGroupView, error) {
+	var n int64
+	if err := db.Model(&models.StudentGroupMember{}).Where("group_id = ?", group.ID).Count(&n).Error; err != nil {
+		return adminGroupView{}, err
+	}
+	return adminGroupView{ID: group.ID, Name: group.Name, Description: group.Description, MemberCount: int(n), CreatedAt: group.CreatedAt}, nil
+}
+
+func findGroup(c *gin.Context, db *gorm.DB) (models.StudentGroup, bool) {
+	groupID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid group id"})
+		return models.StudentGroup{}, false
+	}
+	var group models.StudentGroup
+	if err := db.First(&group, "id = ?", groupID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "group not found"})
+			return models.StudentGroup{}, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load group"})
+		return models.StudentGroup{}, false
+	}
+	return group, true
+}
+
+func groupNameTaken(db *gorm.DB, name string, except uuid.UUID) (bool, error) {
+	var n int64
+	err := db.Model(&models.StudentGroup{}).Where("lower(name) = lower(?) AND id <> ?", name, except).Count(&n).Error
+	return n > 0, err
+}
+
+// AdminGroupsList lists all groups with their member counts.
+func AdminGroupsList(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var groups []models.StudentGroup
+		if err := db.Order("name asc").Find(&groups).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load groups"})
+			return
+		}
+
+		type countRow struct {
+			GroupID uuid.UUID
+			Cnt     int
+		}
+		var rows []countRow
+		if err := db.Model(&models.StudentGroupMember{}).
+			Select("group_id, COUNT(*) AS cnt").
+			Group("group_id").
+			Scan(&rows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load group members"})
+			return
+		}
+		counts := map[uuid.UUID]int{}
+		for _, r := range rows {
+			counts[r.GroupID] = r.Cnt
+		}
+
+		resp := make([]adminGroupView, 0, len(groups))
+		for _, g := range groups {
+			resp = append(resp, adminGroupView{ID: g.ID, Name: g.Name, Description: g.Description, MemberCount: counts[g.ID], CreatedAt: g.CreatedAt})
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// AdminGroupsGet returns a group and its members.
+func AdminGroupsGet(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		group, ok := findGroup(c, db)
+		if !ok {
+			return
+		}
+
+		var students []models.Student
+		if err := db.Preload("User").Where("id IN (?)", studentIDsInGroup(db, group.ID)).Order("full_name asc").Find(&students).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load group members"})
+			return
+		}
+
+		resp := adminGroupDetailResponse{
+			adminGroupView: adminGroupView{ID: group.ID, Name: group.Name, Description: group.Description, MemberCount: len(students), CreatedAt: group.CreatedAt},
+			Members:        make([]adminGroupMemberView, 0, len(students)),
+		}
+		for _, s := range students {
+			resp.Members = append(resp.Members, adminGroupMemberView{
+				StudentID:  s.ID,
+				Username:   s.User.Username,
+				FullName:   s.FullName,
+				Department: s.Department,
+				Year:       s.Year,
+			})
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+func AdminGroupsCreate(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req adminGroupRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
+			return
+		}
+		if err := req.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		taken, err := groupNameTaken(db, req.Name, uuid.Nil)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to check group name"})
+			return
+		}
+		if taken {
+			c.JSON(http.StatusConflict, gin.H{"message": "a group with this name already exists"})
+			return
+		}
+
+		group := models.StudentGroup{Name: req.Name, Description: req.Description}
+		var view adminGroupView
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&group).Error; err != nil {
+				return err
+			}
+			view = adminGroupView{ID: group.ID, Name: group.Name, Description: group.Description, CreatedAt: group.CreatedAt}
+			return audit.Record(tx, c, audit.Entry{Action: audit.ActionGroupCreate, EntityType: audit.EntityGroup, EntityID: group.ID, After: view})
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to create group"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, view)
+	}
+}
+
+func AdminGroupsUpdate(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		group, ok := findGroup(c, db)
+		if !ok {
+			return
+		}
+
+		var req adminGroupRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
+			return
+		}
+		if err := req.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		taken, err := groupNameTaken(db, req.Name, group.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to check group name"})
+			return
+		}
+		if taken {
+			c.JSON(http.StatusConflict, gin.H{"message": "a group with this name already exists"})
+			return
+		}
+
+		before := adminGroupView{ID: group.ID, Name: group.Name, Description: group.Description, CreatedAt: group.CreatedAt}
+		group.Name = req.Name
+		group.Description = req.Description
+		var view adminGroupView
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&group).Error; err != nil {
+				return err
+			}
+			var err error
+			if view, err = loadGroupView(tx, group); err != nil {
+				return err
+			}
+			before.MemberCount = view.MemberCount
+			return audit.Record(tx, c, audit.Entry{Action: audit.ActionGroupUpdate, EntityType: audit.EntityGroup, EntityID: group.ID, Before: before, After: view})
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to update group"})
+			return
+		}
+
+		c.JSON(http.StatusOK, view)
+	}
+}
+
+// AdminGroupsDelete deletes a group and its memberships. A group that exams
+// are still assigned to cannot be deleted: dropping the target could open
+// the exam to every student.
+func AdminGroupsDelete(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		group, ok := findGroup(c, db)
+		if !ok {
+			return
+		}
+
+		var targets int64
+		if err := db.Model(&models.ExamTarget{}).Where("group_id = ?", group.ID).Count(&targets).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to check exam assignments"})
+			return
+		}
+		if targets > 0 {
+			c.JSON(http.StatusConflict, gin.H{"message": "group is assigned to exams; remove it from their targets first"})
+			return
+		}
+
+		before, err := loadGroupView(db, group)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load group"})
+			return
+		}
+
+		// Hard delete so the name and memberships can be reused.
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Unscoped().Where("group_id = ?", group.ID).Delete(&models.StudentGroupMember{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Delete(&models.StudentGroup{}, "id = ?", group.ID).Error; err != nil {
+				return err
+			}
+			return audit.Record(tx, c, audit.Entry{Action: audit.ActionGroupDelete, EntityType: audit.EntityGroup, EntityID: group.ID, Before: before})
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to delete group"})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func bindGroupMembersRequest(c *gin.Context) ([]uuid.UUID, bool) {
+	var req adminGroupMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
+		return nil, false
+	}
+	if len(req.StudentIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "studentIds is required"})
+		return nil, false
+	}
+	if len(req.StudentIDs) > maxGroupMembersEdit {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "too many students (max 1000)"})
+		return nil, false
+	}
+	seen := map[uuid.UUID]bool{}
+	ids := make([]uuid.UUID, 0, len(req.StudentIDs))
+	for _, id := range req.StudentIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, true
+}
+
+// AdminGroupMembersAdd adds students to a group. Students already in the
+// group are skipped.
+func AdminGroupMembersAdd(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		group, ok := findGroup(c, db)
+		if !ok {
+			return
+		}
+		ids, ok := bindGroupMembersRequest(c)
+		if !ok {
+			return
+		}
+
+		var n int64
+		if err := db.Model(&models.Student{}).Where("id IN ?", ids).Count(&n).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load students"})
+			return
+		}
+		if int(n) != len(ids) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "unknown studentId"})
+			return
+		}
+
+		members := make([]models.StudentGroupMember, 0, len(ids))
+		for _, id := range ids {
+			members = append(members, models.StudentGroupMember{GroupID: group.ID, StudentID: id})
+		}
+		var added int64
+		err := db.Transaction(func(tx *gorm.DB) error {
+			res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&members)
+			if res.Error != nil {
+				return res.Error
+			}
+			added = res.RowsAffected
+			return audit.Record(tx, c, audit.Entry{
+				Action:     audit.ActionGroupMembersAdd,
+				EntityType: audit.EntityGroup,
+				EntityID:   group.ID,
+				After:      gin.H{"studentIds": ids, "added": added},
+			})
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to add members"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"added": added})
+	}
+}
+
+// AdminGroupMembersRemove removes students from a group. Students not in the
+// group are ignored.
+func AdminGroupMembersRemove(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		group, ok := findGroup(c, db)
+		if !ok {
+			return
+		}
+		ids, ok := bindGroupMembersRequest(c)
+		if !ok {
+			return
+		}
+
+		var removed int64
+		err := db.Transaction(func(tx *gorm.DB) error {
+			res := tx.Unscoped().Where("group_id = ? AND student_id IN ?", group.ID, ids).Delete(&models.StudentGroupMember{})
+			if res.Error != nil {
+				return res.Error
+			}
+			removed = res.RowsAffected
+			return audit.Record(tx, c, audit.Entry{
+				Action:     audit.ActionGroupMembersRemove,
+				EntityType: audit.EntityGroup,
+				EntityID:   group.ID,
+				Before:     gin.H{"studentIds": ids, "removed": removed},
+			})
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to remove members"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"removed": removed})
+	}
+}
+
+type adminGroupsImportResponse struct {
+	CreatedGroups int `json:"createdGroups"`
+	AddedMembers  int `json:"addedMembers"`
+}
+
+// AdminGroupsImportCSV imports group memberships from a CSV file. Groups that
+// do not exist are created. Students are matched by username or email.
+//
+// Supported CSV format (with optional header row):
+//
+//	group,student
+func AdminGroupsImportCSV(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		file, header, err := c.Request.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "file is required"})
+			return
+		}
+		defer func() { _ = file.Close() }()
+
+		ext := strings.ToLower(filepath.Ext(header.Filename))
+		if ext != "" && ext != ".csv" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "file must be a .csv"})
+			return
+		}
+
+		const maxBytes = 5 * 1024 * 1024
+		reader := csv.NewReader(bufio.NewReader(io.LimitReader(file, maxBytes)))
+		reader.FieldsPerRecord = -1
+
+		type row struct {
+			rowNum  int
+			group   string
+			student string
+		}
+		var rows []row
+		for i := 1; ; i++ {
+			rec, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "invalid CSV: " + err.Error()})
+				return
+			}
+			get := func(idx int) string {
+				if idx >= len(rec) {
+					return ""
+				}
+				return strings.TrimSpace(rec[idx])
+			}
+			group, student := get(0), get(1)
+			if group == "" && student == "" {
+				continue
+			}
+			if i == 1 && strings.EqualFold(group, "group") {
+				continue
+			}
+			if group == "" || student == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("row %d: group and student are required", i)})
+				return
+			}
+			if len(group) > maxGroupNameLength {
+				c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("row %d: group name is too long (max 100 characters)", i)})
+				return
+			}
+			rows = append(rows, row{rowNum: i, group: group, student: student})
+			if len(rows) > maxGroupImportRows {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "too many rows (max 5000)"})
+				return
+			}
+		}
+		if len(rows) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "CSV is empty"})
+			return
+		}
+
+		// Resolve students up front so a bad row fails the whole import.
+		keys := make([]string, 0, len(rows))
+		for _, r := range rows {
+			keys = append(keys, strings.ToLower(r.student))
+		}
+		type studentRow struct {
+			ID       uuid.UUID
+			Username string
+			Email    string
+		}
+		var found []studentRow
+		if err := db.Model(&models.Student{}).
+			Select("students.id AS id, users.username AS username, users.email AS email").
+			Joins("JOIN users ON users.id = students.user_id AND users.deleted_at IS NULL").
+			Where("lower(users.username) IN ? OR lower(users.email) IN ?", keys, keys).
+			Scan(&found).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load students"})
+			return
+		}
+		studentByKey := map[string]uuid.UUID{}
+		for _, s := range found {
+			studentByKey[strings.ToLower(s.Username)] = s.ID
+			studentByKey[strings.ToLower(s.Email)] = s.ID
+		}
+		for _, r := range rows {
+			if _, ok := studentByKey[strings.ToLower(r.student)]; !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("row %d: unknown student %q", r.rowNum, r.student)})
+				return
+			}
+		}
+
+		var resp adminGroupsImportResponse
+		err = db.Transaction(func(tx *gorm.DB) error {
+			var existing []models.StudentGroup
+			if err := tx.Find(&existing).Error; err != nil {
+				return err
+			}
+			groupByName := map[string]uuid.UUID{}
+			for _, g := range existing {
+				groupByName[strings.ToLower(g.Name)] = g.ID
+			}
+
+			seen := map[[2]uuid.UUID]bool{}
+			var members []models.StudentGroupMember
+			for _, r := range rows {
+				groupID, ok := groupByName[strings.ToLower(r.group)]
+				if !ok {
+					g := models.StudentGroup{Name: r.group}
+					if err := tx.Create(&g).Error; err != nil {
+						return err
+					}
+					groupID = g.ID
+					groupByName[strings.ToLower(r.group)] = groupID
+					resp.CreatedGroups++
+				}
+				studentID := studentByKey[strings.ToLower(r.student)]
+				key := [2]uuid.UUID{groupID, studentID}
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				members = append(members, models.StudentGroupMember{GroupID: groupID, StudentID: studentID})
+			}
+
+			res := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&members, 500)
+			if res.Error != nil {
+				return res.Error
+			}
+			resp.AddedMembers = int(res.RowsAffected)
+			return audit.Record(tx, c, audit.Entry{Action: audit.ActionGroupImport, EntityType: audit.EntityGroup, After: resp})
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to import memberships"})
+			return
+		}
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// studentGroupNames returns the names of each student's groups, sorted.
+func studentGroupNames(db *gorm.DB, studentIDs []uuid.UUID) (map[uuid.UUID][]string, error) {
+	out := map[uuid.UUID][]string{}
+	if len(studentIDs) == 0 {
+		return out, nil
+	}
+	type row struct {
+		StudentID uuid.UUID
+		Name      string
+	}
+	var rows []row
+	if err := db.Model(&models.StudentGroupMember{}).
+		Select("student_group_members.student_id AS student_id, student_groups.name AS name").
+		Joins("JOIN student_groups ON student_groups.id = student_group_members.group_id AND student_groups.deleted_at IS NULL").
+		Where("student_group_members.student_id IN ?", studentIDs).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		out[r.StudentID] = append(out[r.StudentID], r.Name)
+	}
+	for _, names := range out {
+		sort.Strings(names)
+	}
+	return out, nil
+}
@@ -0,0 +1,239 @@
+package controllers
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/letera1/huhems-exam-system/backend/internal/live"
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// Live event types sent on the exam topic.
+const (
+	liveAttemptStarted   = "attempt.started"
+	liveAttemptProgress  = "attempt.progress"
+	liveAttemptSubmitted = "attempt.submitted"
+	liveAttemptUpdated   = "attempt.updated"
+	liveIntegrityEvent   = "integrity.event"
+)
+
+const liveHeartbeatInterval = 15 * time.Second
+
+type liveAttemptState struct {
+	AttemptID        uuid.UUID  `json:"attemptId"`
+	StudentID        uuid.UUID  `json:"studentId"`
+	FullName         string     `json:"fullName"`
+	StartTime        time.Time  `json:"startTime"`
+	Deadline         *time.Time `json:"deadline"`
+	RemainingSeconds *int64     `json:"remainingSeconds"`
+	QuestionsTotal   int        `json:"questionsTotal"`
+	Answered         int        `json:"answered"`
+	Flagged          int        `json:"flagged"`
+	Submitted        bool       `json:"submitted"`
+	EndTime          *time.Time `json:"endTime"`
+	Score            *float64   `json:"score"`
+	IntegrityEvents  int        `json:"integrityEvents"`
+	FlaggedForReview bool       `json:"flaggedForReview"`
+	Paused           bool       `json:"paused"`
+	ExtraMinutes     int        `json:"extraMinutes"`
+	Invalidated      bool       `json:"invalidated"`
+}
+
+type liveIntegrityEventData struct {
+	AttemptID  uuid.UUID `json:"attemptId"`
+	StudentID  uuid.UUID `json:"studentId"`
+	Type       string    `json:"type"`
+	OccurredAt time.Time `json:"occurredAt"`
+	Details    string    `json:"details"`
+}
+
+type liveSnapshot struct {
+	ExamID     uuid.UUID          `json:"examId"`
+	ServerTime time.Time          `json:"serverTime"`
+	Attempts   []liveAttemptState `json:"attempts"`
+}
+
+// loadLiveAttemptStates builds the dashboard state of the given attempts of an
+// exam, or of all its attempts when attemptIDs is nil.
+func loadLiveAttemptStates(db *gorm.DB, examID uuid.UUID, attemptIDs []uuid.UUID) ([]liveAttemptState, error) {
+	var exam models.Exam
+	if err := db.Select("id", "duration_minutes").First(&exam, "id = ?", examID).Error; err != nil {
+		return nil, err
+	}
+	var questionsTotal int64
+	if err := db.Model(&models.Question{}).Where("exam_id = ?", examID).Count(&questionsTotal).Error; err != nil {
+		return nil, err
+	}
+
+	q := db.Preload("Student").Where("exam_id = ?", examID)
+	if attemptIDs != nil {
+		q = q.Where("id IN ?", attemptIDs)
+	}
+	var attempts []models.ExamAttempt
+	if err := q.Order("start_time asc").Find(&attempts).Error; err != nil {
+		return nil, err
+	}
+	if len(attempts) == 0 {
+		return []liveAttemptState{}, nil
+	}
+	ids := make([]uuid.UUID, 0, len(attempts))
+	for _, a := range attempts {
+		ids = append(ids, a.ID)
+	}
+
+	type progressRow struct {
+		AttemptID uuid.UUID
+		Answered  int
+		Flagged   int
+	}
+	var rows []progressRow
+	if err := db.Model(&models.StudentAnswer{}).
+		Select("attempt_id, COUNT(*) FILTER (WHERE cardinality(selected_choice_ids) > 0) AS answered, COUNT(*) FILTER (WHERE flagged) AS flagged").
+		Where("attempt_id IN ?", ids).
+		Group("attempt_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	progress := map[uuid.UUID]progressRow{}
+	for _, r := range rows {
+		progress[r.AttemptID] = r
+	}
+
+	integrity, err := countIntegrityEvents(db, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	out := make([]liveAttemptState, 0, len(attempts))
+	for _, a := range attempts {
+		s := liveAttemptState{
+			AttemptID:        a.ID,
+			StudentID:        a.StudentID,
+			FullName:         a.Student.FullName,
+			StartTime:        a.StartTime,
+			QuestionsTotal:   int(questionsTotal),
+			Answered:         progress[a.ID].Answered,
+			Flagged:          progress[a.ID].Flagged,
+			Submitted:        a.Submitted,
+			EndTime:          a.EndTime,
+			FlaggedForReview: a.FlaggedForReview,
+			Paused:           a.PausedAt != nil,
+			ExtraMinutes:     a.ExtraMinutes,
+			Invalidated:      a.Invalidated,
+		}
+		for _, n := range integrity[a.ID] {
+			s.IntegrityEvents += n
+		}
+		if a.Submitted {
+			score := a.Score
+			s.Score = &score
+		} else if deadline, ok := attemptDeadline(a, exam.DurationMinutes); ok {
+			remaining := int64(deadline.Sub(now).Seconds())
+			if remaining < 0 {
+				remaining = 0
+			}
+			s.Deadline = &deadline
+			s.RemainingSeconds = &remaining
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// publishAttemptState publishes the current state of an attempt to its exam's
+// dashboards. Failures are only logged: the change itself has been saved.
+func publishAttemptState(db *gorm.DB, hub *live.Hub, examID, attemptID uuid.UUID, eventType string) {
+	topic := live.ExamTopic(examID)
+	if !hub.HasSubscribers(topic) {
+		return
+	}
+	states, err := loadLiveAttemptStates(db, examID, []uuid.UUID{attemptID})
+	if err != nil {
+		log.Printf("live: failed to load attempt %s: %v", attemptID, err)
+		return
+	}
+	if len(states) == 0 {
+		return
+	}
+	hub.Publish(topic, live.Event{Type: eventType, Data: states[0]})
+}
+
+func publishIntegrityEvents(db *gorm.DB, hub *live.Hub, attempt models.ExamAttempt, events []models.IntegrityEvent) {
+	topic := live.ExamTopic(attempt.ExamID)
+	if !hub.HasSubscribers(topic) {
+		return
+	}
+	for _, e := range events {
+		hub.Publish(topic, live.Event{Type: liveIntegrityEvent, Data: liveIntegrityEventData{
+			AttemptID:  attempt.ID,
+			StudentID:  attempt.StudentID,
+			Type:       e.Type,
+			OccurredAt: e.OccurredAt,
+			Details:    e.Details,
+		}})
+	}
+	// Counts and the review flag may have changed.
+	publishAttemptState(db, hub, attempt.ExamID, attempt.ID, liveAttemptUpdated)
+}
+
+// AdminExamLive streams the live state of an exam as Server-Sent Events. The
+// first event ("snapshot") holds every attempt; later events carry a single
+// attempt's new state or an integrity event as it happens.
+func AdminExamLive(db *gorm.DB, hub *live.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		examID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid exam id"})
+			return
+		}
+
+		// Subscribe before loading the snapshot so no change is missed.
+		events, unsubscribe := hub.Subscribe(live.ExamTopic(examID))
+		defer unsubscribe()
+
+		states, err := loadLiveAttemptStates(db, examID, nil)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"message": "exam not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load attempts"})
+			return
+		}
+		sort.SliceStable(states, func(i, j int) bool { return states[i].FullName < states[j].FullName })
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+
+		c.SSEvent("snapshot", liveSnapshot{ExamID: examID, ServerTime: time.Now().UTC(), Attempts: states})
+		c.Writer.Flush()
+
+		heartbeat := time.NewTicker(liveHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case <-c.Request.Context().Done():
+				return false
+			case e, ok := <-events:
+				if !ok {
+					return false
+				}
+				c.SSEvent(e.Type, e)
+				return true
+			case <-heartbeat.C:
+				_, err := io.WriteString(w, ": ping\n\n")
+				return err == nil
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/letera1/huhems-exam-system/backend/internal/audit"
+	"github.com/letera1/huhems-exam-system/backend/internal/middleware"
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// maxBulkPasswordResets caps how many accounts one bulk reset may cover.
+const maxBulkPasswordResets = 5000
+
+type adminStudentPasswordResetRequest struct {
+	StudentIDs []uuid.UUID `json:"studentIds"`
+	GroupID    *uuid.UUID  `json:"groupId"`
+	Department *string     `json:"department"`
+	Year       *int        `json:"year"`
+}
+
+type adminPasswordResetResponse struct {
+	Reset       int                `json:"reset"`
+	Credentials []issuedCredential `json:"credentials"`
+}
+
+// newTemporaryCredentials returns n temporary passwords and their hashes.
+// Hashing is deliberately slow, so bulk resets spread it over the CPUs.
+func newTemporaryCredentials(n int) (passwords, hashes []string, err error) {
+	passwords = make([]string, n)
+	hashes = make([]string, n)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				p, h, e := newTemporaryCredential()
+				if e != nil {
+					mu.Lock()
+					err = e
+					mu.Unlock()
+					continue
+				}
+				passwords[i], hashes[i] = p, h
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	if err != nil {
+		return nil, nil, err
+	}
+	return passwords, hashes, nil
+}
+
+// checkNoEscalation writes a 403 and returns false if the users hold, between
+// them, a permission the caller lacks. Taking over an account by resetting
+// its credentials must not gain the caller access they do not already have,
+// so resetting an admin takes an admin.
+func checkNoEscalation(c *gin.Context, db *gorm.DB, userIDs []uuid.UUID) bool {
+	held, err := middleware.LoadUsersAccess(db, userIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load permissions"})
+		return false
+	}
+	for _, p := range held.SortedPermissions() {
+		if !middleware.HasPermission(c, p) {
+			c.JSON(http.StatusForbidden, gin.H{"message": "the account holds permission " + p + ", which you do not have"})
+			return false
+		}
+	}
+	return true
+}
+
+// issueTemporaryPasswords gives each user a new temporary password that must
+// be changed at next login, ends their sessions and voids their pending
+// reset links. The passwords are returned in the order of users and are not
+// stored anywhere. It fails with errExternalAccount if any user signs in
+// through an identity provider.
+func issueTemporaryPasswords(db *gorm.DB, users []models.User) ([]string, error) {
+	passwords, hashes, err := newTemporaryCredentials(len(users))
+	if err != nil {
+		return nil, err
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for i, u := range users {
+			res := tx.Model(&models.User{}).Where("id = ? AND auth_provider = ?", u.ID, models.AuthProviderLocal).Updates(map[string]any{
+				"password_hash":        hashes[i],
+				"must_change_password": true,
+			})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return errExternalAccount
+			}
+			if _, err := revokeSessions(tx, u.ID, uuid.Nil, models.SessionRevokedAdminReset); err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("user_id = ? AND used_at IS NULL", u.ID).Delete(&models.PasswordResetToken{}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return passwords, nil
+}
+
+// AdminUserPasswordReset replaces a user's password with a temporary one and
+// returns it once, as JSON or, with ?format=csv, as a CSV handout. The user
+// may hold no permission the caller lacks.
+func AdminUserPasswordReset(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := findUser(c, db)
+		if !ok {
+			return
+		}
+		if user.ID == currentUserID(c) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "use the change password page for your own account"})
+			return
+		}
+		if !hasLocalPassword(user) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "this account signs in through single sign-on; reset the password there"})
+			return
+		}
+		if !checkNoEscalation(c, db, []uuid.UUID{user.ID}) {
+			return
+		}
+		var passwords []string
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if passwords, err = issueTemporaryPasswords(tx, []models.User{user}); err != nil {
+				return err
+			}
+			return audit.Record(tx, c, audit.Entry{
+				Action:     audit.ActionPasswordAdminReset,
+				EntityType: audit.EntityUser,
+				EntityID:   user.ID,
+				After:      gin.H{"username": user.Username},
+			})
+		})
+		if errors.Is(err, errExternalAccount) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "this account signs in through single sign-on; reset the password there"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to reset password"})
+			return
+		}
+
+		cred := issuedCredential{Username: user.Username, Email: user.Email, TemporaryPassword: passwords[0]}
+		var students []models.Student
+		if err := db.Where("user_id = ?", user.ID).Limit(1).Find(&students).Error; err == nil && len(students) > 0 {
+			cred.FullName = students[0].FullName
+		}
+		if wantsCSVHandout(c) {
+			writeCredentialsCSV(c, http.StatusOK, "credentials.csv", []issuedCredential{cred})
+			return
+		}
+		noStore(c)
+		c.JSON(http.StatusOK, cred)
+	}
+}
+
+// AdminStudentsPasswordReset reissues credentials for many students at once,
+// typically before a semester. Students are selected by ID, group,
+// department and year; the given criteria are combined, and at least one is
+// required. Students who sign in through single sign-on or the directory
+// are left out, and the selection may hold no permission the caller lacks.
+// The temporary passwords are returned once, as JSON or, with
+// ?format=csv, as a CSV handout.
+func AdminStudentsPasswordReset(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req adminStudentPasswordResetRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
+			return
+		}
+		if req.Department != nil {
+			d := strings.TrimSpace(*req.Department)
+			req.Department = &d
+		}
+		if len(req.StudentIDs) == 0 && req.GroupID == nil && (req.Department == nil || *req.Department == "") && req.Year == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "select students by studentIds, groupId, department or year"})
+			return
+		}
+
+		q := db.Preload("User").Order("created_at asc").
+			Where("user_id IN (?)", db.Model(&models.User{}).Select("id").Where("auth_provider = ?", models.AuthProviderLocal))
+		if len(req.StudentIDs) > 0 {
+			q = q.Where("id IN ?", req.StudentIDs)
+		}
+		if req.GroupID != nil {
+			q = q.Where("id IN (?)", studentIDsInGroup(db, *req.GroupID))
+		}
+		if req.Department != nil && *req.Department != "" {
+			q = q.Where("LOWER(department) = LOWER(?)", *req.Department)
+		}
+		if req.Year != nil {
+			q = q.Where("year = ?", *req.Year)
+		}
+		var students []models.Student
+		if err := q.Limit(maxBulkPasswordResets + 1).Find(&students).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load students"})
+			return
+		}
+		if len(students) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"message": "no students match the selection"})
+			return
+		}
+		if len(students) > maxBulkPasswordResets {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "selection is too large; narrow it down"})
+			return
+		}
+
+		users := make([]models.User, 0, len(students))
+		userIDs := make([]uuid.UUID, 0, len(students))
+		for _, s := range students {
+			users = append(users, s.User)
+			userIDs = append(userIDs, s.User.ID)
+		}
+		if !checkNoEscalation(c, db, userIDs) {
+			return
+		}
+		usernames := make([]string, 0, len(students))
+		for _, s := range students {
+			usernames = append(usernames, s.User.Username)
+		}
+		started := time.Now()
+		var passwords []string
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if passwords, err = issueTemporaryPasswords(tx, users); err != nil {
+				return err
+			}
+			return audit.Record(tx, c, audit.Entry{
+				Action:     audit.ActionStudentPasswordsReset,
+				EntityType: audit.EntityStudent,
+				After: gin.H{
+					"selection":  req,
+					"reset":      len(students),
+					"usernames":  usernames,
+					"durationMs": time.Since(started).Milliseconds(),
+				},
+			})
+		})
+		if errors.Is(err, errExternalAccount) {
+			c.JSON(http.StatusConflict, gin.H{"message": "a selected student now signs in through single sign-on; try again"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to reset passwords"})
+			return
+		}
+
+		creds := make([]issuedCredential, 0, len(students))
+		for i, s := range students {
+			creds = append(creds, issuedCredential{Username: s.User.Username, Email: s.User.Email, FullName: s.FullName, TemporaryPassword: passwords[i]})
+		}
+
+		if wantsCSVHandout(c) {
+			writeCredentialsCSV(c, http.StatusOK, "student-credentials.csv", creds)
+			return
+		}
+		noStore(c)
+		c.JSON(http.StatusOK, adminPasswordResetResponse{Reset: len(creds), Credentials: creds})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/letera1/huhems-exam-system/backend/internal/audit"
 	"github.com/letera1/huhems-exam-system/backend/internal/models"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -114,7 +115,7 @@ func AdminExamQuestionsCreate(db *gorm.DB) gin.HandlerFunc {
 				return err
 			}
 			question.Choices = choices
-			return nil
+			return audit.Record(tx, c, audit.Entry{Action: audit.ActionQuestionCreate, EntityType: audit.EntityQuestion, EntityID: question.ID, After: question})
 		})
 
 		if err != nil {
@@ -149,6 +150,11 @@ func AdminQuestionsUpdate(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load question"})
 			return
 		}
+		if err := db.Where("question_id = ?", question.ID).Order("\"order\" asc").Find(&question.Choices).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load choices"})
+			return
+		}
+		before := question
 
 		if req.Text != nil {
 			q := strings.TrimSpace(*req.Text)
@@ -217,7 +223,7 @@ func AdminQuestionsUpdate(db *gorm.DB) gin.HandlerFunc {
 				question.Choices = choices
 			}
 
-			return nil
+			return audit.Record(tx, c, audit.Entry{Action: audit.ActionQuestionUpdate, EntityType: audit.EntityQuestion, EntityID: question.ID, Before: before, After: question})
 		})
 
 		// Handle validation errors bubbled via gin.Error.
@@ -230,11 +236,6 @@ func AdminQuestionsUpdate(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Return current question + choices.
-		var choices []models.Choice
-		_ = db.Where("question_id = ?", question.ID).Order("\"order\" asc").Find(&choices).Error
-		question.Choices = choices
-
 		c.JSON(http.StatusOK, question)
 	}
 }
@@ -247,6 +248,13 @@ func AdminQuestionsDelete(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		// Loaded only for the audit snapshot; deleting a missing question is a no-op.
+		var questions []models.Question
+		if err := db.Preload("Choices").Where("id = ?", questionID).Limit(1).Find(&questions).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load question"})
+			return
+		}
+
 		err = db.Transaction(func(tx *gorm.DB) error {
 			if err := tx.Where("question_id = ?", questionID).Delete(&models.Choice{}).Error; err != nil {
 				return err
@@ -254,7 +262,10 @@ func AdminQuestionsDelete(db *gorm.DB) gin.HandlerFunc {
 			if err := tx.Delete(&models.Question{}, "id = ?", questionID).Error; err != nil {
 				return err
 			}
-			return nil
+			if len(questions) == 0 {
+				return nil
+			}
+			return audit.Record(tx, c, audit.Entry{Action: audit.ActionQuestionDelete, EntityType: audit.EntityQuestion, EntityID: questionID, Before: questions[0]})
 		})
 
 		if err != nil {
@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/letera1/huhems-exam-system/backend/internal/audit"
 	"github.com/letera1/huhems-exam-system/backend/internal/models"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -345,7 +346,12 @@ func AdminExamQuestionsImportCSV(db *gorm.DB) gin.HandlerFunc {
 					return err
 				}
 			}
-			return nil
+			return audit.Record(tx, c, audit.Entry{
+				Action:     audit.ActionQuestionImport,
+				EntityType: audit.EntityExam,
+				EntityID:   examID,
+				After:      gin.H{"file": header.Filename, "createdQuestions": len(payloads)},
+			})
 		})
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to import questions: " + err.Error()})
@@ -3,6 +3,8 @@ package controllers
 import (
 	"net/http"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/letera1/huhems-exam-system/backend/internal/models"
 	"github.com/gin-gonic/gin"
@@ -22,6 +24,10 @@ type examReportResponse struct {
 	AnswersTotal    int              `json:"answersTotal"`
 	CorrectTotal    int              `json:"correctTotal"`
 	QuestionReports []questionReport `json:"questionReports"`
+
+	// FastAnswerers lists submitted attempts whose median time per answered
+	// question is below fastSeconds (query parameter, default 5).
+	FastAnswerers []fastAnswerer `json:"fastAnswerers"`
 }
 
 type questionReport struct {
@@ -33,6 +39,8 @@ type questionReport struct {
 	CorrectTotal int `json:"correctTotal"`
 
 	ChoiceCounts []choiceCount `json:"choiceCounts"`
+
+	questionAnswerBehavior
 }
 
 type choiceCount struct {
@@ -51,14 +59,38 @@ func AdminExamReport(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		fastSeconds := defaultFastAnswerSeconds
+		if v := strings.TrimSpace(c.Query("fastSeconds")); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || f <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "invalid fastSeconds"})
+				return
+			}
+			fastSeconds = f
+		}
+
+		groupID, ok := parseGroupFilter(c)
+		if !ok {
+			return
+		}
+		// examAttempts scopes attempt queries to the exam and, when filtering
+		// by group, to the group's students.
+		examAttempts := func() *gorm.DB {
+			q := db.Model(&models.ExamAttempt{}).Where("exam_id = ?", examID)
+			if groupID != uuid.Nil {
+				q = q.Where("student_id IN (?)", studentIDsInGroup(db, groupID))
+			}
+			return q
+		}
+
 		// Attempts summary
 		var attemptsTotal int64
-		if err := db.Model(&models.ExamAttempt{}).Where("exam_id = ?", examID).Count(&attemptsTotal).Error; err != nil {
+		if err := examAttempts().Count(&attemptsTotal).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load attempts"})
 			return
 		}
 		var submittedTotal int64
-		if err := db.Model(&models.ExamAttempt{}).Where("exam_id = ? AND submitted = true", examID).Count(&submittedTotal).Error; err != nil {
+		if err := examAttempts().Where("submitted = true AND invalidated = false").Count(&submittedTotal).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load attempts"})
 			return
 		}
@@ -67,9 +99,9 @@ func AdminExamReport(db *gorm.DB) gin.HandlerFunc {
 		minScore := 0.0
 		maxScore := 0.0
 		if submittedTotal > 0 {
-			row := db.Model(&models.ExamAttempt{}).
+			row := examAttempts().
 				Select("COALESCE(AVG(score), 0) as avg, COALESCE(MIN(score), 0) as min, COALESCE(MAX(score), 0) as max").
-				Where("exam_id = ? AND submitted = true", examID).Row()
+				Where("submitted = true AND invalidated = false").Row()
 			_ = row.Scan(&avgScore, &minScore, &maxScore)
 		}
 
@@ -111,7 +143,7 @@ func AdminExamReport(db *gorm.DB) gin.HandlerFunc {
 		attemptIDs := []uuid.UUID{}
 		if submittedTotal > 0 {
 			var attempts []models.ExamAttempt
-			if err := db.Select("id").Where("exam_id = ? AND submitted = true", examID).Find(&attempts).Error; err != nil {
+			if err := examAttempts().Select("id").Where("submitted = true AND invalidated = false").Find(&attempts).Error; err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load submitted attempts"})
 				return
 			}
@@ -133,6 +165,12 @@ func AdminExamReport(db *gorm.DB) gin.HandlerFunc {
 			}
 		}
 
+		behavior, fast, err := answerBehavior(db, attemptIDs, questions, correctSets, fastSeconds)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load answer events"})
+			return
+		}
+
 		resp := examReportResponse{
 			ExamID:         examID,
 			AttemptsTotal:  attemptsTotal,
@@ -141,6 +179,7 @@ func AdminExamReport(db *gorm.DB) gin.HandlerFunc {
 			MinScore:       minScore,
 			MaxScore:       maxScore,
 			QuestionsTotal: len(questions),
+			FastAnswerers:  fast,
 		}
 
 		correctTotal := 0
@@ -184,6 +223,8 @@ func AdminExamReport(db *gorm.DB) gin.HandlerFunc {
 				AnswersTotal: len(qAnswers),
 				CorrectTotal: qCorrect,
 				ChoiceCounts: choiceCounts,
+
+				questionAnswerBehavior: behavior[q.ID],
 			})
 		}
 
@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// defaultFastAnswerSeconds is the median time per answered question below
+// which an attempt is reported as implausibly fast.
+const defaultFastAnswerSeconds = 5.0
+
+type questionAnswerBehavior struct {
+	// MedianTimeSeconds is the median total time spent on the question per
+	// attempt. Nil when no answer events were recorded.
+	MedianTimeSeconds *float64 `json:"medianTimeSeconds"`
+
+	AnswerChanges     int     `json:"answerChanges"`
+	WrongToRight      int     `json:"wrongToRight"`
+	RightToWrong      int     `json:"rightToWrong"`
+	WrongToWrong      int     `json:"wrongToWrong"`
+	WrongToRightShare float64 `json:"wrongToRightShare"`
+	RightToWrongShare float64 `json:"rightToWrongShare"`
+}
+
+type fastAnswerer struct {
+	AttemptID                uuid.UUID `json:"attemptId"`
+	StudentID                uuid.UUID `json:"studentId"`
+	FullName                 string    `json:"fullName"`
+	QuestionsAnswered        int       `json:"questionsAnswered"`
+	MedianSecondsPerQuestion float64   `json:"medianSecondsPerQuestion"`
+	Score                    float64   `json:"score"`
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[mid]
+	}
+	return (sorted[mid-1] + sorted[mid]) / 2
+}
+
+// answerBehavior analyses the answer event log of the given submitted attempts.
+//
+// Time on a question is the sum, over its events, of the client-reported
+// visible time, falling back to the server-side gap since the previous event
+// of the attempt (or the attempt start) when the client did not report one.
+// Answer changes are transitions between consecutive non-empty selections.
+func answerBehavior(db *gorm.DB, attemptIDs []uuid.UUID, questions []models.Question, correctSets map[uuid.UUID]map[string]struct{}, fastSeconds float64) (map[uuid.UUID]questionAnswerBehavior, []fastAnswerer, error) {
+	behavior := map[uuid.UUID]questionAnswerBehavior{}
+	fast := []fastAnswerer{}
+	if len(attemptIDs) == 0 {
+		return behavior, fast, nil
+	}
+
+	type attemptRow struct {
+		ID        uuid.UUID
+		StudentID uuid.UUID
+		FullName  string
+		Score     float64
+		StartTime time.Time
+	}
+	var attempts []attemptRow
+	if err := db.Model(&models.ExamAttempt{}).
+		Select("exam_attempts.id as id, exam_attempts.student_id as student_id, students.full_name as full_name, exam_attempts.score as score, exam_attempts.start_time as start_time").
+		Joins("JOIN students ON students.id = exam_attempts.student_id").
+		Where("exam_attempts.id IN ?", attemptIDs).
+		Scan(&attempts).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var events []models.AnswerEvent
+	if err := db.Where("attempt_id IN ?", attemptIDs).Order("created_at asc").Find(&events).Error; err != nil {
+		return nil, nil, err
+	}
+	eventsByAttempt := map[uuid.UUID][]models.AnswerEvent{}
+	for _, e := range events {
+		eventsByAttempt[e.AttemptID] = append(eventsByAttempt[e.AttemptID], e)
+	}
+
+	questionTypes := map[uuid.UUID]string{}
+	for _, q := range questions {
+		questionTypes[q.ID] = q.Type
+	}
+
+	timesByQuestion := map[uuid.UUID][]float64{}
+	changes := map[uuid.UUID]*questionAnswerBehavior{}
+	for _, q := range questions {
+		changes[q.ID] = &questionAnswerBehavior{}
+	}
+
+	for _, a := range attempts {
+		attemptEvents := eventsByAttempt[a.ID]
+		secondsByQuestion := map[uuid.UUID]float64{}
+		lastSelection := map[uuid.UUID][]string{}
+		prev := a.StartTime
+
+		for _, e := range attemptEvents {
+			var seconds float64
+			if e.VisibleMs != nil {
+				seconds = float64(*e.VisibleMs) / 1000.0
+			} else {
+				seconds = e.CreatedAt.Sub(prev).Seconds()
+			}
+			if seconds < 0 {
+				seconds = 0
+			}
+			prev = e.CreatedAt
+
+			qType, ok := questionTypes[e.QuestionID]
+			if !ok {
+				continue
+			}
+			secondsByQuestion[e.QuestionID] += seconds
+
+			if len(e.SelectedChoiceIDs) == 0 {
+				continue
+			}
+			if before, ok := lastSelection[e.QuestionID]; ok && !sameSelection(before, e.SelectedChoiceIDs) {
+				wasRight := isAnswerCorrect(qType, correctSets[e.QuestionID], before)
+				isRight := isAnswerCorrect(qType, correctSets[e.QuestionID], e.SelectedChoiceIDs)
+				b := changes[e.QuestionID]
+				b.AnswerChanges++
+				switch {
+				case !wasRight && isRight:
+					b.WrongToRight++
+				case wasRight && !isRight:
+					b.RightToWrong++
+				case !wasRight && !isRight:
+					b.WrongToWrong++
+				}
+			}
+			lastSelection[e.QuestionID] = e.SelectedChoiceIDs
+		}
+
+		perQuestion := make([]float64, 0, len(secondsByQuestion))
+		for qid, s := range secondsByQuestion {
+			timesByQuestion[qid] = append(timesByQuestion[qid], s)
+			perQuestion = append(perQuestion, s)
+		}
+
+		if len(perQuestion) > 0 {
+			m := median(perQuestion)
+			if m < fastSeconds {
+				fast = append(fast, fastAnswerer{
+					AttemptID:                a.ID,
+					StudentID:                a.StudentID,
+					FullName:                 a.FullName,
+					QuestionsAnswered:        len(lastSelection),
+					MedianSecondsPerQuestion: m,
+					Score:                    a.Score,
+				})
+			}
+		}
+	}
+
+	for _, q := range questions {
+		b := *changes[q.ID]
+		if times := timesByQuestion[q.ID]; len(times) > 0 {
+			m := median(times)
+			b.MedianTimeSeconds = &m
+		}
+		if b.AnswerChanges > 0 {
+			b.WrongToRightShare = float64(b.WrongToRight) / float64(b.AnswerChanges)
+			b.RightToWrongShare = float64(b.RightToWrong) / float64(b.AnswerChanges)
+		}
+		behavior[q.ID] = b
+	}
+
+	sort.Slice(fast, func(i, j int) bool { return fast[i].MedianSecondsPerQuestion < fast[j].MedianSecondsPerQuestion })
+	return behavior, fast, nil
+}
+
+func sameSelection(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := map[string]struct{}{}
+	for _, id := range a {
+		set[id] = struct{}{}
+	}
+	for _, id := range b {
+		if _, ok := set[id]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,560 @@
+package controllers
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/letera1/huhems-exam-system/backend/internal/audit"
+	"github.com/letera1/huhems-exam-system/backend/internal/middleware"
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,31}$`)
+
+type adminRoleView struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Builtin     bool      `json:"builtin"`
+	Permissions []string  `json:"permissions"`
+	UserCount   int       `json:"userCount"`
+}
+
+type adminRoleRequest struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+type adminRolePermissionsRequest struct {
+	Permissions []string `json:"permissions"`
+}
+
+type adminUserRolesView struct {
+	ID          uuid.UUID  `json:"id"`
+	Username    string     `json:"username"`
+	Email       string     `json:"email"`
+	PrimaryRole string     `json:"primaryRole"`
+	Roles       []string   `json:"roles"`
+	LastLoginAt *time.Time `json:"lastLoginAt"`
+}
+
+type adminUserRolesRequest struct {
+	Roles []string `json:"roles"`
+}
+
+func isBuiltinRole(name string) bool {
+	for _, r := range models.BuiltinRoles {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizePermissions validates, de-duplicates and sorts permission names.
+func normalizePermissions(in []string) ([]string, error) {
+	seen := map[string]bool{}
+	out := make([]string, 0, len(in))
+	for _, p := range in {
+		p = strings.TrimSpace(p)
+		if !models.IsPermission(p) {
+			return nil, errInvalid("unknown permission " + p)
+		}
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// checkRolePermissions rejects permissions on roles that cannot hold them.
+func checkRolePermissions(role string, perms []string) error {
+	switch {
+	case role == models.RoleAdmin:
+		return errInvalid("the admin role always holds every permission")
+	case role == models.RoleStudent && len(perms) > 0:
+		return errInvalid("the student role cannot hold permissions")
+	}
+	return nil
+}
+
+func findRole(c *gin.Context, db *gorm.DB) (models.Role, bool) {
+	roleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid role id"})
+		return models.Role{}, false
+	}
+	var role models.Role
+	if err := db.First(&role, "id = ?", roleID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "role not found"})
+			return models.Role{}, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load role"})
+		return models.Role{}, false
+	}
+	return role, true
+}
+
+func loadRoleViews(db *gorm.DB, roles []models.Role) ([]adminRoleView, error) {
+	out := make([]adminRoleView, 0, len(roles))
+	if len(roles) == 0 {
+		return out, nil
+	}
+	ids := make([]uuid.UUID, 0, len(roles))
+	for _, r := range roles {
+		ids = append(ids, r.ID)
+	}
+
+	var perms []models.RolePermission
+	if err := db.Where("role_id IN ?", ids).Order("permission asc").Find(&perms).Error; err != nil {
+		return nil, err
+	}
+	byRole := map[uuid.UUID][]string{}
+	for _, p := range perms {
+		byRole[p.RoleID] = append(byRole[p.RoleID], p.Permission)
+	}
+
+	type countRow struct {
+		RoleID uuid.UUID
+		Count  int
+	}
+	var counts []countRow
+	if err := db.Raw(`SELECT role_id, COUNT(DISTINCT user_id) AS count FROM (
+			SELECT role_id, id AS user_id FROM users WHERE deleted_at IS NULL
+			UNION ALL
+			SELECT role_id, user_id FROM user_roles WHERE deleted_at IS NULL
+		) r WHERE role_id IN ? GROUP BY role_id`, ids).Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	users := map[uuid.UUID]int{}
+	for _, r := range counts {
+		users[r.RoleID] = r.Count
+	}
+
+	for _, r := range roles {
+		view := adminRoleView{ID: r.ID, Name: r.Name, Builtin: isBuiltinRole(r.Name), Permissions: byRole[r.ID], UserCount: users[r.ID]}
+		if r.Name == models.RoleAdmin {
+			view.Permissions = make([]string, 0, len(models.Permissions))
+			for _, p := range models.Permissions {
+				view.Permissions = append(view.Permissions, p.Name)
+			}
+		}
+		if view.Permissions == nil {
+			view.Permissions = []string{}
+		}
+		out = append(out, view)
+	}
+	return out, nil
+}
+
+// AdminPermissionsList lists every permission that can be granted.
+func AdminPermissionsList(c *gin.Context) {
+	c.JSON(http.StatusOK, models.Permissions)
+}
+
+// AdminRolesList lists roles with their permissions and how many users hold
+// them.
+func AdminRolesList(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var roles []models.Role
+		if err := db.Order("name asc").Find(&roles).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load roles"})
+			return
+		}
+		resp, err := loadRoleViews(db, roles)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load roles"})
+			return
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// AdminRolesCreate creates a custom role.
+func AdminRolesCreate(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req adminRoleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
+			return
+		}
+		req.Name = strings.ToLower(strings.TrimSpace(req.Name))
+		if !roleNamePattern.MatchString(req.Name) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "name must be 2-32 lowercase letters, digits, '-' or '_', starting with a letter"})
+			return
+		}
+		perms, err := normalizePermissions(req.Permissions)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		var n int64
+		if err := db.Unscoped().Model(&models.Role{}).Where("name = ?", req.Name).Count(&n).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to check role name"})
+			return
+		}
+		if n > 0 {
+			c.JSON(http.StatusConflict, gin.H{"message": "a role with this name already exists"})
+			return
+		}
+
+		role := models.Role{Name: req.Name}
+		var views []adminRoleView
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&role).Error; err != nil {
+				return err
+			}
+			if len(perms) > 0 {
+				rows := make([]models.RolePermission, 0, len(perms))
+				for _, p := range perms {
+					rows = append(rows, models.RolePermission{RoleID: role.ID, Permission: p})
+				}
+				if err := tx.Create(&rows).Error; err != nil {
+					return err
+				}
+			}
+			if views, err = loadRoleViews(tx, []models.Role{role}); err != nil {
+				return err
+			}
+			return audit.Record(tx, c, audit.Entry{Action: audit.ActionRoleCreate, EntityType: audit.EntityRole, EntityID: role.ID, After: views[0]})
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to create role"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, views[0])
+	}
+}
+
+// AdminRolePermissionsPut replaces the permissions of a role. Changes apply
+// to the role's users on their next request.
+func AdminRolePermissionsPut(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, ok := findRole(c, db)
+		if !ok {
+			return
+		}
+		var req adminRolePermissionsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
+			return
+		}
+		perms, err := normalizePermissions(req.Permissions)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		if err := checkRolePermissions(role.Name, perms); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		var before []string
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&models.RolePermission{}).Where("role_id = ?", role.ID).Order("permission asc").Pluck("permission", &before).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("role_id = ?", role.ID).Delete(&models.RolePermission{}).Error; err != nil {
+				return err
+			}
+			if len(perms) > 0 {
+				rows := make([]models.RolePermission, 0, len(perms))
+				for _, p := range perms {
+					rows = append(rows, models.RolePermission{RoleID: role.ID, Permission: p})
+				}
+				if err := tx.Create(&rows).Error; err != nil {
+					return err
+				}
+			}
+			return audit.Record(tx, c, audit.Entry{
+				Action:     audit.ActionRolePermissionsSet,
+				EntityType: audit.EntityRole,
+				EntityID:   role.ID,
+				Before:     gin.H{"name": role.Name, "permissions": before},
+				After:      gin.H{"name": role.Name, "permissions": perms},
+			})
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to save permissions"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"permissions": perms})
+	}
+}
+
+// AdminRolesDelete deletes a custom role that no user holds.
+func AdminRolesDelete(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, ok := findRole(c, db)
+		if !ok {
+			return
+		}
+		if isBuiltinRole(role.Name) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "built-in roles cannot be deleted"})
+			return
+		}
+		views, err := loadRoleViews(db, []models.Role{role})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load role"})
+			return
+		}
+		if views[0].UserCount > 0 {
+			c.JSON(http.StatusConflict, gin.H{"message": "role is assigned to users; remove it from them first"})
+			return
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Unscoped().Where("role_id = ?", role.ID).Delete(&models.RolePermission{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Delete(&models.Role{}, "id = ?", role.ID).Error; err != nil {
+				return err
+			}
+			return audit.Record(tx, c, audit.Entry{Action: audit.ActionRoleDelete, EntityType: audit.EntityRole, EntityID: role.ID, Before: views[0]})
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to delete role"})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// countAdmins counts the users holding the admin role, as their primary role
+// or an additional one.
+func countAdmins(db *gorm.DB) (int64, error) {
+	var admins int64
+	err := db.Model(&models.User{}).
+		Where("role_id IN (SELECT id FROM roles WHERE name = ?) OR id IN (SELECT user_id FROM user_roles JOIN roles ON roles.id = user_roles.role_id WHERE roles.name = ? AND user_roles.deleted_at IS NULL)", models.RoleAdmin, models.RoleAdmin).
+		Count(&admins).Error
+	return admins, err
+}
+
+func loadUserRoleViews(db *gorm.DB, users []models.User) ([]adminUserRolesView, error) {
+	out := make([]adminUserRolesView, 0, len(users))
+	if len(users) == 0 {
+		return out, nil
+	}
+	ids := make([]uuid.UUID, 0, len(users))
+	for _, u := range users {
+		ids = append(ids, u.ID)
+	}
+	var extra []models.UserRole
+	if err := db.Preload("Role").Where("user_id IN ?", ids).Find(&extra).Error; err != nil {
+		return nil, err
+	}
+	byUser := map[uuid.UUID][]string{}
+	for _, r := range extra {
+		byUser[r.UserID] = append(byUser[r.UserID], r.Role.Name)
+	}
+	for _, u := range users {
+		roles := append([]string{u.Role.Name}, byUser[u.ID]...)
+		sort.Strings(roles)
+		out = append(out, adminUserRolesView{
+			ID:          u.ID,
+			Username:    u.Username,
+			Email:       u.Email,
+			PrimaryRole: u.Role.Name,
+			Roles:       roles,
+			LastLoginAt: u.LastLoginAt,
+		})
+	}
+	return out, nil
+}
+
+// AdminUsersList lists staff accounts with their roles. Pass role=<name> to
+// list only holders of that role.
+func AdminUsersList(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q := db.Preload("Role").
+			Joins("JOIN roles ON roles.id = users.role_id").
+			Where("roles.name <> ?", models.RoleStudent).
+			Order("users.username asc")
+		if role := strings.TrimSpace(c.Query("role")); role != "" {
+			q = q.Where("roles.name = ? OR users.id IN (SELECT user_roles.user_id FROM user_roles JOIN roles r ON r.id = user_roles.role_id WHERE r.name = ? AND user_roles.deleted_at IS NULL)", role, role)
+		}
+		var users []models.User
+		if err := q.Find(&users).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load users"})
+			return
+		}
+		resp, err := loadUserRoleViews(db, users)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load roles"})
+			return
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// AdminUserRolesPut replaces the roles of a staff account. The primary role
+// is kept if it is still listed; otherwise the first listed role becomes
+// primary, which the frontend picks up at the user's next sign-in.
+func AdminUserRolesPut(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid user id"})
+			return
+		}
+		var req adminUserRolesRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
+			return
+		}
+
+		var user models.User
+		if err := db.Preload("Role").First(&user, "id = ?", userID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"message": "user not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load user"})
+			return
+		}
+		if user.Role.Name == models.RoleStudent {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "student accounts cannot be given staff roles"})
+			return
+		}
+
+		names := make([]string, 0, len(req.Roles))
+		seen := map[string]bool{}
+		for _, name := range req.Roles {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name != "" && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "at least one role is required"})
+			return
+		}
+		if seen[models.RoleStudent] {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "staff accounts cannot be given the student role"})
+			return
+		}
+		var roles []models.Role
+		if err := db.Where("name IN ?", names).Find(&roles).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load roles"})
+			return
+		}
+		if len(roles) != len(names) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "unknown role"})
+			return
+		}
+		byName := make(map[string]models.Role, len(roles))
+		for _, r := range roles {
+			byName[r.Name] = r
+		}
+
+		before, err := loadUserRoleViews(db, []models.User{user})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load roles"})
+			return
+		}
+		wasAdmin := false
+		for _, r := range before[0].Roles {
+			wasAdmin = wasAdmin || r == models.RoleAdmin
+		}
+		if wasAdmin && !seen[models.RoleAdmin] {
+			admins, err := countAdmins(db)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to count admins"})
+				return
+			}
+			if admins <= 1 {
+				c.JSON(http.StatusConflict, gin.H{"message": "cannot remove the admin role from the last admin"})
+				return
+			}
+		}
+
+		primary := user.Role
+		if !seen[primary.Name] {
+			primary = byName[names[0]]
+		}
+		var after []adminUserRolesView
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if primary.ID != user.RoleID {
+				if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Update("role_id", primary.ID).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.UserRole{}).Error; err != nil {
+				return err
+			}
+			rows := make([]models.UserRole, 0, len(names))
+			for _, name := range names {
+				if name != primary.Name {
+					rows = append(rows, models.UserRole{UserID: user.ID, RoleID: byName[name].ID})
+				}
+			}
+			if len(rows) > 0 {
+				if err := tx.Create(&rows).Error; err != nil {
+					return err
+				}
+			}
+
+			user.RoleID, user.Role = primary.ID, primary
+			if after, err = loadUserRoleViews(tx, []models.User{user}); err != nil {
+				return err
+			}
+			return audit.Record(tx, c, audit.Entry{
+				Action:     audit.ActionUserRolesSet,
+				EntityType: audit.EntityUser,
+				EntityID:   user.ID,
+				Before:     before[0],
+				After:      after[0],
+			})
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to save roles"})
+			return
+		}
+
+		c.JSON(http.StatusOK, after[0])
+	}
+}
+
+// AdminUserAccessGet returns a user's roles and effective permissions.
+func AdminUserAccessGet(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid user id"})
+			return
+		}
+		var user models.User
+		if err := db.Preload("Role").First(&user, "id = ?", userID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"message": "user not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load user"})
+			return
+		}
+		views, err := loadUserRoleViews(db, []models.User{user})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load roles"})
+			return
+		}
+		access, err := middleware.LoadUserAccess(db, user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load permissions"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"user": views[0], "permissions": access.SortedPermissions()})
+	}
+}
@@ -0,0 +1,559 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/letera1/huhems-exam-system/backend/internal/audit"
+	"github.com/letera1/huhems-exam-system/backend/internal/middleware"
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultSimilarityMaxK         = 0.001
+	defaultSimilarityMinIdentical = 3
+
+	// A run still marked running after this long is assumed to have died with
+	// the process and no longer blocks new runs.
+	similarityRunStaleAfter = time.Hour
+)
+
+type similarityAttempt struct {
+	id        uuid.UUID
+	studentID uuid.UUID
+	// answers[i] is the normalized selection for question i ("" if blank).
+	answers []string
+	wrong   []bool
+	wrongs  int
+}
+
+// selectionKey normalizes a selection so that identical choices compare equal
+// regardless of order.
+func selectionKey(ids []string) string {
+	if len(ids) == 0 {
+		return ""
+	}
+	sorted := append([]string(nil), ids...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
+
+// binomialUpperTail returns P(X >= k) for X ~ Binomial(n, p).
+func binomialUpperTail(n, k int, p float64) float64 {
+	if k <= 0 {
+		return 1
+	}
+	if k > n {
+		return 0
+	}
+	lgN, _ := math.Lgamma(float64(n + 1))
+	sum := 0.0
+	for i := k; i <= n; i++ {
+		lgI, _ := math.Lgamma(float64(i + 1))
+		lgNI, _ := math.Lgamma(float64(n - i + 1))
+		sum += math.Exp(lgN - lgI - lgNI + float64(i)*math.Log(p) + float64(n-i)*math.Log1p(-p))
+	}
+	return math.Min(sum, 1)
+}
+
+// matchRateModel predicts, for a fixed source attempt, the share of the
+// source's wrong answers another attempt matches by chance, as a linear
+// function of that attempt's proportion of wrong answers. This is the
+// regression used by the K-index family of statistics (Holland 1996).
+type matchRateModel struct {
+	intercept float64
+	slope     float64
+}
+
+func fitMatchRate(xs, ys []float64) matchRateModel {
+	if len(xs) == 0 {
+		return matchRateModel{}
+	}
+	var sx, sy float64
+	for i := range xs {
+		sx += xs[i]
+		sy += ys[i]
+	}
+	n := float64(len(xs))
+	mx, my := sx/n, sy/n
+	var sxx, sxy float64
+	for i := range xs {
+		sxx += (xs[i] - mx) * (xs[i] - mx)
+		sxy += (xs[i] - mx) * (ys[i] - my)
+	}
+	if sxx == 0 {
+		return matchRateModel{intercept: my}
+	}
+	slope := sxy / sxx
+	return matchRateModel{intercept: my - slope*mx, slope: slope}
+}
+
+func (m matchRateModel) predict(x float64) float64 {
+	p := m.intercept + m.slope*x
+	// Keep p away from 0 and 1 so the binomial tail stays meaningful.
+	return math.Min(math.Max(p, 1e-4), 1-1e-4)
+}
+
+type similarityResult struct {
+	a, b             int
+	identicalWrong   int
+	identicalAnswers int
+	kIndex           float64
+}
+
+// computeSimilarity scores every pair of attempts by different students. For
+// a source attempt s and a candidate copier c, K(c|s) is the probability of c
+// matching at least as many of s's wrong answers as observed, given the match
+// rate expected for someone with c's number of wrong answers. A pair is
+// scored with the smaller of K(a|b) and K(b|a).
+func computeSimilarity(attempts []similarityAttempt, questionsTotal int) []similarityResult {
+	n := len(attempts)
+	if n < 2 || questionsTotal == 0 {
+		return nil
+	}
+
+	identicalWrong := make([][]int, n)
+	identicalAnswers := make([][]int, n)
+	for i := range attempts {
+		identicalWrong[i] = make([]int, n)
+		identicalAnswers[i] = make([]int, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			for q := 0; q < questionsTotal; q++ {
+				ai, aj := attempts[i].answers[q], attempts[j].answers[q]
+				if ai == "" || ai != aj {
+					continue
+				}
+				identicalAnswers[i][j]++
+				if attempts[i].wrong[q] {
+					identicalWrong[i][j]++
+				}
+			}
+			identicalWrong[j][i] = identicalWrong[i][j]
+			identicalAnswers[j][i] = identicalAnswers[i][j]
+		}
+	}
+
+	// Fit one match-rate model per source attempt, excluding the student's
+	// own other attempts.
+	rates := make([]matchRateModel, n)
+	for s := range attempts {
+		if attempts[s].wrongs == 0 {
+			continue
+		}
+		var xs, ys []float64
+		for j := range attempts {
+			if j == s || attempts[j].studentID == attempts[s].studentID {
+				continue
+			}
+			xs = append(xs, float64(attempts[j].wrongs)/float64(questionsTotal))
+			ys = append(ys, float64(identicalWrong[s][j])/float64(attempts[s].wrongs))
+		}
+		rates[s] = fitMatchRate(xs, ys)
+	}
+
+	kIndex := func(copier, source int) float64 {
+		w := attempts[source].wrongs
+		if w == 0 {
+			return 1
+		}
+		p := rates[source].predict(float64(attempts[copier].wrongs) / float64(questionsTotal))
+		return binomialUpperTail(w, identicalWrong[copier][source], p)
+	}
+
+	var out []similarityResult
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			if attempts[i].studentID == attempts[j].studentID || identicalWrong[i][j] == 0 {
+				continue
+			}
+			out = append(out, similarityResult{
+				a:                i,
+				b:                j,
+				identicalWrong:   identicalWrong[i][j],
+				identicalAnswers: identicalAnswers[i][j],
+				kIndex:           math.Min(kIndex(i, j), kIndex(j, i)),
+			})
+		}
+	}
+	return out
+}
+
+func loadSimilarityAttempts(db *gorm.DB, examID uuid.UUID) ([]similarityAttempt, int, error) {
+	var questions []models.Question
+	if err := db.Select("id", "type").Where("exam_id = ?", examID).Order("created_at asc").Find(&questions).Error; err != nil {
+		return nil, 0, err
+	}
+	questionIndex := map[uuid.UUID]int{}
+	questionIDs := make([]uuid.UUID, 0, len(questions))
+	for i, q := range questions {
+		questionIndex[q.ID] = i
+		questionIDs = append(questionIDs, q.ID)
+	}
+
+	correctSets := map[uuid.UUID]map[string]struct{}{}
+	if len(questionIDs) > 0 {
+		var choices []models.Choice
+		if err := db.Select("id", "question_id").Where("question_id IN ? AND is_correct = true", questionIDs).Find(&choices).Error; err != nil {
+			return nil, 0, err
+		}
+		for _, ch := range choices {
+			set := correctSets[ch.QuestionID]
+			if set == nil {
+				set = map[string]struct{}{}
+				correctSets[ch.QuestionID] = set
+			}
+			set[ch.ID.String()] = struct{}{}
+		}
+	}
+
+	var attempts []models.ExamAttempt
+	if err := db.Select("id", "student_id").Where("exam_id = ? AND submitted = true AND invalidated = false", examID).Order("created_at asc").Find(&attempts).Error; err != nil {
+		return nil, 0, err
+	}
+	out := make([]similarityAttempt, 0, len(attempts))
+	byID := map[uuid.UUID]int{}
+	attemptIDs := make([]uuid.UUID, 0, len(attempts))
+	for _, a := range attempts {
+		byID[a.ID] = len(out)
+		attemptIDs = append(attemptIDs, a.ID)
+		out = append(out, similarityAttempt{
+			id:        a.ID,
+			studentID: a.StudentID,
+			answers:   make([]string, len(questions)),
+			wrong:     make([]bool, len(questions)),
+		})
+	}
+	if len(attemptIDs) == 0 {
+		return out, len(questions), nil
+	}
+
+	var answers []models.StudentAnswer
+	if err := db.Select("attempt_id", "question_id", "selected_choice_ids").Where("attempt_id IN ?", attemptIDs).Find(&answers).Error; err != nil {
+		return nil, 0, err
+	}
+	for _, ans := range answers {
+		qi, ok := questionIndex[ans.QuestionID]
+		if !ok || len(ans.SelectedChoiceIDs) == 0 {
+			continue
+		}
+		a := &out[byID[ans.AttemptID]]
+		a.answers[qi] = selectionKey(ans.SelectedChoiceIDs)
+		if !isAnswerCorrect(questions[qi].Type, correctSets[ans.QuestionID], ans.SelectedChoiceIDs) {
+			a.wrong[qi] = true
+			a.wrongs++
+		}
+	}
+	return out, len(questions), nil
+}
+
+// runSimilarity computes the pairs for run and stores them. It is meant to be
+// run in its own goroutine.
+func runSimilarity(db *gorm.DB, run models.SimilarityRun) {
+	fail := func(err error) {
+		log.Printf("similarity run %s failed: %v", run.ID, err)
+		now := time.Now().UTC()
+		_ = db.Model(&models.SimilarityRun{}).Where("id = ?", run.ID).
+			Updates(map[string]any{"status": string(models.SimilarityRunFailed), "error": err.Error(), "completed_at": &now}).Error
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			fail(fmt.Errorf("panic: %v", r))
+		}
+	}()
+
+	attempts, questionsTotal, err := loadSimilarityAttempts(db, run.ExamID)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	var pairs []models.SimilarityPair
+	for _, r := range computeSimilarity(attempts, questionsTotal) {
+		if r.identicalWrong < run.MinIdentical || r.kIndex > run.MaxK {
+			continue
+		}
+		pairs = append(pairs, models.SimilarityPair{
+			RunID:            run.ID,
+			AttemptAID:       attempts[r.a].id,
+			AttemptBID:       attempts[r.b].id,
+			IdenticalWrong:   r.identicalWrong,
+			IdenticalAnswers: r.identicalAnswers,
+			WrongA:           attempts[r.a].wrongs,
+			WrongB:           attempts[r.b].wrongs,
+			KIndex:           r.kIndex,
+		})
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if len(pairs) > 0 {
+			if err := tx.CreateInBatches(&pairs, 500).Error; err != nil {
+				return err
+			}
+		}
+		now := time.Now().UTC()
+		return tx.Model(&models.SimilarityRun{}).Where("id = ?", run.ID).Updates(map[string]any{
+			"status":            string(models.SimilarityRunCompleted),
+			"completed_at":      &now,
+			"attempts_analyzed": len(attempts),
+			"pairs_reported":    len(pairs),
+		}).Error
+	})
+	if err != nil {
+		fail(err)
+	}
+}
+
+type adminSimilarityRunView struct {
+	ID               uuid.UUID  `json:"id"`
+	ExamID           uuid.UUID  `json:"examId"`
+	Status           string     `json:"status"`
+	Error            string     `json:"error,omitempty"`
+	StartedAt        time.Time  `json:"startedAt"`
+	CompletedAt      *time.Time `json:"completedAt"`
+	MaxK             float64    `json:"maxK"`
+	MinIdentical     int        `json:"minIdentical"`
+	AttemptsAnalyzed int        `json:"attemptsAnalyzed"`
+	PairsReported    int        `json:"pairsReported"`
+}
+
+func toSimilarityRunView(r models.SimilarityRun) adminSimilarityRunView {
+	return adminSimilarityRunView{
+		ID:               r.ID,
+		ExamID:           r.ExamID,
+		Status:           r.Status,
+		Error:            r.Error,
+		StartedAt:        r.StartedAt,
+		CompletedAt:      r.CompletedAt,
+		MaxK:             r.MaxK,
+		MinIdentical:     r.MinIdentical,
+		AttemptsAnalyzed: r.AttemptsAnalyzed,
+		PairsReported:    r.PairsReported,
+	}
+}
+
+type adminSimilarityRunRequest struct {
+	MaxK         *float64 `json:"maxK"`
+	MinIdentical *int     `json:"minIdentical"`
+}
+
+// AdminExamSimilarityRun starts an answer similarity analysis over the
+// submitted attempts of an exam. The analysis runs in the background; poll
+// AdminExamSimilarity for the result.
+func AdminExamSimilarityRun(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		examID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid exam id"})
+			return
+		}
+
+		var req adminSimilarityRunRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
+				return
+			}
+		}
+		maxK := defaultSimilarityMaxK
+		if req.MaxK != nil {
+			if *req.MaxK <= 0 || *req.MaxK > 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "maxK must be in (0, 1]"})
+				return
+			}
+			maxK = *req.MaxK
+		}
+		minIdentical := defaultSimilarityMinIdentical
+		if req.MinIdentical != nil {
+			if *req.MinIdentical < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "minIdentical must be >= 1"})
+				return
+			}
+			minIdentical = *req.MinIdentical
+		}
+
+		var exam models.Exam
+		if err := db.Select("id").First(&exam, "id = ?", examID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"message": "exam not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load exam"})
+			return
+		}
+
+		actorID, _ := c.MustGet(string(middleware.ContextUserID)).(uuid.UUID)
+		run := models.SimilarityRun{
+			ExamID:        examID,
+			TriggeredByID: actorID,
+			Status:        string(models.SimilarityRunRunning),
+			StartedAt:     time.Now().UTC(),
+			MaxK:          maxK,
+			MinIdentical:  minIdentical,
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			// Serialise starts per exam so two requests cannot both see no
+			// running analysis. The lock is released at commit.
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "similarity:"+examID.String()).Error; err != nil {
+				return err
+			}
+			var running int64
+			if err := tx.Model(&models.SimilarityRun{}).
+				Where("exam_id = ? AND status = ? AND started_at > ?", examID, string(models.SimilarityRunRunning), time.Now().UTC().Add(-similarityRunStaleAfter)).
+				Count(&running).Error; err != nil {
+				return err
+			}
+			if running > 0 {
+				return errInvalid("an analysis is already running for this exam")
+			}
+			if err := tx.Create(&run).Error; err != nil {
+				return err
+			}
+			return audit.Record(tx, c, audit.Entry{
+				Action:     audit.ActionSimilarityRun,
+				EntityType: audit.EntityExam,
+				EntityID:   examID,
+				After:      gin.H{"runId": run.ID, "maxK": maxK, "minIdentical": minIdentical},
+			})
+		})
+		if err != nil {
+			var invalid invalidError
+			if errors.As(err, &invalid) {
+				c.JSON(http.StatusConflict, gin.H{"message": invalid.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to start analysis"})
+			return
+		}
+
+		go runSimilarity(db, run)
+
+		c.JSON(http.StatusAccepted, toSimilarityRunView(run))
+	}
+}
+
+type adminSimilarityAttemptView struct {
+	AttemptID uuid.UUID `json:"attemptId"`
+	StudentID uuid.UUID `json:"studentId"`
+	FullName  string    `json:"fullName"`
+	Score     float64   `json:"score"`
+	Wrong     int       `json:"wrong"`
+}
+
+type adminSimilarityPairView struct {
+	AttemptA         adminSimilarityAttemptView `json:"attemptA"`
+	AttemptB         adminSimilarityAttemptView `json:"attemptB"`
+	IdenticalWrong   int                        `json:"identicalWrong"`
+	IdenticalAnswers int                        `json:"identicalAnswers"`
+	KIndex           float64                    `json:"kIndex"`
+}
+
+type adminSimilarityResponse struct {
+	Runs  []adminSimilarityRunView  `json:"runs"`
+	Run   *adminSimilarityRunView   `json:"run"`
+	Pairs []adminSimilarityPairView `json:"pairs"`
+}
+
+// AdminExamSimilarity lists the similarity runs of an exam and the pairs of
+// one run (runId query parameter, default the latest completed run), most
+// suspicious first.
+func AdminExamSimilarity(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		examID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid exam id"})
+			return
+		}
+
+		var runs []models.SimilarityRun
+		if err := db.Where("exam_id = ?", examID).Order("started_at desc").Limit(20).Find(&runs).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load analyses"})
+			return
+		}
+
+		resp := adminSimilarityResponse{Runs: make([]adminSimilarityRunView, 0, len(runs)), Pairs: []adminSimilarityPairView{}}
+		for _, r := range runs {
+			resp.Runs = append(resp.Runs, toSimilarityRunView(r))
+		}
+
+		var selected *models.SimilarityRun
+		if v := strings.TrimSpace(c.Query("runId")); v != "" {
+			runID, err := uuid.Parse(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "invalid runId"})
+				return
+			}
+			var run models.SimilarityRun
+			if err := db.First(&run, "id = ? AND exam_id = ?", runID, examID).Error; err != nil {
+				if err == gorm.ErrRecordNotFound {
+					c.JSON(http.StatusNotFound, gin.H{"message": "analysis not found"})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load analysis"})
+				return
+			}
+			selected = &run
+		} else {
+			for i := range runs {
+				if runs[i].Status == string(models.SimilarityRunCompleted) {
+					selected = &runs[i]
+					break
+				}
+			}
+		}
+		if selected == nil {
+			c.JSON(http.StatusOK, resp)
+			return
+		}
+		view := toSimilarityRunView(*selected)
+		resp.Run = &view
+
+		var pairs []models.SimilarityPair
+		if err := db.Where("run_id = ?", selected.ID).Order("k_index asc, identical_wrong desc").Find(&pairs).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load pairs"})
+			return
+		}
+
+		attemptIDs := make([]uuid.UUID, 0, len(pairs)*2)
+		for _, p := range pairs {
+			attemptIDs = append(attemptIDs, p.AttemptAID, p.AttemptBID)
+		}
+		attemptsByID := map[uuid.UUID]models.ExamAttempt{}
+		if len(attemptIDs) > 0 {
+			var attempts []models.ExamAttempt
+			if err := db.Preload("Student").Where("id IN ?", attemptIDs).Find(&attempts).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load attempts"})
+				return
+			}
+			for _, a := range attempts {
+				attemptsByID[a.ID] = a
+			}
+		}
+
+		attemptView := func(id uuid.UUID, wrong int) adminSimilarityAttemptView {
+			a := attemptsByID[id]
+			return adminSimilarityAttemptView{AttemptID: id, StudentID: a.StudentID, FullName: a.Student.FullName, Score: a.Score, Wrong: wrong}
+		}
+		for _, p := range pairs {
+			resp.Pairs = append(resp.Pairs, adminSimilarityPairView{
+				AttemptA:         attemptView(p.AttemptAID, p.WrongA),
+				AttemptB:         attemptView(p.AttemptBID, p.WrongB),
+				IdenticalWrong:   p.IdenticalWrong,
+				IdenticalAnswers: p.IdenticalAnswers,
+				KIndex:           p.KIndex,
+			})
+		}
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestBinomialUpperTail(t *testing.T) {
+	tests := []struct {
+		name string
+		n, k int
+		p    float64
+		want float64
+	}{
+		{"k zero", 10, 0, 0.3, 1},
+		{"k negative", 10, -1, 0.3, 1},
+		{"k above n", 10, 11, 0.3, 0},
+		{"one trial", 1, 1, 0.3, 0.3},
+		{"two fair trials", 2, 1, 0.5, 0.75},
+		{"all of ten", 10, 10, 0.5, 1.0 / 1024},
+		{"two of three", 3, 2, 0.1, 3*0.01*0.9 + 0.001},
+		{"p near one stays at most one", 50, 1, 1 - 1e-4, 1},
+		{"p near zero", 50, 50, 1e-4, math.Pow(1e-4, 50)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := binomialUpperTail(tt.n, tt.k, tt.p)
+			if math.Abs(got-tt.want) > 1e-9*math.Max(1, tt.want) {
+				t.Errorf("binomialUpperTail(%d, %d, %v) = %v, want %v", tt.n, tt.k, tt.p, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBinomialUpperTailBounds(t *testing.T) {
+	for _, n := range []int{1, 5, 40, 200} {
+		prev := 1.0
+		for k := 0; k <= n+1; k++ {
+			for _, p := range []float64{1e-4, 0.2, 0.5, 0.9, 1 - 1e-4} {
+				got := binomialUpperTail(n, k, p)
+				if got < 0 || got > 1 || math.IsNaN(got) {
+					t.Fatalf("binomialUpperTail(%d, %d, %v) = %v, want a probability", n, k, p, got)
+				}
+			}
+			got := binomialUpperTail(n, k, 0.5)
+			if got > prev+1e-12 {
+				t.Fatalf("binomialUpperTail(%d, %d, 0.5) = %v, more than for k-1 (%v)", n, k, got, prev)
+			}
+			prev = got
+		}
+	}
+}
+
+func TestFitMatchRate(t *testing.T) {
+	tests := []struct {
+		name   string
+		xs, ys []float64
+		want   matchRateModel
+	}{
+		{"no points", nil, nil, matchRateModel{}},
+		{"one point", []float64{0.4}, []float64{0.2}, matchRateModel{intercept: 0.2}},
+		{"same x", []float64{0.4, 0.4}, []float64{0.2, 0.4}, matchRateModel{intercept: 0.3}},
+		{"line", []float64{0, 0.5, 1}, []float64{0.1, 0.3, 0.5}, matchRateModel{intercept: 0.1, slope: 0.4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fitMatchRate(tt.xs, tt.ys)
+			if math.Abs(got.intercept-tt.want.intercept) > 1e-12 || math.Abs(got.slope-tt.want.slope) > 1e-12 {
+				t.Errorf("fitMatchRate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	m := matchRateModel{intercept: -1, slope: 3}
+	if p := m.predict(0); p <= 0 {
+		t.Errorf("predict() = %v, want it kept above 0", p)
+	}
+	if p := m.predict(1); p >= 1 {
+		t.Errorf("predict() = %v, want it kept below 1", p)
+	}
+}
+
+// similarityAttempts builds attempts from one answer string per attempt, one
+// letter per question ("." for blank), marked against key. Attempts with the
+// same student letter belong to the same student.
+func similarityAttempts(key string, students string, answers ...string) []similarityAttempt {
+	ids := map[byte]uuid.UUID{}
+	out := make([]similarityAttempt, len(answers))
+	for i, ans := range answers {
+		s := students[i]
+		if _, ok := ids[s]; !ok {
+			ids[s] = uuid.New()
+		}
+		a := similarityAttempt{
+			id:        uuid.New(),
+			studentID: ids[s],
+			answers:   make([]string, len(key)),
+			wrong:     make([]bool, len(key)),
+		}
+		for q := range key {
+			if ans[q] == '.' {
+				continue
+			}
+			a.answers[q] = string(ans[q])
+			if ans[q] != key[q] {
+				a.wrong[q] = true
+				a.wrongs++
+			}
+		}
+		out[i] = a
+	}
+	return out
+}
+
+func TestComputeSimilarity(t *testing.T) {
+	const key = "aaaaaaaaaaaa"
+	type pair struct{ a, b, identicalWrong, identicalAnswers int }
+	tests := []struct {
+		name     string
+		attempts []similarityAttempt
+		total    int
+		want     []pair
+	}{
+		{"one attempt", similarityAttempts(key, "A", "bbbbbaaaaaaa"), len(key), nil},
+		{"no questions", similarityAttempts("", "AB", "", ""), 0, nil},
+		{"all correct", similarityAttempts(key, "AB", key, key), len(key), nil},
+		{"zero shared wrong answers", similarityAttempts(key, "AB", "bbbaaaaaaaaa", "cccaaaaaaaaa"), len(key), nil},
+		{"blank answers do not match", similarityAttempts(key, "AB", "......aaaaaa", "......aaaaaa"), len(key), nil},
+		{"same student", similarityAttempts(key, "AA", "bbbbbaaaaaaa", "bbbbbaaaaaaa"), len(key), nil},
+		{"shared wrong answers", similarityAttempts(key, "ABC", "bcdaaaaaaaaa", "bcaaaaaaaaab", "aaaaaaaaaaaa"), len(key), []pair{{0, 1, 2, 10}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := computeSimilarity(tt.attempts, tt.total)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d pairs, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, r := range got {
+				w := tt.want[i]
+				if r.a != w.a || r.b != w.b || r.identicalWrong != w.identicalWrong || r.identicalAnswers != w.identicalAnswers {
+					t.Errorf("pair %d = %+v, want %+v", i, r, w)
+				}
+				if r.kIndex <= 0 || r.kIndex > 1 || math.IsNaN(r.kIndex) {
+					t.Errorf("pair %d K-index = %v, want a probability", i, r.kIndex)
+				}
+			}
+		})
+	}
+}
+
+func TestComputeSimilarityFlagsCopying(t *testing.T) {
+	const key = "aaaaaaaaaaaaaaaaaaaa"
+	rng := rand.New(rand.NewSource(1))
+	students := []byte{'0', '1'}
+	answers := []string{
+		"bcdbcdbcaaaaaaaaaaaa",
+		"bcdbcdbcaaaaaaaaaaaa",
+	}
+	// Honest students each get about a third of the questions wrong, with
+	// independently chosen wrong answers.
+	for i := 0; i < 40; i++ {
+		var b strings.Builder
+		for range key {
+			if rng.Intn(3) == 0 {
+				b.WriteByte("bcd"[rng.Intn(3)])
+			} else {
+				b.WriteByte('a')
+			}
+		}
+		students = append(students, byte('A'+i))
+		answers = append(answers, b.String())
+	}
+
+	got := computeSimilarity(similarityAttempts(key, string(students), answers...), len(key))
+	if len(got) == 0 || got[0].a != 0 || got[0].b != 1 {
+		t.Fatalf("first pair = %+v, want the copied pair (0, 1)", got)
+	}
+	copied := got[0]
+	if copied.identicalWrong != 8 || copied.identicalAnswers != len(key) {
+		t.Errorf("copied pair shares %d wrong and %d answers, want 8 and %d", copied.identicalWrong, copied.identicalAnswers, len(key))
+	}
+	if copied.kIndex > defaultSimilarityMaxK {
+		t.Errorf("copied pair K-index = %v, want at most %v", copied.kIndex, defaultSimilarityMaxK)
+	}
+	for _, r := range got[1:] {
+		if r.kIndex <= copied.kIndex {
+			t.Errorf("pair (%d, %d) K-index = %v, not above the copied pair's %v", r.a, r.b, r.kIndex, copied.kIndex)
+		}
+	}
+}
+
+func TestSelectionKey(t *testing.T) {
+	if got, want := selectionKey([]string{"c", "a", "b"}), selectionKey([]string{"b", "c", "a"}); got != want || !strings.HasPrefix(got, "a,") {
+		t.Errorf("selectionKey() = %q and %q, want equal sorted keys", got, want)
+	}
+	if got := selectionKey(nil); got != "" {
+		t.Errorf("selectionKey(nil) = %q, want empty", got)
+	}
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"net/mail"
 	"strings"
@@ -8,28 +9,31 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/letera1/huhems-exam-system/backend/internal/auth"
+	"github.com/letera1/huhems-exam-system/backend/internal/audit"
 	"github.com/letera1/huhems-exam-system/backend/internal/models"
 	"gorm.io/gorm"
 )
 
 type adminStudentView struct {
-	ID                uuid.UUID  `json:"id"`
-	UserID            uuid.UUID  `json:"userId"`
-	Username          string     `json:"username"`
-	Email             string     `json:"email"`
-	FullName          string     `json:"fullName"`
-	Year              int        `json:"year"`
-	Department        string     `json:"department"`
-	CreatedAt         time.Time  `json:"createdAt"`
-	PasswordChangedAt *time.Time `json:"passwordChangedAt"`
-	DefaultPassword   string     `json:"defaultPassword,omitempty"` // Only shown if password not changed
+	ID                 uuid.UUID  `json:"id"`
+	UserID             uuid.UUID  `json:"userId"`
+	Username           string     `json:"username"`
+	Email              string     `json:"email"`
+	FullName           string     `json:"fullName"`
+	Year               int        `json:"year"`
+	Department         string     `json:"department"`
+	CreatedAt          time.Time  `json:"createdAt"`
+	PasswordChangedAt  *time.Time `json:"passwordChangedAt"`
+	MustChangePassword bool       `json:"mustChangePassword"`
+	Groups             []string   `json:"groups,omitempty"`
+	// TemporaryPassword is only set in the response that creates the
+	// account; it is not stored and cannot be shown again.
+	TemporaryPassword string `json:"temporaryPassword,omitempty"`
 }
 
 type adminStudentCreateRequest struct {
 	Username   string `json:"username"`
 	Email      string `json:"email"`
-	Password   string `json:"password"`
 	FullName   string `json:"fullName"`
 	Year       int    `json:"year"`
 	Department string `json:"department"`
@@ -43,31 +47,52 @@ type adminStudentUpdateRequest struct {
 	Department *string `json:"department"`
 }
 
+// studentAuditSnapshot is the view of a student stored in audit logs. It never
+// includes credentials.
+func studentAuditSnapshot(s models.Student) adminStudentView {
+	return adminStudentView{
+		ID:                 s.ID,
+		UserID:             s.UserID,
+		Username:           s.User.Username,
+		Email:              s.User.Email,
+		FullName:           s.FullName,
+		Year:               s.Year,
+		Department:         s.Department,
+		CreatedAt:          s.CreatedAt,
+		PasswordChangedAt:  s.User.PasswordChangedAt,
+		MustChangePassword: s.User.MustChangePassword,
+	}
+}
+
 func AdminStudentsList(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		groupID, ok := parseGroupFilter(c)
+		if !ok {
+			return
+		}
+		q := db.Preload("User").Order("created_at desc")
+		if groupID != uuid.Nil {
+			q = q.Where("id IN (?)", studentIDsInGroup(db, groupID))
+		}
 		var students []models.Student
-		if err := db.Preload("User").Order("created_at desc").Find(&students).Error; err != nil {
+		if err := q.Find(&students).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load students"})
 			return
 		}
+		ids := make([]uuid.UUID, 0, len(students))
+		for _, s := range students {
+			ids = append(ids, s.ID)
+		}
+		groups, err := studentGroupNames(db, ids)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load groups"})
+			return
+		}
 
 		resp := make([]adminStudentView, 0, len(students))
 		for _, s := range students {
-			view := adminStudentView{
-				ID:                s.ID,
-				UserID:            s.UserID,
-				Username:          s.User.Username,
-				Email:             s.User.Email,
-				FullName:          s.FullName,
-				Year:              s.Year,
-				Department:        s.Department,
-				CreatedAt:         s.CreatedAt,
-				PasswordChangedAt: s.User.PasswordChangedAt,
-			}
-			// Only show default password if it hasn't been changed
-			if s.User.PasswordChangedAt == nil {
-				view.DefaultPassword = s.User.DefaultPassword
-			}
+			view := studentAuditSnapshot(s)
+			view.Groups = groups[s.ID]
 			resp = append(resp, view)
 		}
 
@@ -96,14 +121,6 @@ func AdminStudentsCreate(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"message": "email is required"})
 			return
 		}
-		if req.Password == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"message": "password is required"})
-			return
-		}
-		if len(req.Password) < 8 {
-			c.JSON(http.StatusBadRequest, gin.H{"message": "password must be at least 8 characters"})
-			return
-		}
 		if req.FullName == "" {
 			c.JSON(http.StatusBadRequest, gin.H{"message": "fullName is required"})
 			return
@@ -123,23 +140,23 @@ func AdminStudentsCreate(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		hash, err := auth.HashPassword(req.Password)
+		password, hash, err := newTemporaryCredential()
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to hash password"})
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to generate password"})
 			return
 		}
 
 		var createdStudent models.Student
 		err = db.Transaction(func(tx *gorm.DB) error {
 			user := models.User{
-				Username:        req.Username,
-				Email:           req.Email,
-				PasswordHash:    hash,
-				DefaultPassword: req.Password, // Store the default password
-				RoleID:          role.ID,
+				Username:           req.Username,
+				Email:              req.Email,
+				PasswordHash:       hash,
+				MustChangePassword: true,
+				RoleID:             role.ID,
 			}
 			if err := tx.Create(&user).Error; err != nil {
-				return err
+				return errInvalid("failed to create student (username/email may already exist)")
 			}
 
 			student := models.Student{
@@ -150,29 +167,25 @@ func AdminStudentsCreate(db *gorm.DB) gin.HandlerFunc {
 				User:       user,
 			}
 			if err := tx.Create(&student).Error; err != nil {
-				return err
+				return errInvalid("failed to create student (username/email may already exist)")
 			}
 			createdStudent = student
-			return nil
+			return audit.Record(tx, c, audit.Entry{Action: audit.ActionStudentCreate, EntityType: audit.EntityStudent, EntityID: student.ID, After: studentAuditSnapshot(student)})
 		})
 		if err != nil {
-			// Most common failures: unique constraints on username/email.
-			c.JSON(http.StatusBadRequest, gin.H{"message": "failed to create student (username/email may already exist)"})
+			var invalid invalidError
+			if errors.As(err, &invalid) {
+				c.JSON(http.StatusBadRequest, gin.H{"message": invalid.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to create student"})
 			return
 		}
 
-		c.JSON(http.StatusCreated, adminStudentView{
-			ID:                createdStudent.ID,
-			UserID:            createdStudent.UserID,
-			Username:          createdStudent.User.Username,
-			Email:             createdStudent.User.Email,
-			FullName:          createdStudent.FullName,
-			Year:              createdStudent.Year,
-			Department:        createdStudent.Department,
-			CreatedAt:         createdStudent.CreatedAt,
-			PasswordChangedAt: createdStudent.User.PasswordChangedAt,
-			DefaultPassword:   createdStudent.User.DefaultPassword,
-		})
+		view := studentAuditSnapshot(createdStudent)
+		view.TemporaryPassword = password
+		noStore(c)
+		c.JSON(http.StatusCreated, view)
 	}
 }
 
@@ -210,11 +223,38 @@ func AdminStudentsDelete(db *gorm.DB) gin.HandlerFunc {
 				if err := tx.Where("attempt_id IN ?", attemptIDs).Delete(&models.StudentAnswer{}).Error; err != nil {
 					return err
 				}
+				if err := tx.Where("attempt_id IN ?", attemptIDs).Delete(&models.AnswerEvent{}).Error; err != nil {
+					return err
+				}
+				if err := tx.Where("attempt_id IN ?", attemptIDs).Delete(&models.IntegrityEvent{}).Error; err != nil {
+					return err
+				}
+				if err := tx.Where("attempt_a_id IN ? OR attempt_b_id IN ?", attemptIDs, attemptIDs).Delete(&models.SimilarityPair{}).Error; err != nil {
+					return err
+				}
 			}
 
 			if err := tx.Where("student_id = ?", student.ID).Delete(&models.ExamAttempt{}).Error; err != nil {
 				return err
 			}
+			if err := tx.Where("student_id = ?", student.ID).Delete(&models.Accommodation{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("student_id = ?", student.ID).Delete(&models.AnnouncementAck{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("student_id = ?", student.ID).Delete(&models.StudentGroupMember{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("student_id = ?", student.ID).Delete(&models.ExamTarget{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("student_id = ?", student.ID).Delete(&models.CourseEnrollment{}).Error; err != nil {
+				return err
+			}
+			if _, err := revokeSessions(tx, student.UserID, uuid.Nil, models.SessionRevokedUserDeleted); err != nil {
+				return err
+			}
 
 			if err := tx.Delete(&models.Student{}, "id = ?", student.ID).Error; err != nil {
 				return err
@@ -224,7 +264,7 @@ func AdminStudentsDelete(db *gorm.DB) gin.HandlerFunc {
 				return err
 			}
 
-			return nil
+			return audit.Record(tx, c, audit.Entry{Action: audit.ActionStudentDelete, EntityType: audit.EntityStudent, EntityID: student.ID, Before: studentAuditSnapshot(student)})
 		})
 
 		if err != nil {
@@ -265,6 +305,8 @@ func AdminStudentsUpdate(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		before := studentAuditSnapshot(student)
+
 		studentUpdates := map[string]any{}
 		userUpdates := map[string]any{}
 		var normalizedEmail string
@@ -328,7 +370,10 @@ func AdminStudentsUpdate(db *gorm.DB) gin.HandlerFunc {
 					return err
 				}
 			}
-			return nil
+			if err := tx.Preload("User").First(&student, "id = ?", studentID).Error; err != nil {
+				return err
+			}
+			return audit.Record(tx, c, audit.Entry{Action: audit.ActionStudentUpdate, EntityType: audit.EntityStudent, EntityID: student.ID, Before: before, After: studentAuditSnapshot(student)})
 		}); err != nil {
 			errMsg := strings.ToLower(err.Error())
 			if strings.Contains(errMsg, "duplicate") || strings.Contains(errMsg, "unique") {
@@ -339,11 +384,6 @@ func AdminStudentsUpdate(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		if err := db.Preload("User").First(&student, "id = ?", studentID).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load updated student"})
-			return
-		}
-
 		c.JSON(http.StatusOK, adminStudentView{
 			ID:         student.ID,
 			UserID:     student.UserID,
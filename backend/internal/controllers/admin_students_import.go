@@ -11,20 +11,20 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/letera1/huhems-exam-system/backend/internal/auth"
+	"github.com/letera1/huhems-exam-system/backend/internal/audit"
 	"github.com/letera1/huhems-exam-system/backend/internal/models"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 type adminStudentsImportResponse struct {
-	CreatedStudents int `json:"createdStudents"`
+	CreatedStudents int                `json:"createdStudents"`
+	Credentials     []issuedCredential `json:"credentials"`
 }
 
 type studentCSVColIndex struct {
 	username   int
 	email      int
-	password   int
 	fullName   int
 	year       int
 	department int
@@ -39,7 +39,7 @@ func looksLikeStudentHeader(record []string) bool {
 }
 
 func resolveStudentCSVCols(header []string) (studentCSVColIndex, bool) {
-	idx := studentCSVColIndex{username: -1, email: -1, password: -1, fullName: -1, year: -1, department: -1}
+	idx := studentCSVColIndex{username: -1, email: -1, fullName: -1, year: -1, department: -1}
 	for i, raw := range header {
 		k := strings.ToLower(strings.TrimSpace(raw))
 		switch k {
@@ -47,8 +47,6 @@ func resolveStudentCSVCols(header []string) (studentCSVColIndex, bool) {
 			idx.username = i
 		case "email", "mail":
 			idx.email = i
-		case "password", "pass":
-			idx.password = i
 		case "full_name", "fullname", "name", "full name":
 			idx.fullName = i
 		case "year", "level":
@@ -57,7 +55,7 @@ func resolveStudentCSVCols(header []string) (studentCSVColIndex, bool) {
 			idx.department = i
 		}
 	}
-	ok := idx.username >= 0 && idx.email >= 0 && idx.password >= 0 && idx.fullName >= 0 && idx.year >= 0 && idx.department >= 0
+	ok := idx.username >= 0 && idx.email >= 0 && idx.fullName >= 0 && idx.year >= 0 && idx.department >= 0
 	return idx, ok
 }
 
@@ -65,7 +63,12 @@ func resolveStudentCSVCols(header []string) (studentCSVColIndex, bool) {
 //
 // Supported CSV format (with optional header row):
 //
-//	username,email,password,fullName,year,department
+//	username,email,fullName,year,department
+//
+// Every imported account gets a random temporary password that must be
+// changed at first login. The passwords are returned once, as JSON or, with
+// ?format=csv, as a CSV handout; they are not stored and cannot be fetched
+// again. A password column in the file is ignored.
 func AdminStudentsImportCSV(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		file, header, err := c.Request.FormFile("file")
@@ -112,7 +115,7 @@ func AdminStudentsImportCSV(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		col := studentCSVColIndex{username: 0, email: 1, password: 2, fullName: 3, year: 4, department: 5}
+		col := studentCSVColIndex{username: 0, email: 1, fullName: 2, year: 3, department: 4}
 		start := 0
 		if looksLikeStudentHeader(records[0]) {
 			if resolved, ok := resolveStudentCSVCols(records[0]); ok {
@@ -125,6 +128,7 @@ func AdminStudentsImportCSV(db *gorm.DB) gin.HandlerFunc {
 			rowNum     int
 			username   string
 			email      string
+			password   string
 			passHash   string
 			fullName   string
 			year       int
@@ -144,12 +148,11 @@ func AdminStudentsImportCSV(db *gorm.DB) gin.HandlerFunc {
 			rowNum := i + 1
 			username := strings.TrimSpace(get(col.username))
 			email := strings.TrimSpace(strings.ToLower(get(col.email)))
-			password := strings.TrimSpace(get(col.password))
 			fullName := strings.TrimSpace(get(col.fullName))
 			yearRaw := strings.TrimSpace(get(col.year))
 			department := strings.TrimSpace(get(col.department))
 
-			if username == "" && email == "" && password == "" && fullName == "" && yearRaw == "" && department == "" {
+			if username == "" && email == "" && fullName == "" && yearRaw == "" && department == "" {
 				continue
 			}
 
@@ -165,14 +168,6 @@ func AdminStudentsImportCSV(db *gorm.DB) gin.HandlerFunc {
 				c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("row %d: invalid email", rowNum)})
 				return
 			}
-			if password == "" {
-				c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("row %d: password is required", rowNum)})
-				return
-			}
-			if len(password) < 8 {
-				c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("row %d: password must be at least 8 characters", rowNum)})
-				return
-			}
 			if fullName == "" {
 				c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("row %d: fullName is required", rowNum)})
 				return
@@ -191,13 +186,13 @@ func AdminStudentsImportCSV(db *gorm.DB) gin.HandlerFunc {
 				return
 			}
 
-			hash, err := auth.HashPassword(password)
+			password, hash, err := newTemporaryCredential()
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to hash password"})
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to generate password"})
 				return
 			}
 
-			payloads = append(payloads, rowPayload{rowNum: rowNum, username: username, email: email, passHash: hash, fullName: fullName, year: year, department: department})
+			payloads = append(payloads, rowPayload{rowNum: rowNum, username: username, email: email, password: password, passHash: hash, fullName: fullName, year: year, department: department})
 		}
 
 		if len(payloads) == 0 {
@@ -205,9 +200,16 @@ func AdminStudentsImportCSV(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		usernames := make([]string, 0, len(payloads))
+		creds := make([]issuedCredential, 0, len(payloads))
+		for _, p := range payloads {
+			usernames = append(usernames, p.username)
+			creds = append(creds, issuedCredential{Username: p.username, Email: p.email, FullName: p.fullName, TemporaryPassword: p.password})
+		}
+
 		err = db.Transaction(func(tx *gorm.DB) error {
 			for _, p := range payloads {
-				user := models.User{Username: p.username, Email: p.email, PasswordHash: p.passHash, RoleID: role.ID}
+				user := models.User{Username: p.username, Email: p.email, PasswordHash: p.passHash, MustChangePassword: true, RoleID: role.ID}
 				if err := tx.Create(&user).Error; err != nil {
 					return fmt.Errorf("row %d: failed to create user: %w", p.rowNum, err)
 				}
@@ -216,13 +218,22 @@ func AdminStudentsImportCSV(db *gorm.DB) gin.HandlerFunc {
 					return fmt.Errorf("row %d: failed to create student: %w", p.rowNum, err)
 				}
 			}
-			return nil
+			return audit.Record(tx, c, audit.Entry{
+				Action:     audit.ActionStudentImport,
+				EntityType: audit.EntityStudent,
+				After:      gin.H{"file": header.Filename, "createdStudents": len(payloads), "usernames": usernames},
+			})
 		})
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"message": "failed to import students: " + err.Error()})
 			return
 		}
 
-		c.JSON(http.StatusCreated, adminStudentsImportResponse{CreatedStudents: len(payloads)})
+		if wantsCSVHandout(c) {
+			writeCredentialsCSV(c, http.StatusCreated, "student-credentials.csv", creds)
+			return
+		}
+		noStore(c)
+		c.JSON(http.StatusCreated, adminStudentsImportResponse{CreatedStudents: len(payloads), Credentials: creds})
 	}
 }
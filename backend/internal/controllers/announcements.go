@@ -0,0 +1,310 @@
+package controllers
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/letera1/huhems-exam-system/backend/internal/audit"
+	"github.com/letera1/huhems-exam-system/backend/internal/live"
+	"github.com/letera1/huhems-exam-system/backend/internal/middleware"
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxAnnouncementLength = 2000
+
+// Live event types for announcements.
+const (
+	liveAnnouncement    = "announcement"
+	liveAnnouncementAck = "announcement.ack"
+)
+
+type studentAnnouncementView struct {
+	ID           uuid.UUID `json:"id"`
+	Message      string    `json:"message"`
+	CreatedAt    time.Time `json:"createdAt"`
+	Acknowledged bool      `json:"acknowledged"`
+}
+
+// loadStudentAnnouncements returns the announcements of an exam, oldest
+// first, with the student's acknowledgement state.
+func loadStudentAnnouncements(db *gorm.DB, examID, studentID uuid.UUID) ([]studentAnnouncementView, error) {
+	var announcements []models.Announcement
+	if err := db.Where("exam_id = ?", examID).Order("created_at asc").Find(&announcements).Error; err != nil {
+		return nil, err
+	}
+	out := make([]studentAnnouncementView, 0, len(announcements))
+	if len(announcements) == 0 {
+		return out, nil
+	}
+	ids := make([]uuid.UUID, 0, len(announcements))
+	for _, a := range announcements {
+		ids = append(ids, a.ID)
+	}
+	var acks []models.AnnouncementAck
+	if err := db.Select("announcement_id").Where("announcement_id IN ? AND student_id = ?", ids, studentID).Find(&acks).Error; err != nil {
+		return nil, err
+	}
+	acked := map[uuid.UUID]bool{}
+	for _, a := range acks {
+		acked[a.AnnouncementID] = true
+	}
+	for _, a := range announcements {
+		out = append(out, studentAnnouncementView{ID: a.ID, Message: a.Message, CreatedAt: a.CreatedAt, Acknowledged: acked[a.ID]})
+	}
+	return out, nil
+}
+
+type adminAnnouncementRequest struct {
+	Message string `json:"message"`
+}
+
+type adminAnnouncementStudent struct {
+	StudentID uuid.UUID  `json:"studentId"`
+	FullName  string     `json:"fullName"`
+	AckedAt   *time.Time `json:"ackedAt,omitempty"`
+}
+
+type adminAnnouncementView struct {
+	ID             uuid.UUID                  `json:"id"`
+	ExamID         uuid.UUID                  `json:"examId"`
+	Message        string                     `json:"message"`
+	AuthorID       uuid.UUID                  `json:"authorId"`
+	AuthorUsername string                     `json:"authorUsername"`
+	CreatedAt      time.Time                  `json:"createdAt"`
+	Seen           []adminAnnouncementStudent `json:"seen"`
+	Unseen         []adminAnnouncementStudent `json:"unseen"`
+}
+
+// AdminExamAnnouncementCreate posts an announcement to an exam and pushes it
+// to every attempt in progress.
+func AdminExamAnnouncementCreate(db *gorm.DB, hub *live.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		examID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid exam id"})
+			return
+		}
+
+		var req adminAnnouncementRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
+			return
+		}
+		req.Message = strings.TrimSpace(req.Message)
+		if req.Message == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "message is required"})
+			return
+		}
+		if len(req.Message) > maxAnnouncementLength {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "message is too long (max 2000 characters)"})
+			return
+		}
+
+		var exam models.Exam
+		if err := db.Select("id").First(&exam, "id = ?", examID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"message": "exam not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load exam"})
+			return
+		}
+
+		authorID, _ := c.MustGet(string(middleware.ContextUserID)).(uuid.UUID)
+		announcement := models.Announcement{ExamID: examID, AuthorID: authorID, Message: req.Message}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&announcement).Error; err != nil {
+				return err
+			}
+			return audit.Record(tx, c, audit.Entry{
+				Action:     audit.ActionAnnouncementCreate,
+				EntityType: audit.EntityExam,
+				EntityID:   examID,
+				After:      gin.H{"announcementId": announcement.ID, "message": announcement.Message},
+			})
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to create announcement"})
+			return
+		}
+
+		view := studentAnnouncementView{ID: announcement.ID, Message: announcement.Message, CreatedAt: announcement.CreatedAt}
+		hub.Publish(live.ExamTopic(examID), live.Event{Type: liveAnnouncement, Data: view})
+
+		var active []models.ExamAttempt
+		if err := db.Select("id").Where("exam_id = ? AND submitted = false", examID).Find(&active).Error; err == nil {
+			for _, a := range active {
+				hub.Publish(live.AttemptTopic(a.ID), live.Event{Type: liveAnnouncement, Data: view})
+			}
+		}
+
+		c.JSON(http.StatusCreated, view)
+	}
+}
+
+// AdminExamAnnouncementsList lists an exam's announcements, newest first, with
+// the students who have and have not acknowledged each one. The audience of
+// an announcement is every student whose attempt was still open when it was
+// posted, or who started one afterwards.
+func AdminExamAnnouncementsList(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		examID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid exam id"})
+			return
+		}
+
+		var announcements []models.Announcement
+		if err := db.Preload("Author").Where("exam_id = ?", examID).Order("created_at desc").Find(&announcements).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load announcements"})
+			return
+		}
+		resp := make([]adminAnnouncementView, 0, len(announcements))
+		if len(announcements) == 0 {
+			c.JSON(http.StatusOK, resp)
+			return
+		}
+
+		var attempts []models.ExamAttempt
+		if err := db.Preload("Student").Select("id", "student_id", "end_time").Where("exam_id = ?", examID).Find(&attempts).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load attempts"})
+			return
+		}
+
+		ids := make([]uuid.UUID, 0, len(announcements))
+		for _, a := range announcements {
+			ids = append(ids, a.ID)
+		}
+		var acks []models.AnnouncementAck
+		if err := db.Where("announcement_id IN ?", ids).Find(&acks).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load acknowledgements"})
+			return
+		}
+		ackedAt := map[uuid.UUID]map[uuid.UUID]time.Time{}
+		for _, a := range acks {
+			m := ackedAt[a.AnnouncementID]
+			if m == nil {
+				m = map[uuid.UUID]time.Time{}
+				ackedAt[a.AnnouncementID] = m
+			}
+			m[a.StudentID] = a.AckedAt
+		}
+
+		for _, an := range announcements {
+			view := adminAnnouncementView{
+				ID:             an.ID,
+				ExamID:         an.ExamID,
+				Message:        an.Message,
+				AuthorID:       an.AuthorID,
+				AuthorUsername: an.Author.Username,
+				CreatedAt:      an.CreatedAt,
+				Seen:           []adminAnnouncementStudent{},
+				Unseen:         []adminAnnouncementStudent{},
+			}
+			audience := map[uuid.UUID]string{}
+			for _, at := range attempts {
+				if at.EndTime == nil || at.EndTime.After(an.CreatedAt) {
+					audience[at.StudentID] = at.Student.FullName
+				}
+			}
+			// Anyone who acknowledged saw it, even if outside the audience.
+			for studentID := range ackedAt[an.ID] {
+				if _, ok := audience[studentID]; !ok {
+					audience[studentID] = ""
+				}
+			}
+			for studentID, name := range audience {
+				entry := adminAnnouncementStudent{StudentID: studentID, FullName: name}
+				if t, ok := ackedAt[an.ID][studentID]; ok {
+					t := t
+					entry.AckedAt = &t
+					view.Seen = append(view.Seen, entry)
+				} else {
+					view.Unseen = append(view.Unseen, entry)
+				}
+			}
+			sort.Slice(view.Seen, func(i, j int) bool { return view.Seen[i].AckedAt.Before(*view.Seen[j].AckedAt) })
+			sort.Slice(view.Unseen, func(i, j int) bool { return view.Unseen[i].FullName < view.Unseen[j].FullName })
+			resp = append(resp, view)
+		}
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// StudentAnnouncementAck records that the student has seen an announcement of
+// the attempt's exam. Acknowledging twice is a no-op.
+func StudentAnnouncementAck(db *gorm.DB, hub *live.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		attemptID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid attempt id"})
+			return
+		}
+		announcementID, err := uuid.Parse(c.Param("announcementId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid announcement id"})
+			return
+		}
+
+		studentID, ok := getStudentID(c, db)
+		if !ok {
+			return
+		}
+
+		var attempt models.ExamAttempt
+		if err := db.First(&attempt, "id = ?", attemptID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"message": "attempt not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load attempt"})
+			return
+		}
+		if attempt.StudentID != studentID {
+			c.JSON(http.StatusForbidden, gin.H{"message": "forbidden"})
+			return
+		}
+		if !attempt.Submitted && !checkAttemptSession(db, hub, c, attempt) {
+			return
+		}
+
+		var announcement models.Announcement
+		if err := db.First(&announcement, "id = ? AND exam_id = ?", announcementID, attempt.ExamID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"message": "announcement not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load announcement"})
+			return
+		}
+
+		ack := models.AnnouncementAck{
+			AnnouncementID: announcement.ID,
+			StudentID:      studentID,
+			AttemptID:      attempt.ID,
+			AckedAt:        time.Now().UTC(),
+		}
+		res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&ack)
+		if res.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to acknowledge announcement"})
+			return
+		}
+		if res.RowsAffected > 0 {
+			hub.Publish(live.ExamTopic(attempt.ExamID), live.Event{Type: liveAnnouncementAck, Data: gin.H{
+				"announcementId": announcement.ID,
+				"studentId":      studentID,
+				"attemptId":      attempt.ID,
+				"ackedAt":        ack.AckedAt,
+			}})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	}
+}
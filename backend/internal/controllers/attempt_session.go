@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/letera1/huhems-exam-system/backend/internal/audit"
+	"github.com/letera1/huhems-exam-system/backend/internal/live"
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// AttemptSessionHeader carries the session token returned when an attempt is
+// started. Every later request for the attempt must send it.
+const AttemptSessionHeader = "X-Attempt-Session"
+
+func hashAttemptSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// attemptTransferAlphabet leaves out characters that are easy to misread when
+// a proctor reads a transfer code out or writes it down.
+const attemptTransferAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+const attemptTransferCodeLength = 8
+
+// newAttemptTransferCode returns a random one-time code for rebinding a
+// transferred attempt.
+func newAttemptTransferCode() (string, error) {
+	raw := make([]byte, attemptTransferCodeLength)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	code := make([]byte, len(raw))
+	for i, b := range raw {
+		code[i] = attemptTransferAlphabet[int(b)%len(attemptTransferAlphabet)]
+	}
+	return string(code), nil
+}
+
+// normalizeAttemptTransferCode ignores case and the separators people add
+// when typing a code.
+func normalizeAttemptTransferCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer(" ", "", "-", "").Replace(code)
+}
+
+// bindAttemptSession generates a new session token for attempt and records the
+// caller's IP and user agent. The caller must save the attempt.
+func bindAttemptSession(c *gin.Context, attempt *models.ExamAttempt) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+	now := time.Now().UTC()
+	attempt.SessionTokenHash = hashAttemptSessionToken(token)
+	attempt.SessionIP = c.ClientIP()
+	attempt.SessionUserAgent = c.Request.UserAgent()
+	attempt.SessionBoundAt = &now
+	attempt.SessionTransferHash = ""
+	return token, nil
+}
+
+func recordSessionEvent(db *gorm.DB, hub *live.Hub, c *gin.Context, attempt models.ExamAttempt, t models.IntegrityEventType, details string) {
+	event := models.IntegrityEvent{
+		AttemptID:  attempt.ID,
+		Type:       string(t),
+		OccurredAt: time.Now().UTC(),
+		Details:    details,
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	}
+	if err := db.Create(&event).Error; err != nil {
+		log.Printf("attempt %s: failed to record %s event: %v", attempt.ID, t, err)
+		return
+	}
+	if err := applyIntegrityThresholds(db, attempt); err != nil {
+		log.Printf("attempt %s: failed to apply integrity thresholds: %v", attempt.ID, err)
+	}
+	publishIntegrityEvents(db, hub, attempt, []models.IntegrityEvent{event})
+}
+
+// checkAttemptSession verifies that the request comes from the session the
+// attempt is bound to. Requests from any other session are rejected with 409
+// and recorded as a session_conflict integrity event. An IP change within the
+// bound session is allowed but recorded, since networks change legitimately.
+// An unbound attempt is rejected until a start request binds it.
+func checkAttemptSession(db *gorm.DB, hub *live.Hub, c *gin.Context, attempt models.ExamAttempt) bool {
+	if attempt.SessionTokenHash == "" {
+		c.JSON(http.StatusConflict, gin.H{"message": "this attempt is not open in this browser; resume it from your exam list"})
+		return false
+	}
+
+	token := strings.TrimSpace(c.GetHeader(AttemptSessionHeader))
+	tokenOK := token != "" && subtle.ConstantTimeCompare([]byte(hashAttemptSessionToken(token)), []byte(attempt.SessionTokenHash)) == 1
+	agentOK := c.Request.UserAgent() == attempt.SessionUserAgent
+
+	if !tokenOK || !agentOK {
+		reason := "missing or invalid session token"
+		if tokenOK {
+			reason = "user agent differs from the bound session"
+		}
+		recordSessionEvent(db, hub, c, attempt, models.IntegrityEventSessionConflict, reason)
+		c.JSON(http.StatusConflict, gin.H{"message": "this attempt is open in another browser or device; ask a proctor to transfer it"})
+		return false
+	}
+
+	if ip := c.ClientIP(); ip != attempt.SessionIP {
+		recordSessionEvent(db, hub, c, attempt, models.IntegrityEventIPChange, fmt.Sprintf("ip changed from %s to %s", attempt.SessionIP, ip))
+		if err := db.Model(&models.ExamAttempt{}).Where("id = ?", attempt.ID).Update("session_ip", ip).Error; err != nil {
+			log.Printf("attempt %s: failed to update session ip: %v", attempt.ID, err)
+		}
+	}
+	return true
+}
+
+type adminAttemptTransferRequest struct {
+	Reason string `json:"reason"`
+}
+
+// AdminAttemptTransfer unbinds an in-progress attempt from its session so the
+// student can resume it on another device, e.g. after a browser crash. It
+// returns a one-time transfer code; the student enters it when resuming the
+// exam, which binds the attempt to the new session.
+func AdminAttemptTransfer(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		attemptID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid attempt id"})
+			return
+		}
+
+		var req adminAttemptTransferRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
+				return
+			}
+		}
+
+		var attempt models.ExamAttempt
+		if err := db.First(&attempt, "id = ?", attemptID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"message": "attempt not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load attempt"})
+			return
+		}
+		if attempt.Submitted {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "attempt already submitted"})
+			return
+		}
+
+		code, err := newAttemptTransferCode()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to transfer attempt"})
+			return
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&models.ExamAttempt{}).Where("id = ?", attempt.ID).Updates(map[string]any{
+				"session_token_hash":    "",
+				"session_ip":            "",
+				"session_user_agent":    "",
+				"session_bound_at":      nil,
+				"session_transfer_hash": hashAttemptSessionToken(code),
+			}).Error; err != nil {
+				return err
+			}
+			return audit.Record(tx, c, audit.Entry{
+				Action:     audit.ActionAttemptTransfer,
+				EntityType: audit.EntityAttempt,
+				EntityID:   attempt.ID,
+				Before:     gin.H{"sessionIp": attempt.SessionIP, "sessionUserAgent": attempt.SessionUserAgent, "sessionBoundAt": attempt.SessionBoundAt},
+				After:      gin.H{"sessionIp": "", "sessionUserAgent": "", "sessionBoundAt": nil, "reason": strings.TrimSpace(req.Reason)},
+			})
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to transfer attempt"})
+			return
+		}
+
+		noStore(c)
+		c.JSON(http.StatusOK, gin.H{"ok": true, "transferCode": code})
+	}
+}
@@ -0,0 +1,285 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/letera1/huhems-exam-system/backend/internal/live"
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// Event types sent on a student's attempt stream, in addition to
+// liveAttemptSubmitted and liveAnnouncement.
+const (
+	attemptStreamClock   = "clock"
+	attemptStreamWarning = "time.warning"
+)
+
+// Who closed an attempt, as reported on its stream.
+const (
+	submittedByStudent = "student"
+	submittedByServer  = "server"
+	submittedByProctor = "proctor"
+)
+
+// attemptTimeWarnings are the remaining-time marks, in minutes, at which the
+// attempt stream sends a warning.
+var attemptTimeWarnings = []int{10, 1}
+
+// attemptClock is the server's view of an attempt's time. Clients should
+// count down from RemainingSeconds rather than from their own clock.
+type attemptClock struct {
+	ServerTime time.Time `json:"serverTime"`
+	// Deadline includes extra time and pauses; nil when the exam is untimed
+	// or the attempt is closed.
+	Deadline         *time.Time `json:"deadline"`
+	RemainingSeconds *int64     `json:"remainingSeconds"`
+	Paused           bool       `json:"paused"`
+}
+
+func newAttemptClock(attempt models.ExamAttempt, durationMinutes int, now time.Time) attemptClock {
+	clock := attemptClock{ServerTime: now, Paused: attempt.PausedAt != nil}
+	if attempt.Submitted {
+		return clock
+	}
+	if deadline, ok := attemptDeadline(attempt, durationMinutes); ok {
+		remaining := int64(deadline.Sub(now).Seconds())
+		if remaining < 0 {
+			remaining = 0
+		}
+		clock.Deadline = &deadline
+		clock.RemainingSeconds = &remaining
+	}
+	return clock
+}
+
+// expired reports whether the attempt's time has run out.
+func (c attemptClock) expired() bool {
+	return c.Deadline != nil && c.ServerTime.After(*c.Deadline)
+}
+
+// at returns the clock as of now, for an attempt whose state has not changed.
+func (c attemptClock) at(now time.Time) attemptClock {
+	c.ServerTime = now
+	if c.Deadline != nil && !c.Paused {
+		remaining := int64(c.Deadline.Sub(now).Seconds())
+		if remaining < 0 {
+			remaining = 0
+		}
+		c.RemainingSeconds = &remaining
+	}
+	return c
+}
+
+func loadAttemptClock(db *gorm.DB, attempt models.ExamAttempt) (attemptClock, error) {
+	var exam models.Exam
+	if err := db.Select("duration_minutes").First(&exam, "id = ?", attempt.ExamID).Error; err != nil {
+		return attemptClock{}, err
+	}
+	return newAttemptClock(attempt, exam.DurationMinutes, time.Now().UTC()), nil
+}
+
+type attemptTimeWarning struct {
+	MinutesLeft int `json:"minutesLeft"`
+	attemptClock
+}
+
+type attemptClosedEvent struct {
+	AttemptID   uuid.UUID  `json:"attemptId"`
+	EndTime     *time.Time `json:"endTime"`
+	SubmittedBy string     `json:"submittedBy"`
+	Invalidated bool       `json:"invalidated"`
+}
+
+// publishAttemptClosed tells the student's open streams that the attempt has
+// been submitted.
+func publishAttemptClosed(hub *live.Hub, attempt models.ExamAttempt, submittedBy string) {
+	hub.Publish(live.AttemptTopic(attempt.ID), live.Event{Type: liveAttemptSubmitted, Data: attemptClosedEvent{
+		AttemptID:   attempt.ID,
+		EndTime:     attempt.EndTime,
+		SubmittedBy: submittedBy,
+		Invalidated: attempt.Invalidated,
+	}})
+}
+
+// publishAttemptChanged tells the student's open streams that the attempt's
+// clock may have changed (pause, resume, extension).
+func publishAttemptChanged(hub *live.Hub, attemptID uuid.UUID) {
+	hub.Publish(live.AttemptTopic(attemptID), live.Event{Type: liveAttemptUpdated})
+}
+
+// StudentAttemptStream streams an attempt's clock as Server-Sent Events. It
+// sends a "clock" event on connect and with every heartbeat, a
+// "time.warning" event when 10 and 1 minutes remain, announcements as they
+// are posted, and a final "attempt.submitted" event when the attempt is
+// closed, submitting it itself when time runs out. The stream is optional:
+// attempts are still finalized on the next request without it.
+func StudentAttemptStream(db *gorm.DB, hub *live.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		attemptID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid attempt id"})
+			return
+		}
+
+		studentID, ok := getStudentID(c, db)
+		if !ok {
+			return
+		}
+
+		// Subscribe before loading the attempt so no change is missed.
+		events, unsubscribe := hub.Subscribe(live.AttemptTopic(attemptID))
+		defer unsubscribe()
+
+		var attempt models.ExamAttempt
+		if err := db.First(&attempt, "id = ?", attemptID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"message": "attempt not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load attempt"})
+			return
+		}
+		if attempt.StudentID != studentID {
+			c.JSON(http.StatusForbidden, gin.H{"message": "forbidden"})
+			return
+		}
+		if !attempt.Submitted && !checkAttemptSession(db, hub, c, attempt) {
+			return
+		}
+
+		var exam models.Exam
+		if err := db.Select("duration_minutes").First(&exam, "id = ?", attempt.ExamID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load exam"})
+			return
+		}
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+
+		if attempt.Submitted {
+			c.SSEvent(liveAttemptSubmitted, live.Event{Type: liveAttemptSubmitted, At: time.Now().UTC(), Data: attemptClosedEvent{
+				AttemptID:   attempt.ID,
+				EndTime:     attempt.EndTime,
+				Invalidated: attempt.Invalidated,
+			}})
+			c.Writer.Flush()
+			return
+		}
+
+		now := time.Now().UTC()
+		clock := newAttemptClock(attempt, exam.DurationMinutes, now)
+		c.SSEvent(attemptStreamClock, clock)
+		c.Writer.Flush()
+
+		// Warnings whose mark has already passed are not sent; the client
+		// has the clock. They are re-armed if the deadline moves back past
+		// their mark.
+		warned := map[int]bool{}
+		rearm := func(clock attemptClock) {
+			if clock.RemainingSeconds == nil {
+				return
+			}
+			for _, m := range attemptTimeWarnings {
+				warned[m] = *clock.RemainingSeconds <= int64(m*60)
+			}
+		}
+		rearm(clock)
+
+		// The timer wakes the stream at the next warning mark or at the
+		// deadline. It is stopped while the attempt is paused, since the
+		// deadline moves with the clock.
+		timer := time.NewTimer(time.Hour)
+		timer.Stop()
+		schedule := func(clock attemptClock) {
+			timer.Stop()
+			if clock.Deadline == nil || clock.Paused {
+				return
+			}
+			next := *clock.Deadline
+			for _, m := range attemptTimeWarnings {
+				if mark := clock.Deadline.Add(-time.Duration(m) * time.Minute); !warned[m] && mark.Before(next) {
+					next = mark
+				}
+			}
+			timer.Reset(time.Until(next))
+		}
+		schedule(clock)
+		defer timer.Stop()
+
+		heartbeat := time.NewTicker(liveHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		c.Stream(func(io.Writer) bool {
+			select {
+			case <-c.Request.Context().Done():
+				return false
+			case e, ok := <-events:
+				if !ok {
+					return false
+				}
+				switch e.Type {
+				case liveAttemptSubmitted:
+					c.SSEvent(e.Type, e)
+					return false
+				case liveAttemptUpdated:
+					var fresh models.ExamAttempt
+					if err := db.First(&fresh, "id = ?", attempt.ID).Error; err != nil {
+						return false
+					}
+					attempt = fresh
+					clock := newAttemptClock(attempt, exam.DurationMinutes, time.Now().UTC())
+					rearm(clock)
+					schedule(clock)
+					c.SSEvent(attemptStreamClock, clock)
+				case liveAnnouncement:
+					c.SSEvent(e.Type, e)
+				}
+				return true
+			case <-timer.C:
+				clock := newAttemptClock(attempt, exam.DurationMinutes, time.Now().UTC())
+				if clock.expired() {
+					finalized, sc, err := finalizeAttemptIfExpired(db, hub, attempt.ID)
+					if err != nil {
+						return false
+					}
+					// finalizeAttemptIfExpired publishes the close to this
+					// topic, so a close done elsewhere arrives as an event.
+					if sc == nil {
+						if finalized.Submitted {
+							return true
+						}
+						attempt = *finalized
+						clock = newAttemptClock(attempt, exam.DurationMinutes, time.Now().UTC())
+						rearm(clock)
+						schedule(clock)
+						return true
+					}
+					c.SSEvent(liveAttemptSubmitted, live.Event{Type: liveAttemptSubmitted, At: time.Now().UTC(), Data: attemptClosedEvent{
+						AttemptID:   finalized.ID,
+						EndTime:     finalized.EndTime,
+						SubmittedBy: submittedByServer,
+					}})
+					return false
+				}
+				for _, m := range attemptTimeWarnings {
+					if !warned[m] && clock.RemainingSeconds != nil && *clock.RemainingSeconds <= int64(m*60) {
+						warned[m] = true
+						c.SSEvent(attemptStreamWarning, attemptTimeWarning{MinutesLeft: m, attemptClock: clock})
+					}
+				}
+				schedule(clock)
+				return true
+			case <-heartbeat.C:
+				c.SSEvent(attemptStreamClock, newAttemptClock(attempt, exam.DurationMinutes, time.Now().UTC()))
+				return true
+			}
+		})
+	}
+}
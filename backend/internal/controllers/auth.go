@@ -1,6 +1,8 @@
 package controllers
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -10,6 +12,7 @@ import (
 	"github.com/letera1/huhems-exam-system/backend/internal/auth"
 	"github.com/letera1/huhems-exam-system/backend/internal/middleware"
 	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"github.com/letera1/huhems-exam-system/backend/internal/ratelimit"
 	"gorm.io/gorm"
 )
 
@@ -19,17 +22,28 @@ type loginRequest struct {
 }
 
 type loginResponse struct {
-	Token      string `json:"token"`
-	Role       string `json:"role"`
-	FirstLogin bool   `json:"firstLogin"`
-	User       struct {
+	sessionTokens
+	Role               string `json:"role"`
+	FirstLogin         bool   `json:"firstLogin"`
+	MustChangePassword bool   `json:"mustChangePassword"`
+	MFASetupRequired   bool   `json:"mfaSetupRequired"`
+	User               struct {
 		ID       uuid.UUID `json:"id"`
 		Username string    `json:"username"`
 		Email    string    `json:"email"`
 	} `json:"user"`
 }
 
-func AuthLogin(db *gorm.DB, jwtSecret string) gin.HandlerFunc {
+// AuthLogin signs a user in. Failed logins are throttled per IP and per
+// account by the guard: progressive delays first, then a temporary lockout.
+// mustChangePassword in the response tells the client that every other route
+// is closed to the user until they change their password. Users with
+// two-factor authentication get an mfaToken instead of a session and finish
+// signing in at AuthLoginMFA. The password is checked by each of providers
+// in turn; a directory provider such as LDAP signs in, and if need be
+// creates, the user it vouches for. Roles in passwordDisabled may not sign
+// in with a local password.
+func AuthLogin(db *gorm.DB, tc auth.TokenConfig, guard *ratelimit.LoginGuard, policy auth.PasswordPolicy, mfa auth.MFAConfig, providers []auth.PasswordProvider, passwordDisabled []string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req loginRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -43,43 +57,115 @@ func AuthLogin(db *gorm.DB, jwtSecret string) gin.HandlerFunc {
 			return
 		}
 
+		ipKey := ipThrottleKey(c.ClientIP())
+		if !reserveLoginAttempt(c, guard, ipKey, guard.IP) {
+			return
+		}
+
 		var user models.User
 		err := db.Preload("Role").Where("username = ?", req.UsernameOrEmail).
 			Or("email = ?", req.UsernameOrEmail).
 			First(&user).Error
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "invalid credentials"})
+		var found *models.User
+		if err == nil {
+			found = &user
+		} else if err != gorm.ErrRecordNotFound {
+			releaseLoginAttempt(c, guard, ipKey, guard.IP)
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load user"})
+			return
+		}
+		accountKey := accountThrottleKey(found, req.UsernameOrEmail)
+		if !reserveLoginAttempt(c, guard, accountKey, guard.Account) {
+			releaseLoginAttempt(c, guard, ipKey, guard.IP)
 			return
 		}
 
-		if err := auth.ComparePassword(user.PasswordHash, req.Password); err != nil {
+		localHash := ""
+		if found != nil {
+			localHash = user.PasswordHash
+		}
+		identity, err := auth.AuthenticatePassword(c.Request.Context(), providers, req.UsernameOrEmail, req.Password, localHash)
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			recordLoginFailure(db, c, guard, found, req.UsernameOrEmail)
 			c.JSON(http.StatusUnauthorized, gin.H{"message": "invalid credentials"})
 			return
 		}
+		// The password was right, or could not be checked: neither counts
+		// as a failure.
+		releaseLoginAttempt(c, guard, ipKey, guard.IP)
+		releaseLoginAttempt(c, guard, accountKey, guard.Account)
+		if err != nil {
+			log.Printf("login: %v", err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"message": "sign-in is temporarily unavailable"})
+			return
+		}
 
-		firstLogin := user.LastLoginAt == nil
-		now := time.Now().UTC()
-		if err := db.Model(&models.User{}).Where("id = ?", user.ID).Update("last_login_at", &now).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to record login"})
+		if identity != nil {
+			if user, err = provisionExternalUser(db, c, *identity); err != nil {
+				externalLoginError(c, err)
+				return
+			}
+		} else if passwordLoginDisabled(user.Role.Name, passwordDisabled) {
+			c.JSON(http.StatusForbidden, gin.H{"message": "password sign-in is disabled for your account; use single sign-on"})
 			return
 		}
 
-		token, err := auth.SignToken(user.ID, user.Role.Name, jwtSecret, 24*time.Hour)
+		beginLogin(db, c, user, tc, guard, policy, mfa)
+	}
+}
+
+// beginLogin continues a login whose first factor has been accepted: users
+// with two-factor authentication get an mfaToken for AuthLoginMFA, everyone
+// else a session.
+func beginLogin(db *gorm.DB, c *gin.Context, user models.User, tc auth.TokenConfig, guard *ratelimit.LoginGuard, policy auth.PasswordPolicy, mfa auth.MFAConfig) {
+	if user.TOTPEnabledAt != nil {
+		token, err := auth.SignMFAToken(user.ID, tc.Secret, mfaTokenTTL)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to sign token"})
 			return
 		}
+		c.JSON(http.StatusOK, gin.H{"mfaRequired": true, "mfaToken": token, "expiresIn": int64(mfaTokenTTL.Seconds())})
+		return
+	}
+	completeLogin(db, c, user, tc, guard, policy, mfa)
+}
 
-		var resp loginResponse
-		resp.Token = token
-		resp.Role = user.Role.Name
-		resp.FirstLogin = firstLogin
-		resp.User.ID = user.ID
-		resp.User.Username = user.Username
-		resp.User.Email = user.Email
+// completeLogin starts a session for a user who has passed every login step
+// and writes the login response.
+func completeLogin(db *gorm.DB, c *gin.Context, user models.User, tc auth.TokenConfig, guard *ratelimit.LoginGuard, policy auth.PasswordPolicy, mfa auth.MFAConfig) {
+	if err := guard.Reset(c.Request.Context(), accountThrottleKey(&user, "")); err != nil {
+		log.Printf("login throttle: %v", err)
+	}
 
-		c.JSON(http.StatusOK, resp)
+	firstLogin := user.LastLoginAt == nil
+	now := time.Now().UTC()
+	if err := db.Model(&models.User{}).Where("id = ?", user.ID).Update("last_login_at", &now).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to record login"})
+		return
 	}
+	setup, err := middleware.MFASetupRequired(db, user, mfa)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to check two-factor authentication"})
+		return
+	}
+
+	tokens, err := startSession(db, c, user, tc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to start session"})
+		return
+	}
+
+	var resp loginResponse
+	resp.sessionTokens = tokens
+	resp.Role = user.Role.Name
+	resp.FirstLogin = firstLogin
+	resp.MustChangePassword = middleware.PasswordChangeRequired(user, policy, now)
+	resp.MFASetupRequired = setup
+	resp.User.ID = user.ID
+	resp.User.Username = user.Username
+	resp.User.Email = user.Email
+
+	c.JSON(http.StatusOK, resp)
 }
 
 // CheckDefaultPasswordStatus returns true if the specified default user (admin/student) has NOT changed their password.
@@ -112,7 +198,7 @@ func AuthCheckDefaultPasswordStatus(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
-func AuthMe(db *gorm.DB) gin.HandlerFunc {
+func AuthMe(db *gorm.DB, policy auth.PasswordPolicy, mfa auth.MFAConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userIDAny, ok := c.Get(string(middleware.ContextUserID))
 		if !ok {
@@ -126,19 +212,29 @@ func AuthMe(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusUnauthorized, gin.H{"message": "not authenticated"})
 			return
 		}
+		access, err := middleware.LoadUserAccess(db, user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load permissions"})
+			return
+		}
+		setup, err := middleware.MFASetupRequired(db, user, mfa)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to check two-factor authentication"})
+			return
+		}
 
 		response := gin.H{
 			"id":                   user.ID,
 			"username":             user.Username,
 			"email":                user.Email,
 			"role":                 user.Role.Name,
+			"roles":                access.Roles,
+			"permissions":          access.SortedPermissions(),
 			"passwordChangedAt":    user.PasswordChangedAt,
 			"passwordNeverChanged": user.PasswordChangedAt == nil,
-		}
-
-		// Only show default password if it hasn't been changed
-		if user.PasswordChangedAt == nil && user.DefaultPassword != "" {
-			response["defaultPassword"] = user.DefaultPassword
+			"mustChangePassword":   middleware.PasswordChangeRequired(user, policy, time.Now().UTC()),
+			"mfaEnabled":           user.TOTPEnabledAt != nil,
+			"mfaSetupRequired":     setup,
 		}
 
 		c.JSON(http.StatusOK, response)
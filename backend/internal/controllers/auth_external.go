@@ -0,0 +1,282 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/letera1/huhems-exam-system/backend/internal/audit"
+	"github.com/letera1/huhems-exam-system/backend/internal/auth"
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	errExternalNoRole    = errors.New("no role is mapped for this account")
+	errExternalNoEmail   = errors.New("the identity provider did not send an email address")
+	errExternalConflict  = errors.New("an account with this username or email already exists")
+	errExternalLastAdmin = errors.New("the identity provider no longer grants this account the admin role, but it is the last admin")
+)
+
+// externalLoginError answers a failed provisioning with the reason, where
+// it is one the user can act on.
+func externalLoginError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errExternalNoRole):
+		c.JSON(http.StatusForbidden, gin.H{"message": "your account has no access to this system"})
+	case errors.Is(err, errExternalNoEmail):
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+	case errors.Is(err, errExternalConflict):
+		c.JSON(http.StatusConflict, gin.H{"message": err.Error() + "; ask an administrator to link it"})
+	case errors.Is(err, errExternalLastAdmin):
+		c.JSON(http.StatusConflict, gin.H{"message": err.Error()})
+	default:
+		log.Printf("external login: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to sign in"})
+	}
+}
+
+// provisionExternalUser finds or creates the user an external identity
+// belongs to. An existing account is linked when the provider vouches for its
+// email address and linkableByEmail allows it. The identity provider is the
+// source of truth for the primary role and student profile, which are
+// brought up to date on every login; additional roles granted here are kept
+// as externalRoleSync describes, and the last admin is never demoted. New
+// users have no password here.
+func provisionExternalUser(db *gorm.DB, c *gin.Context, id auth.ExternalIdentity) (models.User, error) {
+	if id.Role == "" {
+		return models.User{}, errExternalNoRole
+	}
+	var role models.Role
+	if err := db.First(&role, "name = ?", id.Role).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			log.Printf("external login: mapped role %q does not exist", id.Role)
+			return models.User{}, errExternalNoRole
+		}
+		return models.User{}, err
+	}
+
+	var users []models.User
+	if err := db.Preload("Role").Where("auth_provider = ? AND external_id = ?", id.Provider, id.Subject).Limit(1).Find(&users).Error; err != nil {
+		return models.User{}, err
+	}
+	linked := false
+	if len(users) == 0 && id.Email != "" && id.EmailVerified {
+		if err := db.Preload("Role").Where("LOWER(email) = ?", strings.ToLower(id.Email)).Limit(1).Find(&users).Error; err != nil {
+			return models.User{}, err
+		}
+		if len(users) > 0 {
+			ok, err := linkableByEmail(db, users[0])
+			if err != nil {
+				return models.User{}, err
+			}
+			if !ok {
+				return models.User{}, errExternalConflict
+			}
+			linked = true
+		}
+	}
+
+	if len(users) == 0 {
+		if id.Email == "" {
+			return models.User{}, errExternalNoEmail
+		}
+		return createExternalUser(db, c, id, role)
+	}
+
+	user := users[0]
+	subject := id.Subject
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var extra []models.UserRole
+		if err := tx.Preload("Role").Where("user_id = ?", user.ID).Find(&extra).Error; err != nil {
+			return err
+		}
+		held := []string{user.Role.Name}
+		for _, r := range extra {
+			held = append(held, r.Role.Name)
+		}
+		keep, drop := externalRoleSync(held[1:], role.Name)
+		roles := append([]string{role.Name}, keep...)
+		sort.Strings(held)
+		sort.Strings(roles)
+		wasAdmin, isAdmin := false, false
+		for _, r := range held {
+			wasAdmin = wasAdmin || r == models.RoleAdmin
+		}
+		for _, r := range roles {
+			isAdmin = isAdmin || r == models.RoleAdmin
+		}
+		if wasAdmin && !isAdmin {
+			admins, err := countAdmins(tx)
+			if err != nil {
+				return err
+			}
+			if admins <= 1 {
+				return errExternalLastAdmin
+			}
+		}
+
+		q := tx.Model(&models.User{}).Where("id = ?", user.ID)
+		if linked {
+			// Never take over an account another identity has linked since.
+			q = q.Where("external_id IS NULL")
+		}
+		res := q.Updates(map[string]any{
+			"role_id":       role.ID,
+			"auth_provider": id.Provider,
+			"external_id":   &subject,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errExternalConflict
+		}
+		if len(drop) > 0 {
+			err := tx.Unscoped().
+				Where("user_id = ? AND role_id IN (?)", user.ID, tx.Model(&models.Role{}).Select("id").Where("name IN ?", drop)).
+				Delete(&models.UserRole{}).Error
+			if err != nil {
+				return err
+			}
+		}
+		if err := syncStudentProfile(tx, user, id, role); err != nil {
+			return err
+		}
+		if !linked && user.RoleID == role.ID && len(drop) == 0 {
+			return nil
+		}
+		return audit.RecordAs(tx, c, user.ID, audit.Entry{
+			Action:     audit.ActionUserExternalSync,
+			EntityType: audit.EntityUser,
+			EntityID:   user.ID,
+			Before:     gin.H{"role": user.Role.Name, "roles": held, "provider": user.AuthProvider},
+			After:      gin.H{"role": role.Name, "roles": roles, "provider": id.Provider},
+		})
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	user.RoleID, user.Role = role.ID, role
+	user.AuthProvider, user.ExternalID = id.Provider, &subject
+	return user, nil
+}
+
+// externalRoleSync decides which of the additional roles an account holds,
+// beyond its primary role, survive a login that maps it to mapped. The
+// mapped role becomes the primary role, so an additional copy of it is
+// dropped. Other roles an administrator granted are kept, unless the account
+// is mapped to student: students cannot hold staff roles.
+func externalRoleSync(extra []string, mapped string) (keep, drop []string) {
+	for _, r := range extra {
+		if r == mapped || mapped == models.RoleStudent {
+			drop = append(drop, r)
+		} else {
+			keep = append(keep, r)
+		}
+	}
+	return keep, drop
+}
+
+// linkableByEmail reports whether an existing account may be linked to an
+// external identity on the strength of a verified email address alone: a
+// local student account not linked to any identity yet. Staff accounts are
+// linked by an administrator, so that whoever controls a matching address at
+// the provider cannot take one over.
+func linkableByEmail(db *gorm.DB, u models.User) (bool, error) {
+	if !hasLocalPassword(u) || u.ExternalID != nil || u.Role.Name != models.RoleStudent {
+		return false, nil
+	}
+	var extra int64
+	if err := db.Model(&models.UserRole{}).Where("user_id = ?", u.ID).Count(&extra).Error; err != nil {
+		return false, err
+	}
+	return extra == 0, nil
+}
+
+func createExternalUser(db *gorm.DB, c *gin.Context, id auth.ExternalIdentity, role models.Role) (models.User, error) {
+	var taken int64
+	if err := db.Model(&models.User{}).Where("username = ? OR LOWER(email) = ?", id.Username, strings.ToLower(id.Email)).Count(&taken).Error; err != nil {
+		return models.User{}, err
+	}
+	if taken > 0 {
+		return models.User{}, errExternalConflict
+	}
+	subject := id.Subject
+	user := models.User{
+		Username:     id.Username,
+		Email:        id.Email,
+		RoleID:       role.ID,
+		AuthProvider: id.Provider,
+		ExternalID:   &subject,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		if err := syncStudentProfile(tx, user, id, role); err != nil {
+			return err
+		}
+		return audit.RecordAs(tx, c, user.ID, audit.Entry{
+			Action:     audit.ActionUserProvision,
+			EntityType: audit.EntityUser,
+			EntityID:   user.ID,
+			After:      gin.H{"username": user.Username, "email": user.Email, "role": role.Name, "provider": id.Provider},
+		})
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	user.Role = role
+	return user, nil
+}
+
+// syncStudentProfile gives a student the profile fields the identity
+// provider sends, creating the profile if they have none yet.
+func syncStudentProfile(tx *gorm.DB, user models.User, id auth.ExternalIdentity, role models.Role) error {
+	if role.Name != models.RoleStudent {
+		return nil
+	}
+	var students []models.Student
+	if err := tx.Where("user_id = ?", user.ID).Limit(1).Find(&students).Error; err != nil {
+		return err
+	}
+	if len(students) == 0 {
+		student := models.Student{UserID: user.ID, FullName: id.FullName, Year: id.Year, Department: id.Department}
+		if student.FullName == "" {
+			student.FullName = user.Username
+		}
+		if student.Year <= 0 {
+			student.Year = 1
+		}
+		return tx.Create(&student).Error
+	}
+	updates := map[string]any{}
+	if id.FullName != "" {
+		updates["full_name"] = id.FullName
+	}
+	if id.Department != "" {
+		updates["department"] = id.Department
+	}
+	if id.Year > 0 {
+		updates["year"] = id.Year
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return tx.Model(&models.Student{}).Where("id = ?", students[0].ID).Updates(updates).Error
+}
+
+// passwordLoginDisabled reports whether the role may not sign in with a
+// local password.
+func passwordLoginDisabled(role string, disabled []string) bool {
+	for _, r := range disabled {
+		if strings.EqualFold(r, role) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+)
+
+func TestExternalRoleSync(t *testing.T) {
+	tests := []struct {
+		name   string
+		extra  []string
+		mapped string
+		keep   []string
+		drop   []string
+	}{
+		{"no additional roles", nil, models.RoleInstructor, nil, nil},
+		{"granted role survives a login", []string{"grader"}, models.RoleInstructor, []string{"grader"}, nil},
+		{"granted admin survives a login", []string{models.RoleAdmin}, models.RoleInstructor, []string{models.RoleAdmin}, nil},
+		{"copy of the mapped role is dropped", []string{models.RoleInstructor, "grader"}, models.RoleInstructor, []string{"grader"}, []string{models.RoleInstructor}},
+		{"student keeps no staff roles", []string{"grader", models.RoleAdmin}, models.RoleStudent, nil, []string{"grader", models.RoleAdmin}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keep, drop := externalRoleSync(tt.extra, tt.mapped)
+			if !reflect.DeepEqual(keep, tt.keep) || !reflect.DeepEqual(drop, tt.drop) {
+				t.Errorf("externalRoleSync(%v, %q) = %v, %v, want %v, %v", tt.extra, tt.mapped, keep, drop, tt.keep, tt.drop)
+			}
+		})
+	}
+}
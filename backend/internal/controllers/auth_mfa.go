@@ -0,0 +1,446 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/letera1/huhems-exam-system/backend/internal/audit"
+	"github.com/letera1/huhems-exam-system/backend/internal/auth"
+	"github.com/letera1/huhems-exam-system/backend/internal/middleware"
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"github.com/letera1/huhems-exam-system/backend/internal/ratelimit"
+	"gorm.io/gorm"
+)
+
+const (
+	// mfaTokenTTL is how long a user has to enter their second factor after
+	// their password was accepted.
+	mfaTokenTTL = 5 * time.Minute
+	// recoveryCodeCount is how many recovery codes a user holds at a time.
+	recoveryCodeCount = 10
+)
+
+type mfaLoginRequest struct {
+	MFAToken     string `json:"mfaToken"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+type mfaCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type mfaDisableRequest struct {
+	Password     string `json:"password"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+// checkTOTP accepts a code from the user's authenticator. The accepted time
+// step is recorded conditionally, so of two concurrent uses of one code only
+// the first succeeds.
+func checkTOTP(db *gorm.DB, mfa auth.MFAConfig, user models.User, code string) (bool, error) {
+	if user.TOTPSecret == "" {
+		return false, nil
+	}
+	secret, err := auth.OpenSecret(mfa.EncryptionKey, user.TOTPSecret)
+	if err != nil {
+		return false, err
+	}
+	step, ok := auth.VerifyTOTP(secret, code, time.Now(), user.TOTPLastStep)
+	if !ok {
+		return false, nil
+	}
+	res := db.Model(&models.User{}).Where("id = ? AND totp_last_step < ?", user.ID, step).Update("totp_last_step", step)
+	return res.RowsAffected == 1, res.Error
+}
+
+// useRecoveryCode spends one of the user's recovery codes.
+func useRecoveryCode(db *gorm.DB, userID uuid.UUID, code string) (bool, error) {
+	if strings.TrimSpace(code) == "" {
+		return false, nil
+	}
+	now := time.Now().UTC()
+	res := db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, auth.HashRecoveryCode(code)).
+		Update("used_at", &now)
+	return res.RowsAffected == 1, res.Error
+}
+
+// checkSecondFactor accepts either a TOTP code or a recovery code.
+func checkSecondFactor(db *gorm.DB, mfa auth.MFAConfig, user models.User, code, recoveryCode string) (bool, error) {
+	if strings.TrimSpace(code) != "" {
+		return checkTOTP(db, mfa, user, code)
+	}
+	return useRecoveryCode(db, user.ID, recoveryCode)
+}
+
+// replaceRecoveryCodes voids the user's recovery codes and returns a fresh
+// set. Only their hashes are stored.
+func replaceRecoveryCodes(tx *gorm.DB, userID uuid.UUID) ([]string, error) {
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, 0, recoveryCodeCount)
+	rows := make([]models.RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, hash, err := auth.NewRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		rows = append(rows, models.RecoveryCode{UserID: userID, CodeHash: hash})
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// clearMFA turns two-factor authentication off for the user and voids their
+// recovery codes.
+func clearMFA(tx *gorm.DB, userID uuid.UUID) error {
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]any{
+		"totp_secret":     "",
+		"totp_enabled_at": nil,
+		"totp_last_step":  0,
+	}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+}
+
+func loadCurrentUser(c *gin.Context, db *gorm.DB) (models.User, bool) {
+	var user models.User
+	if err := db.First(&user, "id = ?", currentUserID(c)).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "not authenticated"})
+		return models.User{}, false
+	}
+	return user, true
+}
+
+// AuthLoginMFA is the second login step for users with two-factor
+// authentication. It takes the mfaToken from AuthLogin and a code from the
+// user's authenticator or one of their recovery codes. Wrong codes count as
+// failed logins.
+func AuthLoginMFA(db *gorm.DB, tc auth.TokenConfig, guard *ratelimit.LoginGuard, policy auth.PasswordPolicy, mfa auth.MFAConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req mfaLoginRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
+			return
+		}
+		if req.MFAToken == "" || (strings.TrimSpace(req.Code) == "" && strings.TrimSpace(req.RecoveryCode) == "") {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "mfaToken and code or recoveryCode are required"})
+			return
+		}
+		userID, err := auth.ParseMFAToken(req.MFAToken, tc.Secret)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "login expired; sign in again"})
+			return
+		}
+
+		ipKey := ipThrottleKey(c.ClientIP())
+		if !reserveLoginAttempt(c, guard, ipKey, guard.IP) {
+			return
+		}
+		var user models.User
+		if err := db.Preload("Role").First(&user, "id = ?", userID).Error; err != nil {
+			releaseLoginAttempt(c, guard, ipKey, guard.IP)
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "login expired; sign in again"})
+			return
+		}
+		accountKey := accountThrottleKey(&user, "")
+		if !reserveLoginAttempt(c, guard, accountKey, guard.Account) {
+			releaseLoginAttempt(c, guard, ipKey, guard.IP)
+			return
+		}
+		// Only a wrong code counts as a failure.
+		release := func() {
+			releaseLoginAttempt(c, guard, ipKey, guard.IP)
+			releaseLoginAttempt(c, guard, accountKey, guard.Account)
+		}
+		if user.TOTPEnabledAt == nil {
+			// Two-factor authentication was reset after the password step.
+			release()
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "login expired; sign in again"})
+			return
+		}
+
+		ok, err := checkSecondFactor(db, mfa, user, req.Code, req.RecoveryCode)
+		if err != nil {
+			release()
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to check code"})
+			return
+		}
+		if !ok {
+			recordLoginFailure(db, c, guard, &user, user.Username)
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "invalid code"})
+			return
+		}
+		release()
+
+		completeLogin(db, c, user, tc, guard, policy, mfa)
+	}
+}
+
+// AuthMFAStatus reports whether the caller has two-factor authentication
+// and how many recovery codes they have left.
+func AuthMFAStatus(db *gorm.DB, mfa auth.MFAConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := loadCurrentUser(c, db)
+		if !ok {
+			return
+		}
+		var remaining int64
+		if err := db.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", user.ID).Count(&remaining).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load recovery codes"})
+			return
+		}
+		required, err := middleware.MFARequired(db, user.ID, mfa)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to check two-factor authentication"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"enabled":                user.TOTPEnabledAt != nil,
+			"enabledAt":              user.TOTPEnabledAt,
+			"required":               required,
+			"recoveryCodesRemaining": remaining,
+		})
+	}
+}
+
+// AuthMFASetup starts enrollment: it generates a TOTP secret and returns it
+// with the otpauth:// URI for a QR code. The secret is only used once
+// AuthMFAEnable confirms the user's authenticator produces valid codes;
+// calling this again replaces an unconfirmed secret.
+func AuthMFASetup(db *gorm.DB, mfa auth.MFAConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := loadCurrentUser(c, db)
+		if !ok {
+			return
+		}
+		if user.TOTPEnabledAt != nil {
+			c.JSON(http.StatusConflict, gin.H{"message": "two-factor authentication is already enabled"})
+			return
+		}
+		secret, err := auth.NewTOTPSecret()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to generate secret"})
+			return
+		}
+		sealed, err := auth.SealSecret(mfa.EncryptionKey, secret)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to generate secret"})
+			return
+		}
+		if err := db.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]any{
+			"totp_secret":    sealed,
+			"totp_last_step": 0,
+		}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to save secret"})
+			return
+		}
+		noStore(c)
+		c.JSON(http.StatusOK, gin.H{
+			"secret":     secret,
+			"otpauthUri": auth.TOTPProvisioningURI(mfa.Issuer, user.Username, secret),
+		})
+	}
+}
+
+// AuthMFAEnable finishes enrollment with a code from the authenticator and
+// returns the user's recovery codes. They are shown only this once.
+func AuthMFAEnable(db *gorm.DB, mfa auth.MFAConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req mfaCodeRequest
+		if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Code) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "code is required"})
+			return
+		}
+		user, ok := loadCurrentUser(c, db)
+		if !ok {
+			return
+		}
+		if user.TOTPEnabledAt != nil {
+			c.JSON(http.StatusConflict, gin.H{"message": "two-factor authentication is already enabled"})
+			return
+		}
+		if user.TOTPSecret == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "start two-factor setup first"})
+			return
+		}
+		valid, err := checkTOTP(db, mfa, user, req.Code)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to check code"})
+			return
+		}
+		if !valid {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid code"})
+			return
+		}
+
+		var codes []string
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			now := time.Now().UTC()
+			if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Update("totp_enabled_at", &now).Error; err != nil {
+				return err
+			}
+			if codes, err = replaceRecoveryCodes(tx, user.ID); err != nil {
+				return err
+			}
+			return audit.Record(tx, c, audit.Entry{Action: audit.ActionMFAEnable, EntityType: audit.EntityUser, EntityID: user.ID})
+		}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to enable two-factor authentication"})
+			return
+		}
+
+		noStore(c)
+		c.JSON(http.StatusOK, gin.H{"enabled": true, "recoveryCodes": codes})
+	}
+}
+
+// AuthMFADisable turns two-factor authentication off. It takes the password
+// and a current code or recovery code, and is refused while the user's role
+// requires two-factor authentication.
+func AuthMFADisable(db *gorm.DB, mfa auth.MFAConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req mfaDisableRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
+			return
+		}
+		if req.Password == "" || (strings.TrimSpace(req.Code) == "" && strings.TrimSpace(req.RecoveryCode) == "") {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "password and code or recoveryCode are required"})
+			return
+		}
+		user, ok := loadCurrentUser(c, db)
+		if !ok {
+			return
+		}
+		if user.TOTPEnabledAt == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "two-factor authentication is not enabled"})
+			return
+		}
+		required, err := middleware.MFARequired(db, user.ID, mfa)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to check two-factor authentication"})
+			return
+		}
+		if required {
+			c.JSON(http.StatusForbidden, gin.H{"message": "two-factor authentication is required for your role"})
+			return
+		}
+		if auth.ComparePassword(user.PasswordHash, req.Password) != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "password is incorrect"})
+			return
+		}
+		valid, err := checkSecondFactor(db, mfa, user, req.Code, req.RecoveryCode)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to check code"})
+			return
+		}
+		if !valid {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid code"})
+			return
+		}
+
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			if err := clearMFA(tx, user.ID); err != nil {
+				return err
+			}
+			return audit.Record(tx, c, audit.Entry{Action: audit.ActionMFADisable, EntityType: audit.EntityUser, EntityID: user.ID})
+		}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to disable two-factor authentication"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"enabled": false})
+	}
+}
+
+// AuthMFARecoveryCodes replaces the caller's recovery codes, for example
+// after they have used most of them. It takes a current TOTP code.
+func AuthMFARecoveryCodes(db *gorm.DB, mfa auth.MFAConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req mfaCodeRequest
+		if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Code) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "code is required"})
+			return
+		}
+		user, ok := loadCurrentUser(c, db)
+		if !ok {
+			return
+		}
+		if user.TOTPEnabledAt == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "two-factor authentication is not enabled"})
+			return
+		}
+		valid, err := checkTOTP(db, mfa, user, req.Code)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to check code"})
+			return
+		}
+		if !valid {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid code"})
+			return
+		}
+
+		var codes []string
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			if codes, err = replaceRecoveryCodes(tx, user.ID); err != nil {
+				return err
+			}
+			return audit.Record(tx, c, audit.Entry{Action: audit.ActionMFARecoveryCodes, EntityType: audit.EntityUser, EntityID: user.ID})
+		}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to generate recovery codes"})
+			return
+		}
+
+		noStore(c)
+		c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+	}
+}
+
+// AdminUserMFAReset turns off two-factor authentication for a user who has
+// lost both their authenticator and their recovery codes, and signs them out.
+// If their role requires two-factor authentication they enroll again at
+// their next login. The user may hold no permission the caller lacks.
+func AdminUserMFAReset(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := findUser(c, db)
+		if !ok {
+			return
+		}
+		if user.ID == currentUserID(c) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "use your recovery codes for your own account"})
+			return
+		}
+		if !checkNoEscalation(c, db, []uuid.UUID{user.ID}) {
+			return
+		}
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			if err := clearMFA(tx, user.ID); err != nil {
+				return err
+			}
+			if _, err := revokeSessions(tx, user.ID, uuid.Nil, models.SessionRevokedMFAReset); err != nil {
+				return err
+			}
+			return audit.Record(tx, c, audit.Entry{
+				Action:     audit.ActionMFAAdminReset,
+				EntityType: audit.EntityUser,
+				EntityID:   user.ID,
+				Before:     gin.H{"mfaEnabled": user.TOTPEnabledAt != nil},
+				After:      gin.H{"username": user.Username, "mfaEnabled": false},
+			})
+		}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to reset two-factor authentication"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"reset": true})
+	}
+}
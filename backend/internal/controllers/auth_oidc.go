@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/letera1/huhems-exam-system/backend/internal/auth"
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"github.com/letera1/huhems-exam-system/backend/internal/oidc"
+	"github.com/letera1/huhems-exam-system/backend/internal/ratelimit"
+	"gorm.io/gorm"
+)
+
+// oidcStateTTL is how long a user has to sign in at the identity provider.
+const oidcStateTTL = 10 * time.Minute
+
+type oidcCallbackRequest struct {
+	Code  string `json:"code"`
+	State string `json:"state"`
+}
+
+// AuthProviders tells the login page which ways of signing in are offered.
+func AuthProviders(oidcEnabled bool, oidcLabel string, passwordDisabled []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if passwordDisabled == nil {
+			passwordDisabled = []string{}
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"oidc":                       gin.H{"enabled": oidcEnabled, "label": oidcLabel},
+			"passwordLoginDisabledRoles": passwordDisabled,
+		})
+	}
+}
+
+// AuthOIDCStart begins a single sign-on login. It returns the identity
+// provider's login page to send the browser to; the provider sends the user
+// back to the frontend callback page with a code and the state.
+func AuthOIDCStart(db *gorm.DB, provider *oidc.Provider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var values [3]string
+		for i := range values {
+			v, err := oidc.RandomToken()
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to start sign-in"})
+				return
+			}
+			values[i] = v
+		}
+		state, nonce, verifier := values[0], values[1], values[2]
+
+		authURL, err := provider.AuthCodeURL(c.Request.Context(), state, nonce, verifier)
+		if err != nil {
+			log.Printf("oidc: %v", err)
+			c.JSON(http.StatusBadGateway, gin.H{"message": "single sign-on is unavailable"})
+			return
+		}
+
+		now := time.Now().UTC()
+		db.Unscoped().Where("expires_at < ?", now).Delete(&models.OIDCLoginState{})
+		if err := db.Create(&models.OIDCLoginState{
+			StateHash:    oidc.HashState(state),
+			CodeVerifier: verifier,
+			Nonce:        nonce,
+			ExpiresAt:    now.Add(oidcStateTTL),
+		}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to start sign-in"})
+			return
+		}
+		noStore(c)
+		c.JSON(http.StatusOK, gin.H{"authorizationUrl": authURL})
+	}
+}
+
+// AuthOIDCCallback finishes a single sign-on login with the code and state
+// the identity provider returned. The user is created or updated from the
+// ID token's claims, then signed in like after a password login, including
+// the two-factor step if they have enrolled.
+func AuthOIDCCallback(db *gorm.DB, provider *oidc.Provider, mapping oidc.Mapping, tc auth.TokenConfig, guard *ratelimit.LoginGuard, policy auth.PasswordPolicy, mfa auth.MFAConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req oidcCallbackRequest
+		if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Code) == "" || strings.TrimSpace(req.State) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "code and state are required"})
+			return
+		}
+
+		// The state is single-use: only the request that deletes it may
+		// continue.
+		var state models.OIDCLoginState
+		if err := db.First(&state, "state_hash = ?", oidc.HashState(req.State)).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "sign-in expired; try again"})
+			return
+		}
+		res := db.Unscoped().Where("id = ?", state.ID).Delete(&models.OIDCLoginState{})
+		if res.Error != nil || res.RowsAffected != 1 || !time.Now().UTC().Before(state.ExpiresAt) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "sign-in expired; try again"})
+			return
+		}
+
+		claims, err := provider.Exchange(c.Request.Context(), req.Code, state.CodeVerifier)
+		if err != nil {
+			log.Printf("oidc: %v", err)
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "single sign-on failed"})
+			return
+		}
+		if claims.String("nonce") != state.Nonce {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "single sign-on failed"})
+			return
+		}
+
+		user, err := provisionExternalUser(db, c, mapping.Identity(claims))
+		if err != nil {
+			externalLoginError(c, err)
+			return
+		}
+		beginLogin(db, c, user, tc, guard, policy, mfa)
+	}
+}
@@ -5,11 +5,12 @@ import (
 	"strings"
 	"time"
 
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/letera1/huhems-exam-system/backend/internal/audit"
 	"github.com/letera1/huhems-exam-system/backend/internal/auth"
 	"github.com/letera1/huhems-exam-system/backend/internal/middleware"
 	"github.com/letera1/huhems-exam-system/backend/internal/models"
-	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -18,7 +19,53 @@ type changePasswordRequest struct {
 	NewPassword string `json:"newPassword"`
 }
 
-func AuthChangePassword(db *gorm.DB) gin.HandlerFunc {
+// passwordReused reports whether password matches the user's current
+// password or one of the previous ones the policy remembers.
+func passwordReused(db *gorm.DB, user models.User, policy auth.PasswordPolicy, password string) (bool, error) {
+	if auth.ComparePassword(user.PasswordHash, password) == nil {
+		return true, nil
+	}
+	if policy.History <= 1 {
+		return false, nil
+	}
+	var hashes []string
+	if err := db.Model(&models.PasswordHistory{}).Where("user_id = ?", user.ID).
+		Order("created_at desc").Limit(policy.History-1).Pluck("password_hash", &hashes).Error; err != nil {
+		return false, err
+	}
+	for _, h := range hashes {
+		if auth.ComparePassword(h, password) == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// setPassword replaces the user's password with hash, remembers the old one
+// for the reuse check and clears any pending forced change.
+func setPassword(tx *gorm.DB, user models.User, policy auth.PasswordPolicy, hash string) error {
+	now := time.Now().UTC()
+	if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]any{
+		"password_hash":        hash,
+		"password_changed_at":  &now,
+		"must_change_password": false,
+	}).Error; err != nil {
+		return err
+	}
+	if policy.History <= 1 {
+		return tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.PasswordHistory{}).Error
+	}
+	if err := tx.Create(&models.PasswordHistory{UserID: user.ID, PasswordHash: user.PasswordHash}).Error; err != nil {
+		return err
+	}
+	keep := tx.Model(&models.PasswordHistory{}).Select("id").Where("user_id = ?", user.ID).
+		Order("created_at desc").Limit(policy.History - 1)
+	return tx.Unscoped().Where("user_id = ? AND id NOT IN (?)", user.ID, keep).Delete(&models.PasswordHistory{}).Error
+}
+
+// AuthChangePassword lets a user replace their password with one that meets
+// the password policy.
+func AuthChangePassword(db *gorm.DB, policy auth.PasswordPolicy) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userIDAny, ok := c.Get(string(middleware.ContextUserID))
 		if !ok {
@@ -44,10 +91,6 @@ func AuthChangePassword(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"message": "newPassword is required"})
 			return
 		}
-		if len(req.NewPassword) < 8 {
-			c.JSON(http.StatusBadRequest, gin.H{"message": "newPassword must be at least 8 characters"})
-			return
-		}
 		if req.NewPassword == req.OldPassword {
 			c.JSON(http.StatusBadRequest, gin.H{"message": "newPassword must be different from oldPassword"})
 			return
@@ -63,6 +106,19 @@ func AuthChangePassword(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusUnauthorized, gin.H{"message": "old password is incorrect"})
 			return
 		}
+		if err := policy.Check(req.NewPassword, user.Username, user.Email); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		reused, err := passwordReused(db, user, policy, req.NewPassword)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to check password history"})
+			return
+		}
+		if reused {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "password was used recently; choose a different one"})
+			return
+		}
 
 		hash, err := auth.HashPassword(req.NewPassword)
 		if err != nil {
@@ -70,14 +126,12 @@ func AuthChangePassword(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		now := time.Now().UTC()
-		updates := map[string]interface{}{
-			"password_hash":      hash,
-			"password_changed_at": &now,
-			"default_password":   "", // Clear the default password
-		}
-
-		if err := db.Model(&models.User{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			if err := setPassword(tx, user, policy, hash); err != nil {
+				return err
+			}
+			return audit.Record(tx, c, audit.Entry{Action: audit.ActionPasswordChange, EntityType: audit.EntityUser, EntityID: user.ID})
+		}); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to update password"})
 			return
 		}
@@ -85,3 +139,18 @@ func AuthChangePassword(db *gorm.DB) gin.HandlerFunc {
 		c.JSON(http.StatusOK, gin.H{"message": "password updated"})
 	}
 }
+
+// AuthPasswordPolicy describes the password policy so clients can show it
+// before the user picks a password.
+func AuthPasswordPolicy(policy auth.PasswordPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"minLength":      policy.MinLength,
+			"minClasses":     policy.MinClasses,
+			"history":        policy.History,
+			"maxAgeSeconds":  int64(policy.MaxAge.Seconds()),
+			"blocksCommon":   true,
+			"blocksUsername": true,
+		})
+	}
+}
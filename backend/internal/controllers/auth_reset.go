@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/letera1/huhems-exam-system/backend/internal/audit"
+	"github.com/letera1/huhems-exam-system/backend/internal/auth"
+	"github.com/letera1/huhems-exam-system/backend/internal/mailer"
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"github.com/letera1/huhems-exam-system/backend/internal/ratelimit"
+	"gorm.io/gorm"
+)
+
+// resetRequestCooldown is the minimum time between two reset emails to the
+// same account.
+const resetRequestCooldown = time.Minute
+
+var errResetTokenUsed = errors.New("reset token already used")
+
+// errExternalAccount is returned when a password reset targets an account
+// that signs in through an identity provider.
+var errExternalAccount = errors.New("account signs in through an identity provider")
+
+// hasLocalPassword reports whether the user signs in with a password kept
+// here. Passwords of directory and single sign-on accounts are managed by
+// their identity provider, so they are never reset here.
+func hasLocalPassword(u models.User) bool {
+	return u.AuthProvider == "" || u.AuthProvider == models.AuthProviderLocal
+}
+
+type forgotPasswordRequest struct {
+	UsernameOrEmail string `json:"usernameOrEmail"`
+}
+
+type resetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"newPassword"`
+}
+
+func resetLink(base, token string) string {
+	sep := "?"
+	if strings.Contains(base, "?") {
+		sep = "&"
+	}
+	return base + sep + "token=" + url.QueryEscape(token)
+}
+
+func resetEmail(user models.User, link string, ttl time.Duration) mailer.Message {
+	return mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your HUHEMS password",
+		Body: fmt.Sprintf("Hello %s,\n\n"+
+			"Someone asked to reset the password of your HUHEMS account. To choose a new password, open this link:\n\n"+
+			"%s\n\n"+
+			"The link works once and expires in %s. If you did not ask for this, you can ignore this email; your password has not changed.\n",
+			user.Username, link, ttl),
+	}
+}
+
+// AuthForgotPassword emails a password reset link to the account with the
+// given username or email. The response is the same whether or not the
+// account exists, and whether or not it has a password here to reset.
+func AuthForgotPassword(db *gorm.DB, mail mailer.Mailer, resetURL string, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req forgotPasswordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
+			return
+		}
+		req.UsernameOrEmail = strings.TrimSpace(req.UsernameOrEmail)
+		if req.UsernameOrEmail == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "usernameOrEmail is required"})
+			return
+		}
+		sent := func() {
+			c.JSON(http.StatusOK, gin.H{"message": "if the account exists, a password reset link has been sent to its email address"})
+		}
+
+		var users []models.User
+		if err := db.Where("username = ? OR email = ?", req.UsernameOrEmail, strings.ToLower(req.UsernameOrEmail)).
+			Limit(1).Find(&users).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to process request"})
+			return
+		}
+		if len(users) == 0 || !hasLocalPassword(users[0]) {
+			sent()
+			return
+		}
+		user := users[0]
+		now := time.Now().UTC()
+
+		var recent int64
+		if err := db.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND created_at > ?", user.ID, now.Add(-resetRequestCooldown)).
+			Count(&recent).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to process request"})
+			return
+		}
+		if recent > 0 {
+			sent()
+			return
+		}
+
+		token, hash, err := auth.NewResetToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to process request"})
+			return
+		}
+		// Only the newest link works.
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Unscoped().Where("user_id = ? AND used_at IS NULL", user.ID).Delete(&models.PasswordResetToken{}).Error; err != nil {
+				return err
+			}
+			return tx.Create(&models.PasswordResetToken{UserID: user.ID, TokenHash: hash, ExpiresAt: now.Add(ttl), IP: c.ClientIP()}).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to process request"})
+			return
+		}
+
+		// Send in the background so the response time does not reveal that
+		// the account exists.
+		msg := resetEmail(user, resetLink(resetURL, token), ttl)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := mail.Send(ctx, msg); err != nil {
+				log.Printf("password reset: failed to send email to user %s: %v", user.ID, err)
+			}
+		}()
+		sent()
+	}
+}
+
+// AuthResetPassword sets a new password using a reset link's token. The
+// token works once; all of the user's sessions are ended and any login
+// lockout on the account is lifted.
+func AuthResetPassword(db *gorm.DB, policy auth.PasswordPolicy, guard *ratelimit.LoginGuard) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req resetPasswordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
+			return
+		}
+		req.Token = strings.TrimSpace(req.Token)
+		req.NewPassword = strings.TrimSpace(req.NewPassword)
+		if req.Token == "" || req.NewPassword == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "token and newPassword are required"})
+			return
+		}
+
+		now := time.Now().UTC()
+		var tokens []models.PasswordResetToken
+		if err := db.Preload("User").Where("token_hash = ?", auth.HashResetToken(req.Token)).Limit(1).Find(&tokens).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load reset token"})
+			return
+		}
+		if len(tokens) == 0 || tokens[0].UsedAt != nil || !now.Before(tokens[0].ExpiresAt) || tokens[0].User.ID == uuid.Nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "reset link is invalid or has expired"})
+			return
+		}
+		rt := tokens[0]
+		user := rt.User
+		if !hasLocalPassword(user) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "this account signs in through single sign-on; reset the password there"})
+			return
+		}
+
+		if err := policy.Check(req.NewPassword, user.Username, user.Email); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		reused, err := passwordReused(db, user, policy, req.NewPassword)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to check password history"})
+			return
+		}
+		if reused {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "password was used recently; choose a different one"})
+			return
+		}
+		hash, err := auth.HashPassword(req.NewPassword)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to hash password"})
+			return
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			// Claiming the token first makes concurrent uses of it race
+			// safely: only one of them resets the password.
+			res := tx.Model(&models.PasswordResetToken{}).Where("id = ? AND used_at IS NULL", rt.ID).Update("used_at", &now)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return errResetTokenUsed
+			}
+			if err := setPassword(tx, user, policy, hash); err != nil {
+				return err
+			}
+			if _, err := revokeSessions(tx, user.ID, uuid.Nil, models.SessionRevokedPasswordReset); err != nil {
+				return err
+			}
+			// The user proved control of the account, so they are the actor.
+			return audit.RecordAs(tx, c, user.ID, audit.Entry{Action: audit.ActionPasswordReset, EntityType: audit.EntityUser, EntityID: user.ID, After: gin.H{"method": "email"}})
+		})
+		if errors.Is(err, errResetTokenUsed) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "reset link is invalid or has expired"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to reset password"})
+			return
+		}
+
+		if err := guard.Reset(c.Request.Context(), accountThrottleKey(&user, "")); err != nil {
+			log.Printf("login throttle: %v", err)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "password reset; sign in with your new password"})
+	}
+}
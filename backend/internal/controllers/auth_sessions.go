@@ -0,0 +1,355 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/letera1/huhems-exam-system/backend/internal/audit"
+	"github.com/letera1/huhems-exam-system/backend/internal/auth"
+	"github.com/letera1/huhems-exam-system/backend/internal/middleware"
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// refreshReuseGrace is how long after rotation the previous refresh token is
+// still accepted, without a new refresh token, instead of counting as reuse.
+const refreshReuseGrace = 30 * time.Second
+
+// sessionTokens is returned by login and refresh. RefreshToken is empty when
+// a refresh was answered within the grace period: the client keeps the
+// refresh token the winning request received.
+type sessionTokens struct {
+	Token            string    `json:"token"`
+	ExpiresIn        int64     `json:"expiresIn"`
+	RefreshToken     string    `json:"refreshToken,omitempty"`
+	RefreshExpiresAt time.Time `json:"refreshExpiresAt"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type sessionView struct {
+	ID         uuid.UUID  `json:"id"`
+	IP         string     `json:"ip"`
+	UserAgent  string     `json:"userAgent"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt time.Time  `json:"lastUsedAt"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	Current    bool       `json:"current,omitempty"`
+}
+
+func newSessionView(s models.Session) sessionView {
+	return sessionView{
+		ID:         s.ID,
+		IP:         s.IP,
+		UserAgent:  s.UserAgent,
+		CreatedAt:  s.CreatedAt,
+		LastUsedAt: s.LastUsedAt,
+		ExpiresAt:  s.ExpiresAt,
+		RevokedAt:  s.RevokedAt,
+	}
+}
+
+// startSession creates a session for a user who has just authenticated and
+// returns its first token pair. user.Role must be loaded.
+func startSession(db *gorm.DB, c *gin.Context, user models.User, tc auth.TokenConfig) (sessionTokens, error) {
+	refresh, hash, err := auth.NewRefreshToken()
+	if err != nil {
+		return sessionTokens{}, err
+	}
+	now := time.Now().UTC()
+	session := models.Session{
+		UserID:           user.ID,
+		RefreshTokenHash: hash,
+		ExpiresAt:        now.Add(tc.RefreshTTL),
+		LastUsedAt:       now,
+		IP:               c.ClientIP(),
+		UserAgent:        c.Request.UserAgent(),
+	}
+	if err := db.Create(&session).Error; err != nil {
+		return sessionTokens{}, err
+	}
+
+	// Drop this user's sessions that ended long enough ago to be of no
+	// further interest.
+	cutoff := now.Add(-tc.RefreshTTL)
+	db.Unscoped().Where("user_id = ? AND (expires_at < ? OR revoked_at < ?)", user.ID, cutoff, cutoff).Delete(&models.Session{})
+
+	token, err := auth.SignToken(user.ID, user.Role.Name, session.ID, tc.Secret, tc.AccessTTL)
+	if err != nil {
+		return sessionTokens{}, err
+	}
+	return sessionTokens{
+		Token:            token,
+		ExpiresIn:        int64(tc.AccessTTL.Seconds()),
+		RefreshToken:     refresh,
+		RefreshExpiresAt: session.ExpiresAt,
+	}, nil
+}
+
+// revokeSessions revokes the user's active sessions, except keep if it is
+// not uuid.Nil, and returns how many were revoked.
+func revokeSessions(db *gorm.DB, userID uuid.UUID, keep uuid.UUID, reason string) (int64, error) {
+	q := db.Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", userID)
+	if keep != uuid.Nil {
+		q = q.Where("id <> ?", keep)
+	}
+	now := time.Now().UTC()
+	res := q.Updates(map[string]any{"revoked_at": &now, "revoke_reason": reason})
+	return res.RowsAffected, res.Error
+}
+
+// withinRefreshGrace reports whether hash is the refresh token the session
+// rotated out moments ago. Requests sent in parallel by one browser all
+// refresh with the same token; those that lose the race are not reuse.
+func withinRefreshGrace(s models.Session, hash string, now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt) &&
+		s.PreviousTokenHash == hash && s.RotatedAt != nil && now.Sub(*s.RotatedAt) < refreshReuseGrace
+}
+
+// refreshAccessToken answers a refresh within the grace period with a new
+// access token for the session and no new refresh token.
+func refreshAccessToken(db *gorm.DB, c *gin.Context, tc auth.TokenConfig, session models.Session) {
+	var user models.User
+	if err := db.Preload("Role").First(&user, "id = ?", session.UserID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "session expired or revoked"})
+		return
+	}
+	token, err := auth.SignToken(user.ID, user.Role.Name, session.ID, tc.Secret, tc.AccessTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to sign token"})
+		return
+	}
+	c.JSON(http.StatusOK, sessionTokens{
+		Token:            token,
+		ExpiresIn:        int64(tc.AccessTTL.Seconds()),
+		RefreshExpiresAt: session.ExpiresAt,
+	})
+}
+
+func currentSessionID(c *gin.Context) uuid.UUID {
+	v, _ := c.Get(string(middleware.ContextSessionID))
+	id, _ := v.(uuid.UUID)
+	return id
+}
+
+// AuthRefresh exchanges a refresh token for a new access token and a new
+// refresh token. The session's expiry slides forward on every refresh. The
+// token it replaces stays usable for refreshReuseGrace; after that, using it
+// revokes the session.
+func AuthRefresh(db *gorm.DB, tc auth.TokenConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req refreshRequest
+		if err := c.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "refreshToken is required"})
+			return
+		}
+		hash := auth.HashRefreshToken(req.RefreshToken)
+		now := time.Now().UTC()
+
+		var session models.Session
+		if err := db.First(&session, "refresh_token_hash = ?", hash).Error; err != nil {
+			if err != gorm.ErrRecordNotFound {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load session"})
+				return
+			}
+			var rotated models.Session
+			if err := db.First(&rotated, "previous_token_hash = ? AND revoked_at IS NULL", hash).Error; err == nil && withinRefreshGrace(rotated, hash, now) {
+				refreshAccessToken(db, c, tc, rotated)
+				return
+			}
+			// A rotated-out token being used again means it was copied:
+			// end the session for both holders.
+			res := db.Model(&models.Session{}).
+				Where("previous_token_hash = ? AND revoked_at IS NULL", hash).
+				Updates(map[string]any{"revoked_at": &now, "revoke_reason": models.SessionRevokedReuse})
+			if res.Error == nil && res.RowsAffected > 0 {
+				c.JSON(http.StatusUnauthorized, gin.H{"message": "refresh token reused; session revoked"})
+				return
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "invalid refresh token"})
+			return
+		}
+		if session.RevokedAt != nil || !now.Before(session.ExpiresAt) {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "session expired or revoked"})
+			return
+		}
+
+		var user models.User
+		if err := db.Preload("Role").First(&user, "id = ?", session.UserID).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "session expired or revoked"})
+			return
+		}
+
+		refresh, newHash, err := auth.NewRefreshToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to issue token"})
+			return
+		}
+		expiresAt := now.Add(tc.RefreshTTL)
+		// Matching on the old hash makes concurrent refreshes with the same
+		// token race safely: only one of them rotates it.
+		res := db.Model(&models.Session{}).
+			Where("id = ? AND refresh_token_hash = ? AND revoked_at IS NULL", session.ID, hash).
+			Updates(map[string]any{
+				"refresh_token_hash":  newHash,
+				"previous_token_hash": hash,
+				"rotated_at":          now,
+				"expires_at":          expiresAt,
+				"last_used_at":        now,
+				"ip":                  c.ClientIP(),
+				"user_agent":          c.Request.UserAgent(),
+			})
+		if res.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to rotate token"})
+			return
+		}
+		if res.RowsAffected == 0 {
+			// A parallel request rotated the token first.
+			if err := db.First(&session, "id = ?", session.ID).Error; err == nil && withinRefreshGrace(session, hash, now) {
+				refreshAccessToken(db, c, tc, session)
+				return
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "invalid refresh token"})
+			return
+		}
+
+		token, err := auth.SignToken(user.ID, user.Role.Name, session.ID, tc.Secret, tc.AccessTTL)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to sign token"})
+			return
+		}
+		c.JSON(http.StatusOK, sessionTokens{
+			Token:            token,
+			ExpiresIn:        int64(tc.AccessTTL.Seconds()),
+			RefreshToken:     refresh,
+			RefreshExpiresAt: expiresAt,
+		})
+	}
+}
+
+// AuthLogout ends the session the refresh token belongs to. It succeeds even
+// if the session has already ended, so clients can always clear their state.
+func AuthLogout(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req refreshRequest
+		if err := c.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "refreshToken is required"})
+			return
+		}
+		now := time.Now().UTC()
+		if err := db.Model(&models.Session{}).
+			Where("refresh_token_hash = ? AND revoked_at IS NULL", auth.HashRefreshToken(req.RefreshToken)).
+			Updates(map[string]any{"revoked_at": &now, "revoke_reason": models.SessionRevokedLogout}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to end session"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	}
+}
+
+// AuthLogoutAll ends every session of the caller, including the current one.
+func AuthLogoutAll(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		revoked, err := revokeSessions(db, currentUserID(c), uuid.Nil, models.SessionRevokedLogoutAll)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to end sessions"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"revoked": revoked})
+	}
+}
+
+// AuthSessionsList lists the caller's active sessions.
+func AuthSessionsList(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var sessions []models.Session
+		if err := db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", currentUserID(c), time.Now().UTC()).
+			Order("last_used_at desc").
+			Find(&sessions).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load sessions"})
+			return
+		}
+		current := currentSessionID(c)
+		resp := make([]sessionView, 0, len(sessions))
+		for _, s := range sessions {
+			view := newSessionView(s)
+			view.Current = s.ID == current
+			resp = append(resp, view)
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+func findUser(c *gin.Context, db *gorm.DB) (models.User, bool) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid user id"})
+		return models.User{}, false
+	}
+	var user models.User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "user not found"})
+			return models.User{}, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load user"})
+		return models.User{}, false
+	}
+	return user, true
+}
+
+// AdminUserSessionsList lists a user's active sessions.
+func AdminUserSessionsList(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := findUser(c, db)
+		if !ok {
+			return
+		}
+		var sessions []models.Session
+		if err := db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", user.ID, time.Now().UTC()).
+			Order("last_used_at desc").
+			Find(&sessions).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load sessions"})
+			return
+		}
+		resp := make([]sessionView, 0, len(sessions))
+		for _, s := range sessions {
+			resp = append(resp, newSessionView(s))
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// AdminUserSessionsRevoke signs a user out everywhere. Their access tokens
+// stop working immediately.
+func AdminUserSessionsRevoke(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := findUser(c, db)
+		if !ok {
+			return
+		}
+		var revoked int64
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if revoked, err = revokeSessions(tx, user.ID, uuid.Nil, models.SessionRevokedAdmin); err != nil {
+				return err
+			}
+			return audit.Record(tx, c, audit.Entry{
+				Action:     audit.ActionSessionsRevoke,
+				EntityType: audit.EntityUser,
+				EntityID:   user.ID,
+				After:      gin.H{"username": user.Username, "revoked": revoked},
+			})
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to revoke sessions"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"revoked": revoked})
+	}
+}
@@ -0,0 +1,247 @@
+package controllers
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/letera1/huhems-exam-system/backend/internal/audit"
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"github.com/letera1/huhems-exam-system/backend/internal/ratelimit"
+	"gorm.io/gorm"
+)
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// accountThrottleKey keys an existing user by ID, so username and email
+// share one count, and an unknown identifier by itself, so unknown accounts
+// are throttled exactly like real ones.
+func accountThrottleKey(user *models.User, identifier string) string {
+	if user != nil {
+		return "account:" + user.ID.String()
+	}
+	return "account:" + strings.ToLower(identifier)
+}
+
+func lockoutThrottleKey(l models.LoginLockout) string {
+	if l.Scope == models.LockoutIP {
+		return ipThrottleKey(l.Identifier)
+	}
+	if l.UserID != nil {
+		return "account:" + l.UserID.String()
+	}
+	return "account:" + strings.ToLower(l.Identifier)
+}
+
+// tooManyAttempts rejects a login that arrives while its key is locked or
+// still waiting out its progressive delay.
+func tooManyAttempts(c *gin.Context, wait time.Duration, locked bool) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	msg := "too many login attempts; try again later"
+	if locked {
+		msg = "too many failed logins; temporarily locked"
+	}
+	c.JSON(http.StatusTooManyRequests, gin.H{"message": msg, "retryAfterSeconds": int(math.Ceil(wait.Seconds()))})
+}
+
+// reserveLoginAttempt counts a login attempt on key before its credentials
+// are checked. It writes a 429 and returns false if the key may not try
+// again yet. A reserved attempt is settled by recordLoginFailure or
+// releaseLoginAttempt.
+func reserveLoginAttempt(c *gin.Context, guard *ratelimit.LoginGuard, key string, p ratelimit.Policy) bool {
+	wait, locked, err := guard.Reserve(c.Request.Context(), key, p, time.Now().UTC())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to check login limits"})
+		return false
+	}
+	if wait > 0 {
+		tooManyAttempts(c, wait, locked)
+		return false
+	}
+	return true
+}
+
+// releaseLoginAttempt gives back an attempt reserved on key for a login
+// that did not fail on its credentials. Errors are only logged.
+func releaseLoginAttempt(c *gin.Context, guard *ratelimit.LoginGuard, key string, p ratelimit.Policy) {
+	if err := guard.Release(c.Request.Context(), key, p, time.Now().UTC()); err != nil {
+		log.Printf("login throttle: %v", err)
+	}
+}
+
+// recordLoginFailure settles the attempts reserved against the IP and the
+// account as failed and records any lockout they cause. Failures here are
+// only logged: the login has failed either way.
+func recordLoginFailure(db *gorm.DB, c *gin.Context, guard *ratelimit.LoginGuard, user *models.User, identifier string) {
+	now := time.Now().UTC()
+	ip := c.ClientIP()
+
+	if a, locked, err := guard.Fail(c.Request.Context(), ipThrottleKey(ip), guard.IP, now); err != nil {
+		log.Printf("login throttle: %v", err)
+	} else if locked {
+		recordLockout(db, models.LoginLockout{Scope: models.LockoutIP, Identifier: ip, IP: ip, Failures: a.Failures, LockedUntil: a.LockedUntil})
+	}
+
+	if a, locked, err := guard.Fail(c.Request.Context(), accountThrottleKey(user, identifier), guard.Account, now); err != nil {
+		log.Printf("login throttle: %v", err)
+	} else if locked {
+		l := models.LoginLockout{Scope: models.LockoutAccount, Identifier: identifier, IP: ip, Failures: a.Failures, LockedUntil: a.LockedUntil}
+		if user != nil {
+			id := user.ID
+			l.UserID = &id
+		}
+		recordLockout(db, l)
+	}
+}
+
+func recordLockout(db *gorm.DB, l models.LoginLockout) {
+	if err := db.Create(&l).Error; err != nil {
+		log.Printf("login throttle: failed to record lockout: %v", err)
+	}
+}
+
+type loginLockoutView struct {
+	ID           uuid.UUID  `json:"id"`
+	Scope        string     `json:"scope"`
+	Identifier   string     `json:"identifier"`
+	UserID       *uuid.UUID `json:"userId"`
+	IP           string     `json:"ip"`
+	Failures     int        `json:"failures"`
+	LockedAt     time.Time  `json:"lockedAt"`
+	LockedUntil  time.Time  `json:"lockedUntil"`
+	UnlockedAt   *time.Time `json:"unlockedAt"`
+	UnlockedByID *uuid.UUID `json:"unlockedById"`
+	Active       bool       `json:"active"`
+}
+
+func newLoginLockoutView(l models.LoginLockout, now time.Time) loginLockoutView {
+	return loginLockoutView{
+		ID:           l.ID,
+		Scope:        l.Scope,
+		Identifier:   l.Identifier,
+		UserID:       l.UserID,
+		IP:           l.IP,
+		Failures:     l.Failures,
+		LockedAt:     l.CreatedAt,
+		LockedUntil:  l.LockedUntil,
+		UnlockedAt:   l.UnlockedAt,
+		UnlockedByID: l.UnlockedByID,
+		Active:       l.UnlockedAt == nil && now.Before(l.LockedUntil),
+	}
+}
+
+// AdminLockoutsList lists login lockouts, newest first. By default only
+// active ones are listed; pass all=true for the history.
+func AdminLockoutsList(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		now := time.Now().UTC()
+		q := db.Order("created_at desc").Limit(500)
+		if c.Query("all") != "true" {
+			q = q.Where("unlocked_at IS NULL AND locked_until > ?", now)
+		}
+		if scope := c.Query("scope"); scope != "" {
+			q = q.Where("scope = ?", scope)
+		}
+		var lockouts []models.LoginLockout
+		if err := q.Find(&lockouts).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load lockouts"})
+			return
+		}
+		resp := make([]loginLockoutView, 0, len(lockouts))
+		for _, l := range lockouts {
+			resp = append(resp, newLoginLockoutView(l, now))
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// releaseLockouts lifts the throttle on key, marks the matching lockout
+// records as unlocked by the caller and records entry, with the number of
+// records released, in the audit log.
+func releaseLockouts(db *gorm.DB, c *gin.Context, guard *ratelimit.LoginGuard, key string, where *gorm.DB, entry audit.Entry) (int64, error) {
+	if err := guard.Reset(c.Request.Context(), key); err != nil {
+		return 0, err
+	}
+	now := time.Now().UTC()
+	actor := currentUserID(c)
+	var released int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.LoginLockout{}).Where(where).Where("unlocked_at IS NULL").
+			Updates(map[string]any{"unlocked_at": &now, "unlocked_by_id": &actor})
+		if res.Error != nil {
+			return res.Error
+		}
+		released = res.RowsAffected
+		if after, ok := entry.After.(gin.H); ok {
+			after["lockoutsReleased"] = released
+		}
+		return audit.Record(tx, c, entry)
+	})
+	return released, err
+}
+
+// AdminUserUnlock clears a user's failed logins and lifts their lockout.
+func AdminUserUnlock(db *gorm.DB, guard *ratelimit.LoginGuard) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := findUser(c, db)
+		if !ok {
+			return
+		}
+		released, err := releaseLockouts(db, c, guard, accountThrottleKey(&user, ""), db.Where("user_id = ?", user.ID), audit.Entry{
+			Action:     audit.ActionLockoutRelease,
+			EntityType: audit.EntityUser,
+			EntityID:   user.ID,
+			After:      gin.H{"username": user.Username},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to unlock account"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"unlocked": true, "lockoutsReleased": released})
+	}
+}
+
+// AdminLockoutRelease lifts a single lockout, including IP lockouts.
+func AdminLockoutRelease(db *gorm.DB, guard *ratelimit.LoginGuard) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lockoutID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid lockout id"})
+			return
+		}
+		var lockout models.LoginLockout
+		if err := db.First(&lockout, "id = ?", lockoutID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"message": "lockout not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load lockout"})
+			return
+		}
+		// Release every open record for the same key, not just this one.
+		where := db.Where("scope = ? AND identifier = ?", lockout.Scope, lockout.Identifier)
+		if lockout.UserID != nil {
+			where = db.Where("user_id = ?", *lockout.UserID)
+		}
+		entry := audit.Entry{
+			Action: audit.ActionLockoutRelease,
+			After:  gin.H{"scope": lockout.Scope, "identifier": lockout.Identifier},
+		}
+		if lockout.UserID != nil {
+			entry.EntityType, entry.EntityID = audit.EntityUser, *lockout.UserID
+		}
+		released, err := releaseLockouts(db, c, guard, lockoutThrottleKey(lockout), where, entry)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to release lockout"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"unlocked": true, "lockoutsReleased": released})
+	}
+}
@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/letera1/huhems-exam-system/backend/internal/middleware"
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// seesAllCourses reports whether the caller may access every course rather
+// than only those they are assigned to.
+func seesAllCourses(c *gin.Context) bool {
+	return middleware.HasPermission(c, models.PermCourseAll)
+}
+
+func currentUserID(c *gin.Context) uuid.UUID {
+	v, _ := c.Get(string(middleware.ContextUserID))
+	id, _ := v.(uuid.UUID)
+	return id
+}
+
+// taughtCourseIDs returns the courses the user teaches.
+func taughtCourseIDs(db *gorm.DB, userID uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := db.Model(&models.CourseInstructor{}).Where("user_id = ?", userID).Pluck("course_id", &ids).Error
+	return ids, err
+}
+
+// scopeExamsToCaller restricts a query on exams to those the caller may
+// access: everything with course:all, otherwise the exams of the courses the
+// caller is assigned to.
+func scopeExamsToCaller(c *gin.Context, db *gorm.DB, q *gorm.DB) (*gorm.DB, error) {
+	if seesAllCourses(c) {
+		return q, nil
+	}
+	courses, err := taughtCourseIDs(db, currentUserID(c))
+	if err != nil {
+		return nil, err
+	}
+	if len(courses) == 0 {
+		return q.Where("1 = 0"), nil
+	}
+	return q.Where("exams.course_id IN ?", courses), nil
+}
+
+// callerTeaches reports whether the caller is assigned to the course or may
+// access every course.
+func callerTeaches(c *gin.Context, db *gorm.DB, courseID uuid.UUID) (bool, error) {
+	if seesAllCourses(c) {
+		return true, nil
+	}
+	var n int64
+	err := db.Model(&models.CourseInstructor{}).Where("course_id = ? AND user_id = ?", courseID, currentUserID(c)).Count(&n).Error
+	return n > 0, err
+}
+
+// requireExamCourseAccess aborts unless the caller may manage the exam.
+// A missing exam is left for the handler to report.
+func requireExamCourseAccess(c *gin.Context, db *gorm.DB, examID uuid.UUID) {
+	if seesAllCourses(c) {
+		c.Next()
+		return
+	}
+	var exams []models.Exam
+	if err := db.Select("id", "course_id").Where("id = ?", examID).Limit(1).Find(&exams).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "failed to load exam"})
+		return
+	}
+	if len(exams) == 0 {
+		c.Next()
+		return
+	}
+	if exams[0].CourseID == nil {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "forbidden"})
+		return
+	}
+	ok, err := callerTeaches(c, db, *exams[0].CourseID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "failed to check course access"})
+		return
+	}
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "forbidden"})
+		return
+	}
+	c.Next()
+}
+
+// RequireExamAccess guards routes whose :id is an exam.
+func RequireExamAccess(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		examID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "invalid exam id"})
+			return
+		}
+		requireExamCourseAccess(c, db, examID)
+	}
+}
+
+// RequireAttemptAccess guards routes whose :id is an attempt.
+func RequireAttemptAccess(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if seesAllCourses(c) {
+			c.Next()
+			return
+		}
+		attemptID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "invalid attempt id"})
+			return
+		}
+		var attempts []models.ExamAttempt
+		if err := db.Select("id", "exam_id").Where("id = ?", attemptID).Limit(1).Find(&attempts).Error; err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "failed to load attempt"})
+			return
+		}
+		if len(attempts) == 0 {
+			c.Next()
+			return
+		}
+		requireExamCourseAccess(c, db, attempts[0].ExamID)
+	}
+}
+
+// RequireQuestionAccess guards routes whose :id is a question.
+func RequireQuestionAccess(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if seesAllCourses(c) {
+			c.Next()
+			return
+		}
+		questionID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "invalid question id"})
+			return
+		}
+		var questions []models.Question
+		if err := db.Select("id", "exam_id").Where("id = ?", questionID).Limit(1).Find(&questions).Error; err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "failed to load question"})
+			return
+		}
+		if len(questions) == 0 {
+			c.Next()
+			return
+		}
+		requireExamCourseAccess(c, db, questions[0].ExamID)
+	}
+}
+
+// RequireCourseAccess guards routes whose :id is a course.
+func RequireCourseAccess(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		courseID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "invalid course id"})
+			return
+		}
+		ok, err := callerTeaches(c, db, courseID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "failed to check course access"})
+			return
+		}
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "forbidden"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// enrolledCourseIDs returns the courses the student is enrolled in.
+func enrolledCourseIDs(db *gorm.DB, studentID uuid.UUID) (map[uuid.UUID]bool, error) {
+	var ids []uuid.UUID
+	if err := db.Model(&models.CourseEnrollment{}).Where("student_id = ?", studentID).Pluck("course_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		out[id] = true
+	}
+	return out, nil
+}
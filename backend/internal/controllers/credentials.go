@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"encoding/csv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/letera1/huhems-exam-system/backend/internal/auth"
+)
+
+// issuedCredential is a temporary password handed out for a new account.
+// It is only ever returned in the response that creates the account.
+type issuedCredential struct {
+	Username          string `json:"username"`
+	Email             string `json:"email"`
+	FullName          string `json:"fullName,omitempty"`
+	TemporaryPassword string `json:"temporaryPassword"`
+}
+
+// newTemporaryCredential returns a random temporary password and its hash.
+func newTemporaryCredential() (password, hash string, err error) {
+	password, err = auth.NewTemporaryPassword()
+	if err != nil {
+		return "", "", err
+	}
+	hash, err = auth.HashPassword(password)
+	if err != nil {
+		return "", "", err
+	}
+	return password, hash, nil
+}
+
+// wantsCSVHandout reports whether the caller asked for credentials as a CSV
+// download rather than JSON.
+func wantsCSVHandout(c *gin.Context) bool {
+	return c.Query("format") == "csv"
+}
+
+// writeCredentialsCSV sends the credentials as a CSV attachment to hand out
+// to the account holders.
+func writeCredentialsCSV(c *gin.Context, status int, filename string, creds []issuedCredential) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	noStore(c)
+	c.Status(status)
+
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write([]string{"username", "email", "fullName", "temporaryPassword"})
+	for _, cr := range creds {
+		_ = w.Write([]string{cr.Username, cr.Email, cr.FullName, cr.TemporaryPassword})
+	}
+	w.Flush()
+}
+
+// noStore keeps responses carrying credentials out of caches.
+func noStore(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+}
@@ -0,0 +1,460 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/letera1/huhems-exam-system/backend/internal/audit"
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Roster statuses of an eligible student.
+const (
+	rosterNotStarted = "not_started"
+	rosterInProgress = "in_progress"
+	rosterSubmitted  = "submitted"
+)
+
+// examTargetMatches reports whether a single target covers the student.
+// groups holds the ids of the student's groups.
+func examTargetMatches(t models.ExamTarget, student models.Student, groups map[uuid.UUID]bool) bool {
+	switch {
+	case t.StudentID != nil:
+		return *t.StudentID == student.ID
+	case t.GroupID != nil:
+		return groups[*t.GroupID]
+	default:
+		if t.Department != "" && !strings.EqualFold(t.Department, strings.TrimSpace(student.Department)) {
+			return false
+		}
+		if t.Year != 0 && t.Year != student.Year {
+			return false
+		}
+		return t.Department != "" || t.Year != 0
+	}
+}
+
+// examTargetsAllow reports whether the student may take an exam with the
+// given targets. An exam without targets is open to everyone.
+func examTargetsAllow(targets []models.ExamTarget, student models.Student, groups map[uuid.UUID]bool) bool {
+	if len(targets) == 0 {
+		return true
+	}
+	for _, t := range targets {
+		if examTargetMatches(t, student, groups) {
+			return true
+		}
+	}
+	return false
+}
+
+func loadStudentGroupIDs(db *gorm.DB, studentID uuid.UUID) (map[uuid.UUID]bool, error) {
+	var members []models.StudentGroupMember
+	if err := db.Select("group_id").Where("student_id = ?", studentID).Find(&members).Error; err != nil {
+		return nil, err
+	}
+	groups := make(map[uuid.UUID]bool, len(members))
+	for _, m := range members {
+		groups[m.GroupID] = true
+	}
+	return groups, nil
+}
+
+// studentEligibleForExam reports whether the exam's targets cover the student
+// and, for a course exam, whether the student is enrolled in the course.
+func studentEligibleForExam(db *gorm.DB, examID uuid.UUID, student models.Student) (bool, error) {
+	var exam models.Exam
+	if err := db.Select("id", "course_id").First(&exam, "id = ?", examID).Error; err != nil {
+		return false, err
+	}
+	if exam.CourseID != nil {
+		var n int64
+		if err := db.Model(&models.CourseEnrollment{}).Where("course_id = ? AND student_id = ?", *exam.CourseID, student.ID).Count(&n).Error; err != nil {
+			return false, err
+		}
+		if n == 0 {
+			return false, nil
+		}
+	}
+	var targets []models.ExamTarget
+	if err := db.Where("exam_id = ?", examID).Find(&targets).Error; err != nil {
+		return false, err
+	}
+	if len(targets) == 0 {
+		return true, nil
+	}
+	groups, err := loadStudentGroupIDs(db, student.ID)
+	if err != nil {
+		return false, err
+	}
+	return examTargetsAllow(targets, student, groups), nil
+}
+
+// whereEligible restricts a query on students to those covered by targets.
+func whereEligible(q *gorm.DB, targets []models.ExamTarget) *gorm.DB {
+	if len(targets) == 0 {
+		return q
+	}
+	conds := make([]string, 0, len(targets))
+	args := make([]any, 0, len(targets))
+	for _, t := range targets {
+		switch {
+		case t.StudentID != nil:
+			conds = append(conds, "students.id = ?")
+			args = append(args, *t.StudentID)
+		case t.GroupID != nil:
+			conds = append(conds, "students.id IN (SELECT student_id FROM student_group_members WHERE group_id = ? AND deleted_at IS NULL)")
+			args = append(args, *t.GroupID)
+		default:
+			var parts []string
+			if t.Department != "" {
+				parts = append(parts, "lower(trim(students.department)) = lower(?)")
+				args = append(args, t.Department)
+			}
+			if t.Year != 0 {
+				parts = append(parts, "students.year = ?")
+				args = append(args, t.Year)
+			}
+			if len(parts) == 0 {
+				continue
+			}
+			conds = append(conds, "("+strings.Join(parts, " AND ")+")")
+		}
+	}
+	if len(conds) == 0 {
+		return q.Where("1 = 0")
+	}
+	return q.Where("("+strings.Join(conds, " OR ")+")", args...)
+}
+
+type examTargetView struct {
+	ID         uuid.UUID  `json:"id"`
+	Department string     `json:"department,omitempty"`
+	Year       int        `json:"year,omitempty"`
+	GroupID    *uuid.UUID `json:"groupId,omitempty"`
+	StudentID  *uuid.UUID `json:"studentId,omitempty"`
+}
+
+func toExamTargetViews(targets []models.ExamTarget) []examTargetView {
+	out := make([]examTargetView, 0, len(targets))
+	for _, t := range targets {
+		out = append(out, examTargetView{ID: t.ID, Department: t.Department, Year: t.Year, GroupID: t.GroupID, StudentID: t.StudentID})
+	}
+	return out
+}
+
+type examTargetInput struct {
+	Department string     `json:"department"`
+	Year       int        `json:"year"`
+	GroupID    *uuid.UUID `json:"groupId"`
+	StudentID  *uuid.UUID `json:"studentId"`
+}
+
+type adminExamTargetsRequest struct {
+	Targets []examTargetInput `json:"targets"`
+}
+
+// validate normalizes the targets, drops duplicates and checks that the
+// referenced groups and students exist. Call it in the transaction that saves
+// the targets.
+func (r *adminExamTargetsRequest) validate(db *gorm.DB) error {
+	seen := map[string]bool{}
+	out := make([]examTargetInput, 0, len(r.Targets))
+	var groupIDs, studentIDs []uuid.UUID
+	for _, t := range r.Targets {
+		t.Department = strings.TrimSpace(t.Department)
+		kinds := 0
+		if t.Department != "" || t.Year != 0 {
+			kinds++
+		}
+		if t.GroupID != nil {
+			kinds++
+		}
+		if t.StudentID != nil {
+			kinds++
+		}
+		if kinds != 1 {
+			return errInvalid("each target must set a department and/or year, a groupId, or a studentId")
+		}
+		if t.Year < 0 {
+			return errInvalid("year must be >= 1")
+		}
+
+		var key string
+		switch {
+		case t.GroupID != nil:
+			key = "g:" + t.GroupID.String()
+		case t.StudentID != nil:
+			key = "s:" + t.StudentID.String()
+		default:
+			key = "c:" + strings.ToLower(t.Department) + ":" + strconv.Itoa(t.Year)
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if t.GroupID != nil {
+			groupIDs = append(groupIDs, *t.GroupID)
+		}
+		if t.StudentID != nil {
+			studentIDs = append(studentIDs, *t.StudentID)
+		}
+		out = append(out, t)
+	}
+
+	// The referenced rows are locked so they cannot be deleted before the
+	// targets are saved.
+	if len(groupIDs) > 0 {
+		var found []uuid.UUID
+		if err := db.Model(&models.StudentGroup{}).Clauses(clause.Locking{Strength: "SHARE"}).Where("id IN ?", groupIDs).Pluck("id", &found).Error; err != nil {
+			return err
+		}
+		if len(found) != len(groupIDs) {
+			return errInvalid("unknown groupId")
+		}
+	}
+	if len(studentIDs) > 0 {
+		var found []uuid.UUID
+		if err := db.Model(&models.Student{}).Clauses(clause.Locking{Strength: "SHARE"}).Where("id IN ?", studentIDs).Pluck("id", &found).Error; err != nil {
+			return err
+		}
+		if len(found) != len(studentIDs) {
+			return errInvalid("unknown studentId")
+		}
+	}
+	r.Targets = out
+	return nil
+}
+
+// AdminExamTargetsGet lists who an exam is assigned to. An empty list means
+// every student.
+func AdminExamTargetsGet(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		examID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid exam id"})
+			return
+		}
+
+		var targets []models.ExamTarget
+		if err := db.Where("exam_id = ?", examID).Order("created_at asc").Find(&targets).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load targets"})
+			return
+		}
+		c.JSON(http.StatusOK, toExamTargetViews(targets))
+	}
+}
+
+// AdminExamTargetsPut replaces an exam's targets. Sending an empty list opens
+// the exam to every student. Attempts already in progress are not affected.
+func AdminExamTargetsPut(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		examID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid exam id"})
+			return
+		}
+
+		var req adminExamTargetsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
+			return
+		}
+		var exam models.Exam
+		if err := db.Select("id").First(&exam, "id = ?", examID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"message": "exam not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load exam"})
+			return
+		}
+
+		var before, after []models.ExamTarget
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := req.validate(tx); err != nil {
+				return err
+			}
+			if err := tx.Where("exam_id = ?", examID).Order("created_at asc").Find(&before).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("exam_id = ?", examID).Delete(&models.ExamTarget{}).Error; err != nil {
+				return err
+			}
+			for _, t := range req.Targets {
+				after = append(after, models.ExamTarget{
+					ExamID:     examID,
+					Department: t.Department,
+					Year:       t.Year,
+					GroupID:    t.GroupID,
+					StudentID:  t.StudentID,
+				})
+			}
+			if len(after) > 0 {
+				if err := tx.Create(&after).Error; err != nil {
+					return err
+				}
+			}
+			return audit.Record(tx, c, audit.Entry{
+				Action:     audit.ActionExamTargetsSet,
+				EntityType: audit.EntityExam,
+				EntityID:   examID,
+				Before:     toExamTargetViews(before),
+				After:      toExamTargetViews(after),
+			})
+		})
+		if err != nil {
+			var invalid invalidError
+			if errors.As(err, &invalid) {
+				c.JSON(http.StatusBadRequest, gin.H{"message": invalid.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to save targets"})
+			return
+		}
+
+		view := toExamTargetViews(after)
+
+		c.JSON(http.StatusOK, view)
+	}
+}
+
+type examRosterEntry struct {
+	StudentID     uuid.UUID  `json:"studentId"`
+	FullName      string     `json:"fullName"`
+	Username      string     `json:"username"`
+	Department    string     `json:"department"`
+	Year          int        `json:"year"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastAttemptID *uuid.UUID `json:"lastAttemptId"`
+}
+
+type examRosterResponse struct {
+	ExamID     uuid.UUID         `json:"examId"`
+	Targeted   bool              `json:"targeted"`
+	Eligible   int               `json:"eligible"`
+	NotStarted int               `json:"notStarted"`
+	InProgress int               `json:"inProgress"`
+	Submitted  int               `json:"submitted"`
+	Students   []examRosterEntry `json:"students"`
+}
+
+// AdminExamRoster lists the students eligible for an exam and how far each
+// has got. The optional status query parameter (not_started, in_progress,
+// submitted) filters the list; the counts always cover everyone eligible.
+func AdminExamRoster(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		examID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid exam id"})
+			return
+		}
+		status := strings.TrimSpace(c.Query("status"))
+		switch status {
+		case "", rosterNotStarted, rosterInProgress, rosterSubmitted:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"message": "status must be not_started, in_progress or submitted"})
+			return
+		}
+
+		var exam models.Exam
+		if err := db.Select("id", "course_id").First(&exam, "id = ?", examID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"message": "exam not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load exam"})
+			return
+		}
+
+		var targets []models.ExamTarget
+		if err := db.Where("exam_id = ?", examID).Find(&targets).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load targets"})
+			return
+		}
+
+		q := whereEligible(db.Preload("User"), targets)
+		if exam.CourseID != nil {
+			q = q.Where("students.id IN (SELECT student_id FROM course_enrollments WHERE course_id = ? AND deleted_at IS NULL)", *exam.CourseID)
+		}
+		var students []models.Student
+		if err := q.Find(&students).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load students"})
+			return
+		}
+
+		type attemptRow struct {
+			StudentID uuid.UUID
+			Attempts  int
+			Open      bool
+		}
+		var rows []attemptRow
+		if err := db.Model(&models.ExamAttempt{}).
+			Select("student_id, COUNT(*) AS attempts, bool_or(NOT submitted) AS open").
+			Where("exam_id = ?", examID).
+			Group("student_id").
+			Scan(&rows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load attempts"})
+			return
+		}
+		byStudent := map[uuid.UUID]attemptRow{}
+		for _, r := range rows {
+			byStudent[r.StudentID] = r
+		}
+		var latest []models.ExamAttempt
+		if err := db.Select("DISTINCT ON (student_id) id, student_id").
+			Where("exam_id = ?", examID).
+			Order("student_id, start_time desc").
+			Find(&latest).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load attempts"})
+			return
+		}
+		lastAttempt := map[uuid.UUID]uuid.UUID{}
+		for _, a := range latest {
+			lastAttempt[a.StudentID] = a.ID
+		}
+
+		resp := examRosterResponse{ExamID: examID, Targeted: len(targets) > 0, Students: []examRosterEntry{}}
+		for _, s := range students {
+			r := byStudent[s.ID]
+			entry := examRosterEntry{
+				StudentID:  s.ID,
+				FullName:   s.FullName,
+				Username:   s.User.Username,
+				Department: s.Department,
+				Year:       s.Year,
+				Attempts:   r.Attempts,
+			}
+			switch {
+			case r.Open:
+				entry.Status = rosterInProgress
+				resp.InProgress++
+			case r.Attempts > 0:
+				entry.Status = rosterSubmitted
+				resp.Submitted++
+			default:
+				entry.Status = rosterNotStarted
+				resp.NotStarted++
+			}
+			if id, ok := lastAttempt[s.ID]; ok {
+				id := id
+				entry.LastAttemptID = &id
+			}
+			resp.Eligible++
+			if status == "" || status == entry.Status {
+				resp.Students = append(resp.Students, entry)
+			}
+		}
+		sort.Slice(resp.Students, func(i, j int) bool { return resp.Students[i].FullName < resp.Students[j].FullName })
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
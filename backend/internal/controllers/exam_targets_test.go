@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+)
+
+func TestExamTargetsAllow(t *testing.T) {
+	student := models.Student{Year: 3, Department: " Computer Science "}
+	student.ID = uuid.New()
+	other := uuid.New()
+	group := uuid.New()
+	groups := map[uuid.UUID]bool{group: true}
+
+	tests := []struct {
+		name    string
+		targets []models.ExamTarget
+		want    bool
+	}{
+		{"no targets", nil, true},
+		{"student", []models.ExamTarget{{StudentID: &student.ID}}, true},
+		{"other student", []models.ExamTarget{{StudentID: &other}}, false},
+		{"group member", []models.ExamTarget{{GroupID: &group}}, true},
+		{"not a group member", []models.ExamTarget{{GroupID: &other}}, false},
+		{"department ignores case and spaces", []models.ExamTarget{{Department: "computer science"}}, true},
+		{"other department", []models.ExamTarget{{Department: "Physics"}}, false},
+		{"year", []models.ExamTarget{{Year: 3}}, true},
+		{"other year", []models.ExamTarget{{Year: 2}}, false},
+		{"department and year", []models.ExamTarget{{Department: "Computer Science", Year: 3}}, true},
+		{"department but other year", []models.ExamTarget{{Department: "Computer Science", Year: 2}}, false},
+		{"empty cohort matches nobody", []models.ExamTarget{{}}, false},
+		{"any target matches", []models.ExamTarget{{Year: 2}, {GroupID: &group}}, true},
+		{"student target ignores cohort", []models.ExamTarget{{StudentID: &other, Year: 3}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := examTargetsAllow(tt.targets, student, groups); got != tt.want {
+				t.Errorf("examTargetsAllow() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,440 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/letera1/huhems-exam-system/backend/internal/audit"
+	"github.com/letera1/huhems-exam-system/backend/internal/live"
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"github.com/letera1/huhems-exam-system/backend/internal/ratelimit"
+	"gorm.io/gorm"
+)
+
+const (
+	maxIntegrityEventsPerRequest = 50
+	maxIntegrityEventDetails     = 500
+)
+
+func isIntegrityEventType(t string) bool {
+	for _, known := range models.IntegrityEventTypes {
+		if string(known) == t {
+			return true
+		}
+	}
+	return false
+}
+
+func isServerIntegrityEventType(t string) bool {
+	for _, known := range models.ServerIntegrityEventTypes {
+		if string(known) == t {
+			return true
+		}
+	}
+	return false
+}
+
+// validateIntegrityThresholds checks that every key is a known event type or
+// "total" and every value is positive. An empty map disables flagging.
+func validateIntegrityThresholds(in map[string]int) (models.IntegrityThresholds, error) {
+	if len(in) == 0 {
+		return nil, nil
+	}
+	out := models.IntegrityThresholds{}
+	for k, v := range in {
+		k = strings.ToLower(strings.TrimSpace(k))
+		if k != models.IntegrityThresholdTotal && !isIntegrityEventType(k) && !isServerIntegrityEventType(k) {
+			return nil, errInvalid("unknown integrity event type: " + k)
+		}
+		if v <= 0 {
+			return nil, errInvalid("integrity thresholds must be >= 1")
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
+// exceededIntegrityThreshold returns a human readable reason for the first
+// threshold reached by counts, or "" if none is.
+func exceededIntegrityThreshold(thresholds models.IntegrityThresholds, counts map[string]int) string {
+	if len(thresholds) == 0 {
+		return ""
+	}
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+
+	keys := make([]string, 0, len(thresholds))
+	for k := range thresholds {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		limit := thresholds[k]
+		n := counts[k]
+		if k == models.IntegrityThresholdTotal {
+			n = total
+		}
+		if n >= limit {
+			return fmt.Sprintf("integrity threshold reached: %s %d/%d", k, n, limit)
+		}
+	}
+	return ""
+}
+
+func countIntegrityEvents(db *gorm.DB, attemptIDs []uuid.UUID) (map[uuid.UUID]map[string]int, error) {
+	out := map[uuid.UUID]map[string]int{}
+	if len(attemptIDs) == 0 {
+		return out, nil
+	}
+	type row struct {
+		AttemptID uuid.UUID
+		Type      string
+		Cnt       int
+	}
+	var rows []row
+	if err := db.Model(&models.IntegrityEvent{}).
+		Select("attempt_id, type, COUNT(*) as cnt").
+		Where("attempt_id IN ?", attemptIDs).
+		Group("attempt_id, type").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		m := out[r.AttemptID]
+		if m == nil {
+			m = map[string]int{}
+			out[r.AttemptID] = m
+		}
+		m[r.Type] = r.Cnt
+	}
+	return out, nil
+}
+
+// applyIntegrityThresholds flags the attempt for review if its recorded events
+// reach one of the exam's thresholds.
+func applyIntegrityThresholds(db *gorm.DB, attempt models.ExamAttempt) error {
+	if attempt.FlaggedForReview {
+		return nil
+	}
+	var exam models.Exam
+	if err := db.Select("integrity_thresholds").First(&exam, "id = ?", attempt.ExamID).Error; err != nil {
+		return err
+	}
+	if len(exam.IntegrityThresholds) == 0 {
+		return nil
+	}
+	counts, err := countIntegrityEvents(db, []uuid.UUID{attempt.ID})
+	if err != nil {
+		return err
+	}
+	reason := exceededIntegrityThreshold(exam.IntegrityThresholds, counts[attempt.ID])
+	if reason == "" {
+		return nil
+	}
+	now := time.Now().UTC()
+	return db.Model(&models.ExamAttempt{}).Where("id = ? AND flagged_for_review = false", attempt.ID).
+		Updates(map[string]any{"flagged_for_review": true, "flagged_at": &now, "review_reason": reason}).Error
+}
+
+type studentIntegrityEventInput struct {
+	Type       string     `json:"type"`
+	OccurredAt *time.Time `json:"occurredAt"`
+	Details    string     `json:"details"`
+}
+
+type studentIntegrityEventsRequest struct {
+	Events []studentIntegrityEventInput `json:"events"`
+}
+
+// StudentAttemptEvents ingests a batch of integrity events reported by the
+// exam client. Requests are rate limited per attempt.
+func StudentAttemptEvents(db *gorm.DB, hub *live.Hub, limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		attemptID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid attempt id"})
+			return
+		}
+
+		studentID, ok := getStudentID(c, db)
+		if !ok {
+			return
+		}
+
+		var req studentIntegrityEventsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
+			return
+		}
+		if len(req.Events) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "events are required"})
+			return
+		}
+		if len(req.Events) > maxIntegrityEventsPerRequest {
+			c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("too many events (max %d)", maxIntegrityEventsPerRequest)})
+			return
+		}
+
+		var attempt models.ExamAttempt
+		if err := db.First(&attempt, "id = ?", attemptID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"message": "attempt not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load attempt"})
+			return
+		}
+		if attempt.StudentID != studentID {
+			c.JSON(http.StatusForbidden, gin.H{"message": "forbidden"})
+			return
+		}
+		// Only the owner's reports count against the attempt's limit.
+		if ok, retryAfter := limiter.Allow("attempt-events:" + attemptID.String()); !ok {
+			c.Header("Retry-After", fmt.Sprintf("%d", int(retryAfter.Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"message": "too many event reports, slow down"})
+			return
+		}
+		if attempt.Submitted {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "attempt already submitted"})
+			return
+		}
+		if !checkAttemptSession(db, hub, c, attempt) {
+			return
+		}
+
+		now := time.Now().UTC()
+		events := make([]models.IntegrityEvent, 0, len(req.Events))
+		for _, in := range req.Events {
+			t := strings.ToLower(strings.TrimSpace(in.Type))
+			if !isIntegrityEventType(t) {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "unknown event type: " + t})
+				return
+			}
+			details := strings.TrimSpace(in.Details)
+			if r := []rune(details); len(r) > maxIntegrityEventDetails {
+				details = string(r[:maxIntegrityEventDetails])
+			}
+			occurredAt := now
+			// Ignore client clocks that are obviously off.
+			if in.OccurredAt != nil && in.OccurredAt.After(attempt.StartTime.Add(-time.Minute)) && in.OccurredAt.Before(now.Add(time.Minute)) {
+				occurredAt = in.OccurredAt.UTC()
+			}
+			events = append(events, models.IntegrityEvent{
+				AttemptID:  attempt.ID,
+				Type:       t,
+				OccurredAt: occurredAt,
+				Details:    details,
+				IP:         c.ClientIP(),
+				UserAgent:  c.Request.UserAgent(),
+			})
+		}
+
+		if err := db.Create(&events).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to record events"})
+			return
+		}
+
+		if err := applyIntegrityThresholds(db, attempt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to apply integrity thresholds"})
+			return
+		}
+		publishIntegrityEvents(db, hub, attempt, events)
+
+		c.JSON(http.StatusCreated, gin.H{"recorded": len(events)})
+	}
+}
+
+type adminAttemptIntegritySummary struct {
+	AttemptID        uuid.UUID      `json:"attemptId"`
+	StudentID        uuid.UUID      `json:"studentId"`
+	FullName         string         `json:"fullName"`
+	Submitted        bool           `json:"submitted"`
+	Counts           map[string]int `json:"counts"`
+	Total            int            `json:"total"`
+	FlaggedForReview bool           `json:"flaggedForReview"`
+	FlaggedAt        *time.Time     `json:"flaggedAt"`
+	ReviewReason     string         `json:"reviewReason"`
+	SessionIP        string         `json:"sessionIp"`
+	SessionUserAgent string         `json:"sessionUserAgent"`
+	SessionBoundAt   *time.Time     `json:"sessionBoundAt"`
+}
+
+// AdminExamIntegrity summarizes integrity events per attempt of an exam,
+// flagged attempts first.
+func AdminExamIntegrity(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		examID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid exam id"})
+			return
+		}
+
+		var attempts []models.ExamAttempt
+		if err := db.Preload("Student").Where("exam_id = ?", examID).Find(&attempts).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load attempts"})
+			return
+		}
+		attemptIDs := make([]uuid.UUID, 0, len(attempts))
+		for _, a := range attempts {
+			attemptIDs = append(attemptIDs, a.ID)
+		}
+
+		counts, err := countIntegrityEvents(db, attemptIDs)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to count events"})
+			return
+		}
+
+		resp := make([]adminAttemptIntegritySummary, 0, len(attempts))
+		for _, a := range attempts {
+			m := counts[a.ID]
+			if m == nil {
+				m = map[string]int{}
+			}
+			total := 0
+			for _, n := range m {
+				total += n
+			}
+			resp = append(resp, adminAttemptIntegritySummary{
+				AttemptID:        a.ID,
+				StudentID:        a.StudentID,
+				FullName:         a.Student.FullName,
+				Submitted:        a.Submitted,
+				Counts:           m,
+				Total:            total,
+				FlaggedForReview: a.FlaggedForReview,
+				FlaggedAt:        a.FlaggedAt,
+				ReviewReason:     a.ReviewReason,
+				SessionIP:        a.SessionIP,
+				SessionUserAgent: a.SessionUserAgent,
+				SessionBoundAt:   a.SessionBoundAt,
+			})
+		}
+		sort.SliceStable(resp, func(i, j int) bool {
+			if resp[i].FlaggedForReview != resp[j].FlaggedForReview {
+				return resp[i].FlaggedForReview
+			}
+			return resp[i].Total > resp[j].Total
+		})
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+type adminIntegrityEventView struct {
+	ID         uuid.UUID `json:"id"`
+	Type       string    `json:"type"`
+	OccurredAt time.Time `json:"occurredAt"`
+	ReceivedAt time.Time `json:"receivedAt"`
+	Details    string    `json:"details"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"userAgent"`
+}
+
+// AdminAttemptEvents lists the integrity events of a single attempt in the
+// order they occurred.
+func AdminAttemptEvents(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		attemptID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid attempt id"})
+			return
+		}
+
+		var events []models.IntegrityEvent
+		if err := db.Where("attempt_id = ?", attemptID).Order("occurred_at asc, created_at asc").Find(&events).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load events"})
+			return
+		}
+
+		resp := make([]adminIntegrityEventView, 0, len(events))
+		for _, e := range events {
+			resp = append(resp, adminIntegrityEventView{
+				ID:         e.ID,
+				Type:       e.Type,
+				OccurredAt: e.OccurredAt,
+				ReceivedAt: e.CreatedAt,
+				Details:    e.Details,
+				IP:         e.IP,
+				UserAgent:  e.UserAgent,
+			})
+		}
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+type adminAttemptReviewRequest struct {
+	Flagged bool   `json:"flagged"`
+	Reason  string `json:"reason"`
+}
+
+// AdminAttemptReview lets an admin flag an attempt for review manually or
+// clear a flag after reviewing it.
+func AdminAttemptReview(db *gorm.DB, hub *live.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		attemptID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid attempt id"})
+			return
+		}
+
+		var req adminAttemptReviewRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
+			return
+		}
+
+		var attempt models.ExamAttempt
+		if err := db.First(&attempt, "id = ?", attemptID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"message": "attempt not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load attempt"})
+			return
+		}
+
+		updates := map[string]any{"flagged_for_review": req.Flagged}
+		if req.Flagged {
+			now := time.Now().UTC()
+			reason := strings.TrimSpace(req.Reason)
+			if reason == "" {
+				reason = "flagged by proctor"
+			}
+			updates["flagged_at"] = &now
+			updates["review_reason"] = reason
+		} else {
+			updates["flagged_at"] = nil
+			updates["review_reason"] = ""
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&models.ExamAttempt{}).Where("id = ?", attempt.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+			return audit.Record(tx, c, audit.Entry{
+				Action:     audit.ActionAttemptReview,
+				EntityType: audit.EntityAttempt,
+				EntityID:   attempt.ID,
+				Before:     gin.H{"flaggedForReview": attempt.FlaggedForReview, "reviewReason": attempt.ReviewReason},
+				After:      gin.H{"flaggedForReview": req.Flagged, "reviewReason": updates["review_reason"]},
+			})
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to update attempt"})
+			return
+		}
+		publishAttemptState(db, hub, attempt.ExamID, attempt.ID, liveAttemptUpdated)
+
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	}
+}
@@ -1,10 +1,12 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"sort"
 	"time"
 
+	"github.com/letera1/huhems-exam-system/backend/internal/live"
 	"github.com/letera1/huhems-exam-system/backend/internal/models"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -13,6 +15,10 @@ import (
 	"gorm.io/gorm/clause"
 )
 
+// attemptDeadline returns when the attempt's time runs out: the exam duration
+// plus accommodation time, any extra minutes granted and any time spent
+// paused. While the attempt is paused the deadline moves forward with the
+// clock.
 func attemptDeadline(attempt models.ExamAttempt, durationMinutes int) (time.Time, bool) {
 	if durationMinutes <= 0 {
 		return time.Time{}, false
@@ -20,13 +26,22 @@ func attemptDeadline(attempt models.ExamAttempt, durationMinutes int) (time.Time
 	if attempt.StartTime.IsZero() {
 		return time.Time{}, false
 	}
-	return attempt.StartTime.Add(time.Duration(durationMinutes) * time.Minute), true
+	deadline := attempt.StartTime.
+		Add(time.Duration(durationMinutes+attempt.AccommodationMinutes+attempt.ExtraMinutes) * time.Minute).
+		Add(time.Duration(attempt.PausedSeconds) * time.Second)
+	if attempt.PausedAt != nil {
+		if paused := time.Now().UTC().Sub(*attempt.PausedAt); paused > 0 {
+			deadline = deadline.Add(paused)
+		}
+	}
+	return deadline, true
 }
 
 type attemptScore struct {
 	score          float64
 	correctTotal   int
 	questionsTotal int
+	answeredTotal  int
 }
 
 func computeAttemptScore(db *gorm.DB, attempt models.ExamAttempt) (attemptScore, []models.Question, error) {
@@ -70,9 +85,12 @@ func computeAttemptScore(db *gorm.DB, attempt models.ExamAttempt) (attemptScore,
 		}
 	}
 
-	correctTotal := 0
+	correctTotal, answeredTotal := 0, 0
 	for _, q := range questions {
 		ans := answersByQuestion[q.ID]
+		if len(ans.SelectedChoiceIDs) > 0 {
+			answeredTotal++
+		}
 		if isAnswerCorrect(q.Type, correctSets[q.ID], []string(ans.SelectedChoiceIDs)) {
 			correctTotal++
 		}
@@ -83,7 +101,7 @@ func computeAttemptScore(db *gorm.DB, attempt models.ExamAttempt) (attemptScore,
 		score = (float64(correctTotal) / float64(len(questions))) * 100.0
 	}
 
-	return attemptScore{score: score, correctTotal: correctTotal, questionsTotal: len(questions)}, questions, nil
+	return attemptScore{score: score, correctTotal: correctTotal, questionsTotal: len(questions), answeredTotal: answeredTotal}, questions, nil
 }
 
 func ensureEmptyAnswersExist(db *gorm.DB, attemptID uuid.UUID, questionIDs []uuid.UUID) error {
@@ -114,15 +132,15 @@ func ensureEmptyAnswersExist(db *gorm.DB, attemptID uuid.UUID, questionIDs []uui
 	return nil
 }
 
-func finalizeAttemptIfExpired(db *gorm.DB, attemptID uuid.UUID) (*models.ExamAttempt, *attemptScore, error) {
+func finalizeAttemptIfExpired(db *gorm.DB, hub *live.Hub, attemptID uuid.UUID) (*models.ExamAttempt, *attemptScore, error) {
 	tx := db.Begin()
 	if tx.Error != nil {
 		return nil, nil, tx.Error
 	}
 	defer func() { _ = tx.Rollback() }()
 
-	var attempt models.ExamAttempt
-	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&attempt, "id = ?", attemptID).Error; err != nil {
+	attempt, err := lockAttempt(tx, attemptID)
+	if err != nil {
 		return nil, nil, err
 	}
 
@@ -148,35 +166,69 @@ func finalizeAttemptIfExpired(db *gorm.DB, attemptID uuid.UUID) (*models.ExamAtt
 		return &attempt, nil, nil
 	}
 
-	sc, questions, err := computeAttemptScore(tx, attempt)
+	end := time.Now().UTC()
+	if hasDeadline {
+		end = deadline
+	}
+	sc, err := closeAttempt(tx, &attempt, end, false)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	if err := tx.Commit().Error; err != nil {
+		return nil, nil, err
+	}
+	publishAttemptState(db, hub, attempt.ExamID, attempt.ID, liveAttemptSubmitted)
+	publishAttemptClosed(hub, attempt, submittedByServer)
+	return &attempt, &sc, nil
+}
+
+// lockAttempt loads the attempt and locks its row until the end of tx.
+// Anything that closes the attempt or writes to it while it is open does so
+// under this lock, so state checks cannot go stale before the write.
+func lockAttempt(tx *gorm.DB, attemptID uuid.UUID) (models.ExamAttempt, error) {
+	var attempt models.ExamAttempt
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&attempt, "id = ?", attemptID).Error
+	return attempt, err
+}
+
+// closeAttempt scores and submits attempt as of end, filling in blank answers
+// for unanswered questions. With complete set it refuses, with errInvalid, to
+// close an attempt that has unanswered questions. Only the columns closing
+// changes are written. It must be called inside a transaction holding the
+// lock from lockAttempt.
+func closeAttempt(tx *gorm.DB, attempt *models.ExamAttempt, end time.Time, complete bool) (attemptScore, error) {
+	sc, questions, err := computeAttemptScore(tx, *attempt)
+	if err != nil {
+		return attemptScore{}, err
+	}
+	if complete && sc.answeredTotal < sc.questionsTotal {
+		return attemptScore{}, errInvalid("all questions must be answered before submitting")
+	}
+
 	questionIDs := make([]uuid.UUID, 0, len(questions))
 	for _, q := range questions {
 		questionIDs = append(questionIDs, q.ID)
 	}
 	if err := ensureEmptyAnswersExist(tx, attempt.ID, questionIDs); err != nil {
-		return nil, nil, err
+		return attemptScore{}, err
 	}
 
-	end := time.Now().UTC()
-	if hasDeadline {
-		end = deadline
-	}
 	attempt.Submitted = true
 	attempt.Score = sc.score
 	attempt.EndTime = &end
-
-	if err := tx.Save(&attempt).Error; err != nil {
-		return nil, nil, err
-	}
-
-	if err := tx.Commit().Error; err != nil {
-		return nil, nil, err
+	attempt.PausedAt = nil
+
+	err = tx.Model(&models.ExamAttempt{}).Where("id = ?", attempt.ID).Updates(map[string]any{
+		"submitted": true,
+		"score":     sc.score,
+		"end_time":  &end,
+		"paused_at": nil,
+	}).Error
+	if err != nil {
+		return attemptScore{}, err
 	}
-	return &attempt, &sc, nil
+	return sc, nil
 }
 
 type studentAttemptView struct {
@@ -186,6 +238,20 @@ type studentAttemptView struct {
 	EndTime   *time.Time `json:"endTime"`
 	Score     float64    `json:"score"`
 	Submitted bool       `json:"submitted"`
+
+	Invalidated       bool   `json:"invalidated"`
+	InvalidatedReason string `json:"invalidatedReason,omitempty"`
+
+	// The server's clock for the attempt: serverTime, deadline,
+	// remainingSeconds and paused.
+	attemptClock
+}
+
+// studentAttemptWriteResponse is returned by the answer and flag endpoints so
+// clients can resync their countdown on every save.
+type studentAttemptWriteResponse struct {
+	OK bool `json:"ok"`
+	attemptClock
 }
 
 type studentExamView struct {
@@ -215,26 +281,13 @@ type studentAttemptDetailResponse struct {
 	Attempt   studentAttemptView    `json:"attempt"`
 	Exam      studentExamView       `json:"exam"`
 	Questions []studentQuestionView `json:"questions"`
-}
 
-func isAttemptExpired(db *gorm.DB, attempt models.ExamAttempt) (bool, error) {
-	var exam models.Exam
-	if err := db.Select("duration_minutes").First(&exam, "id = ?", attempt.ExamID).Error; err != nil {
-		return false, err
-	}
-
-	if exam.DurationMinutes <= 0 {
-		return false, nil
-	}
-	if attempt.StartTime.IsZero() {
-		return false, nil
-	}
-
-	deadline := attempt.StartTime.Add(time.Duration(exam.DurationMinutes) * time.Minute)
-	return time.Now().UTC().After(deadline), nil
+	// Announcements posted to the exam, oldest first. Clients polling this
+	// endpoint should acknowledge the ones not yet acknowledged.
+	Announcements []studentAnnouncementView `json:"announcements"`
 }
 
-func StudentAttemptGet(db *gorm.DB) gin.HandlerFunc {
+func StudentAttemptGet(db *gorm.DB, hub *live.Hub) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		attemptID, err := uuid.Parse(c.Param("id"))
 		if err != nil {
@@ -261,9 +314,13 @@ func StudentAttemptGet(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		if !attempt.Submitted && !checkAttemptSession(db, hub, c, attempt) {
+			return
+		}
+
 		// Server-side auto-submit when time is up.
 		if !attempt.Submitted {
-			if finalized, _, err := finalizeAttemptIfExpired(db, attempt.ID); err == nil && finalized != nil {
+			if finalized, _, err := finalizeAttemptIfExpired(db, hub, attempt.ID); err == nil && finalized != nil {
 				attempt = *finalized
 			}
 		}
@@ -310,12 +367,15 @@ func StudentAttemptGet(db *gorm.DB) gin.HandlerFunc {
 
 		resp := studentAttemptDetailResponse{
 			Attempt: studentAttemptView{
-				ID:        attempt.ID,
-				ExamID:    attempt.ExamID,
-				StartTime: attempt.StartTime,
-				EndTime:   attempt.EndTime,
-				Score:     attempt.Score,
-				Submitted: attempt.Submitted,
+				ID:                attempt.ID,
+				ExamID:            attempt.ExamID,
+				StartTime:         attempt.StartTime,
+				EndTime:           attempt.EndTime,
+				Score:             attempt.Score,
+				Submitted:         attempt.Submitted,
+				Invalidated:       attempt.Invalidated,
+				InvalidatedReason: attempt.InvalidatedReason,
+				attemptClock:      newAttemptClock(attempt, exam.DurationMinutes, time.Now().UTC()),
 			},
 			Exam: studentExamView{
 				ID:               exam.ID,
@@ -326,6 +386,13 @@ func StudentAttemptGet(db *gorm.DB) gin.HandlerFunc {
 			},
 		}
 
+		announcements, err := loadStudentAnnouncements(db, exam.ID, studentID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load announcements"})
+			return
+		}
+		resp.Announcements = announcements
+
 		for _, q := range questions {
 			choices := choicesByQuestion[q.ID]
 			sort.SliceStable(choices, func(i, j int) bool { return choices[i].Order < choices[j].Order })
@@ -348,12 +415,63 @@ func StudentAttemptGet(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
+// Errors for answer and flag writes to an attempt that was closed after the
+// request first checked it.
+var (
+	errAttemptSubmitted = errors.New("attempt already submitted")
+	errAttemptTimeUp    = errors.New("time is up")
+)
+
+// lockWritableAttempt locks the attempt for an answer or flag write and
+// checks again, under the lock, that it is still open: not submitted (which
+// includes invalidated), not paused by a proctor and within its time. It
+// returns the attempt's clock.
+func lockWritableAttempt(tx *gorm.DB, attemptID uuid.UUID) (attemptClock, error) {
+	attempt, err := lockAttempt(tx, attemptID)
+	if err != nil {
+		return attemptClock{}, err
+	}
+	switch {
+	case attempt.Submitted:
+		return attemptClock{}, errAttemptSubmitted
+	case attempt.PausedAt != nil:
+		return attemptClock{}, errAttemptPaused
+	}
+	clock, err := loadAttemptClock(tx, attempt)
+	if err != nil {
+		return attemptClock{}, err
+	}
+	if clock.expired() {
+		return attemptClock{}, errAttemptTimeUp
+	}
+	return clock, nil
+}
+
+// respondAttemptWriteError answers a failed answer or flag write, with
+// message for unexpected errors.
+func respondAttemptWriteError(c *gin.Context, db *gorm.DB, hub *live.Hub, attemptID uuid.UUID, err error, message string) {
+	switch {
+	case errors.Is(err, errAttemptSubmitted):
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+	case errors.Is(err, errAttemptPaused):
+		c.JSON(http.StatusConflict, gin.H{"message": err.Error()})
+	case errors.Is(err, errAttemptTimeUp):
+		_, _, _ = finalizeAttemptIfExpired(db, hub, attemptID)
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"message": message})
+	}
+}
+
 type studentAnswerUpdateRequest struct {
 	QuestionID        uuid.UUID `json:"questionId"`
 	SelectedChoiceIDs []string  `json:"selectedChoiceIds"`
+	// VisibleMs is how long the question was visible on the client since the
+	// last answer was saved. Optional.
+	VisibleMs *int64 `json:"visibleMs"`
 }
 
-func StudentAttemptAnswer(db *gorm.DB) gin.HandlerFunc {
+func StudentAttemptAnswer(db *gorm.DB, hub *live.Hub) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		attemptID, err := uuid.Parse(c.Param("id"))
 		if err != nil {
@@ -375,6 +493,10 @@ func StudentAttemptAnswer(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"message": "questionId is required"})
 			return
 		}
+		if req.VisibleMs != nil && *req.VisibleMs < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "visibleMs must be >= 0"})
+			return
+		}
 
 		var attempt models.ExamAttempt
 		if err := db.First(&attempt, "id = ?", attemptID).Error; err != nil {
@@ -393,14 +515,21 @@ func StudentAttemptAnswer(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"message": "attempt already submitted"})
 			return
 		}
+		if !checkAttemptSession(db, hub, c, attempt) {
+			return
+		}
+		if attempt.PausedAt != nil {
+			c.JSON(http.StatusConflict, gin.H{"message": "attempt is paused by a proctor"})
+			return
+		}
 
-		expired, err := isAttemptExpired(db, attempt)
+		clock, err := loadAttemptClock(db, attempt)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to validate time limit"})
 			return
 		}
-		if expired {
-			_, _, _ = finalizeAttemptIfExpired(db, attempt.ID)
+		if clock.expired() {
+			_, _, _ = finalizeAttemptIfExpired(db, hub, attempt.ID)
 			c.JSON(http.StatusBadRequest, gin.H{"message": "time is up"})
 			return
 		}
@@ -443,28 +572,37 @@ func StudentAttemptAnswer(db *gorm.DB) gin.HandlerFunc {
 			}
 		}
 
-		var ans models.StudentAnswer
-		if err := db.First(&ans, "attempt_id = ? AND question_id = ?", attempt.ID, question.ID).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				ans = models.StudentAnswer{AttemptID: attempt.ID, QuestionID: question.ID, SelectedChoiceIDs: pq.StringArray{}, Flagged: false}
-				if err := db.Create(&ans).Error; err != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to create answer"})
-					return
-				}
-				// fallthrough to save
-			} else {
-				c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load answer"})
-				return
+		err = db.Transaction(func(tx *gorm.DB) error {
+			// A proctor, the clock or another tab may have closed or paused
+			// the attempt since the checks above.
+			var err error
+			if clock, err = lockWritableAttempt(tx, attempt.ID); err != nil {
+				return err
 			}
-		}
 
-		ans.SelectedChoiceIDs = pq.StringArray(req.SelectedChoiceIDs)
-		if err := db.Save(&ans).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to save answer"})
+			ans := models.StudentAnswer{AttemptID: attempt.ID, QuestionID: question.ID}
+			if err := tx.Where("attempt_id = ? AND question_id = ?", attempt.ID, question.ID).FirstOrInit(&ans).Error; err != nil {
+				return err
+			}
+			ans.SelectedChoiceIDs = pq.StringArray(req.SelectedChoiceIDs)
+			if err := tx.Save(&ans).Error; err != nil {
+				return err
+			}
+			event := models.AnswerEvent{
+				AttemptID:         attempt.ID,
+				QuestionID:        question.ID,
+				SelectedChoiceIDs: pq.StringArray(req.SelectedChoiceIDs),
+				VisibleMs:         req.VisibleMs,
+			}
+			return tx.Create(&event).Error
+		})
+		if err != nil {
+			respondAttemptWriteError(c, db, hub, attempt.ID, err, "failed to save answer")
 			return
 		}
+		publishAttemptState(db, hub, attempt.ExamID, attempt.ID, liveAttemptProgress)
 
-		c.JSON(http.StatusOK, gin.H{"ok": true})
+		c.JSON(http.StatusOK, studentAttemptWriteResponse{OK: true, attemptClock: clock.at(time.Now().UTC())})
 	}
 }
 
@@ -473,7 +611,7 @@ type studentFlagUpdateRequest struct {
 	Flagged    bool      `json:"flagged"`
 }
 
-func StudentAttemptFlag(db *gorm.DB) gin.HandlerFunc {
+func StudentAttemptFlag(db *gorm.DB, hub *live.Hub) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		attemptID, err := uuid.Parse(c.Param("id"))
 		if err != nil {
@@ -513,39 +651,46 @@ func StudentAttemptFlag(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"message": "attempt already submitted"})
 			return
 		}
+		if !checkAttemptSession(db, hub, c, attempt) {
+			return
+		}
+		if attempt.PausedAt != nil {
+			c.JSON(http.StatusConflict, gin.H{"message": "attempt is paused by a proctor"})
+			return
+		}
 
-		expired, err := isAttemptExpired(db, attempt)
+		clock, err := loadAttemptClock(db, attempt)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to validate time limit"})
 			return
 		}
-		if expired {
-			_, _, _ = finalizeAttemptIfExpired(db, attempt.ID)
+		if clock.expired() {
+			_, _, _ = finalizeAttemptIfExpired(db, hub, attempt.ID)
 			c.JSON(http.StatusBadRequest, gin.H{"message": "time is up"})
 			return
 		}
 
-		var ans models.StudentAnswer
-		if err := db.First(&ans, "attempt_id = ? AND question_id = ?", attempt.ID, req.QuestionID).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				ans = models.StudentAnswer{AttemptID: attempt.ID, QuestionID: req.QuestionID, SelectedChoiceIDs: pq.StringArray{}, Flagged: req.Flagged}
-				if err := db.Create(&ans).Error; err != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to update flag"})
-					return
-				}
-			} else {
-				c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load answer"})
-				return
+		err = db.Transaction(func(tx *gorm.DB) error {
+			// As for answers, re-check the attempt under its lock.
+			var err error
+			if clock, err = lockWritableAttempt(tx, attempt.ID); err != nil {
+				return err
 			}
-		} else {
-			ans.Flagged = req.Flagged
-			if err := db.Save(&ans).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to update flag"})
-				return
+
+			ans := models.StudentAnswer{AttemptID: attempt.ID, QuestionID: req.QuestionID, SelectedChoiceIDs: pq.StringArray{}}
+			if err := tx.Where("attempt_id = ? AND question_id = ?", attempt.ID, req.QuestionID).FirstOrInit(&ans).Error; err != nil {
+				return err
 			}
+			ans.Flagged = req.Flagged
+			return tx.Save(&ans).Error
+		})
+		if err != nil {
+			respondAttemptWriteError(c, db, hub, attempt.ID, err, "failed to update flag")
+			return
 		}
+		publishAttemptState(db, hub, attempt.ExamID, attempt.ID, liveAttemptProgress)
 
-		c.JSON(http.StatusOK, gin.H{"ok": true})
+		c.JSON(http.StatusOK, studentAttemptWriteResponse{OK: true, attemptClock: clock.at(time.Now().UTC())})
 	}
 }
 
@@ -555,7 +700,11 @@ type studentSubmitResponse struct {
 	QuestionsTotal int     `json:"questionsTotal"`
 }
 
-func StudentAttemptSubmit(db *gorm.DB) gin.HandlerFunc {
+// errAttemptPaused is returned when a proctor paused the attempt while it was
+// being submitted.
+var errAttemptPaused = errors.New("attempt is paused by a proctor")
+
+func StudentAttemptSubmit(db *gorm.DB, hub *live.Hub) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		attemptID, err := uuid.Parse(c.Param("id"))
 		if err != nil {
@@ -591,105 +740,76 @@ func StudentAttemptSubmit(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusOK, studentSubmitResponse{Score: attempt.Score, CorrectTotal: sc.correctTotal, QuestionsTotal: sc.questionsTotal})
 			return
 		}
-
-		expired, err := isAttemptExpired(db, attempt)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to validate time limit"})
+		if !checkAttemptSession(db, hub, c, attempt) {
 			return
 		}
-
-		// Load questions + correct sets.
-		var questions []models.Question
-		if err := db.Where("exam_id = ?", attempt.ExamID).Order("created_at asc").Find(&questions).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load questions"})
+		if attempt.PausedAt != nil {
+			c.JSON(http.StatusConflict, gin.H{"message": "attempt is paused by a proctor"})
 			return
 		}
-		questionIDs := make([]uuid.UUID, 0, len(questions))
-		for _, q := range questions {
-			questionIDs = append(questionIDs, q.ID)
-		}
-
-		correctSets := map[uuid.UUID]map[string]struct{}{}
-		if len(questionIDs) > 0 {
-			var choices []models.Choice
-			if err := db.Where("question_id IN ?", questionIDs).Find(&choices).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load choices"})
-				return
-			}
-			for _, ch := range choices {
-				if !ch.IsCorrect {
-					continue
-				}
-				set := correctSets[ch.QuestionID]
-				if set == nil {
-					set = map[string]struct{}{}
-					correctSets[ch.QuestionID] = set
-				}
-				set[ch.ID.String()] = struct{}{}
-			}
-		}
 
-		answersByQuestion := map[uuid.UUID]models.StudentAnswer{}
-		{
-			var answers []models.StudentAnswer
-			if err := db.Where("attempt_id = ?", attempt.ID).Find(&answers).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load answers"})
-				return
+		// Score and close the attempt under a row lock, so a concurrent
+		// submit, auto-submit or proctor action cannot interleave with it.
+		var (
+			sc      attemptScore
+			already bool
+		)
+		err = db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if attempt, err = lockAttempt(tx, attempt.ID); err != nil {
+				return err
 			}
-			for _, a := range answers {
-				answersByQuestion[a.QuestionID] = a
+			if attempt.Submitted {
+				already = true
+				return nil
 			}
-		}
-
-		// Enforce: all questions must be answered before submission (unless time is up).
-		if !expired {
-			for _, q := range questions {
-				ans, ok := answersByQuestion[q.ID]
-				if !ok || len(ans.SelectedChoiceIDs) == 0 {
-					c.JSON(http.StatusBadRequest, gin.H{"message": "all questions must be answered before submitting"})
-					return
-				}
+			if attempt.PausedAt != nil {
+				return errAttemptPaused
 			}
-		}
 
-		correctTotal := 0
-		for _, q := range questions {
-			ans := answersByQuestion[q.ID]
-			if isAnswerCorrect(q.Type, correctSets[q.ID], []string(ans.SelectedChoiceIDs)) {
-				correctTotal++
+			var exam models.Exam
+			if err := tx.Select("duration_minutes").First(&exam, "id = ?", attempt.ExamID).Error; err != nil {
+				return err
 			}
-		}
+			now := time.Now().UTC()
+			deadline, hasDeadline := attemptDeadline(attempt, exam.DurationMinutes)
+			expired := hasDeadline && now.After(deadline)
 
-		score := 0.0
-		if len(questions) > 0 {
-			score = (float64(correctTotal) / float64(len(questions))) * 100.0
-		}
-
-		now := time.Now().UTC()
-		attempt.Submitted = true
-		attempt.Score = score
-		if exam, ok := func() (*models.Exam, bool) {
-			var ex models.Exam
-			if err := db.Select("duration_minutes").First(&ex, "id = ?", attempt.ExamID).Error; err != nil {
-				return nil, false
+			end := now
+			if expired {
+				end = deadline
 			}
-			return &ex, true
-		}(); ok {
-			if deadline, has := attemptDeadline(attempt, exam.DurationMinutes); has && now.After(deadline) {
-				attempt.EndTime = &deadline
-			} else {
-				attempt.EndTime = &now
+			// Unless time is up, every question must be answered.
+			sc, err = closeAttempt(tx, &attempt, end, !expired)
+			return err
+		})
+		if err != nil {
+			var invalid invalidError
+			switch {
+			case errors.Is(err, errAttemptPaused):
+				c.JSON(http.StatusConflict, gin.H{"message": err.Error()})
+				return
+			case errors.As(err, &invalid):
+				c.JSON(http.StatusBadRequest, gin.H{"message": invalid.Error()})
+				return
 			}
-		} else {
-			attempt.EndTime = &now
-		}
-
-		if err := db.Save(&attempt).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to submit attempt"})
 			return
 		}
+		if already {
+			// Submitted by a concurrent request; answer as the idempotent path above does.
+			sc, _, err := computeAttemptScore(db, attempt)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load attempt score"})
+				return
+			}
+			c.JSON(http.StatusOK, studentSubmitResponse{Score: attempt.Score, CorrectTotal: sc.correctTotal, QuestionsTotal: sc.questionsTotal})
+			return
+		}
+		publishAttemptState(db, hub, attempt.ExamID, attempt.ID, liveAttemptSubmitted)
+		publishAttemptClosed(hub, attempt, submittedByStudent)
 
-		c.JSON(http.StatusOK, studentSubmitResponse{Score: score, CorrectTotal: correctTotal, QuestionsTotal: len(questions)})
+		c.JSON(http.StatusOK, studentSubmitResponse{Score: sc.score, CorrectTotal: sc.correctTotal, QuestionsTotal: sc.questionsTotal})
 	}
 }
 
@@ -710,9 +830,12 @@ type studentResultResponse struct {
 	CorrectTotal   int                     `json:"correctTotal"`
 	QuestionsTotal int                     `json:"questionsTotal"`
 	Questions      []studentResultQuestion `json:"questions"`
+
+	Invalidated       bool   `json:"invalidated"`
+	InvalidatedReason string `json:"invalidatedReason,omitempty"`
 }
 
-func StudentAttemptResult(db *gorm.DB) gin.HandlerFunc {
+func StudentAttemptResult(db *gorm.DB, hub *live.Hub) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		attemptID, err := uuid.Parse(c.Param("id"))
 		if err != nil {
@@ -740,7 +863,7 @@ func StudentAttemptResult(db *gorm.DB) gin.HandlerFunc {
 		}
 		if !attempt.Submitted {
 			// If time is up, auto-submit server-side and allow viewing results.
-			finalized, _, err := finalizeAttemptIfExpired(db, attempt.ID)
+			finalized, _, err := finalizeAttemptIfExpired(db, hub, attempt.ID)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to finalize attempt"})
 				return
@@ -797,7 +920,14 @@ func StudentAttemptResult(db *gorm.DB) gin.HandlerFunc {
 			}
 		}
 
-		resp := studentResultResponse{AttemptID: attempt.ID, ExamID: attempt.ExamID, Score: attempt.Score, QuestionsTotal: len(questions)}
+		resp := studentResultResponse{
+			AttemptID:         attempt.ID,
+			ExamID:            attempt.ExamID,
+			Score:             attempt.Score,
+			QuestionsTotal:    len(questions),
+			Invalidated:       attempt.Invalidated,
+			InvalidatedReason: attempt.InvalidatedReason,
+		}
 
 		correctTotal := 0
 		for _, q := range questions {
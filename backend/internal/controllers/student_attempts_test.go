@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+)
+
+func TestAttemptDeadline(t *testing.T) {
+	start := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	now := time.Now().UTC()
+	pausedAt := now.Add(-10 * time.Minute)
+	future := now.Add(time.Hour)
+
+	tests := []struct {
+		name     string
+		attempt  models.ExamAttempt
+		duration int
+		// want is the deadline, or zero for none. For a pause in progress
+		// it is the deadline as of now.
+		want time.Time
+	}{
+		{"untimed exam", models.ExamAttempt{StartTime: start}, 0, time.Time{}},
+		{"not started", models.ExamAttempt{}, 60, time.Time{}},
+		{"duration", models.ExamAttempt{StartTime: start}, 60, start.Add(60 * time.Minute)},
+		{"accommodation", models.ExamAttempt{StartTime: start, AccommodationMinutes: 30}, 60, start.Add(90 * time.Minute)},
+		{"proctor extension", models.ExamAttempt{StartTime: start, ExtraMinutes: 15}, 60, start.Add(75 * time.Minute)},
+		{"accommodation and proctor extension", models.ExamAttempt{StartTime: start, AccommodationMinutes: 30, ExtraMinutes: 15}, 60, start.Add(105 * time.Minute)},
+		{"completed pauses", models.ExamAttempt{StartTime: start, PausedSeconds: 90}, 60, start.Add(61*time.Minute + 30*time.Second)},
+		{"pause in progress", models.ExamAttempt{StartTime: start, PausedAt: &pausedAt}, 60, start.Add(70 * time.Minute)},
+		{"pause in progress after earlier pauses, with extra time", models.ExamAttempt{
+			StartTime:            start,
+			AccommodationMinutes: 30,
+			ExtraMinutes:         15,
+			PausedSeconds:        120,
+			PausedAt:             &pausedAt,
+		}, 60, start.Add(117 * time.Minute)},
+		{"pause stamped in the future", models.ExamAttempt{StartTime: start, PausedAt: &future}, 60, start.Add(60 * time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := attemptDeadline(tt.attempt, tt.duration)
+			if tt.want.IsZero() {
+				if ok {
+					t.Errorf("attemptDeadline() = %v, want no deadline", got)
+				}
+				return
+			}
+			if !ok {
+				t.Fatal("attemptDeadline() has no deadline")
+			}
+			// A pause in progress counts up to the call's own clock reading.
+			if d := got.Sub(tt.want); d < 0 || d > time.Second {
+				t.Errorf("attemptDeadline() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAttemptDeadlineMovesWhilePaused(t *testing.T) {
+	start := time.Now().UTC().Add(-30 * time.Minute)
+	pausedAt := time.Now().UTC().Add(-5 * time.Minute)
+	attempt := models.ExamAttempt{StartTime: start, PausedAt: &pausedAt}
+
+	first, _ := attemptDeadline(attempt, 60)
+	time.Sleep(20 * time.Millisecond)
+	second, _ := attemptDeadline(attempt, 60)
+	if !second.After(first) {
+		t.Errorf("deadline did not move while paused: %v then %v", first, second)
+	}
+}
@@ -1,10 +1,12 @@
 package controllers
 
 import (
+	"crypto/subtle"
 	"net/http"
 	"time"
 
 	"github.com/letera1/huhems-exam-system/backend/internal/middleware"
+	"github.com/letera1/huhems-exam-system/backend/internal/live"
 	"github.com/letera1/huhems-exam-system/backend/internal/models"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -20,6 +22,11 @@ type studentExamListItem struct {
 	DurationMinutes  int       `json:"durationMinutes"`
 	QuestionsPerPage int       `json:"questionsPerPage"`
 	QuestionCount    int       `json:"questionCount"`
+
+	// Availability and limits after applying the student's accommodation.
+	AvailableFrom  *time.Time `json:"availableFrom"`
+	AvailableUntil *time.Time `json:"availableUntil"`
+	ExtraMinutes   int        `json:"extraMinutes"`
 }
 
 func getStudentID(c *gin.Context, db *gorm.DB) (uuid.UUID, bool) {
@@ -44,12 +51,74 @@ func getStudentID(c *gin.Context, db *gorm.DB) (uuid.UUID, bool) {
 
 func StudentExamsList(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var exams []models.Exam
-		if err := db.Where("published = true").Order("created_at desc").Find(&exams).Error; err != nil {
+		studentID, ok := getStudentID(c, db)
+		if !ok {
+			return
+		}
+		var student models.Student
+		if err := db.First(&student, "id = ?", studentID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load student"})
+			return
+		}
+		groups, err := loadStudentGroupIDs(db, studentID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load groups"})
+			return
+		}
+		enrolled, err := enrolledCourseIDs(db, studentID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load courses"})
+			return
+		}
+
+		var accommodations []models.Accommodation
+		if err := db.Where("student_id = ?", studentID).Find(&accommodations).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load accommodations"})
+			return
+		}
+		var defaultAcc *models.Accommodation
+		examAcc := map[uuid.UUID]*models.Accommodation{}
+		for i := range accommodations {
+			if accommodations[i].ExamID == nil {
+				defaultAcc = &accommodations[i]
+			} else {
+				examAcc[*accommodations[i].ExamID] = &accommodations[i]
+			}
+		}
+
+		var published []models.Exam
+		if err := db.Where("published = true").Order("created_at desc").Find(&published).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load exams"})
 			return
 		}
 
+		// Only list exams assigned to the student, and course exams only to
+		// enrolled students.
+		targets := map[uuid.UUID][]models.ExamTarget{}
+		if len(published) > 0 {
+			ids := make([]uuid.UUID, 0, len(published))
+			for _, e := range published {
+				ids = append(ids, e.ID)
+			}
+			var rows []models.ExamTarget
+			if err := db.Where("exam_id IN ?", ids).Find(&rows).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load exam targets"})
+				return
+			}
+			for _, t := range rows {
+				targets[t.ExamID] = append(targets[t.ExamID], t)
+			}
+		}
+		exams := make([]models.Exam, 0, len(published))
+		for _, e := range published {
+			if e.CourseID != nil && !enrolled[*e.CourseID] {
+				continue
+			}
+			if examTargetsAllow(targets[e.ID], student, groups) {
+				exams = append(exams, e)
+			}
+		}
+
 		examIDs := make([]uuid.UUID, 0, len(exams))
 		for _, e := range exams {
 			examIDs = append(examIDs, e.ID)
@@ -75,14 +144,22 @@ func StudentExamsList(db *gorm.DB) gin.HandlerFunc {
 
 		resp := make([]studentExamListItem, 0, len(exams))
 		for _, e := range exams {
+			acc := defaultAcc
+			if override, ok := examAcc[e.ID]; ok {
+				acc = override
+			}
+			from, until := examAvailability(e, acc)
 			resp = append(resp, studentExamListItem{
 				ID:               e.ID,
 				Title:            e.Title,
 				Description:      e.Description,
-				MaxAttempts:      e.MaxAttempts,
+				MaxAttempts:      effectiveMaxAttempts(e, acc),
 				DurationMinutes:  e.DurationMinutes,
 				QuestionsPerPage: e.QuestionsPerPage,
 				QuestionCount:    counts[e.ID],
+				AvailableFrom:    from,
+				AvailableUntil:   until,
+				ExtraMinutes:     accommodationMinutes(acc, e.DurationMinutes),
 			})
 		}
 
@@ -90,11 +167,19 @@ func StudentExamsList(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
+type startAttemptRequest struct {
+	// TransferCode resumes an attempt a proctor transferred to a new device.
+	TransferCode string `json:"transferCode"`
+}
+
 type startAttemptResponse struct {
 	AttemptID uuid.UUID `json:"attemptId"`
+	// SessionToken is only returned when the attempt is bound to the caller.
+	// It must be sent in the X-Attempt-Session header of later requests.
+	SessionToken string `json:"sessionToken,omitempty"`
 }
 
-func StudentExamStartAttempt(db *gorm.DB) gin.HandlerFunc {
+func StudentExamStartAttempt(db *gorm.DB, hub *live.Hub) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		examID, err := uuid.Parse(c.Param("id"))
 		if err != nil {
@@ -107,6 +192,14 @@ func StudentExamStartAttempt(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		var req startAttemptRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
+				return
+			}
+		}
+
 		var exam models.Exam
 		if err := db.First(&exam, "id = ?", examID).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
@@ -121,12 +214,85 @@ func StudentExamStartAttempt(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// If there's an active attempt, reuse it.
+		// If there's an active attempt, reuse it. A bound attempt only returns
+		// its id; the session check on the attempt endpoints decides whether the
+		// caller may use it. An unbound one is bound to the caller, which for a
+		// transferred attempt takes the proctor's transfer code.
 		var active models.ExamAttempt
 		if err := db.Where("exam_id = ? AND student_id = ? AND submitted = false", examID, studentID).
 			Order("created_at desc").
 			First(&active).Error; err == nil {
-			c.JSON(http.StatusOK, startAttemptResponse{AttemptID: active.ID})
+			if active.SessionTokenHash != "" {
+				c.JSON(http.StatusOK, startAttemptResponse{AttemptID: active.ID})
+				return
+			}
+			transferHash := active.SessionTransferHash
+			if transferHash != "" {
+				code := normalizeAttemptTransferCode(req.TransferCode)
+				if code == "" || subtle.ConstantTimeCompare([]byte(hashAttemptSessionToken(code)), []byte(transferHash)) != 1 {
+					c.JSON(http.StatusConflict, gin.H{"message": "this attempt was transferred; enter the transfer code from your proctor", "transferCodeRequired": true})
+					return
+				}
+			}
+			token, err := bindAttemptSession(c, &active)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to start attempt"})
+				return
+			}
+			// The transfer code is used up by whichever request binds first.
+			res := db.Model(&models.ExamAttempt{}).
+				Where("id = ? AND session_token_hash = '' AND session_transfer_hash = ?", active.ID, transferHash).
+				Updates(map[string]any{
+					"session_token_hash":    active.SessionTokenHash,
+					"session_ip":            active.SessionIP,
+					"session_user_agent":    active.SessionUserAgent,
+					"session_bound_at":      active.SessionBoundAt,
+					"session_transfer_hash": "",
+				})
+			if res.Error != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to start attempt"})
+				return
+			}
+			if res.RowsAffected == 0 {
+				// Another session bound it first.
+				c.JSON(http.StatusOK, startAttemptResponse{AttemptID: active.ID})
+				return
+			}
+			c.JSON(http.StatusOK, startAttemptResponse{AttemptID: active.ID, SessionToken: token})
+			return
+		}
+
+		// Assignment is checked for new attempts only, so changing an exam's
+		// targets does not lock out a student mid-attempt.
+		var student models.Student
+		if err := db.First(&student, "id = ?", studentID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load student"})
+			return
+		}
+		eligible, err := studentEligibleForExam(db, examID, student)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to check exam assignment"})
+			return
+		}
+		if !eligible {
+			c.JSON(http.StatusForbidden, gin.H{"message": "exam is not assigned to you"})
+			return
+		}
+
+		acc, err := effectiveAccommodation(db, studentID, examID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to load accommodation"})
+			return
+		}
+
+		now := time.Now().UTC()
+		from, until := examAvailability(exam, acc)
+		if from != nil && now.Before(*from) {
+			c.JSON(http.StatusForbidden, gin.H{"message": "exam is not open yet"})
+			return
+		}
+		if until != nil && now.After(*until) {
+			c.JSON(http.StatusForbidden, gin.H{"message": "exam has closed"})
 			return
 		}
 
@@ -138,7 +304,7 @@ func StudentExamStartAttempt(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to check attempts"})
 			return
 		}
-		if exam.MaxAttempts > 0 && int(submittedCount) >= exam.MaxAttempts {
+		if limit := effectiveMaxAttempts(exam, acc); limit > 0 && int(submittedCount) >= limit {
 			c.JSON(http.StatusForbidden, gin.H{"message": "attempt limit reached"})
 			return
 		}
@@ -157,7 +323,14 @@ func StudentExamStartAttempt(db *gorm.DB) gin.HandlerFunc {
 		attempt := models.ExamAttempt{
 			StudentID: studentID,
 			ExamID:    examID,
-			StartTime: time.Now().UTC(),
+			StartTime: now,
+
+			AccommodationMinutes: accommodationMinutes(acc, exam.DurationMinutes),
+		}
+		token, err := bindAttemptSession(c, &attempt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to start attempt"})
+			return
 		}
 
 		err = db.Transaction(func(tx *gorm.DB) error {
@@ -185,6 +358,8 @@ func StudentExamStartAttempt(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		c.JSON(http.StatusCreated, startAttemptResponse{AttemptID: attempt.ID})
+		publishAttemptState(db, hub, attempt.ExamID, attempt.ID, liveAttemptStarted)
+
+		c.JSON(http.StatusCreated, startAttemptResponse{AttemptID: attempt.ID, SessionToken: token})
 	}
 }
@@ -17,6 +17,8 @@ type studentResultsListItem struct {
 	Score     float64    `json:"score"`
 	StartTime time.Time  `json:"startTime"`
 	EndTime   *time.Time `json:"endTime"`
+
+	Invalidated bool `json:"invalidated"`
 }
 
 func StudentResultsList(db *gorm.DB) gin.HandlerFunc {
@@ -31,7 +33,7 @@ func StudentResultsList(db *gorm.DB) gin.HandlerFunc {
 		var rows []studentResultsListItem
 		err := db.Model(&models.ExamAttempt{}).
 			Select(
-				"exam_attempts.id as attempt_id, exam_attempts.exam_id as exam_id, exams.title as exam_title, exam_attempts.score as score, exam_attempts.start_time as start_time, exam_attempts.end_time as end_time",
+				"exam_attempts.id as attempt_id, exam_attempts.exam_id as exam_id, exams.title as exam_title, exam_attempts.score as score, exam_attempts.start_time as start_time, exam_attempts.end_time as end_time, exam_attempts.invalidated as invalidated",
 			).
 			Joins("JOIN exams ON exams.id = exam_attempts.exam_id").
 			Where("exam_attempts.student_id = ? AND exam_attempts.submitted = true", studentID).
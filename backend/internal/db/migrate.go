@@ -15,15 +15,54 @@ func Migrate(db *gorm.DB) error {
 	// However, if the table exists but lacks the deleted_at column or other fields, AutoMigrate should handle it.
 	// The problem is likely conflicting existing table structure with GORM's expectation or prepared statement caching issue.
 
-	return db.AutoMigrate(
+	if err := db.AutoMigrate(
 		&models.Role{},
 		&models.User{},
+		&models.RolePermission{},
+		&models.UserRole{},
+		&models.PasswordHistory{},
+		&models.PasswordResetToken{},
+		&models.RecoveryCode{},
+		&models.OIDCLoginState{},
+		&models.Session{},
+		&models.LoginThrottle{},
+		&models.LoginLockout{},
 		&models.Student{},
+		&models.StudentGroup{},
+		&models.StudentGroupMember{},
+		&models.Accommodation{},
+		&models.Course{},
+		&models.CourseInstructor{},
+		&models.CourseEnrollment{},
 		&models.Exam{},
+		&models.ExamTarget{},
 		&models.Question{},
 		&models.Choice{},
 		&models.ExamAttempt{},
 		&models.StudentAnswer{},
+		&models.AnswerEvent{},
+		&models.IntegrityEvent{},
+		&models.Announcement{},
+		&models.AnnouncementAck{},
+		&models.SimilarityRun{},
+		&models.SimilarityPair{},
 		&models.AuditLog{},
-	)
+		&models.AuditCheckpoint{},
+	); err != nil {
+		return err
+	}
+	return dropDefaultPasswords(db)
+}
+
+// dropDefaultPasswords removes the plaintext default_password column older
+// versions kept. Users who were still on that password must change it.
+func dropDefaultPasswords(db *gorm.DB) error {
+	m := db.Migrator()
+	if !m.HasColumn(&models.User{}, "default_password") {
+		return nil
+	}
+	if err := db.Exec("UPDATE users SET must_change_password = true WHERE password_changed_at IS NULL AND COALESCE(default_password, '') <> ''").Error; err != nil {
+		return err
+	}
+	return m.DropColumn(&models.User{}, "default_password")
 }
@@ -22,14 +22,33 @@ const (
 // It will NOT overwrite existing users' passwords if they already exist.
 func Seed(db *gorm.DB) error {
 	// 1. Ensure Roles Exist
-	adminRole := models.Role{Name: "admin"}
-	if err := db.Where("name = ?", adminRole.Name).FirstOrCreate(&adminRole).Error; err != nil {
-		return err
+	roles := map[string]models.Role{}
+	for _, name := range models.BuiltinRoles {
+		role := models.Role{Name: name}
+		if err := db.Where("name = ?", role.Name).FirstOrCreate(&role).Error; err != nil {
+			return err
+		}
+		roles[name] = role
 	}
-
-	studentRole := models.Role{Name: "student"}
-	if err := db.Where("name = ?", studentRole.Name).FirstOrCreate(&studentRole).Error; err != nil {
-		return err
+	adminRole, studentRole := roles[models.RoleAdmin], roles[models.RoleStudent]
+
+	// Grant default permissions to built-in roles that have none, so that
+	// mappings edited by an admin are left alone.
+	for name, perms := range models.DefaultRolePermissions {
+		var n int64
+		if err := db.Model(&models.RolePermission{}).Where("role_id = ?", roles[name].ID).Count(&n).Error; err != nil {
+			return err
+		}
+		if n > 0 {
+			continue
+		}
+		rows := make([]models.RolePermission, 0, len(perms))
+		for _, p := range perms {
+			rows = append(rows, models.RolePermission{RoleID: roles[name].ID, Permission: p})
+		}
+		if err := db.Create(&rows).Error; err != nil {
+			return err
+		}
 	}
 
 	// 2. Ensure Admin Exists (Safe)
@@ -84,12 +103,12 @@ func ensureUserReturning(db *gorm.DB, username, email, password string, roleID u
 	}
 
 	user = models.User{
-		Username:          username,
-		Email:             email,
-		PasswordHash:      hash,
-		DefaultPassword:   password, // Store the default password for display
-		RoleID:            roleID,
-		PasswordChangedAt: nil, // Ensure it's treated as default
+		Username:           username,
+		Email:              email,
+		PasswordHash:       hash,
+		MustChangePassword: true, // The seeded password is well known
+		RoleID:             roleID,
+		PasswordChangedAt:  nil, // Ensure it's treated as default
 	}
 
 	if err := db.Create(&user).Error; err != nil {
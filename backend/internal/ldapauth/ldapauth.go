@@ -0,0 +1,258 @@
+// Package ldapauth checks passwords against an LDAP directory such as
+// OpenLDAP or Active Directory.
+package ldapauth
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/letera1/huhems-exam-system/backend/internal/auth"
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+)
+
+// defaultTimeout bounds each connection and request so a slow directory
+// cannot hold logins open.
+const defaultTimeout = 10 * time.Second
+
+// Config describes the directory and where users' details are kept in it.
+type Config struct {
+	// URL is ldap://host:port or ldaps://host:port.
+	URL string
+	// StartTLS upgrades an ldap:// connection before binding.
+	StartTLS           bool
+	InsecureSkipVerify bool
+	// BindDN and BindPassword are the service account used to find users.
+	// Leave them empty to search anonymously.
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	// UserFilter finds the user signing in; {identifier} is replaced with
+	// the escaped username or email they typed.
+	UserFilter string
+
+	// Attributes holding the user's details. IDAttr should never change for
+	// a user, like entryUUID or objectGUID; the DN is used if it is empty.
+	IDAttr         string
+	UsernameAttr   string
+	EmailAttr      string
+	NameAttr       string
+	DepartmentAttr string
+	YearAttr       string
+	// TrustEmail vouches for the addresses in EmailAttr, letting them link
+	// existing accounts. Set it only if users cannot change their own.
+	TrustEmail bool
+	// GroupAttr lists the user's groups on their own entry, like memberOf.
+	GroupAttr string
+	// GroupFilter, if set, searches GroupBaseDN (or BaseDN) for the user's
+	// groups instead; {dn} and {username} are replaced with the user's
+	// escaped DN and username.
+	GroupBaseDN string
+	GroupFilter string
+
+	// RoleRules map group names to roles; users matching none get
+	// DefaultRole, or are refused if that is empty.
+	RoleRules   []auth.RoleRule
+	DefaultRole string
+
+	Timeout time.Duration
+}
+
+// Provider authenticates users by binding to the directory as them.
+type Provider struct {
+	cfg Config
+}
+
+// New returns a provider for the directory described by cfg.
+func New(cfg Config) *Provider {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	return &Provider{cfg: cfg}
+}
+
+func (p *Provider) Name() string { return models.AuthProviderLDAP }
+
+// Authenticate finds the user with the service account, checks the password
+// by binding as them, and reads their details and groups.
+func (p *Provider) Authenticate(ctx context.Context, identifier, password, _ string) (*auth.ExternalIdentity, error) {
+	// Most directories treat a bind with an empty password as an anonymous
+	// bind, which would succeed for any user.
+	if identifier == "" || password == "" {
+		return nil, auth.ErrInvalidCredentials
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	conn, err := p.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := p.serviceBind(conn); err != nil {
+		return nil, err
+	}
+	entry, err := p.findUser(conn, identifier)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, auth.ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("ldap bind as user: %w", err)
+	}
+
+	groups := entry.GetAttributeValues(p.cfg.GroupAttr)
+	if p.cfg.GroupFilter != "" {
+		// The user may not be allowed to read groups, so search as the
+		// service account again.
+		if err := p.serviceBind(conn); err != nil {
+			return nil, err
+		}
+		if groups, err = p.findGroups(conn, entry); err != nil {
+			return nil, err
+		}
+	}
+	return p.identity(entry, groups), nil
+}
+
+func (p *Provider) dial() (*ldap.Conn, error) {
+	u, err := url.Parse(p.cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("ldap url: %w", err)
+	}
+	tlsConfig := &tls.Config{ServerName: u.Hostname(), InsecureSkipVerify: p.cfg.InsecureSkipVerify}
+	conn, err := ldap.DialURL(p.cfg.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: p.cfg.Timeout}),
+		ldap.DialWithTLSConfig(tlsConfig),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ldap dial: %w", err)
+	}
+	conn.SetTimeout(p.cfg.Timeout)
+	if p.cfg.StartTLS && u.Scheme == "ldap" {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("ldap starttls: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+func (p *Provider) serviceBind(conn *ldap.Conn) error {
+	if p.cfg.BindDN == "" {
+		return nil
+	}
+	if err := conn.Bind(p.cfg.BindDN, p.cfg.BindPassword); err != nil {
+		return fmt.Errorf("ldap bind as service account: %w", err)
+	}
+	return nil
+}
+
+func (p *Provider) findUser(conn *ldap.Conn, identifier string) (*ldap.Entry, error) {
+	filter := strings.ReplaceAll(p.cfg.UserFilter, "{identifier}", ldap.EscapeFilter(identifier))
+	var attrs []string
+	for _, a := range []string{p.cfg.IDAttr, p.cfg.UsernameAttr, p.cfg.EmailAttr, p.cfg.NameAttr, p.cfg.DepartmentAttr, p.cfg.YearAttr, p.cfg.GroupAttr} {
+		if a != "" {
+			attrs = append(attrs, a)
+		}
+	}
+	res, err := conn.Search(ldap.NewSearchRequest(
+		p.cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, int(p.cfg.Timeout.Seconds()), false, filter, attrs, nil,
+	))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, fmt.Errorf("ldap user search: %w", err)
+	}
+	// No match, or an identifier that matches several users, is treated
+	// as a wrong password rather than guessing.
+	if res == nil || len(res.Entries) != 1 {
+		return nil, auth.ErrInvalidCredentials
+	}
+	return res.Entries[0], nil
+}
+
+func (p *Provider) findGroups(conn *ldap.Conn, user *ldap.Entry) ([]string, error) {
+	base := p.cfg.GroupBaseDN
+	if base == "" {
+		base = p.cfg.BaseDN
+	}
+	filter := strings.NewReplacer(
+		"{dn}", ldap.EscapeFilter(user.DN),
+		"{username}", ldap.EscapeFilter(user.GetAttributeValue(p.cfg.UsernameAttr)),
+	).Replace(p.cfg.GroupFilter)
+	res, err := conn.Search(ldap.NewSearchRequest(
+		base, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		0, int(p.cfg.Timeout.Seconds()), false, filter, []string{"1.1"}, nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("ldap group search: %w", err)
+	}
+	groups := make([]string, 0, len(res.Entries))
+	for _, e := range res.Entries {
+		groups = append(groups, e.DN)
+	}
+	return groups, nil
+}
+
+func (p *Provider) identity(entry *ldap.Entry, groups []string) *auth.ExternalIdentity {
+	id := &auth.ExternalIdentity{
+		Provider:      models.AuthProviderLDAP,
+		Subject:       p.subject(entry),
+		Username:      entry.GetAttributeValue(p.cfg.UsernameAttr),
+		Email:         strings.ToLower(entry.GetAttributeValue(p.cfg.EmailAttr)),
+		EmailVerified: p.cfg.TrustEmail,
+		FullName:      entry.GetAttributeValue(p.cfg.NameAttr),
+		Department:    entry.GetAttributeValue(p.cfg.DepartmentAttr),
+		Role:          auth.MapRole(groupNames(groups), p.cfg.RoleRules, p.cfg.DefaultRole),
+	}
+	if p.cfg.YearAttr != "" {
+		id.Year, _ = strconv.Atoi(strings.TrimSpace(entry.GetAttributeValue(p.cfg.YearAttr)))
+	}
+	if id.Username == "" {
+		id.Username = id.Subject
+	}
+	return id
+}
+
+// subject is the user's ID attribute, hex-encoded if it is binary like
+// Active Directory's objectGUID, or their DN.
+func (p *Provider) subject(entry *ldap.Entry) string {
+	if p.cfg.IDAttr != "" {
+		raw := entry.GetRawAttributeValue(p.cfg.IDAttr)
+		if len(raw) > 0 {
+			if utf8.Valid(raw) && !strings.ContainsRune(string(raw), 0) {
+				return string(raw)
+			}
+			return hex.EncodeToString(raw)
+		}
+	}
+	return strings.ToLower(entry.DN)
+}
+
+// groupNames turns group DNs into the value of their first RDN, usually the
+// cn, which is what the role map matches. Values that are not DNs, like an
+// affiliation attribute, are used as they are.
+func groupNames(groups []string) []string {
+	names := make([]string, 0, len(groups))
+	for _, g := range groups {
+		dn, err := ldap.ParseDN(g)
+		if err != nil || len(dn.RDNs) == 0 || len(dn.RDNs[0].Attributes) == 0 {
+			names = append(names, g)
+			continue
+		}
+		names = append(names, dn.RDNs[0].Attributes[0].Value)
+	}
+	return names
+}
@@ -0,0 +1,28 @@
+package ldapauth
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGroupNames(t *testing.T) {
+	tests := []struct {
+		name   string
+		groups []string
+		want   []string
+	}{
+		{"none", nil, []string{}},
+		{"group DN", []string{"cn=Exam-Admins,ou=Groups,dc=huhems,dc=edu"}, []string{"Exam-Admins"}},
+		{"other first attribute", []string{"ou=Staff,dc=huhems,dc=edu"}, []string{"Staff"}},
+		{"escaped comma", []string{`cn=Staff\, Science,ou=Groups,dc=huhems,dc=edu`}, []string{"Staff, Science"}},
+		{"plain value", []string{"faculty"}, []string{"faculty"}},
+		{"mixed", []string{"student", "CN=Proctors,OU=Groups,DC=huhems,DC=edu"}, []string{"student", "Proctors"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := groupNames(tt.groups); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("groupNames(%q) = %q, want %q", tt.groups, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+// Package live fans out attempt state changes to connected dashboards.
+package live
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// subscriberBuffer is how many events a slow subscriber may fall behind
+// before further events to it are dropped.
+const subscriberBuffer = 64
+
+// Event is a single state change published to a topic.
+type Event struct {
+	Type string    `json:"type"`
+	At   time.Time `json:"at"`
+	Data any       `json:"data"`
+}
+
+// ExamTopic is the topic for changes to any attempt of an exam.
+func ExamTopic(examID uuid.UUID) string { return "exam:" + examID.String() }
+
+// AttemptTopic is the topic for changes to a single attempt.
+func AttemptTopic(attemptID uuid.UUID) string { return "attempt:" + attemptID.String() }
+
+// Hub is an in-process publish/subscribe hub. Subscribers only receive events
+// published by this process after they subscribed.
+type Hub struct {
+	mu   sync.RWMutex
+	subs map[string]map[chan Event]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: map[string]map[chan Event]struct{}{}}
+}
+
+// Subscribe returns a channel receiving the events published to topic and a
+// function that cancels the subscription and closes the channel.
+func (h *Hub) Subscribe(topic string) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	h.mu.Lock()
+	set := h.subs[topic]
+	if set == nil {
+		set = map[chan Event]struct{}{}
+		h.subs[topic] = set
+	}
+	set[ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subs[topic], ch)
+			if len(h.subs[topic]) == 0 {
+				delete(h.subs, topic)
+			}
+			h.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// HasSubscribers reports whether anyone is listening on topic, so publishers
+// can skip building events nobody will receive.
+func (h *Hub) HasSubscribers(topic string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subs[topic]) > 0
+}
+
+// Publish sends e to every subscriber of topic without blocking. Subscribers
+// whose buffer is full miss the event.
+func (h *Hub) Publish(topic string, e Event) {
+	if e.At.IsZero() {
+		e.At = time.Now().UTC()
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for ch := range h.subs[topic] {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
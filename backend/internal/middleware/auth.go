@@ -3,20 +3,87 @@ package middleware
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/letera1/huhems-exam-system/backend/internal/auth"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"gorm.io/gorm"
 )
 
 type ContextKey string
 
 const (
-	ContextUserID ContextKey = "userID"
-	ContextRole   ContextKey = "role"
+	ContextUserID    ContextKey = "userID"
+	ContextRole      ContextKey = "role"
+	ContextSessionID ContextKey = "sessionID"
 )
 
-func AuthRequired(jwtSecret string) gin.HandlerFunc {
+// passwordChangePaths are the only routes open to a user who must change
+// their password.
+var passwordChangePaths = map[string]bool{
+	"/auth/password": true,
+	"/auth/me":       true,
+}
+
+// mfaSetupPaths are the only routes open to a user who must enroll in
+// two-factor authentication.
+var mfaSetupPaths = map[string]bool{
+	"/auth/me":         true,
+	"/auth/password":   true,
+	"/auth/2fa":        true,
+	"/auth/2fa/setup":  true,
+	"/auth/2fa/enable": true,
+}
+
+// PasswordChangeRequired reports whether the user must choose a new password
+// before doing anything else, either because they were given a temporary one
+// or because theirs has expired under the policy. Users who sign in through
+// an external identity provider have no password here to change.
+func PasswordChangeRequired(u models.User, policy auth.PasswordPolicy, now time.Time) bool {
+	if u.AuthProvider != "" && u.AuthProvider != models.AuthProviderLocal {
+		return false
+	}
+	if u.MustChangePassword {
+		return true
+	}
+	setAt := u.CreatedAt
+	if u.PasswordChangedAt != nil {
+		setAt = *u.PasswordChangedAt
+	}
+	return policy.Expired(setAt, now)
+}
+
+// MFARequired reports whether the organisation requires two-factor
+// authentication of the user, which it does for holders of the admin role
+// when RequiredForAdmins is set.
+func MFARequired(db *gorm.DB, userID uuid.UUID, mfa auth.MFAConfig) (bool, error) {
+	if !mfa.RequiredForAdmins {
+		return false, nil
+	}
+	var n int64
+	err := db.Model(&models.Role{}).
+		Where("name = ?", models.RoleAdmin).
+		Where("id IN (SELECT role_id FROM users WHERE id = ? AND deleted_at IS NULL) OR id IN (SELECT role_id FROM user_roles WHERE user_id = ? AND deleted_at IS NULL)", userID, userID).
+		Count(&n).Error
+	return n > 0, err
+}
+
+// MFASetupRequired reports whether the user must enroll in two-factor
+// authentication before doing anything else.
+func MFASetupRequired(db *gorm.DB, u models.User, mfa auth.MFAConfig) (bool, error) {
+	if u.TOTPEnabledAt != nil {
+		return false, nil
+	}
+	return MFARequired(db, u.ID, mfa)
+}
+
+// AuthRequired accepts a bearer access token whose session is still active
+// and whose user still exists. Users who must change their password are held
+// to the password change routes until they do, and admins who must enroll in
+// two-factor authentication to the enrollment routes.
+func AuthRequired(db *gorm.DB, jwtSecret string, policy auth.PasswordPolicy, mfa auth.MFAConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -41,14 +108,53 @@ func AuthRequired(jwtSecret string) gin.HandlerFunc {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "invalid token subject"})
 			return
 		}
+		sessionID, err := uuid.Parse(claims.SessionID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "invalid token session"})
+			return
+		}
+
+		now := time.Now().UTC()
+		var users []models.User
+		if err := db.Select("users.id", "users.created_at", "users.password_changed_at", "users.must_change_password", "users.totp_enabled_at", "users.auth_provider").
+			Joins("JOIN sessions ON sessions.user_id = users.id").
+			Where("sessions.id = ? AND users.id = ? AND sessions.revoked_at IS NULL AND sessions.expires_at > ?", sessionID, userID, now).
+			Limit(1).
+			Find(&users).Error; err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "failed to check session"})
+			return
+		}
+		if len(users) == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "session expired or revoked"})
+			return
+		}
+		if PasswordChangeRequired(users[0], policy, now) && !passwordChangePaths[c.FullPath()] {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "password change required", "passwordChangeRequired": true})
+			return
+		}
+		if !mfaSetupPaths[c.FullPath()] {
+			setup, err := MFASetupRequired(db, users[0], mfa)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "failed to check two-factor authentication"})
+				return
+			}
+			if setup {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "two-factor authentication setup required", "mfaSetupRequired": true})
+				return
+			}
+		}
 
 		c.Set(string(ContextUserID), userID)
+		c.Set(string(ContextSessionID), sessionID)
 		c.Set(string(ContextRole), claims.Role)
 		c.Next()
 	}
 }
 
-func RequireRole(role string) gin.HandlerFunc {
+// RequireRole lets the request through if the caller has any of the roles.
+// After LoadAccess every role the user holds counts; otherwise only the
+// primary role in the token does.
+func RequireRole(roles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		v, ok := c.Get(string(ContextRole))
 		if !ok {
@@ -56,10 +162,18 @@ func RequireRole(role string) gin.HandlerFunc {
 			return
 		}
 		current, _ := v.(string)
-		if current != role {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "forbidden"})
-			return
+		held := []string{current}
+		if v, ok := c.Get(string(ContextRoles)); ok {
+			held, _ = v.([]string)
 		}
-		c.Next()
+		for _, role := range roles {
+			for _, h := range held {
+				if h == role {
+					c.Next()
+					return
+				}
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "forbidden"})
 	}
 }
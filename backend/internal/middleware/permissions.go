@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	ContextRoles       ContextKey = "roles"
+	ContextPermissions ContextKey = "permissions"
+)
+
+// Access is what a user may do: every role they hold and the union of those
+// roles' permissions.
+type Access struct {
+	Roles       []string
+	Permissions map[string]bool
+}
+
+// Has reports whether the permission is granted.
+func (a Access) Has(permission string) bool {
+	return a.Permissions[permission]
+}
+
+// SortedPermissions returns the granted permissions in name order.
+func (a Access) SortedPermissions() []string {
+	out := make([]string, 0, len(a.Permissions))
+	for p := range a.Permissions {
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// LoadUserAccess loads a user's roles and permissions. The admin role holds
+// every permission.
+func LoadUserAccess(db *gorm.DB, userID uuid.UUID) (Access, error) {
+	return LoadUsersAccess(db, []uuid.UUID{userID})
+}
+
+// LoadUsersAccess loads the roles and permissions held by any of the users.
+func LoadUsersAccess(db *gorm.DB, userIDs []uuid.UUID) (Access, error) {
+	var roles []models.Role
+	if err := db.
+		Where("id IN (SELECT role_id FROM users WHERE id IN ? AND deleted_at IS NULL)", userIDs).
+		Or("id IN (SELECT role_id FROM user_roles WHERE user_id IN ? AND deleted_at IS NULL)", userIDs).
+		Order("name asc").
+		Find(&roles).Error; err != nil {
+		return Access{}, err
+	}
+
+	access := Access{Roles: make([]string, 0, len(roles)), Permissions: map[string]bool{}}
+	roleIDs := make([]uuid.UUID, 0, len(roles))
+	for _, r := range roles {
+		access.Roles = append(access.Roles, r.Name)
+		roleIDs = append(roleIDs, r.ID)
+		if r.Name == models.RoleAdmin {
+			for _, p := range models.Permissions {
+				access.Permissions[p.Name] = true
+			}
+		}
+	}
+	if len(roleIDs) == 0 {
+		return access, nil
+	}
+
+	var perms []string
+	if err := db.Model(&models.RolePermission{}).Where("role_id IN ?", roleIDs).Distinct().Pluck("permission", &perms).Error; err != nil {
+		return Access{}, err
+	}
+	for _, p := range perms {
+		access.Permissions[p] = true
+	}
+	return access, nil
+}
+
+// LoadAccess loads the caller's roles and permissions from the database, so
+// role changes apply without signing in again. It must run after
+// AuthRequired.
+func LoadAccess(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := c.Get(string(ContextUserID))
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "not authenticated"})
+			return
+		}
+		userID, _ := v.(uuid.UUID)
+		access, err := LoadUserAccess(db, userID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "failed to load permissions"})
+			return
+		}
+		c.Set(string(ContextRoles), access.Roles)
+		c.Set(string(ContextPermissions), access.Permissions)
+		c.Next()
+	}
+}
+
+// HasPermission reports whether LoadAccess granted the caller the permission.
+func HasPermission(c *gin.Context, permission string) bool {
+	v, _ := c.Get(string(ContextPermissions))
+	perms, _ := v.(map[string]bool)
+	return perms[permission]
+}
+
+// RequirePermission lets the request through if the caller holds every one
+// of the permissions. It must run after LoadAccess.
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get(string(ContextPermissions)); !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "not authenticated"})
+			return
+		}
+		for _, p := range permissions {
+			if !HasPermission(c, p) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "missing permission " + p})
+				return
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Accommodation records adjustments granted to a student with documented
+// needs. A row with a nil ExamID is the student's default; a row for an exam
+// replaces the default entirely for that exam. A student has at most one of
+// each, which the two partial unique indexes enforce.
+type Accommodation struct {
+	BaseModel
+
+	StudentID uuid.UUID `gorm:"type:uuid;index;not null;uniqueIndex:idx_accommodation_default,where:exam_id IS NULL AND deleted_at IS NULL;uniqueIndex:idx_accommodation_exam,priority:1,where:deleted_at IS NULL"`
+	Student   Student   `gorm:"foreignKey:StudentID"`
+
+	ExamID *uuid.UUID `gorm:"type:uuid;index;uniqueIndex:idx_accommodation_exam,priority:2"`
+
+	// TimeMultiplier scales the exam duration (1.25 = 25% extra time).
+	// ExtraMinutes is added on top.
+	TimeMultiplier float64 `gorm:"not null;default:1"`
+	ExtraMinutes   int     `gorm:"not null;default:0"`
+
+	// ExtraAttempts is added to the exam's MaxAttempts.
+	ExtraAttempts int `gorm:"not null;default:0"`
+
+	// AvailableFrom and AvailableUntil replace the exam's StartTime and
+	// EndTime when set.
+	AvailableFrom  *time.Time
+	AvailableUntil *time.Time
+
+	Notes string `gorm:"type:text"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Announcement is a message posted to everyone taking an exam, e.g. a
+// correction to a question.
+type Announcement struct {
+	BaseModel
+
+	ExamID uuid.UUID `gorm:"type:uuid;index;not null"`
+	Exam   Exam      `gorm:"foreignKey:ExamID"`
+
+	AuthorID uuid.UUID `gorm:"type:uuid;not null"`
+	Author   User      `gorm:"foreignKey:AuthorID"`
+
+	Message string `gorm:"type:text;not null"`
+}
+
+// AnnouncementAck records that a student has seen an announcement.
+type AnnouncementAck struct {
+	BaseModel
+
+	AnnouncementID uuid.UUID    `gorm:"type:uuid;not null;uniqueIndex:idx_announcement_ack"`
+	Announcement   Announcement `gorm:"foreignKey:AnnouncementID"`
+
+	StudentID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_announcement_ack"`
+	Student   Student   `gorm:"foreignKey:StudentID"`
+
+	// AttemptID is the attempt the student was in when acknowledging.
+	AttemptID uuid.UUID `gorm:"type:uuid;not null"`
+
+	AckedAt time.Time `gorm:"not null"`
+}
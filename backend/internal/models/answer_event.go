@@ -0,0 +1,26 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// AnswerEvent is an append-only log of every answer a student saves during an
+// attempt. StudentAnswer only keeps the latest selection; these rows keep the
+// history so reports can look at timing and answer changes.
+type AnswerEvent struct {
+	BaseModel
+
+	AttemptID uuid.UUID   `gorm:"type:uuid;index;not null"`
+	Attempt   ExamAttempt `gorm:"foreignKey:AttemptID"`
+
+	QuestionID uuid.UUID `gorm:"type:uuid;index;not null"`
+	Question   Question  `gorm:"foreignKey:QuestionID"`
+
+	SelectedChoiceIDs pq.StringArray `gorm:"type:text[]"`
+
+	// VisibleMs is the client-reported time (in milliseconds) the question was
+	// on screen since the previous answer event. Nil when the client did not
+	// report it.
+	VisibleMs *int64
+}
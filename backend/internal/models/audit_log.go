@@ -6,14 +6,42 @@ import (
 	"github.com/google/uuid"
 )
 
+// AuditLog entries form a hash chain: every entry stores the hash of the
+// previous one (by Seq) so that edits or deletions can be detected. Entries
+// written before chaining was introduced have Seq 0 and are not chained.
 type AuditLog struct {
 	BaseModel
 
+	Seq      int64  `gorm:"not null;default:0;index"`
+	PrevHash string `gorm:"not null;default:''"`
+	Hash     string `gorm:"not null;default:''"`
+
+	// UserID is the actor who performed the action.
 	UserID uuid.UUID `gorm:"type:uuid;index;not null"`
 	User   User      `gorm:"foreignKey:UserID"`
 
-	Action   string    `gorm:"not null"`
-	EntityID uuid.UUID `gorm:"type:uuid;index;not null"`
+	Action     string     `gorm:"not null;index"`
+	EntityType string     `gorm:"index"`
+	EntityID   *uuid.UUID `gorm:"type:uuid;index"`
+
+	// Before and After hold JSON snapshots of the fields that changed. Before
+	// is nil for creations and After is nil for deletions.
+	Before *string `gorm:"type:jsonb"`
+	After  *string `gorm:"type:jsonb"`
+
+	IP        string
+	UserAgent string
 
 	Timestamp time.Time `gorm:"autoCreateTime"`
 }
+
+// AuditCheckpoint is a signed statement that the audit chain had the given
+// head hash at sequence Seq. Checkpoints make truncation of the chain
+// detectable.
+type AuditCheckpoint struct {
+	BaseModel
+
+	Seq       int64  `gorm:"not null;uniqueIndex"`
+	Hash      string `gorm:"not null"`
+	Signature string `gorm:"not null"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "github.com/google/uuid"
+
+// Course groups exams under the instructors who teach it. Instructors can
+// only manage exams of their own courses, and a course's exams are only open
+// to students enrolled in it.
+type Course struct {
+	BaseModel
+
+	Code        string `gorm:"not null;uniqueIndex"`
+	Title       string `gorm:"not null"`
+	Description string `gorm:"type:text"`
+}
+
+// CourseInstructor links an instructor's user account to a course.
+type CourseInstructor struct {
+	BaseModel
+
+	CourseID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_course_instructor"`
+	Course   Course    `gorm:"foreignKey:CourseID"`
+
+	UserID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_course_instructor;index"`
+	User   User      `gorm:"foreignKey:UserID"`
+}
+
+// CourseEnrollment links a student to a course.
+type CourseEnrollment struct {
+	BaseModel
+
+	CourseID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_course_enrollment"`
+	Course   Course    `gorm:"foreignKey:CourseID"`
+
+	StudentID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_course_enrollment;index"`
+	Student   Student   `gorm:"foreignKey:StudentID"`
+}
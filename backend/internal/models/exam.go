@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type Exam struct {
@@ -17,6 +18,18 @@ type Exam struct {
 
 	Published bool `gorm:"not null;default:false" json:"published"`
 
+	// CourseID is the course the exam belongs to. Exams without a course are
+	// only visible to super-admins.
+	CourseID *uuid.UUID `gorm:"type:uuid;index" json:"courseId"`
+
+	// Tags are free-form labels (e.g. "midterm", "algorithms") used to group
+	// exams in cross-exam analytics.
+	Tags pq.StringArray `gorm:"type:text[]" json:"tags"`
+
+	// IntegrityThresholds flags an attempt for review once it reports this
+	// many integrity events of a type. Nil disables automatic flagging.
+	IntegrityThresholds IntegrityThresholds `gorm:"type:jsonb" json:"integrityThresholds"`
+
 	StartTime *time.Time `json:"startTime"`
 	EndTime   *time.Time `json:"endTime"`
 
@@ -20,4 +20,39 @@ type ExamAttempt struct {
 
 	Score     float64 `gorm:"not null;default:0"`
 	Submitted bool    `gorm:"not null;default:false"`
+
+	// FlaggedForReview is set when the attempt crosses one of the exam's
+	// integrity thresholds (or by a proctor).
+	FlaggedForReview bool `gorm:"not null;default:false"`
+	FlaggedAt        *time.Time
+	ReviewReason     string
+
+	// The attempt is bound to the browser session that started it. Only a
+	// SHA-256 hash of the session token is stored. An empty hash means the
+	// attempt is unbound (started before binding existed, or transferred by a
+	// proctor) and cannot be used until a start request binds it.
+	SessionTokenHash string `gorm:"size:64"`
+	SessionIP        string
+	SessionUserAgent string
+	SessionBoundAt   *time.Time
+	// SessionTransferHash is the SHA-256 hash of the one-time code a proctor
+	// hands out when transferring the attempt. While it is set, only a start
+	// request carrying the code can bind the attempt.
+	SessionTransferHash string `gorm:"size:64"`
+
+	// Proctor interventions. While PausedAt is set the clock is stopped;
+	// PausedSeconds accumulates completed pauses. ExtraMinutes is time granted
+	// by a proctor. Both extend the deadline.
+	PausedAt      *time.Time
+	PausedSeconds int64 `gorm:"not null;default:0"`
+	ExtraMinutes  int   `gorm:"not null;default:0"`
+
+	// AccommodationMinutes is the extra time from the student's accommodation,
+	// fixed when the attempt starts.
+	AccommodationMinutes int `gorm:"not null;default:0"`
+
+	// An invalidated attempt is closed and excluded from reports.
+	Invalidated       bool `gorm:"not null;default:false"`
+	InvalidatedAt     *time.Time
+	InvalidatedReason string
 }
@@ -0,0 +1,22 @@
+package models
+
+import "github.com/google/uuid"
+
+// ExamTarget restricts who may take an exam. An exam without targets is open
+// to every student; otherwise a student is eligible if any target matches.
+//
+// A target sets exactly one of: Department and/or Year (a cohort; both must
+// match when both are set), GroupID, or StudentID.
+type ExamTarget struct {
+	BaseModel
+
+	ExamID uuid.UUID `gorm:"type:uuid;index;not null"`
+	Exam   Exam      `gorm:"foreignKey:ExamID"`
+
+	Department string
+	Year       int
+
+	GroupID *uuid.UUID `gorm:"type:uuid;index"`
+
+	StudentID *uuid.UUID `gorm:"type:uuid;index"`
+}
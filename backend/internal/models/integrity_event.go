@@ -0,0 +1,98 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type IntegrityEventType string
+
+const (
+	IntegrityEventTabHidden      IntegrityEventType = "tab_hidden"
+	IntegrityEventWindowBlur     IntegrityEventType = "window_blur"
+	IntegrityEventCopy           IntegrityEventType = "copy"
+	IntegrityEventCut            IntegrityEventType = "cut"
+	IntegrityEventPaste          IntegrityEventType = "paste"
+	IntegrityEventFullscreenExit IntegrityEventType = "fullscreen_exit"
+	IntegrityEventDevtoolsOpen   IntegrityEventType = "devtools_open"
+)
+
+// IntegrityEventTypes lists the event types clients may report.
+var IntegrityEventTypes = []IntegrityEventType{
+	IntegrityEventTabHidden,
+	IntegrityEventWindowBlur,
+	IntegrityEventCopy,
+	IntegrityEventCut,
+	IntegrityEventPaste,
+	IntegrityEventFullscreenExit,
+	IntegrityEventDevtoolsOpen,
+}
+
+// Event types recorded by the server rather than reported by clients.
+const (
+	IntegrityEventSessionConflict IntegrityEventType = "session_conflict"
+	IntegrityEventIPChange        IntegrityEventType = "ip_change"
+)
+
+// ServerIntegrityEventTypes lists the event types only the server records.
+var ServerIntegrityEventTypes = []IntegrityEventType{
+	IntegrityEventSessionConflict,
+	IntegrityEventIPChange,
+}
+
+// IntegrityThresholdTotal is the threshold key that applies to the sum of all
+// event types.
+const IntegrityThresholdTotal = "total"
+
+// IntegrityEvent is a client-reported event that may indicate a breach of
+// exam rules, such as leaving the exam tab.
+type IntegrityEvent struct {
+	BaseModel
+
+	AttemptID uuid.UUID   `gorm:"type:uuid;index;not null"`
+	Attempt   ExamAttempt `gorm:"foreignKey:AttemptID"`
+
+	Type string `gorm:"not null;index"`
+	// OccurredAt is the client-reported time of the event.
+	OccurredAt time.Time
+	Details    string `gorm:"type:text"`
+
+	IP        string
+	UserAgent string
+}
+
+// IntegrityThresholds maps an event type (or "total") to the number of events
+// at which an attempt is flagged for review.
+type IntegrityThresholds map[string]int
+
+func (t IntegrityThresholds) Value() (driver.Value, error) {
+	if t == nil {
+		return nil, nil
+	}
+	raw, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
+
+func (t *IntegrityThresholds) Scan(src any) error {
+	if src == nil {
+		*t = nil
+		return nil
+	}
+	var raw []byte
+	switch v := src.(type) {
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return errors.New("unsupported type for IntegrityThresholds")
+	}
+	return json.Unmarshal(raw, t)
+}
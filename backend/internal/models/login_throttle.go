@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LoginThrottle holds the failed-login count of one key for the Postgres
+// rate-limit store. Rows are throwaway state, so they are deleted outright.
+type LoginThrottle struct {
+	Key         string `gorm:"primaryKey"`
+	Failures    int    `gorm:"not null"`
+	WindowStart time.Time
+	LastFailure time.Time `gorm:"index"`
+	LockedUntil *time.Time
+}
+
+// Lockout scopes.
+const (
+	LockoutAccount = "account"
+	LockoutIP      = "ip"
+)
+
+// LoginLockout records an account or IP being locked out after repeated
+// failed logins.
+type LoginLockout struct {
+	BaseModel
+
+	Scope string `gorm:"not null;index"`
+	// Identifier is the username or email tried, or the IP for IP lockouts.
+	Identifier string `gorm:"not null"`
+	// UserID is set for account lockouts of an existing user.
+	UserID *uuid.UUID `gorm:"type:uuid;index"`
+	IP     string
+
+	Failures    int       `gorm:"not null"`
+	LockedUntil time.Time `gorm:"not null"`
+
+	UnlockedAt   *time.Time
+	UnlockedByID *uuid.UUID `gorm:"type:uuid"`
+}
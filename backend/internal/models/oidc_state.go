@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// OIDCLoginState carries an OpenID Connect login from the redirect to the
+// identity provider to the callback. Only the hash of the state is stored;
+// the PKCE verifier and nonce never leave the server.
+type OIDCLoginState struct {
+	BaseModel
+
+	StateHash    string    `gorm:"not null;uniqueIndex"`
+	CodeVerifier string    `gorm:"not null"`
+	Nonce        string    `gorm:"not null"`
+	ExpiresAt    time.Time `gorm:"not null;index"`
+}
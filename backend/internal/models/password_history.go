@@ -0,0 +1,12 @@
+package models
+
+import "github.com/google/uuid"
+
+// PasswordHistory keeps the hashes of a user's previous passwords so the
+// password policy can refuse their reuse. Only the most recent ones are kept.
+type PasswordHistory struct {
+	BaseModel
+
+	UserID       uuid.UUID `gorm:"type:uuid;not null;index"`
+	PasswordHash string    `gorm:"not null"`
+}
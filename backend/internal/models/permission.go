@@ -0,0 +1,67 @@
+package models
+
+// Permissions checked by the admin API.
+const (
+	PermExamRead         = "exam:read"
+	PermExamWrite        = "exam:write"
+	PermReportRead       = "report:read"
+	PermLiveMonitor      = "live:monitor"
+	PermAttemptGrade     = "attempt:grade"
+	PermAttemptIntervene = "attempt:intervene"
+	PermStudentRead      = "student:read"
+	PermStudentWrite     = "student:write"
+	PermStudentImport    = "student:import"
+	PermCourseWrite      = "course:write"
+	PermCourseAll        = "course:all"
+	PermAuditRead        = "audit:read"
+	PermSessionRevoke    = "session:revoke"
+	PermAccountUnlock    = "account:unlock"
+	PermPasswordReset    = "password:reset"
+	PermMFAReset         = "mfa:reset"
+	PermRoleManage       = "role:manage"
+)
+
+type PermissionInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Permissions lists every permission that can be granted to a role.
+var Permissions = []PermissionInfo{
+	{PermExamRead, "View exams, their questions, targets and rosters"},
+	{PermExamWrite, "Create, edit, publish and delete exams and questions"},
+	{PermReportRead, "View exam reports, integrity, similarity and analytics"},
+	{PermLiveMonitor, "Watch the live view of running exams"},
+	{PermAttemptGrade, "Review and grade attempts"},
+	{PermAttemptIntervene, "Pause, resume, extend, transfer, force-submit and invalidate attempts"},
+	{PermStudentRead, "View students, groups and accommodations"},
+	{PermStudentWrite, "Create, edit and delete students, groups, enrollments and accommodations"},
+	{PermStudentImport, "Import students and group memberships from CSV"},
+	{PermCourseWrite, "Create, edit and delete courses and staff accounts"},
+	{PermCourseAll, "Access every course rather than only assigned ones"},
+	{PermAuditRead, "View the audit log"},
+	{PermSessionRevoke, "View and revoke users' sign-in sessions"},
+	{PermAccountUnlock, "View login lockouts and unlock accounts"},
+	{PermPasswordReset, "Reset users' passwords to temporary ones, singly or in bulk"},
+	{PermMFAReset, "Turn off users' two-factor authentication"},
+	{PermRoleManage, "Manage roles, permissions and user role assignments"},
+}
+
+// IsPermission reports whether name is a known permission.
+func IsPermission(name string) bool {
+	for _, p := range Permissions {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultRolePermissions are granted by the seed to built-in roles that have
+// no permissions yet.
+var DefaultRolePermissions = map[string][]string{
+	RoleInstructor: {PermExamRead, PermExamWrite, PermReportRead, PermLiveMonitor, PermAttemptGrade, PermAttemptIntervene},
+	RoleGrader:     {PermExamRead, PermReportRead, PermAttemptGrade},
+	RoleProctor:    {PermExamRead, PermLiveMonitor, PermAttemptIntervene},
+	RoleAuditor:    {PermExamRead, PermReportRead, PermAuditRead, PermCourseAll},
+}
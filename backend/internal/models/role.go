@@ -1,6 +1,43 @@
 package models
 
+import "github.com/google/uuid"
+
+// Built-in role names.
+const (
+	RoleAdmin      = "admin"
+	RoleInstructor = "instructor"
+	RoleGrader     = "grader"
+	RoleProctor    = "proctor"
+	RoleAuditor    = "auditor"
+	RoleStudent    = "student"
+)
+
+// BuiltinRoles are created by the seed and cannot be deleted.
+var BuiltinRoles = []string{RoleAdmin, RoleInstructor, RoleGrader, RoleProctor, RoleAuditor, RoleStudent}
+
 type Role struct {
 	BaseModel
 	Name string `gorm:"uniqueIndex;not null"`
 }
+
+// RolePermission grants a permission to every user holding the role. The
+// admin role implicitly holds every permission and needs no rows.
+type RolePermission struct {
+	BaseModel
+
+	RoleID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_role_permission"`
+	Role   Role      `gorm:"foreignKey:RoleID"`
+
+	Permission string `gorm:"not null;uniqueIndex:idx_role_permission"`
+}
+
+// UserRole gives a user a role in addition to the primary role on User.
+type UserRole struct {
+	BaseModel
+
+	UserID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_user_role"`
+	User   User      `gorm:"foreignKey:UserID"`
+
+	RoleID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_user_role;index"`
+	Role   Role      `gorm:"foreignKey:RoleID"`
+}
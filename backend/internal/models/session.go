@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Reasons recorded when a session is revoked.
+const (
+	SessionRevokedLogout        = "logout"
+	SessionRevokedLogoutAll     = "logout_all"
+	SessionRevokedAdmin         = "admin"
+	SessionRevokedReuse         = "refresh_reuse"
+	SessionRevokedUserDeleted   = "user_deleted"
+	SessionRevokedPasswordReset = "password_reset"
+	SessionRevokedAdminReset    = "admin_password_reset"
+	SessionRevokedMFAReset      = "admin_mfa_reset"
+)
+
+// Session is one sign-in of a user. Access tokens carry its ID and stop
+// working as soon as it is revoked. The refresh token is stored hashed and
+// rotates on every use; presenting the previous one again revokes the
+// session, since it means the token was copied. RotatedAt lets the previous
+// token through for a few seconds after rotation, for parallel requests that
+// refreshed with it at the same time.
+type Session struct {
+	BaseModel
+
+	UserID uuid.UUID `gorm:"type:uuid;not null;index"`
+	User   User      `gorm:"foreignKey:UserID"`
+
+	RefreshTokenHash  string `gorm:"not null;uniqueIndex"`
+	PreviousTokenHash string `gorm:"index"`
+	RotatedAt         *time.Time
+
+	ExpiresAt    time.Time  `gorm:"not null"`
+	LastUsedAt   time.Time  `gorm:"not null"`
+	RevokedAt    *time.Time `gorm:"index"`
+	RevokeReason string
+
+	IP        string
+	UserAgent string `gorm:"type:text"`
+}
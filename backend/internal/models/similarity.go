@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SimilarityRunStatus string
+
+const (
+	SimilarityRunRunning   SimilarityRunStatus = "running"
+	SimilarityRunCompleted SimilarityRunStatus = "completed"
+	SimilarityRunFailed    SimilarityRunStatus = "failed"
+)
+
+// SimilarityRun is one execution of the answer similarity analysis for an
+// exam. Its results are the SimilarityPairs that reference it.
+type SimilarityRun struct {
+	BaseModel
+
+	ExamID uuid.UUID `gorm:"type:uuid;index;not null"`
+	Exam   Exam      `gorm:"foreignKey:ExamID"`
+
+	TriggeredByID uuid.UUID `gorm:"type:uuid;not null"`
+
+	Status      string `gorm:"not null;index"`
+	Error       string `gorm:"type:text"`
+	StartedAt   time.Time
+	CompletedAt *time.Time
+
+	// Parameters the run was started with.
+	MaxK         float64 `gorm:"not null"`
+	MinIdentical int     `gorm:"not null"`
+
+	AttemptsAnalyzed int `gorm:"not null;default:0"`
+	PairsReported    int `gorm:"not null;default:0"`
+}
+
+// SimilarityPair is a pair of attempts whose identical wrong answers are
+// unlikely to be a coincidence.
+type SimilarityPair struct {
+	BaseModel
+
+	RunID uuid.UUID     `gorm:"type:uuid;index;not null"`
+	Run   SimilarityRun `gorm:"foreignKey:RunID"`
+
+	AttemptAID uuid.UUID `gorm:"type:uuid;not null"`
+	AttemptBID uuid.UUID `gorm:"type:uuid;not null"`
+
+	// IdenticalWrong is the number of questions both attempts answered with
+	// the same wrong selection; IdenticalAnswers counts all identical
+	// non-empty selections.
+	IdenticalWrong   int `gorm:"not null"`
+	IdenticalAnswers int `gorm:"not null"`
+	WrongA           int `gorm:"not null"`
+	WrongB           int `gorm:"not null"`
+
+	// KIndex is the smaller of the two directional K-index probabilities.
+	// Lower is more suspicious.
+	KIndex float64 `gorm:"not null;index"`
+}
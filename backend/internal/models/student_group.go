@@ -0,0 +1,22 @@
+package models
+
+import "github.com/google/uuid"
+
+// StudentGroup is a named set of students, e.g. a class section.
+type StudentGroup struct {
+	BaseModel
+
+	Name        string `gorm:"not null;uniqueIndex"`
+	Description string `gorm:"type:text"`
+}
+
+// StudentGroupMember links a student to a group.
+type StudentGroupMember struct {
+	BaseModel
+
+	GroupID uuid.UUID    `gorm:"type:uuid;not null;uniqueIndex:idx_student_group_member"`
+	Group   StudentGroup `gorm:"foreignKey:GroupID"`
+
+	StudentID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_student_group_member;index"`
+	Student   Student   `gorm:"foreignKey:StudentID"`
+}
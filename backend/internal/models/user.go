@@ -6,15 +6,39 @@ import (
 	"github.com/google/uuid"
 )
 
+// How users sign in.
+const (
+	AuthProviderLocal = "local"
+	AuthProviderOIDC  = "oidc"
+	AuthProviderLDAP  = "ldap"
+)
+
 type User struct {
 	BaseModel
 
 	Username          string     `gorm:"uniqueIndex;not null"`
 	Email             string     `gorm:"uniqueIndex;not null"`
 	PasswordHash      string     `gorm:"not null"`
-	DefaultPassword   string     `gorm:"type:text" json:"-"` // Stores the default password (plain text, only for display)
 	LastLoginAt       *time.Time `json:"lastLoginAt"`
 	PasswordChangedAt *time.Time `json:"passwordChangedAt"`
+	// MustChangePassword is set on accounts given a temporary password and
+	// cleared once the user picks their own.
+	MustChangePassword bool `gorm:"not null;default:false" json:"mustChangePassword"`
+
+	// TOTPSecret is the encrypted TOTP secret. It is set during enrollment
+	// and only in use once TOTPEnabledAt is set.
+	TOTPSecret    string     `gorm:"type:text" json:"-"`
+	TOTPEnabledAt *time.Time `json:"totpEnabledAt"`
+	// TOTPLastStep is the time step of the last accepted code, so a code
+	// cannot be replayed.
+	TOTPLastStep int64 `gorm:"not null;default:0" json:"-"`
+
+	// AuthProvider is how the user signs in: with a password, or through an
+	// external identity provider or directory, in which case they have no
+	// password here.
+	AuthProvider string `gorm:"not null;default:local;uniqueIndex:idx_users_external,priority:1" json:"authProvider"`
+	// ExternalID identifies the user at their identity provider.
+	ExternalID *string `gorm:"uniqueIndex:idx_users_external,priority:2" json:"-"`
 
 	RoleID uuid.UUID `gorm:"type:uuid;not null"`
 	Role   Role      `gorm:"foreignKey:RoleID"`
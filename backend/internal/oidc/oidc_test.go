@@ -0,0 +1,175 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// stubIssuer serves discovery metadata and a JWKS holding its keys.
+type stubIssuer struct {
+	*httptest.Server
+	mu   sync.Mutex
+	keys map[string]*rsa.PrivateKey
+}
+
+func newStubIssuer(t *testing.T) *stubIssuer {
+	t.Helper()
+	s := &stubIssuer{keys: map[string]*rsa.PrivateKey{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(metadata{
+			Issuer:                s.URL,
+			AuthorizationEndpoint: s.URL + "/authorize",
+			TokenEndpoint:         s.URL + "/token",
+			JWKSURI:               s.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		var set struct {
+			Keys []map[string]string `json:"keys"`
+		}
+		for kid, k := range s.keys {
+			set.Keys = append(set.Keys, map[string]string{
+				"kid": kid,
+				"kty": "RSA",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+			})
+		}
+		json.NewEncoder(w).Encode(set)
+	})
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *stubIssuer) addKey(t *testing.T, kid string) *rsa.PrivateKey {
+	t.Helper()
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	s.mu.Lock()
+	s.keys[kid] = k
+	s.mu.Unlock()
+	return k
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(method, claims)
+	tok.Header["kid"] = kid
+	raw, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	return raw
+}
+
+func TestProviderVerify(t *testing.T) {
+	ctx := context.Background()
+	issuer := newStubIssuer(t)
+	key := issuer.addKey(t, "k1")
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	p := NewProvider(Config{Issuer: issuer.URL, ClientID: "huhems"})
+
+	now := time.Now()
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss": issuer.URL,
+			"aud": "huhems",
+			"sub": "user-1",
+			"iat": now.Unix(),
+			"exp": now.Add(5 * time.Minute).Unix(),
+		}
+	}
+	with := func(name string, v any) jwt.MapClaims {
+		c := valid()
+		if v == nil {
+			delete(c, name)
+		} else {
+			c[name] = v
+		}
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{"valid", signToken(t, jwt.SigningMethodRS256, "k1", key, valid()), ""},
+		{"audience list", signToken(t, jwt.SigningMethodRS256, "k1", key, with("aud", []string{"other", "huhems"})), ""},
+		{"expired within leeway", signToken(t, jwt.SigningMethodRS256, "k1", key, with("exp", now.Add(-30*time.Second).Unix())), ""},
+		{"expired", signToken(t, jwt.SigningMethodRS256, "k1", key, with("exp", now.Add(-5*time.Minute).Unix())), "token is expired"},
+		{"no expiry", signToken(t, jwt.SigningMethodRS256, "k1", key, with("exp", nil)), "token is missing required claim"},
+		{"issued in the future", signToken(t, jwt.SigningMethodRS256, "k1", key, with("iat", now.Add(5*time.Minute).Unix())), "token used before issued"},
+		{"wrong issuer", signToken(t, jwt.SigningMethodRS256, "k1", key, with("iss", "https://evil.example")), "token has invalid issuer"},
+		{"wrong audience", signToken(t, jwt.SigningMethodRS256, "k1", key, with("aud", "other")), "token has invalid audience"},
+		{"no subject", signToken(t, jwt.SigningMethodRS256, "k1", key, with("sub", nil)), "id_token has no subject"},
+		{"wrong key", signToken(t, jwt.SigningMethodRS256, "k1", other, valid()), "verification error"},
+		{"unknown key", signToken(t, jwt.SigningMethodRS256, "k2", other, valid()), `unknown signing key "k2"`},
+		{"symmetric algorithm", signToken(t, jwt.SigningMethodHS256, "k1", []byte("secret"), valid()), "signing method HS256 is invalid"},
+		{"not a token", "not.a.token", "token is malformed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := p.verify(ctx, tt.token)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("verify: %v", err)
+			case tt.wantErr == "":
+				if claims.String("sub") != "user-1" {
+					t.Errorf("sub = %q, want user-1", claims.String("sub"))
+				}
+			case err == nil:
+				t.Fatalf("verify succeeded, want error containing %q", tt.wantErr)
+			case !strings.Contains(err.Error(), tt.wantErr):
+				t.Errorf("verify error = %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProviderVerifyKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	issuer := newStubIssuer(t)
+	old := issuer.addKey(t, "old")
+	p := NewProvider(Config{Issuer: issuer.URL, ClientID: "huhems"})
+	claims := jwt.MapClaims{"iss": issuer.URL, "aud": "huhems", "sub": "user-1", "exp": time.Now().Add(time.Minute).Unix()}
+
+	if _, err := p.verify(ctx, signToken(t, jwt.SigningMethodRS256, "old", old, claims)); err != nil {
+		t.Fatalf("verify with the old key: %v", err)
+	}
+
+	rotated := issuer.addKey(t, "new")
+	token := signToken(t, jwt.SigningMethodRS256, "new", rotated, claims)
+	if _, err := p.verify(ctx, token); err == nil {
+		t.Fatal("keys were fetched again within the refresh interval")
+	}
+
+	p.mu.Lock()
+	p.keysFetched = time.Now().Add(-jwksRefreshInterval)
+	p.mu.Unlock()
+	if _, err := p.verify(ctx, token); err != nil {
+		t.Fatalf("verify with the rotated key: %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Attempts is the failure history of one key (an account or an IP).
+type Attempts struct {
+	Failures    int
+	WindowStart time.Time
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// FailureStore keeps failure counts. MemoryStore suits a single node;
+// PostgresStore shares counts between replicas.
+type FailureStore interface {
+	// Update forgets the key's failures if they started more than window
+	// before now, passes its history to fn and saves what fn leaves. No other
+	// Update or Reset of the key runs in between, so fn may check and change
+	// the history as one step. It returns the saved history.
+	Update(ctx context.Context, key string, now time.Time, window time.Duration, fn func(a *Attempts)) (Attempts, error)
+	// Reset forgets the key, lifting any lock.
+	Reset(ctx context.Context, key string) error
+}
+
+// expireWindow forgets the failures in a once its window has passed, and
+// with them a lock that has run out.
+func expireWindow(a *Attempts, now time.Time, window time.Duration) {
+	if a.Failures == 0 || now.Sub(a.WindowStart) >= window {
+		a.Failures = 0
+		a.WindowStart = now
+		if !now.Before(a.LockedUntil) {
+			a.LockedUntil = time.Time{}
+		}
+	}
+}
+
+// Policy says how failures on one kind of key are throttled.
+type Policy struct {
+	// MaxFailures within Window lock the key for Lockout.
+	MaxFailures int
+	Window      time.Duration
+	Lockout     time.Duration
+	// After FreeFailures, each further failure doubles the wait before the
+	// next attempt, starting at BaseDelay and capped at MaxDelay, if set. A
+	// zero BaseDelay disables progressive delays.
+	FreeFailures int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+}
+
+// Delay returns how long to wait after the given number of failures.
+func (p Policy) Delay(failures int) time.Duration {
+	if p.BaseDelay <= 0 || failures <= p.FreeFailures {
+		return 0
+	}
+	d := p.BaseDelay
+	for i := p.FreeFailures + 1; i < failures && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		if d > math.MaxInt64/2 {
+			return math.MaxInt64
+		}
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+// LoginGuard throttles failed logins per account and per IP.
+type LoginGuard struct {
+	Store   FailureStore
+	Account Policy
+	IP      Policy
+}
+
+// Reserve counts an attempt on key as a failure before the credentials are
+// checked, so that concurrent attempts cannot all pass the same check. It
+// returns how long the caller must wait instead, and whether that is because
+// the key is locked, if the policy does not allow an attempt now. Every
+// reserved attempt must be settled with Fail or Release.
+func (g *LoginGuard) Reserve(ctx context.Context, key string, p Policy, now time.Time) (wait time.Duration, locked bool, err error) {
+	_, err = g.Store.Update(ctx, key, now, p.Window, func(a *Attempts) {
+		wait, locked = 0, false
+		if now.Before(a.LockedUntil) {
+			wait, locked = a.LockedUntil.Sub(now), true
+			return
+		}
+		// Attempts still being checked may already use up the allowance;
+		// once a lock has run out, the delays below pace further attempts.
+		if p.MaxFailures > 0 && a.Failures >= p.MaxFailures && a.LockedUntil.IsZero() {
+			wait, locked = p.Lockout, true
+			return
+		}
+		if a.Failures > 0 {
+			if next := a.LastFailure.Add(p.Delay(a.Failures)); now.Before(next) {
+				wait = next.Sub(now)
+				return
+			}
+		}
+		a.Failures++
+		a.LastFailure = now
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	return wait, locked, nil
+}
+
+// Fail settles a reserved attempt that failed, locking the key once it
+// reaches the policy's limit. lockedNow is true only for the failure that
+// caused the lock.
+func (g *LoginGuard) Fail(ctx context.Context, key string, p Policy, now time.Time) (a Attempts, lockedNow bool, err error) {
+	a, err = g.Store.Update(ctx, key, now, p.Window, func(a *Attempts) {
+		lockedNow = false
+		if p.MaxFailures > 0 && a.Failures >= p.MaxFailures && !now.Before(a.LockedUntil) {
+			a.LockedUntil = now.Add(p.Lockout)
+			lockedNow = true
+		}
+	})
+	if err != nil {
+		return a, false, err
+	}
+	return a, lockedNow, nil
+}
+
+// Release settles a reserved attempt that did not fail, giving it back.
+func (g *LoginGuard) Release(ctx context.Context, key string, p Policy, now time.Time) error {
+	_, err := g.Store.Update(ctx, key, now, p.Window, func(a *Attempts) {
+		if a.Failures > 0 {
+			a.Failures--
+		}
+	})
+	return err
+}
+
+// Reset forgets the key's failures and lifts its lock.
+func (g *LoginGuard) Reset(ctx context.Context, key string) error {
+	return g.Store.Reset(ctx, key)
+}
+
+// MemoryStore is a FailureStore local to this process.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*Attempts
+	sweptAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]*Attempts{}}
+}
+
+func (s *MemoryStore) Update(_ context.Context, key string, now time.Time, window time.Duration, fn func(a *Attempts)) (Attempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now, window)
+
+	a := s.entries[key]
+	if a == nil {
+		a = &Attempts{}
+		s.entries[key] = a
+	}
+	expireWindow(a, now, window)
+	fn(a)
+	return *a, nil
+}
+
+func (s *MemoryStore) Reset(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// sweep drops entries whose window and lock have both passed, at most once
+// per window.
+func (s *MemoryStore) sweep(now time.Time, window time.Duration) {
+	if now.Sub(s.sweptAt) < window {
+		return
+	}
+	for k, a := range s.entries {
+		if now.Sub(a.LastFailure) >= window && !now.Before(a.LockedUntil) {
+			delete(s.entries, k)
+		}
+	}
+	s.sweptAt = now
+}
@@ -0,0 +1,195 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPolicyDelay(t *testing.T) {
+	p := Policy{FreeFailures: 2, BaseDelay: time.Second, MaxDelay: 30 * time.Second}
+	tests := []struct {
+		name     string
+		policy   Policy
+		failures int
+		want     time.Duration
+	}{
+		{"no failures", p, 0, 0},
+		{"free failures", p, 2, 0},
+		{"first delayed failure", p, 3, time.Second},
+		{"doubles", p, 4, 2 * time.Second},
+		{"keeps doubling", p, 6, 8 * time.Second},
+		{"capped", p, 10, 30 * time.Second},
+		{"far past the cap", p, 1000, 30 * time.Second},
+		{"disabled", Policy{FreeFailures: 2}, 10, 0},
+		{"no cap", Policy{BaseDelay: time.Second}, 4, 8 * time.Second},
+		{"no cap does not overflow", Policy{BaseDelay: time.Second}, 1000, math.MaxInt64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Delay(tt.failures); got != tt.want {
+				t.Errorf("Delay(%d) = %v, want %v", tt.failures, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreUpdate(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	window := 15 * time.Minute
+	fail := func(a *Attempts) { a.Failures++ }
+
+	tests := []struct {
+		name    string
+		steps   []time.Duration // offsets from start of each failure
+		want    int
+		wantWin time.Duration // offset of the window start
+	}{
+		{"first failure", []time.Duration{0}, 1, 0},
+		{"within the window", []time.Duration{0, time.Minute, 2 * time.Minute}, 3, 0},
+		{"window passed", []time.Duration{0, time.Minute, window}, 1, window},
+		{"window restarts", []time.Duration{0, window + time.Minute, window + 2*time.Minute}, 2, window + time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMemoryStore()
+			var a Attempts
+			var err error
+			for _, d := range tt.steps {
+				if a, err = s.Update(ctx, "k", start.Add(d), window, fail); err != nil {
+					t.Fatalf("Update: %v", err)
+				}
+			}
+			if a.Failures != tt.want || !a.WindowStart.Equal(start.Add(tt.wantWin)) {
+				t.Errorf("got %d failures from %v, want %d from %v", a.Failures, a.WindowStart, tt.want, start.Add(tt.wantWin))
+			}
+		})
+	}
+}
+
+func TestMemoryStoreReset(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	s := NewMemoryStore()
+	s.Update(ctx, "k", now, time.Hour, func(a *Attempts) {
+		a.Failures = 5
+		a.LockedUntil = now.Add(time.Hour)
+	})
+	if err := s.Reset(ctx, "k"); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	a, _ := s.Update(ctx, "k", now, time.Hour, func(*Attempts) {})
+	if a.Failures != 0 || !a.LockedUntil.IsZero() {
+		t.Errorf("after Reset got %+v, want no failures and no lock", a)
+	}
+}
+
+func TestMemoryStoreUpdateIsAtomic(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	s := NewMemoryStore()
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Update(ctx, "k", now, time.Hour, func(a *Attempts) { a.Failures++ })
+		}()
+	}
+	wg.Wait()
+	if a, _ := s.Update(ctx, "k", now, time.Hour, func(*Attempts) {}); a.Failures != 100 {
+		t.Errorf("got %d failures, want 100", a.Failures)
+	}
+}
+
+func TestLoginGuard(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	p := Policy{MaxFailures: 3, Window: time.Hour, Lockout: 15 * time.Minute, FreeFailures: 1, BaseDelay: time.Second, MaxDelay: time.Minute}
+
+	type step struct {
+		at         time.Duration
+		op         string // reserve, fail or release
+		wantWait   time.Duration
+		wantLocked bool
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"free attempt", []step{
+			{0, "reserve", 0, false},
+			{0, "fail", 0, false},
+			{0, "reserve", 0, false},
+		}},
+		{"progressive delay", []step{
+			{0, "reserve", 0, false}, {0, "fail", 0, false},
+			{0, "reserve", 0, false}, {0, "fail", 0, false},
+			{0, "reserve", time.Second, false},
+			{time.Second, "reserve", 0, false},
+		}},
+		{"concurrent attempts wait for the pending one", []step{
+			{0, "reserve", 0, false},
+			{0, "reserve", 0, false},
+			{0, "reserve", time.Second, false},
+		}},
+		{"released attempts do not count", []step{
+			{0, "reserve", 0, false}, {0, "release", 0, false},
+			{0, "reserve", 0, false}, {0, "release", 0, false},
+			{0, "reserve", 0, false}, {0, "release", 0, false},
+			{0, "reserve", 0, false},
+		}},
+		{"pending attempts use up the allowance", []step{
+			{0, "reserve", 0, false},
+			{0, "reserve", 0, false},
+			{time.Minute, "reserve", 0, false},
+			{2 * time.Minute, "reserve", 15 * time.Minute, true},
+		}},
+		{"lockout", []step{
+			{0, "reserve", 0, false}, {0, "fail", 0, false},
+			{time.Minute, "reserve", 0, false}, {time.Minute, "fail", 0, false},
+			{2 * time.Minute, "reserve", 0, false}, {2 * time.Minute, "fail", 0, true},
+			{3 * time.Minute, "reserve", 14 * time.Minute, true},
+		}},
+		{"lock runs out", []step{
+			{0, "reserve", 0, false}, {0, "fail", 0, false},
+			{0, "reserve", 0, false}, {0, "fail", 0, false},
+			{2 * time.Second, "reserve", 0, false}, {2 * time.Second, "fail", 0, true},
+			{20 * time.Minute, "reserve", 0, false},
+			{20 * time.Minute, "fail", 0, true},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &LoginGuard{Store: NewMemoryStore(), Account: p}
+			for i, s := range tt.steps {
+				now := start.Add(s.at)
+				switch s.op {
+				case "reserve":
+					wait, locked, err := g.Reserve(ctx, "k", p, now)
+					if err != nil {
+						t.Fatalf("step %d: Reserve: %v", i, err)
+					}
+					if wait != s.wantWait || locked != s.wantLocked {
+						t.Fatalf("step %d: Reserve() = (%v, %v), want (%v, %v)", i, wait, locked, s.wantWait, s.wantLocked)
+					}
+				case "fail":
+					_, lockedNow, err := g.Fail(ctx, "k", p, now)
+					if err != nil {
+						t.Fatalf("step %d: Fail: %v", i, err)
+					}
+					if lockedNow != s.wantLocked {
+						t.Fatalf("step %d: Fail() locked now = %v, want %v", i, lockedNow, s.wantLocked)
+					}
+				case "release":
+					if err := g.Release(ctx, "k", p, now); err != nil {
+						t.Fatalf("step %d: Release: %v", i, err)
+					}
+				}
+			}
+		})
+	}
+}
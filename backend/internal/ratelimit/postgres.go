@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PostgresStore is a FailureStore shared by every replica using the
+// database. Each key's row is locked while it is updated, so concurrent
+// attempts are neither lost nor let through together.
+type PostgresStore struct {
+	DB *gorm.DB
+}
+
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{DB: db}
+}
+
+func attemptsFromRow(row models.LoginThrottle) Attempts {
+	a := Attempts{Failures: row.Failures, WindowStart: row.WindowStart, LastFailure: row.LastFailure}
+	if row.LockedUntil != nil {
+		a.LockedUntil = *row.LockedUntil
+	}
+	return a
+}
+
+// Update locks the key's row for the length of a transaction, creating it
+// first if need be, so concurrent updates of one key run one at a time.
+func (s *PostgresStore) Update(ctx context.Context, key string, now time.Time, window time.Duration, fn func(a *Attempts)) (Attempts, error) {
+	var a Attempts
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.LoginThrottle{Key: key, WindowStart: now, LastFailure: now}).Error; err != nil {
+			return err
+		}
+		var row models.LoginThrottle
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&row, "key = ?", key).Error; err != nil {
+			return err
+		}
+		a = attemptsFromRow(row)
+		expireWindow(&a, now, window)
+		fn(&a)
+
+		var lockedUntil *time.Time
+		if !a.LockedUntil.IsZero() {
+			lockedUntil = &a.LockedUntil
+		}
+		return tx.Model(&models.LoginThrottle{}).Where("key = ?", key).Updates(map[string]any{
+			"failures":     a.Failures,
+			"window_start": a.WindowStart,
+			"last_failure": a.LastFailure,
+			"locked_until": lockedUntil,
+		}).Error
+	})
+	if err != nil {
+		return Attempts{}, err
+	}
+	return a, nil
+}
+
+func (s *PostgresStore) Reset(ctx context.Context, key string) error {
+	return s.DB.WithContext(ctx).Where("key = ?", key).Delete(&models.LoginThrottle{}).Error
+}
+
+// RunPurge deletes rows idle for longer than maxAge every interval until ctx
+// is done, so keys tried once do not accumulate.
+func (s *PostgresStore) RunPurge(ctx context.Context, interval, maxAge time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			cutoff := now.UTC().Add(-maxAge)
+			if err := s.DB.WithContext(ctx).
+				Where("last_failure < ? AND (locked_until IS NULL OR locked_until < ?)", cutoff, cutoff).
+				Delete(&models.LoginThrottle{}).Error; err != nil {
+				log.Printf("ratelimit: purge failed: %v", err)
+			}
+		}
+	}
+}
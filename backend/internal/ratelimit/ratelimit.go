@@ -0,0 +1,64 @@
+// Package ratelimit provides simple fixed-window request limiters.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter allows at most Limit hits per key within each Window.
+type Limiter struct {
+	Limit  int
+	Window time.Duration
+
+	mu      sync.Mutex
+	windows map[string]*bucket
+	sweptAt time.Time
+}
+
+type bucket struct {
+	start time.Time
+	count int
+}
+
+// New returns an in-memory limiter. It is safe for concurrent use but only
+// limits requests handled by this process.
+func New(limit int, window time.Duration) *Limiter {
+	return &Limiter{Limit: limit, Window: window, windows: map[string]*bucket{}}
+}
+
+// Allow records a hit for key and reports whether it is within the limit.
+// When it is not, retryAfter is the time until the current window resets.
+func (l *Limiter) Allow(key string) (ok bool, retryAfter time.Duration) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	w := l.windows[key]
+	if w == nil || now.Sub(w.start) >= l.Window {
+		w = &bucket{start: now}
+		l.windows[key] = w
+	}
+	if w.count >= l.Limit {
+		return false, w.start.Add(l.Window).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+// sweep drops expired windows at most once per window length so the map does
+// not grow without bound.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.sweptAt) < l.Window {
+		return
+	}
+	for k, w := range l.windows {
+		if now.Sub(w.start) >= l.Window {
+			delete(l.windows, k)
+		}
+	}
+	l.sweptAt = now
+}
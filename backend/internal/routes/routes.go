@@ -1,13 +1,56 @@
 package routes
 
 import (
+	"context"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/letera1/huhems-exam-system/backend/internal/auth"
+	"github.com/letera1/huhems-exam-system/backend/internal/config"
 	"github.com/letera1/huhems-exam-system/backend/internal/controllers"
+	"github.com/letera1/huhems-exam-system/backend/internal/ldapauth"
+	"github.com/letera1/huhems-exam-system/backend/internal/live"
+	"github.com/letera1/huhems-exam-system/backend/internal/mailer"
 	"github.com/letera1/huhems-exam-system/backend/internal/middleware"
+	"github.com/letera1/huhems-exam-system/backend/internal/models"
+	"github.com/letera1/huhems-exam-system/backend/internal/oidc"
+	"github.com/letera1/huhems-exam-system/backend/internal/ratelimit"
 	"gorm.io/gorm"
 )
 
-func Register(r *gin.Engine, db *gorm.DB, jwtSecret string) {
+func Register(r *gin.Engine, db *gorm.DB, cfg config.Config) {
+	hub := live.NewHub()
+	tokens := auth.TokenConfig{Secret: cfg.JWTSecret, AccessTTL: cfg.AccessTokenTTL, RefreshTTL: cfg.RefreshTokenTTL}
+	passwordPolicy := auth.NewPasswordPolicy(cfg.PasswordMinLength, cfg.PasswordMinClasses, cfg.PasswordHistory, cfg.PasswordMaxAge, cfg.PasswordBlocklist)
+	mfa := auth.MFAConfig{Issuer: cfg.TOTPIssuer, EncryptionKey: cfg.TOTPEncryptionKey, RequiredForAdmins: cfg.AdminMFARequired}
+	authRequired := middleware.AuthRequired(db, cfg.JWTSecret, passwordPolicy, mfa)
+
+	loginGuard := &ratelimit.LoginGuard{
+		Store: ratelimit.NewMemoryStore(),
+		Account: ratelimit.Policy{
+			MaxFailures:  cfg.LoginMaxFailures,
+			Window:       cfg.LoginLockout,
+			Lockout:      cfg.LoginLockout,
+			FreeFailures: 2,
+			BaseDelay:    time.Second,
+			MaxDelay:     30 * time.Second,
+		},
+		IP: ratelimit.Policy{
+			MaxFailures: cfg.LoginIPMaxFailures,
+			Window:      cfg.LoginLockout,
+			Lockout:     cfg.LoginLockout,
+		},
+	}
+	var mail mailer.Mailer = mailer.LogMailer{Dir: cfg.MailDir, From: cfg.MailFrom}
+	if cfg.Mailer == "smtp" {
+		mail = mailer.SMTPMailer{Host: cfg.SMTPHost, Port: cfg.SMTPPort, Username: cfg.SMTPUser, Password: cfg.SMTPPass, From: cfg.MailFrom}
+	}
+
+	if cfg.RateLimitStore == "postgres" {
+		store := ratelimit.NewPostgresStore(db)
+		loginGuard.Store = store
+		go store.RunPurge(context.Background(), time.Hour, 24*time.Hour)
+	}
 
 	r.GET("/", func(c *gin.Context) {
 		c.JSON(200, gin.H{"message": "Welcome to HUHEMS API", "status": "online"})
@@ -15,44 +58,185 @@ func Register(r *gin.Engine, db *gorm.DB, jwtSecret string) {
 
 	r.GET("/health", controllers.Health)
 
-	r.POST("/auth/login", controllers.AuthLogin(db, jwtSecret))
+	var passwordProviders []auth.PasswordProvider
+	for _, name := range cfg.AuthProviders {
+		switch name {
+		case "local":
+			passwordProviders = append(passwordProviders, auth.LocalProvider{})
+		case "ldap":
+			passwordProviders = append(passwordProviders, ldapauth.New(ldapauth.Config{
+				URL:                cfg.LDAPURL,
+				StartTLS:           cfg.LDAPStartTLS,
+				InsecureSkipVerify: cfg.LDAPInsecureSkipVerify,
+				BindDN:             cfg.LDAPBindDN,
+				BindPassword:       cfg.LDAPBindPassword,
+				BaseDN:             cfg.LDAPBaseDN,
+				UserFilter:         cfg.LDAPUserFilter,
+				IDAttr:             cfg.LDAPIDAttr,
+				UsernameAttr:       cfg.LDAPUsernameAttr,
+				EmailAttr:          cfg.LDAPEmailAttr,
+				NameAttr:           cfg.LDAPNameAttr,
+				DepartmentAttr:     cfg.LDAPDepartmentAttr,
+				YearAttr:           cfg.LDAPYearAttr,
+				TrustEmail:         cfg.LDAPTrustEmail,
+				GroupAttr:          cfg.LDAPGroupAttr,
+				GroupBaseDN:        cfg.LDAPGroupBaseDN,
+				GroupFilter:        cfg.LDAPGroupFilter,
+				RoleRules:          auth.ParseRoleRules(cfg.LDAPRoleMap),
+				DefaultRole:        cfg.LDAPDefaultRole,
+			}))
+		}
+	}
+
+	r.POST("/auth/login", controllers.AuthLogin(db, tokens, loginGuard, passwordPolicy, mfa, passwordProviders, cfg.PasswordLoginDisabledRoles))
+	r.POST("/auth/login/mfa", controllers.AuthLoginMFA(db, tokens, loginGuard, passwordPolicy, mfa))
+	r.GET("/auth/providers", controllers.AuthProviders(cfg.OIDCIssuer != "", cfg.OIDCLabel, cfg.PasswordLoginDisabledRoles))
+	if cfg.OIDCIssuer != "" {
+		provider := oidc.NewProvider(oidc.Config{
+			Issuer:       cfg.OIDCIssuer,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+			Scopes:       cfg.OIDCScopes,
+		})
+		mapping := oidc.Mapping{
+			Username:    cfg.OIDCUsernameClaim,
+			Email:       cfg.OIDCEmailClaim,
+			FullName:    cfg.OIDCNameClaim,
+			Department:  cfg.OIDCDepartmentClaim,
+			Year:        cfg.OIDCYearClaim,
+			Role:        cfg.OIDCRoleClaim,
+			RoleRules:   auth.ParseRoleRules(cfg.OIDCRoleMap),
+			DefaultRole: cfg.OIDCDefaultRole,
+		}
+		r.GET("/auth/oidc/start", controllers.AuthOIDCStart(db, provider))
+		r.POST("/auth/oidc/callback", controllers.AuthOIDCCallback(db, provider, mapping, tokens, loginGuard, passwordPolicy, mfa))
+	}
+	r.POST("/auth/refresh", controllers.AuthRefresh(db, tokens))
+	r.POST("/auth/logout", controllers.AuthLogout(db))
+	r.GET("/auth/password-policy", controllers.AuthPasswordPolicy(passwordPolicy))
+	r.POST("/auth/forgot-password", controllers.AuthForgotPassword(db, mail, cfg.PasswordResetURL, cfg.PasswordResetTTL))
+	r.POST("/auth/reset-password", controllers.AuthResetPassword(db, passwordPolicy, loginGuard))
 	// Check if default credentials are still active
 	r.GET("/auth/default-status/:role", controllers.AuthCheckDefaultPasswordStatus(db))
 
 	authGroup := r.Group("/")
-	authGroup.Use(middleware.AuthRequired(jwtSecret))
-	authGroup.GET("/auth/me", controllers.AuthMe(db))
-	authGroup.PUT("/auth/password", controllers.AuthChangePassword(db))
+	authGroup.Use(authRequired)
+	authGroup.GET("/auth/me", controllers.AuthMe(db, passwordPolicy, mfa))
+	authGroup.GET("/auth/sessions", controllers.AuthSessionsList(db))
+	authGroup.POST("/auth/logout-all", controllers.AuthLogoutAll(db))
+	authGroup.PUT("/auth/password", controllers.AuthChangePassword(db, passwordPolicy))
+	authGroup.GET("/auth/2fa", controllers.AuthMFAStatus(db, mfa))
+	authGroup.POST("/auth/2fa/setup", controllers.AuthMFASetup(db, mfa))
+	authGroup.POST("/auth/2fa/enable", controllers.AuthMFAEnable(db, mfa))
+	authGroup.POST("/auth/2fa/disable", controllers.AuthMFADisable(db, mfa))
+	authGroup.POST("/auth/2fa/recovery-codes", controllers.AuthMFARecoveryCodes(db, mfa))
+
+	// Staff share the admin API. Each route requires a permission, and
+	// callers without course:all are further limited to the exams of their
+	// courses by the access middleware.
+	examAccess := controllers.RequireExamAccess(db)
+	attemptAccess := controllers.RequireAttemptAccess(db)
+	questionAccess := controllers.RequireQuestionAccess(db)
+	can := middleware.RequirePermission
 
 	admin := r.Group("/admin")
-	admin.Use(middleware.AuthRequired(jwtSecret), middleware.RequireRole("admin"))
-	admin.GET("/exams", controllers.AdminExamsList(db))
-	admin.POST("/exams", controllers.AdminExamsCreate(db))
-	admin.GET("/exams/:id", controllers.AdminExamsGet(db))
-	admin.PUT("/exams/:id", controllers.AdminExamsUpdate(db))
-	admin.DELETE("/exams/:id", controllers.AdminExamsDelete(db))
-	admin.POST("/exams/:id/publish", controllers.AdminExamsPublish(db))
-	admin.GET("/exams/:id/report", controllers.AdminExamReport(db))
-
-	admin.GET("/students", controllers.AdminStudentsList(db))
-	admin.POST("/students", controllers.AdminStudentsCreate(db))
-	admin.POST("/students/import", controllers.AdminStudentsImportCSV(db))
-	admin.PUT("/students/:id", controllers.AdminStudentsUpdate(db))
-	admin.DELETE("/students/:id", controllers.AdminStudentsDelete(db))
-
-	admin.POST("/exams/:id/questions", controllers.AdminExamQuestionsCreate(db))
-	admin.POST("/exams/:id/questions/import", controllers.AdminExamQuestionsImportCSV(db))
-	admin.PUT("/questions/:id", controllers.AdminQuestionsUpdate(db))
-	admin.DELETE("/questions/:id", controllers.AdminQuestionsDelete(db))
+	admin.Use(authRequired, middleware.LoadAccess(db))
+	admin.GET("/exams", can(models.PermExamRead), controllers.AdminExamsList(db))
+	admin.POST("/exams", can(models.PermExamWrite), controllers.AdminExamsCreate(db))
+	admin.GET("/exams/:id", can(models.PermExamRead), examAccess, controllers.AdminExamsGet(db))
+	admin.PUT("/exams/:id", can(models.PermExamWrite), examAccess, controllers.AdminExamsUpdate(db))
+	admin.DELETE("/exams/:id", can(models.PermExamWrite), examAccess, controllers.AdminExamsDelete(db))
+	admin.POST("/exams/:id/publish", can(models.PermExamWrite), examAccess, controllers.AdminExamsPublish(db))
+	admin.GET("/exams/:id/targets", can(models.PermExamRead), examAccess, controllers.AdminExamTargetsGet(db))
+	admin.PUT("/exams/:id/targets", can(models.PermExamWrite), examAccess, controllers.AdminExamTargetsPut(db))
+	admin.GET("/exams/:id/roster", can(models.PermExamRead), examAccess, controllers.AdminExamRoster(db))
+	admin.GET("/exams/:id/report", can(models.PermReportRead), examAccess, controllers.AdminExamReport(db))
+	admin.GET("/exams/:id/live", can(models.PermLiveMonitor), examAccess, controllers.AdminExamLive(db, hub))
+	admin.GET("/exams/:id/announcements", can(models.PermExamRead), examAccess, controllers.AdminExamAnnouncementsList(db))
+	admin.POST("/exams/:id/announcements", can(models.PermLiveMonitor), examAccess, controllers.AdminExamAnnouncementCreate(db, hub))
+	admin.GET("/exams/:id/integrity", can(models.PermReportRead), examAccess, controllers.AdminExamIntegrity(db))
+	admin.GET("/exams/:id/similarity", can(models.PermReportRead), examAccess, controllers.AdminExamSimilarity(db))
+	admin.POST("/exams/:id/similarity", can(models.PermExamWrite), examAccess, controllers.AdminExamSimilarityRun(db))
+	admin.GET("/attempts/:id/events", can(models.PermReportRead), attemptAccess, controllers.AdminAttemptEvents(db))
+	admin.PUT("/attempts/:id/review", can(models.PermAttemptGrade), attemptAccess, controllers.AdminAttemptReview(db, hub))
+	admin.POST("/attempts/:id/transfer", can(models.PermAttemptIntervene), attemptAccess, controllers.AdminAttemptTransfer(db))
+	admin.POST("/attempts/:id/pause", can(models.PermAttemptIntervene), attemptAccess, controllers.AdminAttemptPause(db, hub))
+	admin.POST("/attempts/:id/resume", can(models.PermAttemptIntervene), attemptAccess, controllers.AdminAttemptResume(db, hub))
+	admin.POST("/attempts/:id/extend", can(models.PermAttemptIntervene), attemptAccess, controllers.AdminAttemptExtend(db, hub))
+	admin.POST("/attempts/:id/force-submit", can(models.PermAttemptIntervene), attemptAccess, controllers.AdminAttemptForceSubmit(db, hub))
+	admin.POST("/attempts/:id/invalidate", can(models.PermAttemptIntervene), attemptAccess, controllers.AdminAttemptInvalidate(db, hub))
+
+	admin.GET("/analytics/progress", can(models.PermReportRead), controllers.AdminAnalyticsProgress(db))
+	admin.GET("/analytics/students", can(models.PermReportRead), controllers.AdminAnalyticsStudents(db))
+	admin.GET("/analytics/students/:id", can(models.PermReportRead), controllers.AdminAnalyticsStudent(db))
+
+	admin.POST("/exams/:id/questions", can(models.PermExamWrite), examAccess, controllers.AdminExamQuestionsCreate(db))
+	admin.POST("/exams/:id/questions/import", can(models.PermExamWrite), examAccess, controllers.AdminExamQuestionsImportCSV(db))
+	admin.PUT("/questions/:id", can(models.PermExamWrite), questionAccess, controllers.AdminQuestionsUpdate(db))
+	admin.DELETE("/questions/:id", can(models.PermExamWrite), questionAccess, controllers.AdminQuestionsDelete(db))
+
+	admin.GET("/audit-logs", can(models.PermAuditRead), controllers.AdminAuditLogsList(db))
+
+	admin.GET("/courses", can(models.PermExamRead), controllers.AdminCoursesList(db))
+	admin.GET("/courses/:id", can(models.PermExamRead), controllers.RequireCourseAccess(db), controllers.AdminCoursesGet(db))
+	admin.POST("/courses", can(models.PermCourseWrite), controllers.AdminCoursesCreate(db))
+	admin.PUT("/courses/:id", can(models.PermCourseWrite), controllers.AdminCoursesUpdate(db))
+	admin.DELETE("/courses/:id", can(models.PermCourseWrite), controllers.AdminCoursesDelete(db))
+	admin.PUT("/courses/:id/instructors", can(models.PermCourseWrite), controllers.AdminCourseInstructorsPut(db))
+	admin.POST("/courses/:id/students", can(models.PermStudentWrite), controllers.AdminCourseStudentsAdd(db))
+	admin.POST("/courses/:id/students/remove", can(models.PermStudentWrite), controllers.AdminCourseStudentsRemove(db))
+	admin.GET("/instructors", can(models.PermCourseWrite), controllers.AdminInstructorsList(db))
+	admin.POST("/instructors", can(models.PermCourseWrite), controllers.AdminInstructorsCreate(db))
+
+	admin.GET("/permissions", can(models.PermRoleManage), controllers.AdminPermissionsList)
+	admin.GET("/roles", can(models.PermRoleManage), controllers.AdminRolesList(db))
+	admin.POST("/roles", can(models.PermRoleManage), controllers.AdminRolesCreate(db))
+	admin.PUT("/roles/:id/permissions", can(models.PermRoleManage), controllers.AdminRolePermissionsPut(db))
+	admin.DELETE("/roles/:id", can(models.PermRoleManage), controllers.AdminRolesDelete(db))
+	admin.GET("/users", can(models.PermRoleManage), controllers.AdminUsersList(db))
+	admin.GET("/users/:id/access", can(models.PermRoleManage), controllers.AdminUserAccessGet(db))
+	admin.PUT("/users/:id/roles", can(models.PermRoleManage), controllers.AdminUserRolesPut(db))
+	admin.GET("/users/:id/sessions", can(models.PermSessionRevoke), controllers.AdminUserSessionsList(db))
+	admin.POST("/users/:id/sessions/revoke", can(models.PermSessionRevoke), controllers.AdminUserSessionsRevoke(db))
+	admin.POST("/users/:id/unlock", can(models.PermAccountUnlock), controllers.AdminUserUnlock(db, loginGuard))
+	admin.POST("/users/:id/reset-password", can(models.PermPasswordReset), controllers.AdminUserPasswordReset(db))
+	admin.POST("/users/:id/2fa/reset", can(models.PermMFAReset), controllers.AdminUserMFAReset(db))
+	admin.GET("/lockouts", can(models.PermAccountUnlock), controllers.AdminLockoutsList(db))
+	admin.POST("/lockouts/:id/unlock", can(models.PermAccountUnlock), controllers.AdminLockoutRelease(db, loginGuard))
+
+	admin.GET("/students", can(models.PermStudentRead), controllers.AdminStudentsList(db))
+	admin.POST("/students", can(models.PermStudentWrite), controllers.AdminStudentsCreate(db))
+	admin.POST("/students/import", can(models.PermStudentImport), controllers.AdminStudentsImportCSV(db))
+	admin.POST("/students/reset-passwords", can(models.PermPasswordReset), controllers.AdminStudentsPasswordReset(db))
+	admin.PUT("/students/:id", can(models.PermStudentWrite), controllers.AdminStudentsUpdate(db))
+	admin.DELETE("/students/:id", can(models.PermStudentWrite), controllers.AdminStudentsDelete(db))
+	admin.GET("/groups", can(models.PermStudentRead), controllers.AdminGroupsList(db))
+	admin.POST("/groups", can(models.PermStudentWrite), controllers.AdminGroupsCreate(db))
+	admin.POST("/groups/import", can(models.PermStudentImport), controllers.AdminGroupsImportCSV(db))
+	admin.GET("/groups/:id", can(models.PermStudentRead), controllers.AdminGroupsGet(db))
+	admin.PUT("/groups/:id", can(models.PermStudentWrite), controllers.AdminGroupsUpdate(db))
+	admin.DELETE("/groups/:id", can(models.PermStudentWrite), controllers.AdminGroupsDelete(db))
+	admin.POST("/groups/:id/members", can(models.PermStudentWrite), controllers.AdminGroupMembersAdd(db))
+	admin.POST("/groups/:id/members/remove", can(models.PermStudentWrite), controllers.AdminGroupMembersRemove(db))
+
+	admin.GET("/students/:id/accommodations", can(models.PermStudentRead), controllers.AdminStudentAccommodationsList(db))
+	admin.PUT("/students/:id/accommodation", can(models.PermStudentWrite), controllers.AdminStudentAccommodationPut(db))
+	admin.DELETE("/students/:id/accommodation", can(models.PermStudentWrite), controllers.AdminStudentAccommodationDelete(db))
+	admin.PUT("/students/:id/accommodations/:examId", can(models.PermStudentWrite), controllers.AdminStudentAccommodationPut(db))
+	admin.DELETE("/students/:id/accommodations/:examId", can(models.PermStudentWrite), controllers.AdminStudentAccommodationDelete(db))
 
 	student := r.Group("/student")
-	student.Use(middleware.AuthRequired(jwtSecret), middleware.RequireRole("student"))
+	student.Use(authRequired, middleware.RequireRole("student"))
 	student.GET("/exams", controllers.StudentExamsList(db))
-	student.POST("/exams/:id/start", controllers.StudentExamStartAttempt(db))
-	student.GET("/attempts/:id", controllers.StudentAttemptGet(db))
-	student.POST("/attempts/:id/answer", controllers.StudentAttemptAnswer(db))
-	student.POST("/attempts/:id/flag", controllers.StudentAttemptFlag(db))
-	student.POST("/attempts/:id/submit", controllers.StudentAttemptSubmit(db))
-	student.GET("/attempts/:id/result", controllers.StudentAttemptResult(db))
+	student.POST("/exams/:id/start", controllers.StudentExamStartAttempt(db, hub))
+	student.GET("/attempts/:id", controllers.StudentAttemptGet(db, hub))
+	student.POST("/attempts/:id/answer", controllers.StudentAttemptAnswer(db, hub))
+	student.POST("/attempts/:id/flag", controllers.StudentAttemptFlag(db, hub))
+	student.POST("/attempts/:id/submit", controllers.StudentAttemptSubmit(db, hub))
+	student.GET("/attempts/:id/stream", controllers.StudentAttemptStream(db, hub))
+	student.GET("/attempts/:id/result", controllers.StudentAttemptResult(db, hub))
+	student.POST("/attempts/:id/announcements/:announcementId/ack", controllers.StudentAnnouncementAck(db, hub))
+	student.POST("/attempts/:id/events", controllers.StudentAttemptEvents(db, hub, ratelimit.New(30, time.Minute)))
 	student.GET("/results", controllers.StudentResultsList(db))
 }
@@ -212,6 +212,12 @@ export default async function AdminDashboard() {
               <ArrowRightIcon className="ml-2 size-4 transition-transform group-hover:translate-x-1" />
             </Link>
           </Button>
+          <Button asChild variant="outline" className="group">
+            <Link href="/admin/security">
+              Two-Factor Authentication
+              <ArrowRightIcon className="ml-2 size-4 transition-transform group-hover:translate-x-1" />
+            </Link>
+          </Button>
         </div>
       </div>
     </div>
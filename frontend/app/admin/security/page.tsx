@@ -0,0 +1,35 @@
+import Link from "next/link";
+
+import { Badge } from "@/components/ui/badge";
+import { BackButton } from "@/components/back-button";
+import { Button } from "@/components/ui/button";
+import { TwoFactorCard } from "@/components/auth/two-factor-card";
+
+export default function AdminSecurityPage() {
+  return (
+    <div className="grid gap-6">
+      <div>
+        <div className="flex flex-wrap items-center justify-between gap-3">
+          <div className="flex items-center gap-2">
+            <Badge variant="secondary">Admin</Badge>
+            <Badge>Security</Badge>
+          </div>
+          <div className="flex items-center gap-2">
+            <BackButton fallbackHref="/admin" size="sm">
+              Back
+            </BackButton>
+            <Button asChild variant="outline" size="sm">
+              <Link href="/admin">Dashboard</Link>
+            </Button>
+          </div>
+        </div>
+        <h1 className="mt-3 text-3xl font-semibold tracking-tight">Two-Factor Authentication</h1>
+        <p className="mt-2 max-w-2xl text-muted-foreground">
+          Protect your account with codes from an authenticator app in addition to your password.
+        </p>
+      </div>
+
+      <TwoFactorCard />
+    </div>
+  );
+}
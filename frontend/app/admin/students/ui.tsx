@@ -28,7 +28,16 @@ type StudentRow = {
   department: string;
   createdAt: string;
   isPasswordChanged: boolean;
-  defaultPassword?: string; // Only present if password not changed
+  mustChangePassword: boolean;
+};
+
+// A temporary password as returned once by create or import. It is never
+// stored by the backend, so it is only shown until the admin dismisses it.
+type IssuedCredential = {
+  username: string;
+  email: string;
+  fullName?: string;
+  temporaryPassword: string;
 };
 
 type StudentSort = "newest" | "oldest" | "name_asc" | "name_desc";
@@ -55,10 +64,39 @@ function normalizeStudent(raw: unknown): StudentRow {
     department: String(r?.department ?? r?.Department ?? ""),
     createdAt: String(r?.createdAt ?? r?.CreatedAt ?? ""),
     isPasswordChanged: Boolean(r?.passwordChangedAt || r?.PasswordChangedAt),
-    defaultPassword: r?.defaultPassword ? String(r.defaultPassword) : undefined,
+    mustChangePassword: Boolean(r?.mustChangePassword),
+  };
+}
+
+function normalizeIssued(raw: unknown): IssuedCredential | null {
+  const r = asRecord(raw);
+  if (!r || typeof r.temporaryPassword !== "string" || !r.temporaryPassword) return null;
+  return {
+    username: String(r.username ?? ""),
+    email: String(r.email ?? ""),
+    fullName: r.fullName ? String(r.fullName) : undefined,
+    temporaryPassword: r.temporaryPassword,
   };
 }
 
+function csvCell(value: string): string {
+  return /[",\n\r]/.test(value) ? `"${value.replace(/"/g, '""')}"` : value;
+}
+
+function downloadCredentialsCSV(creds: IssuedCredential[]) {
+  const lines = [["username", "email", "fullName", "temporaryPassword"].join(",")];
+  for (const c of creds) {
+    lines.push([c.username, c.email, c.fullName ?? "", c.temporaryPassword].map(csvCell).join(","));
+  }
+  const blob = new Blob([lines.join("\r\n") + "\r\n"], { type: "text/csv;charset=utf-8" });
+  const url = URL.createObjectURL(blob);
+  const a = document.createElement("a");
+  a.href = url;
+  a.download = "student-credentials.csv";
+  a.click();
+  URL.revokeObjectURL(url);
+}
+
 export function AdminStudentsClient() {
   const [students, setStudents] = useState<StudentRow[]>([]);
   const [loading, setLoading] = useState(true);
@@ -73,13 +111,16 @@ export function AdminStudentsClient() {
 
   const [username, setUsername] = useState("");
   const [email, setEmail] = useState("");
-  const [password, setPassword] = useState("");
   const [fullName, setFullName] = useState("");
   const [year, setYear] = useState(1);
   const [department, setDepartment] = useState("");
 
   const [busyId, setBusyId] = useState<string | null>(null);
   const [creating, setCreating] = useState(false);
+  const [issued, setIssued] = useState<IssuedCredential[]>([]);
+  const [resetDepartment, setResetDepartment] = useState("");
+  const [resetYear, setResetYear] = useState("");
+  const [resetting, setResetting] = useState(false);
 
   const [editId, setEditId] = useState<string | null>(null);
   const [editUsername, setEditUsername] = useState("");
@@ -92,6 +133,30 @@ export function AdminStudentsClient() {
   const [deleteStudentId, setDeleteStudentId] = useState<string | null>(null);
   const [deleteBusy, setDeleteBusy] = useState(false);
 
+  async function resetPasswords(url: string, body?: unknown) {
+    setResetting(true);
+    setError(null);
+    try {
+      const res = await fetch(url, {
+        method: "POST",
+        headers: body ? { "content-type": "application/json" } : undefined,
+        body: body ? JSON.stringify(body) : undefined,
+      });
+      const data = (await res.json().catch(() => null)) as unknown;
+      const r = asRecord(data);
+      if (!res.ok) {
+        throw new Error(r && typeof r.message === "string" ? r.message : "Failed to reset passwords");
+      }
+      const list = Array.isArray(r?.credentials) ? r.credentials : [data];
+      setIssued(list.map(normalizeIssued).filter((c): c is IssuedCredential => c !== null));
+      await load();
+    } catch (e: unknown) {
+      setError(getErrorMessage(e, "Failed to reset passwords"));
+    } finally {
+      setResetting(false);
+    }
+  }
+
   async function load() {
     setLoading(true);
     setError(null);
@@ -296,11 +361,8 @@ export function AdminStudentsClient() {
                       <td className="px-3 py-2 text-center text-xs">
                          {s.isPasswordChanged ? (
                            <Badge variant="outline" className="border-green-600 bg-green-50 text-green-700 dark:bg-green-950 dark:text-green-400">Changed</Badge>
-                         ) : s.defaultPassword ? (
-                           <div className="flex flex-col gap-1">
-                             <Badge variant="destructive" className="bg-orange-100 text-orange-700 hover:bg-orange-200 border-orange-200 dark:bg-orange-950 dark:text-orange-400">Default</Badge>
-                             <code className="text-xs font-mono bg-muted px-2 py-1 rounded">{s.defaultPassword}</code>
-                           </div>
+                         ) : s.mustChangePassword ? (
+                           <Badge variant="destructive" className="bg-orange-100 text-orange-700 hover:bg-orange-200 border-orange-200 dark:bg-orange-950 dark:text-orange-400">Temporary</Badge>
                          ) : (
                            <Badge variant="destructive" className="bg-orange-100 text-orange-700 hover:bg-orange-200 border-orange-200 dark:bg-orange-950 dark:text-orange-400">Default</Badge>
                          )}
@@ -407,6 +469,18 @@ export function AdminStudentsClient() {
                               >
                                 Edit
                               </Button>
+                              <Button
+                                type="button"
+                                variant="outline"
+                                size="sm"
+                                disabled={resetting}
+                                onClick={() => {
+                                  if (!window.confirm(`Reset the password of @${s.username}? They will be signed out and must choose a new password.`)) return;
+                                  void resetPasswords(`/api/admin/users/${s.userId}/reset-password`);
+                                }}
+                              >
+                                Reset password
+                              </Button>
                               <Button
                                 type="button"
                                 variant="destructive"
@@ -432,6 +506,103 @@ export function AdminStudentsClient() {
         </CardContent>
       </Card>
 
+      {issued.length > 0 ? (
+        <Card className="border-amber-300">
+          <CardHeader>
+            <CardTitle>Temporary passwords</CardTitle>
+            <CardDescription>
+              These are shown only once and cannot be retrieved later. Hand them out now; each student must choose a
+              new password at next login.
+            </CardDescription>
+          </CardHeader>
+          <CardContent className="grid gap-3">
+            <div className="overflow-x-auto rounded-md border">
+              <table className="w-full text-sm">
+                <thead>
+                  <tr className="border-b bg-muted/30 text-left">
+                    <th className="px-3 py-2 font-medium">Username</th>
+                    <th className="px-3 py-2 font-medium">Email</th>
+                    <th className="px-3 py-2 font-medium">Temporary password</th>
+                  </tr>
+                </thead>
+                <tbody>
+                  {issued.map((cred) => (
+                    <tr key={cred.username} className="border-b last:border-b-0">
+                      <td className="px-3 py-2">@{cred.username}</td>
+                      <td className="px-3 py-2 text-muted-foreground">{cred.email}</td>
+                      <td className="px-3 py-2">
+                        <code className="rounded bg-muted px-2 py-1 font-mono text-xs">{cred.temporaryPassword}</code>
+                      </td>
+                    </tr>
+                  ))}
+                </tbody>
+              </table>
+            </div>
+            <div className="flex flex-wrap gap-2">
+              <Button type="button" onClick={() => downloadCredentialsCSV(issued)}>
+                Download CSV handout
+              </Button>
+              <Button type="button" variant="outline" onClick={() => setIssued([])}>
+                Dismiss
+              </Button>
+            </div>
+          </CardContent>
+        </Card>
+      ) : null}
+
+      <div>
+        <h2 className="text-xl font-semibold tracking-tight">Reissue Credentials</h2>
+        <p className="mt-1 text-sm text-muted-foreground">
+          Give a department or year new temporary passwords, for example before a semester.
+        </p>
+      </div>
+
+      <Card>
+        <CardHeader>
+          <CardTitle>Reset Passwords</CardTitle>
+          <CardDescription>
+            Matching students are signed out and must choose a new password at next login. Fill in one or both fields.
+          </CardDescription>
+        </CardHeader>
+        <CardContent>
+          <form
+            className="grid gap-4 sm:grid-cols-[1fr_8rem_auto] sm:items-end"
+            onSubmit={(e) => {
+              e.preventDefault();
+              const department = resetDepartment.trim();
+              const year = Number(resetYear);
+              if (!department && !year) {
+                setError("Enter a department or a year.");
+                return;
+              }
+              const body: Record<string, unknown> = {};
+              if (department) body.department = department;
+              if (year) body.year = year;
+              const scope = [department, year ? `year ${year}` : ""].filter(Boolean).join(", ");
+              if (!window.confirm(`Reset the passwords of every student in ${scope}?`)) return;
+              void resetPasswords("/api/admin/students/reset-passwords", body);
+            }}
+          >
+            <div className="grid gap-2">
+              <Label htmlFor="resetDepartment">Department</Label>
+              <Input
+                id="resetDepartment"
+                value={resetDepartment}
+                onChange={(e) => setResetDepartment(e.target.value)}
+                placeholder="e.g. Computer Science"
+              />
+            </div>
+            <div className="grid gap-2">
+              <Label htmlFor="resetYear">Year</Label>
+              <Input id="resetYear" type="number" min={1} value={resetYear} onChange={(e) => setResetYear(e.target.value)} />
+            </div>
+            <Button type="submit" variant="destructive" disabled={resetting}>
+              {resetting ? "Resetting..." : "Reset passwords"}
+            </Button>
+          </form>
+        </CardContent>
+      </Card>
+
       <div>
         <h2 className="text-xl font-semibold tracking-tight">Import Students (CSV)</h2>
         <p className="mt-1 text-sm text-muted-foreground">
@@ -443,7 +614,8 @@ export function AdminStudentsClient() {
         <CardHeader>
           <CardTitle>Import Students</CardTitle>
           <CardDescription>
-            Columns: <span className="font-medium">username,email,password,fullName,year,department</span>.
+            Columns: <span className="font-medium">username,email,fullName,year,department</span>. Each student gets
+            a temporary password, shown once after the import.
           </CardDescription>
         </CardHeader>
         <CardContent className="grid gap-3">
@@ -500,6 +672,8 @@ export function AdminStudentsClient() {
 
                   const r = asRecord(data);
                   const created = r && typeof r.createdStudents === "number" ? r.createdStudents : null;
+                  const creds = Array.isArray(r?.credentials) ? r.credentials : [];
+                  setIssued(creds.map(normalizeIssued).filter((c): c is IssuedCredential => c !== null));
                   setImportResult(
                     created !== null ? `Imported ${created} student(s) successfully.` : "Imported students successfully."
                   );
@@ -553,7 +727,7 @@ export function AdminStudentsClient() {
                 const res = await fetch("/api/admin/students", {
                   method: "POST",
                   headers: { "content-type": "application/json" },
-                  body: JSON.stringify({ username, email, password, fullName, year, department }),
+                  body: JSON.stringify({ username, email, fullName, year, department }),
                 });
 
                 const text = await res.text();
@@ -571,9 +745,10 @@ export function AdminStudentsClient() {
                   throw new Error(msg || "Failed to create student");
                 }
 
+                const cred = normalizeIssued(data);
+                setIssued(cred ? [cred] : []);
                 setUsername("");
                 setEmail("");
-                setPassword("");
                 setFullName("");
                 setYear(1);
                 setDepartment("");
@@ -595,11 +770,6 @@ export function AdminStudentsClient() {
               <Input id="email" type="email" value={email} onChange={(e) => setEmail(e.target.value)} placeholder="student001@huhems.local" />
             </div>
 
-            <div className="grid gap-2">
-              <Label htmlFor="password">Password</Label>
-              <Input id="password" type="password" value={password} onChange={(e) => setPassword(e.target.value)} placeholder="Min 8 characters" />
-            </div>
-
             <div className="grid gap-2">
               <Label htmlFor="fullName">Full name</Label>
               <Input id="fullName" value={fullName} onChange={(e) => setFullName(e.target.value)} placeholder="Student Name" />
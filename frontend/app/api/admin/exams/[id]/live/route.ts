@@ -0,0 +1,34 @@
+import { NextResponse } from "next/server";
+
+import { getBackendAuthHeaders, getBackendBaseUrl } from "../../../_util";
+
+// EventSource cannot send an Authorization header, so the browser connects
+// here and the stream from the backend is passed through unbuffered.
+export async function GET(request: Request, ctx: { params: Promise<{ id: string }> }) {
+  const { id } = await ctx.params;
+  const headers = await getBackendAuthHeaders();
+  if (!headers) return NextResponse.json({ message: "not authenticated" }, { status: 401 });
+
+  const res = await fetch(`${getBackendBaseUrl()}/admin/exams/${id}/live`, {
+    headers: { ...headers, accept: "text/event-stream" },
+    cache: "no-store",
+    signal: request.signal,
+  });
+
+  if (!res.ok || !res.body) {
+    const text = await res.text();
+    return new NextResponse(text, {
+      status: res.status,
+      headers: { "content-type": res.headers.get("content-type") ?? "application/json" },
+    });
+  }
+
+  return new Response(res.body, {
+    status: 200,
+    headers: {
+      "content-type": "text/event-stream",
+      "cache-control": "no-cache",
+      connection: "keep-alive",
+    },
+  });
+}
@@ -0,0 +1,21 @@
+import { NextResponse } from "next/server";
+
+import { getBackendAuthHeaders, getBackendBaseUrl } from "../../../_util";
+
+export async function POST(_: Request, { params }: { params: Promise<{ id: string }> }) {
+  const { id } = await params;
+  const headers = await getBackendAuthHeaders();
+  if (!headers) return NextResponse.json({ message: "not authenticated" }, { status: 401 });
+
+  const res = await fetch(`${getBackendBaseUrl()}/admin/users/${id}/reset-password`, {
+    method: "POST",
+    headers,
+    cache: "no-store",
+  });
+
+  const text = await res.text();
+  return new NextResponse(text, {
+    status: res.status,
+    headers: { "content-type": res.headers.get("content-type") ?? "application/json", "cache-control": "no-store" },
+  });
+}
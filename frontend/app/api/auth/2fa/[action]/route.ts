@@ -0,0 +1,32 @@
+import { NextResponse } from "next/server";
+import { cookies } from "next/headers";
+
+import { getBackendAuthHeaders, getBackendBaseUrl } from "../../../admin/_util";
+import { MFA_SETUP_COOKIE } from "../../_session";
+
+const ACTIONS = new Set(["setup", "enable", "disable", "recovery-codes"]);
+
+export async function POST(request: Request, { params }: { params: Promise<{ action: string }> }) {
+  const { action } = await params;
+  if (!ACTIONS.has(action)) return NextResponse.json({ message: "not found" }, { status: 404 });
+
+  const headers = await getBackendAuthHeaders();
+  if (!headers) return NextResponse.json({ message: "not authenticated" }, { status: 401 });
+
+  const res = await fetch(`${getBackendBaseUrl()}/auth/2fa/${action}`, {
+    method: "POST",
+    headers: { ...headers, "content-type": "application/json" },
+    body: await request.text(),
+    cache: "no-store",
+  });
+
+  if (res.ok && action === "enable") {
+    (await cookies()).delete(MFA_SETUP_COOKIE);
+  }
+
+  const text = await res.text();
+  return new NextResponse(text, {
+    status: res.status,
+    headers: { "content-type": res.headers.get("content-type") ?? "application/json", "cache-control": "no-store" },
+  });
+}
@@ -0,0 +1,15 @@
+import { NextResponse } from "next/server";
+
+import { getBackendAuthHeaders, getBackendBaseUrl } from "../../admin/_util";
+
+export async function GET() {
+  const headers = await getBackendAuthHeaders();
+  if (!headers) return NextResponse.json({ message: "not authenticated" }, { status: 401 });
+
+  const res = await fetch(`${getBackendBaseUrl()}/auth/2fa`, { headers, cache: "no-store" });
+  const text = await res.text();
+  return new NextResponse(text, {
+    status: res.status,
+    headers: { "content-type": res.headers.get("content-type") ?? "application/json" },
+  });
+}
@@ -0,0 +1,116 @@
+import { NextResponse } from "next/server";
+import { cookies, headers } from "next/headers";
+
+import { secureCookies } from "@/lib/env";
+
+const TOKEN_COOKIE = "huhems_token";
+const REFRESH_COOKIE = "huhems_refresh";
+const FIRST_LOGIN_COOKIE = "huhems_first_login";
+const MUST_CHANGE_COOKIE = "huhems_must_change_password";
+export const MFA_SETUP_COOKIE = "huhems_mfa_setup_required";
+export type Role = "admin" | "student";
+
+export function asRecord(value: unknown): Record<string, unknown> | null {
+  return value && typeof value === "object" ? (value as Record<string, unknown>) : null;
+}
+
+// completeLogin stores the session the backend started, once every login
+// step has passed, and tells the client where the user must go next.
+export async function completeLogin(dataObj: Record<string, unknown> | null, expectedRole: Role | undefined) {
+  const secure = secureCookies(await headers());
+  const token = (typeof dataObj?.token === "string" ? dataObj.token : undefined) ?? undefined;
+  if (!token) {
+    return NextResponse.json({ message: "Missing token from backend" }, { status: 500 });
+  }
+  const refreshToken = typeof dataObj?.refreshToken === "string" ? dataObj.refreshToken : undefined;
+  const refreshExpiresAt = typeof dataObj?.refreshExpiresAt === "string" ? Date.parse(dataObj.refreshExpiresAt) : NaN;
+  // Both cookies live as long as the session; the middleware renews the
+  // short-lived access token with the refresh token.
+  const sessionMaxAge = Number.isFinite(refreshExpiresAt)
+    ? Math.max(0, Math.floor((refreshExpiresAt - Date.now()) / 1000))
+    : 60 * 60 * 24;
+
+  const role = (typeof dataObj?.role === "string" ? dataObj.role : undefined) ?? undefined;
+  // Every staff role signs in through the admin login.
+  const side: Role | undefined = role ? (role === "student" ? "student" : "admin") : undefined;
+  if (expectedRole && side && side !== expectedRole) {
+    const cookieStore = await cookies();
+    cookieStore.set(TOKEN_COOKIE, "", {
+      httpOnly: true,
+      sameSite: "lax",
+      secure,
+      path: "/",
+      maxAge: 0,
+    });
+    return NextResponse.json(
+      {
+        message:
+          expectedRole === "admin"
+            ? "This account is not an admin. Use Student Login instead."
+            : "This account is not a student. Use Admin Login instead.",
+      },
+      { status: 403 },
+    );
+  }
+
+  const cookieStore = await cookies();
+  cookieStore.set(TOKEN_COOKIE, token, {
+    httpOnly: true,
+    sameSite: "lax",
+    secure,
+    path: "/",
+    maxAge: sessionMaxAge,
+  });
+  if (refreshToken) {
+    cookieStore.set(REFRESH_COOKIE, refreshToken, {
+      httpOnly: true,
+      sameSite: "lax",
+      secure,
+      path: "/",
+      maxAge: sessionMaxAge,
+    });
+  }
+
+  const firstLogin = Boolean(dataObj?.firstLogin);
+  const mustChangePassword = Boolean(dataObj?.mustChangePassword);
+  const mfaSetupRequired = Boolean(dataObj?.mfaSetupRequired);
+  if (firstLogin || mustChangePassword) {
+    cookieStore.set(FIRST_LOGIN_COOKIE, "1", {
+      httpOnly: true,
+      sameSite: "lax",
+      secure,
+      path: "/",
+      // Long enough to survive redirect; short enough not to linger.
+      maxAge: 60 * 10,
+    });
+  }
+
+  if (mustChangePassword) {
+    // Keeps the user on the password page until they change it; the backend
+    // refuses everything else meanwhile.
+    cookieStore.set(MUST_CHANGE_COOKIE, "1", {
+      httpOnly: true,
+      sameSite: "lax",
+      secure,
+      path: "/",
+      maxAge: sessionMaxAge,
+    });
+  } else {
+    cookieStore.delete(MUST_CHANGE_COOKIE);
+  }
+
+  if (mfaSetupRequired) {
+    // Likewise keeps admins on the two-factor setup page until they enroll.
+    cookieStore.set(MFA_SETUP_COOKIE, "1", {
+      httpOnly: true,
+      sameSite: "lax",
+      secure,
+      path: "/",
+      maxAge: sessionMaxAge,
+    });
+  } else {
+    cookieStore.delete(MFA_SETUP_COOKIE);
+  }
+
+  return NextResponse.json({ role: dataObj?.role, user: dataObj?.user, firstLogin, mustChangePassword, mfaSetupRequired });
+}
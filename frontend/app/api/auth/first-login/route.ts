@@ -1,14 +1,16 @@
 import { NextResponse } from "next/server";
 import { cookies } from "next/headers";
 
+import { secureCookies } from "@/lib/env";
+
 const FIRST_LOGIN_COOKIE = "huhems_first_login";
 
-export async function POST() {
+export async function POST(request: Request) {
   const cookieStore = await cookies();
   cookieStore.set(FIRST_LOGIN_COOKIE, "", {
     httpOnly: true,
     sameSite: "lax",
-    secure: false,
+    secure: secureCookies(request.headers, request.url),
     path: "/",
     maxAge: 0,
   });
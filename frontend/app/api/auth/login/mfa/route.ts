@@ -0,0 +1,47 @@
+import { NextResponse } from "next/server";
+
+import { getApiBaseUrl } from "@/lib/env";
+import { getClientHeaders } from "@/lib/forward";
+
+import { asRecord, completeLogin, type Role } from "../../_session";
+
+export async function POST(request: Request) {
+  const body = await request.json().catch(() => null);
+  if (!body) {
+    return NextResponse.json({ message: "Invalid request" }, { status: 400 });
+  }
+
+  const expectedRole = body?.expectedRole as Role | undefined;
+  const forwardBody = { ...body } as Record<string, unknown>;
+  delete forwardBody.expectedRole;
+
+  const apiBase = getApiBaseUrl();
+  let res: Response;
+  try {
+    res = await fetch(`${apiBase}/auth/login/mfa`, {
+      method: "POST",
+      headers: { "content-type": "application/json", ...getClientHeaders(request) },
+      body: JSON.stringify(forwardBody),
+      cache: "no-store",
+    });
+  } catch {
+    return NextResponse.json({ message: `Cannot reach backend at ${apiBase}. Is it running?` }, { status: 502 });
+  }
+
+  const rawText = await res.text();
+  const data = (() => {
+    try {
+      return JSON.parse(rawText) as unknown;
+    } catch {
+      return null;
+    }
+  })();
+  const dataObj = asRecord(data);
+
+  if (!res.ok) {
+    const msg = (typeof dataObj?.message === "string" ? dataObj.message : undefined) ?? rawText?.trim() ?? "Login failed";
+    return NextResponse.json({ message: msg }, { status: res.status });
+  }
+
+  return completeLogin(dataObj, expectedRole);
+}
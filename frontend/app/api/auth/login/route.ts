@@ -1,15 +1,9 @@
 import { NextResponse } from "next/server";
-import { cookies } from "next/headers";
-
-const TOKEN_COOKIE = "huhems_token";
-const FIRST_LOGIN_COOKIE = "huhems_first_login";
-type Role = "admin" | "student";
-
-function asRecord(value: unknown): Record<string, unknown> | null {
-  return value && typeof value === "object" ? (value as Record<string, unknown>) : null;
-}
 
 import { getApiBaseUrl } from "@/lib/env";
+import { getClientHeaders } from "@/lib/forward";
+
+import { asRecord, completeLogin, type Role } from "../_session";
 
 export async function POST(request: Request) {
   const body = await request.json().catch(() => null);
@@ -26,7 +20,7 @@ export async function POST(request: Request) {
   try {
     res = await fetch(`${apiBase}/auth/login`, {
       method: "POST",
-      headers: { "content-type": "application/json" },
+      headers: { "content-type": "application/json", ...getClientHeaders(request) },
       body: JSON.stringify(forwardBody),
       cache: "no-store",
     });
@@ -65,52 +59,10 @@ export async function POST(request: Request) {
     return NextResponse.json({ message: msg }, { status: res.status });
   }
 
-  const token = (typeof dataObj?.token === "string" ? dataObj.token : undefined) ?? undefined;
-  if (!token) {
-    return NextResponse.json({ message: "Missing token from backend" }, { status: 500 });
-  }
-
-  const role = (typeof dataObj?.role === "string" ? (dataObj.role as Role) : undefined) ?? undefined;
-  if (expectedRole && role && role !== expectedRole) {
-    const cookieStore = await cookies();
-    cookieStore.set(TOKEN_COOKIE, "", {
-      httpOnly: true,
-      sameSite: "lax",
-      secure: false,
-      path: "/",
-      maxAge: 0,
-    });
-    return NextResponse.json(
-      {
-        message:
-          expectedRole === "admin"
-            ? "This account is not an admin. Use Student Login instead."
-            : "This account is not a student. Use Admin Login instead.",
-      },
-      { status: 403 },
-    );
-  }
-
-  const cookieStore = await cookies();
-  cookieStore.set(TOKEN_COOKIE, token, {
-    httpOnly: true,
-    sameSite: "lax",
-    secure: false,
-    path: "/",
-    maxAge: 60 * 60 * 24,
-  });
-
-  const firstLogin = Boolean(dataObj?.firstLogin);
-  if (firstLogin) {
-    cookieStore.set(FIRST_LOGIN_COOKIE, "1", {
-      httpOnly: true,
-      sameSite: "lax",
-      secure: false,
-      path: "/",
-      // Long enough to survive redirect; short enough not to linger.
-      maxAge: 60 * 10,
-    });
+  if (dataObj?.mfaRequired) {
+    // The password was right; the second factor goes to /api/auth/login/mfa.
+    return NextResponse.json({ mfaRequired: true, mfaToken: dataObj.mfaToken, expiresIn: dataObj.expiresIn });
   }
 
-  return NextResponse.json({ role: dataObj?.role, user: dataObj?.user, firstLogin });
+  return completeLogin(dataObj, expectedRole);
 }
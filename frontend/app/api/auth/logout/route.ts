@@ -1,17 +1,49 @@
 import { NextResponse } from "next/server";
 import { cookies } from "next/headers";
 
+import { getApiBaseUrl, secureCookies } from "@/lib/env";
+
 const TOKEN_COOKIE = "huhems_token";
+const REFRESH_COOKIE = "huhems_refresh";
 
-export async function POST() {
+// POST ends the session on the backend, then clears the cookies. Pass
+// ?all=1 to sign out of every device.
+export async function POST(request: Request) {
   const cookieStore = await cookies();
-  cookieStore.set(TOKEN_COOKIE, "", {
-    httpOnly: true,
-    sameSite: "lax",
-    secure: false,
-    path: "/",
-    maxAge: 0,
-  });
+  const token = cookieStore.get(TOKEN_COOKIE)?.value;
+  const refreshToken = cookieStore.get(REFRESH_COOKIE)?.value;
+  const all = new URL(request.url).searchParams.get("all") === "1";
+
+  // Clearing the cookies matters more than reaching the backend, so errors
+  // here are ignored.
+  try {
+    if (all && token) {
+      await fetch(`${getApiBaseUrl()}/auth/logout-all`, {
+        method: "POST",
+        headers: { Authorization: `Bearer ${token}` },
+        cache: "no-store",
+      });
+    } else if (refreshToken) {
+      await fetch(`${getApiBaseUrl()}/auth/logout`, {
+        method: "POST",
+        headers: { "content-type": "application/json" },
+        body: JSON.stringify({ refreshToken }),
+        cache: "no-store",
+      });
+    }
+  } catch {
+    // ignore
+  }
+
+  for (const name of [TOKEN_COOKIE, REFRESH_COOKIE]) {
+    cookieStore.set(name, "", {
+      httpOnly: true,
+      sameSite: "lax",
+      secure: secureCookies(request.headers, request.url),
+      path: "/",
+      maxAge: 0,
+    });
+  }
 
   return NextResponse.json({ ok: true });
 }
@@ -0,0 +1,5 @@
+// The state of a single sign-on started in this browser, checked when the
+// identity provider sends the browser back.
+export const OIDC_STATE_COOKIE = "huhems_oidc_state";
+// Matches how long the backend keeps the state.
+export const OIDC_STATE_MAX_AGE = 60 * 10;
@@ -0,0 +1,63 @@
+import { NextResponse } from "next/server";
+import { cookies } from "next/headers";
+
+import { getApiBaseUrl } from "@/lib/env";
+import { getClientHeaders } from "@/lib/forward";
+
+import { asRecord, completeLogin } from "../../_session";
+import { OIDC_STATE_COOKIE } from "../_state";
+
+export async function POST(request: Request) {
+  const body = await request.text();
+  const state = (() => {
+    try {
+      return asRecord(JSON.parse(body) as unknown)?.state;
+    } catch {
+      return undefined;
+    }
+  })();
+
+  // Only the browser that started the sign-in may finish it; otherwise a
+  // victim could be signed in to an attacker's account with a forged link.
+  const cookieStore = await cookies();
+  const expected = cookieStore.get(OIDC_STATE_COOKIE)?.value;
+  cookieStore.delete({ name: OIDC_STATE_COOKIE, path: "/api/auth/oidc" });
+  if (!expected || typeof state !== "string" || state !== expected) {
+    return NextResponse.json({ message: "Sign-in was not started in this browser. Try again." }, { status: 400 });
+  }
+
+  const apiBase = getApiBaseUrl();
+  let res: Response;
+  try {
+    res = await fetch(`${apiBase}/auth/oidc/callback`, {
+      method: "POST",
+      headers: { "content-type": "application/json", ...getClientHeaders(request) },
+      body,
+      cache: "no-store",
+    });
+  } catch {
+    return NextResponse.json({ message: `Cannot reach backend at ${apiBase}. Is it running?` }, { status: 502 });
+  }
+
+  const rawText = await res.text();
+  const data = (() => {
+    try {
+      return JSON.parse(rawText) as unknown;
+    } catch {
+      return null;
+    }
+  })();
+  const dataObj = asRecord(data);
+
+  if (!res.ok) {
+    const msg = (typeof dataObj?.message === "string" ? dataObj.message : undefined) ?? rawText?.trim() ?? "Sign-in failed";
+    return NextResponse.json({ message: msg }, { status: res.status });
+  }
+
+  if (dataObj?.mfaRequired) {
+    return NextResponse.json({ mfaRequired: true, mfaToken: dataObj.mfaToken, expiresIn: dataObj.expiresIn });
+  }
+
+  // Single sign-on serves every role, so there is no expected role to check.
+  return completeLogin(dataObj, undefined);
+}
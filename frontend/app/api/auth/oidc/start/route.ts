@@ -0,0 +1,31 @@
+import { NextResponse } from "next/server";
+import { getApiBaseUrl, secureCookies } from "@/lib/env";
+
+import { OIDC_STATE_COOKIE, OIDC_STATE_MAX_AGE } from "../_state";
+
+// Sends the browser to the identity provider's login page. Failures go back
+// to the login page, which shows the message. The state is also kept in a
+// cookie, so that the callback only completes a sign-in this browser began.
+export async function GET(request: Request) {
+  const loginUrl = new URL("/auth/login", request.url);
+  try {
+    const res = await fetch(`${getApiBaseUrl()}/auth/oidc/start`, { cache: "no-store" });
+    const data = (await res.json().catch(() => null)) as { authorizationUrl?: string; message?: string } | null;
+    const state = data?.authorizationUrl ? new URL(data.authorizationUrl).searchParams.get("state") : null;
+    if (res.ok && data?.authorizationUrl && state) {
+      const redirect = NextResponse.redirect(data.authorizationUrl);
+      redirect.cookies.set(OIDC_STATE_COOKIE, state, {
+        httpOnly: true,
+        sameSite: "lax",
+        secure: secureCookies(request.headers, request.url),
+        path: "/api/auth/oidc",
+        maxAge: OIDC_STATE_MAX_AGE,
+      });
+      return redirect;
+    }
+    loginUrl.searchParams.set("ssoError", data?.message ?? "Single sign-on is unavailable");
+  } catch {
+    loginUrl.searchParams.set("ssoError", "Cannot reach backend");
+  }
+  return NextResponse.redirect(loginUrl);
+}
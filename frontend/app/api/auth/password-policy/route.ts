@@ -0,0 +1,12 @@
+import { NextResponse } from "next/server";
+import { getApiBaseUrl } from "@/lib/env";
+
+export async function GET() {
+  try {
+    const res = await fetch(`${getApiBaseUrl()}/auth/password-policy`, { cache: "no-store" });
+    const data = await res.json().catch(() => ({}));
+    return NextResponse.json(data, { status: res.status });
+  } catch {
+    return NextResponse.json({ message: "Cannot reach backend" }, { status: 502 });
+  }
+}
@@ -3,6 +3,7 @@ import { cookies } from "next/headers";
 import { getApiBaseUrl } from "@/lib/env";
 
 const TOKEN_COOKIE = "huhems_token";
+const MUST_CHANGE_COOKIE = "huhems_must_change_password";
 
 function asRecord(value: unknown): Record<string, unknown> | null {
   return value && typeof value === "object" ? (value as Record<string, unknown>) : null;
@@ -63,5 +64,6 @@ export async function PUT(request: Request) {
     return NextResponse.json({ message: msg }, { status: res.status });
   }
 
+  cookieStore.delete(MUST_CHANGE_COOKIE);
   return NextResponse.json({ message: "password updated" });
 }
@@ -0,0 +1,23 @@
+import { NextResponse } from "next/server";
+import { getApiBaseUrl } from "@/lib/env";
+import { getClientHeaders } from "@/lib/forward";
+
+export async function POST(request: Request) {
+  const body = await request.text();
+  const apiBase = getApiBaseUrl();
+
+  let res: Response;
+  try {
+    res = await fetch(`${apiBase}/auth/reset-password`, {
+      method: "POST",
+      headers: { "content-type": "application/json", ...getClientHeaders(request) },
+      body,
+      cache: "no-store",
+    });
+  } catch {
+    return NextResponse.json({ message: `Cannot reach backend at ${apiBase}. Is it running?` }, { status: 502 });
+  }
+
+  const data = await res.json().catch(() => ({}));
+  return NextResponse.json(data, { status: res.status });
+}
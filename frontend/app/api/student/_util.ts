@@ -1,7 +1,9 @@
-import { cookies } from "next/headers";
-import { getApiBaseUrl } from "@/lib/env";
+import { cookies, headers } from "next/headers";
+import { getApiBaseUrl, secureCookies } from "@/lib/env";
+import { getClientHeaders } from "@/lib/forward";
 
 const TOKEN_COOKIE = "huhems_token";
+const ATTEMPT_SESSION_COOKIE_PREFIX = "huhems_attempt_";
 
 export function getBackendBaseUrl(): string {
   return getApiBaseUrl();
@@ -13,6 +15,27 @@ export async function getBackendAuthHeaders(): Promise<Record<string, string> |
   return { Authorization: `Bearer ${token}` };
 }
 
+// The backend binds an attempt to the browser that started it, so attempt
+// requests carry the browser's headers, not ours.
+export { getClientHeaders };
+
+export async function setAttemptSessionCookie(attemptId: string, token: string): Promise<void> {
+  (await cookies()).set(`${ATTEMPT_SESSION_COOKIE_PREFIX}${attemptId}`, token, {
+    httpOnly: true,
+    sameSite: "lax",
+    secure: secureCookies(await headers()),
+    path: "/",
+    maxAge: 60 * 60 * 24,
+  });
+}
+
+export async function getAttemptSessionHeaders(request: Request, attemptId: string): Promise<Record<string, string>> {
+  const headers = getClientHeaders(request);
+  const token = (await cookies()).get(`${ATTEMPT_SESSION_COOKIE_PREFIX}${attemptId}`)?.value;
+  if (token) headers["x-attempt-session"] = token;
+  return headers;
+}
+
 export async function forwardStudentRequest(request: Request, backendPath: string): Promise<Response> {
   const auth = await getBackendAuthHeaders();
   if (!auth) {
@@ -0,0 +1,21 @@
+import { NextResponse } from "next/server";
+
+import { getAttemptSessionHeaders, getBackendAuthHeaders, getBackendBaseUrl } from "../../../../../_util";
+
+export async function POST(request: Request, ctx: { params: Promise<{ id: string; announcementId: string }> }) {
+  const { id, announcementId } = await ctx.params;
+  const headers = await getBackendAuthHeaders();
+  if (!headers) return NextResponse.json({ message: "not authenticated" }, { status: 401 });
+
+  const res = await fetch(`${getBackendBaseUrl()}/student/attempts/${id}/announcements/${announcementId}/ack`, {
+    method: "POST",
+    headers: { ...headers, ...(await getAttemptSessionHeaders(request, id)) },
+    cache: "no-store",
+  });
+
+  const text = await res.text();
+  return new NextResponse(text, {
+    status: res.status,
+    headers: { "content-type": res.headers.get("content-type") ?? "application/json" },
+  });
+}
@@ -1,6 +1,6 @@
 import { NextResponse } from "next/server";
 
-import { getBackendAuthHeaders, getBackendBaseUrl } from "../../../_util";
+import { getAttemptSessionHeaders, getBackendAuthHeaders, getBackendBaseUrl } from "../../../_util";
 
 export async function POST(request: Request, ctx: { params: Promise<{ id: string }> }) {
   const { id } = await ctx.params;
@@ -10,7 +10,7 @@ export async function POST(request: Request, ctx: { params: Promise<{ id: string
   const body = await request.text();
   const res = await fetch(`${getBackendBaseUrl()}/student/attempts/${id}/flag`, {
     method: "POST",
-    headers: { ...headers, "content-type": "application/json" },
+    headers: { ...headers, ...(await getAttemptSessionHeaders(request, id)), "content-type": "application/json" },
     body,
     cache: "no-store",
   });
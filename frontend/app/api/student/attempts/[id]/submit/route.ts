@@ -1,15 +1,15 @@
 import { NextResponse } from "next/server";
 
-import { getBackendAuthHeaders, getBackendBaseUrl } from "../../../_util";
+import { getAttemptSessionHeaders, getBackendAuthHeaders, getBackendBaseUrl } from "../../../_util";
 
-export async function POST(_request: Request, ctx: { params: Promise<{ id: string }> }) {
+export async function POST(request: Request, ctx: { params: Promise<{ id: string }> }) {
   const { id } = await ctx.params;
   const headers = await getBackendAuthHeaders();
   if (!headers) return NextResponse.json({ message: "not authenticated" }, { status: 401 });
 
   const res = await fetch(`${getBackendBaseUrl()}/student/attempts/${id}/submit`, {
     method: "POST",
-    headers,
+    headers: { ...headers, ...(await getAttemptSessionHeaders(request, id)) },
     cache: "no-store",
   });
 
@@ -1,19 +1,36 @@
 import { NextResponse } from "next/server";
 
-import { getBackendAuthHeaders, getBackendBaseUrl } from "../../../_util";
+import { getBackendAuthHeaders, getBackendBaseUrl, getClientHeaders, setAttemptSessionCookie } from "../../../_util";
 
-export async function POST(_request: Request, ctx: { params: Promise<{ id: string }> }) {
+export async function POST(request: Request, ctx: { params: Promise<{ id: string }> }) {
   const { id } = await ctx.params;
   const headers = await getBackendAuthHeaders();
   if (!headers) return NextResponse.json({ message: "not authenticated" }, { status: 401 });
 
+  // The body carries the transfer code when resuming a transferred attempt.
+  const body = await request.text();
   const res = await fetch(`${getBackendBaseUrl()}/student/exams/${id}/start`, {
     method: "POST",
-    headers,
+    headers: { ...headers, ...getClientHeaders(request), ...(body ? { "content-type": "application/json" } : {}) },
+    body: body || undefined,
     cache: "no-store",
   });
 
   const text = await res.text();
+
+  // Keep the attempt session token out of reach of page scripts.
+  if (res.ok) {
+    try {
+      const data = JSON.parse(text) as { attemptId?: string; sessionToken?: string };
+      if (data.attemptId && data.sessionToken) {
+        await setAttemptSessionCookie(data.attemptId, data.sessionToken);
+        return NextResponse.json({ attemptId: data.attemptId }, { status: res.status });
+      }
+    } catch {
+      // Fall through and return the backend response as-is.
+    }
+  }
+
   return new NextResponse(text, {
     status: res.status,
     headers: { "content-type": res.headers.get("content-type") ?? "application/json" },
@@ -28,7 +28,7 @@ export default async function Home() {
   const token = (await cookies()).get("huhems_token")?.value;
   const role = token ? parseJwtPayload(token)?.role : null;
   const isLoggedIn = Boolean(token);
-  const canGoAdmin = role === "admin";
+  const canGoAdmin = Boolean(role) && role !== "student";
   const canGoStudent = role === "student";
 
   return (
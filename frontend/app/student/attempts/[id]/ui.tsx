@@ -26,8 +26,16 @@ type Attempt = {
   endTime: string | null;
   score: number;
   submitted: boolean;
+  // Server clock: deadline includes extra time and pauses. The countdown runs
+  // from remainingSeconds so a skewed local clock does not matter.
+  serverTime?: string;
+  deadline?: string | null;
+  remainingSeconds?: number | null;
+  paused?: boolean;
 };
 
+type AttemptClock = Pick<Attempt, "serverTime" | "deadline" | "remainingSeconds" | "paused">;
+
 type Exam = {
   id: string;
   title: string;
@@ -47,12 +55,33 @@ type Question = {
   flagged: boolean;
 };
 
+type Announcement = {
+  id: string;
+  message: string;
+  createdAt: string;
+  acknowledged: boolean;
+};
+
 type AttemptDetailResponse = {
   attempt: Attempt;
   exam: Exam;
   questions: Question[];
+  announcements?: Announcement[];
 };
 
+type IntegrityEvent = {
+  type: "tab_hidden" | "window_blur" | "copy" | "cut" | "paste" | "fullscreen_exit";
+  occurredAt: string;
+  details?: string;
+};
+
+const ANNOUNCEMENT_POLL_MS = 30 * 1000;
+// Integrity events are sent in batches; the backend limits how often an
+// attempt may report and how many events one report may carry.
+const INTEGRITY_FLUSH_MS = 5 * 1000;
+const INTEGRITY_BATCH_SIZE = 50;
+const INTEGRITY_QUEUE_LIMIT = 500;
+
 function asRecord(value: unknown): Record<string, unknown> | null {
   return value && typeof value === "object" ? (value as Record<string, unknown>) : null;
 }
@@ -87,6 +116,10 @@ export function StudentAttemptClient({ attemptId }: { attemptId: string }) {
   const [submitOpen, setSubmitOpen] = useState(false);
 
   const [remainingMs, setRemainingMs] = useState<number | null>(null);
+  // Deadline on the local clock, derived from the server's remainingSeconds.
+  const [deadlineMs, setDeadlineMs] = useState<number | null>(null);
+  const [paused, setPaused] = useState(false);
+  const [timeWarning, setTimeWarning] = useState<string | null>(null);
   const autoSubmittedRef = useRef(false);
 
   const [index, setIndex] = useState(0);
@@ -129,16 +162,178 @@ export function StudentAttemptClient({ attemptId }: { attemptId: string }) {
     // eslint-disable-next-line react-hooks/exhaustive-deps
   }, [attemptId]);
 
+  // Poll for new announcements without reloading the rest of the attempt.
+  useEffect(() => {
+    if (!data || data.attempt.submitted) return;
+    const id = window.setInterval(async () => {
+      try {
+        const res = await fetch(`/api/student/attempts/${attemptId}`, { cache: "no-store" });
+        if (!res.ok) return;
+        const next = (await res.json()) as AttemptDetailResponse;
+        setData((prev) => (prev ? { ...prev, attempt: next.attempt, announcements: next.announcements } : prev));
+      } catch {
+        // Try again on the next tick.
+      }
+    }, ANNOUNCEMENT_POLL_MS);
+    return () => window.clearInterval(id);
+  }, [attemptId, data?.attempt.submitted, Boolean(data)]);
+
+  async function acknowledgeAnnouncement(announcementId: string) {
+    const res = await fetch(`/api/student/attempts/${attemptId}/announcements/${announcementId}/ack`, { method: "POST" });
+    if (!res.ok) return;
+    setData((prev) =>
+      prev
+        ? {
+            ...prev,
+            announcements: prev.announcements?.map((a) => (a.id === announcementId ? { ...a, acknowledged: true } : a)),
+          }
+        : prev,
+    );
+  }
+
+  const pendingAnnouncements = data?.announcements?.filter((a) => !a.acknowledged) ?? [];
+
   const total = data?.questions.length ?? 0;
   const question = data?.questions[index] ?? null;
 
-  const deadlineMs = useMemo(() => {
-    if (!data) return null;
-    if (data.exam.durationMinutes <= 0) return null;
-    const start = Date.parse(data.attempt.startTime);
-    if (Number.isNaN(start)) return null;
-    return start + data.exam.durationMinutes * 60 * 1000;
-  }, [data]);
+  // Time each question has been on screen since its answer was last saved.
+  // It is sent as visibleMs so time-per-question reports leave out time
+  // spent on other questions or in other tabs.
+  const visibleMsRef = useRef<Record<string, number>>({});
+  const shownRef = useRef<{ questionId: string; since: number } | null>(null);
+
+  function stopVisibleClock() {
+    const shown = shownRef.current;
+    if (!shown) return;
+    visibleMsRef.current[shown.questionId] = (visibleMsRef.current[shown.questionId] ?? 0) + (Date.now() - shown.since);
+    shownRef.current = null;
+  }
+
+  useEffect(() => {
+    const questionId = question?.id;
+    if (!questionId) return;
+    const start = () => {
+      if (document.visibilityState === "visible") shownRef.current = { questionId, since: Date.now() };
+    };
+    const onVisibility = () => (document.visibilityState === "visible" ? start() : stopVisibleClock());
+    start();
+    document.addEventListener("visibilitychange", onVisibility);
+    return () => {
+      document.removeEventListener("visibilitychange", onVisibility);
+      stopVisibleClock();
+    };
+  }, [question?.id]);
+
+  function takeVisibleMs(questionId: string) {
+    if (shownRef.current?.questionId === questionId) {
+      stopVisibleClock();
+      shownRef.current = { questionId, since: Date.now() };
+    }
+    const ms = Math.round(visibleMsRef.current[questionId] ?? 0);
+    visibleMsRef.current[questionId] = 0;
+    return ms;
+  }
+
+  const integrityQueueRef = useRef<IntegrityEvent[]>([]);
+
+  async function flushIntegrityEvents() {
+    const batch = integrityQueueRef.current.splice(0, INTEGRITY_BATCH_SIZE);
+    if (batch.length === 0) return;
+    try {
+      const res = await fetch(`/api/student/attempts/${attemptId}/events`, {
+        method: "POST",
+        headers: { "content-type": "application/json" },
+        body: JSON.stringify({ events: batch }),
+        keepalive: true,
+      });
+      // Rejected batches (bad input, attempt closed) are dropped; only
+      // transient failures are retried.
+      if (res.status === 429 || res.status >= 500) throw new Error(`status ${res.status}`);
+    } catch {
+      integrityQueueRef.current = [...batch, ...integrityQueueRef.current].slice(0, INTEGRITY_QUEUE_LIMIT);
+    }
+  }
+
+  // Report leaving the exam page, clipboard use and leaving fullscreen.
+  useEffect(() => {
+    if (!data || data.attempt.submitted) return;
+    const report = (type: IntegrityEvent["type"], details?: string) => {
+      if (integrityQueueRef.current.length >= INTEGRITY_QUEUE_LIMIT) return;
+      integrityQueueRef.current.push({ type, occurredAt: new Date().toISOString(), details });
+    };
+    const onVisibility = () => {
+      if (document.visibilityState === "hidden") report("tab_hidden");
+    };
+    const onBlur = () => report("window_blur");
+    const onCopy = () => report("copy");
+    const onCut = () => report("cut");
+    const onPaste = () => report("paste");
+    const onFullscreen = () => {
+      if (!document.fullscreenElement) report("fullscreen_exit");
+    };
+    document.addEventListener("visibilitychange", onVisibility);
+    window.addEventListener("blur", onBlur);
+    document.addEventListener("copy", onCopy);
+    document.addEventListener("cut", onCut);
+    document.addEventListener("paste", onPaste);
+    document.addEventListener("fullscreenchange", onFullscreen);
+    const id = window.setInterval(() => void flushIntegrityEvents(), INTEGRITY_FLUSH_MS);
+    return () => {
+      document.removeEventListener("visibilitychange", onVisibility);
+      window.removeEventListener("blur", onBlur);
+      document.removeEventListener("copy", onCopy);
+      document.removeEventListener("cut", onCut);
+      document.removeEventListener("paste", onPaste);
+      document.removeEventListener("fullscreenchange", onFullscreen);
+      window.clearInterval(id);
+      void flushIntegrityEvents();
+    };
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [attemptId, data?.attempt.submitted, Boolean(data)]);
+
+  function applyClock(clock: AttemptClock) {
+    setPaused(Boolean(clock.paused));
+    if (typeof clock.remainingSeconds === "number") {
+      setDeadlineMs(Date.now() + clock.remainingSeconds * 1000);
+    } else {
+      setDeadlineMs(null);
+    }
+  }
+
+  useEffect(() => {
+    if (data) applyClock(data.attempt);
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [data?.attempt]);
+
+  // The server pushes clock resyncs, time warnings, announcements and the
+  // final submission. Reconnects are handled by EventSource.
+  useEffect(() => {
+    if (!data || data.attempt.submitted) return;
+    const source = new EventSource(`/api/student/attempts/${attemptId}/stream`);
+    source.addEventListener("clock", (e) => {
+      applyClock(JSON.parse((e as MessageEvent).data) as AttemptClock);
+    });
+    source.addEventListener("time.warning", (e) => {
+      const w = JSON.parse((e as MessageEvent).data) as AttemptClock & { minutesLeft: number };
+      applyClock(w);
+      setTimeWarning(w.minutesLeft === 1 ? "1 minute left" : `${w.minutesLeft} minutes left`);
+    });
+    source.addEventListener("announcement", (e) => {
+      const a = (JSON.parse((e as MessageEvent).data) as { data: Announcement }).data;
+      setData((prev) =>
+        prev && !prev.announcements?.some((x) => x.id === a.id)
+          ? { ...prev, announcements: [...(prev.announcements ?? []), { ...a, acknowledged: false }] }
+          : prev,
+      );
+    });
+    source.addEventListener("attempt.submitted", () => {
+      source.close();
+      autoSubmittedRef.current = true;
+      router.push(`/student/attempts/${attemptId}/result`);
+    });
+    return () => source.close();
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [attemptId, data?.attempt.submitted, Boolean(data)]);
 
   const timeUp = remainingMs !== null && remainingMs <= 0;
 
@@ -167,14 +362,18 @@ export function StudentAttemptClient({ attemptId }: { attemptId: string }) {
     };
 
     tick();
+    // The clock is stopped while a proctor has the attempt paused.
+    if (paused) return;
     const id = window.setInterval(tick, 1000);
     return () => window.clearInterval(id);
-  }, [deadlineMs, data?.attempt.submitted]);
+  }, [deadlineMs, data?.attempt.submitted, paused]);
 
   async function submitAttemptRequest() {
     setBusy(true);
     setError(null);
     try {
+      // Events are refused once the attempt is submitted.
+      await flushIntegrityEvents();
       const res = await fetch(`/api/student/attempts/${attemptId}/submit`, { method: "POST" });
       const j = (await res.json().catch(() => ({}))) as unknown;
       if (!res.ok) {
@@ -206,11 +405,12 @@ export function StudentAttemptClient({ attemptId }: { attemptId: string }) {
   async function saveAnswer(questionId: string, selectedChoiceIds: string[]) {
     setBusy(true);
     setError(null);
+    const visibleMs = takeVisibleMs(questionId);
     try {
       const res = await fetch(`/api/student/attempts/${attemptId}/answer`, {
         method: "POST",
         headers: { "content-type": "application/json" },
-        body: JSON.stringify({ questionId, selectedChoiceIds }),
+        body: JSON.stringify({ questionId, selectedChoiceIds, visibleMs }),
       });
       const j = (await res.json().catch(() => ({}))) as unknown;
       if (!res.ok) {
@@ -218,6 +418,7 @@ export function StudentAttemptClient({ attemptId }: { attemptId: string }) {
         const msg = r && typeof r.message === "string" ? r.message : "Failed to save answer";
         throw new Error(msg);
       }
+      applyClock(j as AttemptClock);
 
       setData((prev) => {
         if (!prev) return prev;
@@ -227,6 +428,8 @@ export function StudentAttemptClient({ attemptId }: { attemptId: string }) {
         };
       });
     } catch (e: unknown) {
+      // Keep the time for the next save.
+      visibleMsRef.current[questionId] = (visibleMsRef.current[questionId] ?? 0) + visibleMs;
       setError(getErrorMessage(e, "Failed to save answer"));
     } finally {
       setBusy(false);
@@ -248,6 +451,7 @@ export function StudentAttemptClient({ attemptId }: { attemptId: string }) {
         const msg = r && typeof r.message === "string" ? r.message : "Failed to update flag";
         throw new Error(msg);
       }
+      applyClock(j as AttemptClock);
 
       setData((prev) => {
         if (!prev) return prev;
@@ -365,6 +569,22 @@ export function StudentAttemptClient({ attemptId }: { attemptId: string }) {
         <p className="text-sm font-medium text-destructive">{error}</p>
       ) : null}
 
+      {timeWarning ? (
+        <p className="text-sm font-medium text-destructive">{timeWarning}</p>
+      ) : null}
+
+      {pendingAnnouncements.map((a) => (
+        <div key={a.id} className="flex items-start gap-3 rounded-xl border-2 border-amber-500/50 bg-amber-50 p-4 dark:bg-amber-950/30">
+          <div className="grid gap-1">
+            <p className="text-sm font-semibold">Announcement</p>
+            <p className="whitespace-pre-wrap text-sm">{a.message}</p>
+          </div>
+          <Button type="button" size="sm" className="ml-auto" onClick={() => void acknowledgeAnnouncement(a.id)}>
+            Got it
+          </Button>
+        </div>
+      ))}
+
       <div className="grid gap-4 lg:grid-cols-[320px_1fr]">
         <Card className="h-fit">
           <CardHeader>
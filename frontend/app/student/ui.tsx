@@ -24,6 +24,7 @@ import {
   AlertDialogHeader,
   AlertDialogTitle,
 } from "@/components/ui/alert-dialog";
+import { Input } from "@/components/ui/input";
 import { Label } from "@/components/ui/label";
 
 type ExamListItem = {
@@ -71,6 +72,9 @@ export function StudentDashboardClient() {
   const [rulesOpen, setRulesOpen] = useState(false);
   const [rulesAgreed, setRulesAgreed] = useState(false);
   const [rulesExam, setRulesExam] = useState<ExamListItem | null>(null);
+  // Set once the backend asks for a proctor's transfer code.
+  const [transferCodeRequired, setTransferCodeRequired] = useState(false);
+  const [transferCode, setTransferCode] = useState("");
 
   async function load() {
     setLoading(true);
@@ -104,10 +108,15 @@ export function StudentDashboardClient() {
     setStartingId(examId);
     setStartError(null);
     try {
-      const res = await fetch(`/api/student/exams/${examId}/start`, { method: "POST" });
+      const code = transferCode.trim();
+      const res = await fetch(`/api/student/exams/${examId}/start`, {
+        method: "POST",
+        ...(code ? { headers: { "content-type": "application/json" }, body: JSON.stringify({ transferCode: code }) } : {}),
+      });
       const j = (await res.json().catch(() => ({}))) as unknown;
       if (!res.ok) {
         const r = asRecord(j);
+        if (r?.transferCodeRequired === true) setTransferCodeRequired(true);
         const msg = r && typeof r.message === "string" ? r.message : "Failed to start attempt";
         throw new Error(msg);
       }
@@ -236,6 +245,8 @@ export function StudentDashboardClient() {
                   setRulesExam(exam);
                   setRulesAgreed(false);
                   setStartError(null);
+                  setTransferCodeRequired(false);
+                  setTransferCode("");
                   setRulesOpen(true);
                 }}
                 disabled={startingId === exam.id}
@@ -284,6 +295,8 @@ export function StudentDashboardClient() {
           } else {
             setRulesExam(null);
             setRulesAgreed(false);
+            setTransferCodeRequired(false);
+            setTransferCode("");
           }
           setRulesOpen(nextOpen);
         }}
@@ -353,6 +366,20 @@ export function StudentDashboardClient() {
             </div>
           </div>
 
+          {transferCodeRequired ? (
+            <div className="grid gap-2">
+              <Label htmlFor="transfer_code">Transfer code</Label>
+              <Input
+                id="transfer_code"
+                value={transferCode}
+                onChange={(e) => setTransferCode(e.target.value)}
+                placeholder="Code from your proctor"
+                autoComplete="off"
+                disabled={Boolean(startingId)}
+              />
+            </div>
+          ) : null}
+
           {startError ? (
             <div className="rounded-lg border-2 border-red-200 bg-red-50 p-3 dark:border-red-800/50 dark:bg-red-950/30">
               <p className="text-sm font-medium text-red-900 dark:text-red-100">{startError}</p>
@@ -368,7 +395,7 @@ export function StudentDashboardClient() {
             <AlertDialogAction asChild>
               <Button
                 type="button"
-                disabled={!rulesExam || !rulesAgreed || Boolean(startingId)}
+                disabled={!rulesExam || !rulesAgreed || Boolean(startingId) || (transferCodeRequired && !transferCode.trim())}
                 onClick={(e) => {
                   e.preventDefault();
                   if (!rulesExam) return;
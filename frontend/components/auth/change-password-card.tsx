@@ -1,6 +1,6 @@
 "use client";
 
-import { useState } from "react";
+import { useEffect, useState } from "react";
 
 import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card";
 import { Label } from "@/components/ui/label";
@@ -13,6 +13,16 @@ function getErrorMessage(error: unknown, fallback: string): string {
   return fallback;
 }
 
+type PasswordPolicy = { minLength: number; minClasses: number; history: number };
+
+function describePolicy(p: PasswordPolicy): string {
+  const parts = [`At least ${p.minLength} characters`];
+  if (p.minClasses > 1) parts.push(`mixing ${p.minClasses} of lowercase, uppercase, digits and symbols`);
+  let text = parts.join(", ") + ". Common passwords and your username are not allowed.";
+  if (p.history > 1) text += ` You cannot reuse your last ${p.history} passwords.`;
+  return text;
+}
+
 export function ChangePasswordCard() {
   const [oldPassword, setOldPassword] = useState("");
   const [newPassword, setNewPassword] = useState("");
@@ -20,6 +30,20 @@ export function ChangePasswordCard() {
   const [busy, setBusy] = useState(false);
   const [error, setError] = useState<string | null>(null);
   const [success, setSuccess] = useState<string | null>(null);
+  const [policy, setPolicy] = useState<PasswordPolicy | null>(null);
+
+  useEffect(() => {
+    fetch("/api/auth/password-policy", { cache: "no-store" })
+      .then((res) => (res.ok ? res.json() : null))
+      .then((data) => {
+        if (data && typeof data.minLength === "number") {
+          setPolicy({ minLength: data.minLength, minClasses: Number(data.minClasses ?? 1), history: Number(data.history ?? 0) });
+        }
+      })
+      .catch(() => {
+        // The backend still enforces the policy; the hint is optional.
+      });
+  }, []);
 
   return (
     <Card>
@@ -39,8 +63,8 @@ export function ChangePasswordCard() {
               setError("Please fill in all fields.");
               return;
             }
-            if (newPassword.length < 8) {
-              setError("New password must be at least 8 characters.");
+            if (policy && newPassword.length < policy.minLength) {
+              setError(`New password must be at least ${policy.minLength} characters.`);
               return;
             }
             if (newPassword !== confirmNewPassword) {
@@ -100,6 +124,7 @@ export function ChangePasswordCard() {
               onChange={(e) => setNewPassword(e.target.value)}
               autoComplete="new-password"
             />
+            {policy ? <p className="text-xs text-muted-foreground">{describePolicy(policy)}</p> : null}
           </div>
 
           <div className="grid gap-2">
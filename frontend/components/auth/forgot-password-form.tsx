@@ -0,0 +1,83 @@
+"use client";
+
+import Link from "next/link";
+import { useState } from "react";
+
+import { Button } from "@/components/ui/button";
+import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card";
+import { Input } from "@/components/ui/input";
+import { Label } from "@/components/ui/label";
+
+export function ForgotPasswordForm() {
+  const [usernameOrEmail, setUsernameOrEmail] = useState("");
+  const [busy, setBusy] = useState(false);
+  const [error, setError] = useState<string | null>(null);
+  const [sent, setSent] = useState<string | null>(null);
+
+  return (
+    <div className="mx-auto w-full max-w-md">
+      <Card>
+        <CardHeader>
+          <CardTitle>Forgot password</CardTitle>
+          <CardDescription>Enter your username or email and we will email you a link to choose a new password.</CardDescription>
+        </CardHeader>
+        <CardContent>
+          <form
+            className="grid gap-4"
+            onSubmit={async (e) => {
+              e.preventDefault();
+              setError(null);
+              setSent(null);
+              if (!usernameOrEmail.trim()) {
+                setError("Please enter your username or email.");
+                return;
+              }
+              setBusy(true);
+              try {
+                const res = await fetch("/api/auth/forgot-password", {
+                  method: "POST",
+                  headers: { "content-type": "application/json" },
+                  body: JSON.stringify({ usernameOrEmail }),
+                });
+                const data = await res.json().catch(() => ({}));
+                if (!res.ok) {
+                  setError(data?.message ?? "Request failed");
+                  return;
+                }
+                setSent(data?.message ?? "If the account exists, a reset link has been sent.");
+              } catch {
+                setError("Network error. Please try again.");
+              } finally {
+                setBusy(false);
+              }
+            }}
+          >
+            <div className="grid gap-2">
+              <Label htmlFor="usernameOrEmail">Username or email</Label>
+              <Input
+                id="usernameOrEmail"
+                type="text"
+                value={usernameOrEmail}
+                onChange={(e) => setUsernameOrEmail(e.target.value)}
+                autoComplete="username"
+              />
+            </div>
+
+            {error ? <p className="text-sm font-medium text-destructive">{error}</p> : null}
+            {sent ? <p className="text-sm font-medium text-emerald-600">{sent}</p> : null}
+
+            <Button type="submit" className="w-full" disabled={busy}>
+              {busy ? "Sending..." : "Send reset link"}
+            </Button>
+
+            <div className="flex items-center justify-center">
+              <Button asChild variant="link" className="px-0">
+                <Link href="/auth/login">Back to login</Link>
+              </Button>
+            </div>
+          </form>
+        </CardContent>
+      </Card>
+    </div>
+  );
+}
@@ -28,11 +28,27 @@ export function LoginForm({
 }: LoginFormProps) {
   const router = useRouter();
 
+  const [error, setError] = useState<string | null>(null);
   const [nextPath, setNextPath] = useState<string | null>(null);
+  const [ssoLabel, setSsoLabel] = useState<string | null>(null);
+  const [passwordDisabled, setPasswordDisabled] = useState(false);
   useEffect(() => {
     const params = new URLSearchParams(window.location.search);
     setNextPath(params.get("next"));
-  }, []);
+    const ssoError = params.get("ssoError");
+    if (ssoError) setError(ssoError);
+
+    fetch("/api/auth/providers", { cache: "no-store" })
+      .then((res) => (res.ok ? res.json() : null))
+      .then((data) => {
+        if (data?.oidc?.enabled) setSsoLabel(String(data.oidc.label ?? "single sign-on"));
+        const disabled: unknown = data?.passwordLoginDisabledRoles;
+        setPasswordDisabled(Array.isArray(disabled) && disabled.includes(expectedRole));
+      })
+      .catch(() => {
+        // Password login stays available if the backend cannot say otherwise.
+      });
+  }, [expectedRole]);
 
   const safeNextPath = useMemo(() => {
     if (!nextPath) return null;
@@ -43,8 +59,12 @@ export function LoginForm({
 
   const [usernameOrEmail, setUsernameOrEmail] = useState("");
   const [password, setPassword] = useState("");
-  const [error, setError] = useState<string | null>(null);
   const [isLoading, setIsLoading] = useState(false);
+  // Set once the password is accepted for an account with two-factor
+  // authentication; the form then asks for the code.
+  const [mfaToken, setMfaToken] = useState<string | null>(null);
+  const [code, setCode] = useState("");
+  const [useRecoveryCode, setUseRecoveryCode] = useState(false);
 
   return (
     <div className="mx-auto w-full max-w-md">
@@ -61,18 +81,37 @@ export function LoginForm({
               setError(null);
               setIsLoading(true);
               try {
-                const res = await fetch("/api/auth/login", {
-                  method: "POST",
-                  headers: { "content-type": "application/json" },
-                  body: JSON.stringify({ usernameOrEmail, password, expectedRole }),
-                });
+                const res = mfaToken
+                  ? await fetch("/api/auth/login/mfa", {
+                      method: "POST",
+                      headers: { "content-type": "application/json" },
+                      body: JSON.stringify(
+                        useRecoveryCode ? { mfaToken, recoveryCode: code, expectedRole } : { mfaToken, code, expectedRole },
+                      ),
+                    })
+                  : await fetch("/api/auth/login", {
+                      method: "POST",
+                      headers: { "content-type": "application/json" },
+                      body: JSON.stringify({ usernameOrEmail, password, expectedRole }),
+                    });
                 const data = await res.json().catch(() => ({}));
                 if (!res.ok) {
+                  if (mfaToken && res.status === 401 && data?.message !== "invalid code") {
+                    // The second step timed out; start over with the password.
+                    setMfaToken(null);
+                    setCode("");
+                  }
                   setError(data?.message ?? "Login failed");
                   return;
                 }
+                if (data?.mfaRequired) {
+                  setMfaToken(data.mfaToken);
+                  setCode("");
+                  return;
+                }
 
-                const role = data?.role as Role | undefined;
+                // Every staff role signs in through the admin login.
+                const role: Role | undefined = data?.role ? (data.role === "student" ? "student" : "admin") : undefined;
                 if (!role) {
                   setError("Login succeeded, but role is missing.");
                   return;
@@ -81,13 +120,15 @@ export function LoginForm({
                 // Removed automatic dismissal on login. 
                 // We now rely on the user manually dismissing it OR the password change flow.
 
-                if (data.requiresPasswordChange) {
-                  // Redirect to change password page if required
-                  if (role === "admin") {
-                    router.push("/auth/admin-login/change-password");
-                  } else {
-                    router.push("/auth/login/change-password?role=student");
-                  }
+                if (data.mustChangePassword) {
+                  // Accounts on a temporary password must pick their own first.
+                  router.push(role === "admin" ? "/admin/password" : "/student/password");
+                  return;
+                }
+
+                if (data.mfaSetupRequired) {
+                  // Admins must enroll in two-factor authentication first.
+                  router.push("/admin/security");
                   return;
                 }
 
@@ -110,37 +151,77 @@ export function LoginForm({
               }
             }}
           >
-            <div className="grid gap-2">
-              <Label htmlFor="usernameOrEmail">Email</Label>
-              <Input
-                id="usernameOrEmail"
-                type="text"
-                placeholder="username or email"
-                value={usernameOrEmail}
-                onChange={(e) => setUsernameOrEmail(e.target.value)}
-              />
-            </div>
-            <div className="grid gap-2">
-              <Label htmlFor="password">Password</Label>
-              <Input
-                id="password"
-                type="password"
-                placeholder="••••••••"
-                value={password}
-                onChange={(e) => setPassword(e.target.value)}
-              />
-            </div>
+            {ssoLabel && !mfaToken ? (
+              <Button asChild variant={passwordDisabled ? "default" : "outline"} className="w-full">
+                <a href="/api/auth/oidc/start">Sign in with {ssoLabel}</a>
+              </Button>
+            ) : null}
+
+            {passwordDisabled && !mfaToken ? null : mfaToken ? (
+              <div className="grid gap-2">
+                <Label htmlFor="code">{useRecoveryCode ? "Recovery code" : "Authentication code"}</Label>
+                <Input
+                  id="code"
+                  type="text"
+                  inputMode={useRecoveryCode ? "text" : "numeric"}
+                  autoComplete="one-time-code"
+                  autoFocus
+                  placeholder={useRecoveryCode ? "xxxxxxxx-xxxxxxxx" : "123456"}
+                  value={code}
+                  onChange={(e) => setCode(e.target.value)}
+                />
+                <Button
+                  type="button"
+                  variant="link"
+                  className="justify-start px-0"
+                  onClick={() => {
+                    setUseRecoveryCode(!useRecoveryCode);
+                    setCode("");
+                  }}
+                >
+                  {useRecoveryCode ? "Use your authenticator app instead" : "Lost your authenticator? Use a recovery code"}
+                </Button>
+              </div>
+            ) : (
+              <>
+                <div className="grid gap-2">
+                  <Label htmlFor="usernameOrEmail">Email</Label>
+                  <Input
+                    id="usernameOrEmail"
+                    type="text"
+                    placeholder="username or email"
+                    value={usernameOrEmail}
+                    onChange={(e) => setUsernameOrEmail(e.target.value)}
+                  />
+                </div>
+                <div className="grid gap-2">
+                  <Label htmlFor="password">Password</Label>
+                  <Input
+                    id="password"
+                    type="password"
+                    placeholder="••••••••"
+                    value={password}
+                    onChange={(e) => setPassword(e.target.value)}
+                  />
+                </div>
+              </>
+            )}
 
             {error ? <p className="text-sm font-medium text-destructive">{error}</p> : null}
 
-            <Button type="submit" className="w-full" disabled={isLoading}>
-              {isLoading ? "Signing in..." : "Login"}
-            </Button>
+            {passwordDisabled && !mfaToken ? null : (
+              <Button type="submit" className="w-full" disabled={isLoading}>
+                {isLoading ? "Signing in..." : mfaToken ? "Verify" : "Login"}
+              </Button>
+            )}
 
             <div className="flex items-center justify-center gap-3">
               <Button asChild variant="link" className="px-0">
                 <Link href="/">Back to Home</Link>
               </Button>
+              <Button asChild variant="link" className="px-0">
+                <Link href="/auth/forgot-password">Forgot password?</Link>
+              </Button>
             </div>
           </form>
         </CardContent>
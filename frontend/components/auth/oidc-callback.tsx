@@ -0,0 +1,148 @@
+"use client";
+
+import Link from "next/link";
+import { useRouter } from "next/navigation";
+import { useEffect, useRef, useState } from "react";
+
+import { Button } from "@/components/ui/button";
+import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card";
+import { Input } from "@/components/ui/input";
+import { Label } from "@/components/ui/label";
+
+type LoginResult = {
+  role?: string;
+  mustChangePassword?: boolean;
+  mfaSetupRequired?: boolean;
+  mfaRequired?: boolean;
+  mfaToken?: string;
+  message?: string;
+};
+
+// OidcCallback finishes a single sign-on login when the identity provider
+// sends the user back, asking for the two-factor code if the account has one.
+export function OidcCallback() {
+  const router = useRouter();
+  const started = useRef(false);
+  const [error, setError] = useState<string | null>(null);
+  const [mfaToken, setMfaToken] = useState<string | null>(null);
+  const [code, setCode] = useState("");
+  const [busy, setBusy] = useState(false);
+
+  const finish = (data: LoginResult) => {
+    if (data.mfaRequired && data.mfaToken) {
+      setMfaToken(data.mfaToken);
+      return;
+    }
+    const side = data.role === "student" ? "student" : "admin";
+    if (data.mustChangePassword) {
+      router.push(`/${side}/password`);
+    } else if (data.mfaSetupRequired) {
+      router.push("/admin/security");
+    } else {
+      router.push(`/${side}`);
+    }
+    router.refresh();
+  };
+
+  useEffect(() => {
+    // The code is single-use, so guard against the effect running twice.
+    if (started.current) return;
+    started.current = true;
+
+    const params = new URLSearchParams(window.location.search);
+    const providerError = params.get("error_description") ?? params.get("error");
+    if (providerError) {
+      setError(providerError);
+      return;
+    }
+    const codeParam = params.get("code");
+    const state = params.get("state");
+    if (!codeParam || !state) {
+      setError("The sign-in response is incomplete.");
+      return;
+    }
+    void (async () => {
+      try {
+        const res = await fetch("/api/auth/oidc/callback", {
+          method: "POST",
+          headers: { "content-type": "application/json" },
+          body: JSON.stringify({ code: codeParam, state }),
+        });
+        const data = (await res.json().catch(() => ({}))) as LoginResult;
+        if (!res.ok) {
+          setError(data.message ?? "Sign-in failed");
+          return;
+        }
+        finish(data);
+      } catch {
+        setError("Network error. Please try again.");
+      }
+    })();
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, []);
+
+  return (
+    <div className="mx-auto w-full max-w-md">
+      <Card>
+        <CardHeader>
+          <CardTitle>Single Sign-On</CardTitle>
+          <CardDescription>
+            {mfaToken ? "Enter the code from your authenticator app." : error ? "Sign-in did not complete." : "Signing you in..."}
+          </CardDescription>
+        </CardHeader>
+        <CardContent className="grid gap-4">
+          {mfaToken ? (
+            <form
+              className="grid gap-4"
+              onSubmit={async (e) => {
+                e.preventDefault();
+                setError(null);
+                setBusy(true);
+                try {
+                  const res = await fetch("/api/auth/login/mfa", {
+                    method: "POST",
+                    headers: { "content-type": "application/json" },
+                    body: JSON.stringify({ mfaToken, code }),
+                  });
+                  const data = (await res.json().catch(() => ({}))) as LoginResult;
+                  if (!res.ok) {
+                    setError(data.message ?? "Sign-in failed");
+                    return;
+                  }
+                  finish(data);
+                } catch {
+                  setError("Network error. Please try again.");
+                } finally {
+                  setBusy(false);
+                }
+              }}
+            >
+              <div className="grid gap-2">
+                <Label htmlFor="code">Authentication code</Label>
+                <Input
+                  id="code"
+                  inputMode="numeric"
+                  autoComplete="one-time-code"
+                  autoFocus
+                  value={code}
+                  onChange={(e) => setCode(e.target.value)}
+                />
+              </div>
+              <Button type="submit" disabled={busy || !code}>
+                {busy ? "Verifying..." : "Verify"}
+              </Button>
+            </form>
+          ) : null}
+
+          {error ? <p className="text-sm font-medium text-destructive">{error}</p> : null}
+
+          {error ? (
+            <Button asChild variant="link" className="px-0">
+              <Link href="/auth/login">Back to login</Link>
+            </Button>
+          ) : null}
+        </CardContent>
+      </Card>
+    </div>
+  );
+}
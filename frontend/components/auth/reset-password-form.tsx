@@ -0,0 +1,117 @@
+"use client";
+
+import Link from "next/link";
+import { useEffect, useState } from "react";
+
+import { Button } from "@/components/ui/button";
+import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card";
+import { Input } from "@/components/ui/input";
+import { Label } from "@/components/ui/label";
+
+export function ResetPasswordForm() {
+  const [token, setToken] = useState<string | null>(null);
+  const [newPassword, setNewPassword] = useState("");
+  const [confirmNewPassword, setConfirmNewPassword] = useState("");
+  const [busy, setBusy] = useState(false);
+  const [error, setError] = useState<string | null>(null);
+  const [done, setDone] = useState(false);
+
+  useEffect(() => {
+    const params = new URLSearchParams(window.location.search);
+    setToken(params.get("token"));
+    // Keep the token out of the history and any later Referer header.
+    window.history.replaceState(null, "", window.location.pathname);
+  }, []);
+
+  return (
+    <div className="mx-auto w-full max-w-md">
+      <Card>
+        <CardHeader>
+          <CardTitle>Choose a new password</CardTitle>
+          <CardDescription>Reset links work once and expire after a short time.</CardDescription>
+        </CardHeader>
+        <CardContent>
+          {done ? (
+            <div className="grid gap-4">
+              <p className="text-sm font-medium text-emerald-600">Your password has been reset. Sign in with your new password.</p>
+              <Button asChild className="w-full">
+                <Link href="/auth/login">Go to login</Link>
+              </Button>
+            </div>
+          ) : (
+            <form
+              className="grid gap-4"
+              onSubmit={async (e) => {
+                e.preventDefault();
+                setError(null);
+                if (!token) {
+                  setError("This reset link is incomplete. Request a new one.");
+                  return;
+                }
+                if (!newPassword) {
+                  setError("Please enter a new password.");
+                  return;
+                }
+                if (newPassword !== confirmNewPassword) {
+                  setError("New passwords do not match.");
+                  return;
+                }
+                setBusy(true);
+                try {
+                  const res = await fetch("/api/auth/reset-password", {
+                    method: "POST",
+                    headers: { "content-type": "application/json" },
+                    body: JSON.stringify({ token, newPassword }),
+                  });
+                  const data = await res.json().catch(() => ({}));
+                  if (!res.ok) {
+                    setError(data?.message ?? "Failed to reset password");
+                    return;
+                  }
+                  setDone(true);
+                } catch {
+                  setError("Network error. Please try again.");
+                } finally {
+                  setBusy(false);
+                }
+              }}
+            >
+              <div className="grid gap-2">
+                <Label htmlFor="newPassword">New password</Label>
+                <Input
+                  id="newPassword"
+                  type="password"
+                  value={newPassword}
+                  onChange={(e) => setNewPassword(e.target.value)}
+                  autoComplete="new-password"
+                />
+              </div>
+              <div className="grid gap-2">
+                <Label htmlFor="confirmNewPassword">Confirm new password</Label>
+                <Input
+                  id="confirmNewPassword"
+                  type="password"
+                  value={confirmNewPassword}
+                  onChange={(e) => setConfirmNewPassword(e.target.value)}
+                  autoComplete="new-password"
+                />
+              </div>
+
+              {error ? <p className="text-sm font-medium text-destructive">{error}</p> : null}
+
+              <Button type="submit" className="w-full" disabled={busy}>
+                {busy ? "Saving..." : "Reset password"}
+              </Button>
+
+              <div className="flex items-center justify-center">
+                <Button asChild variant="link" className="px-0">
+                  <Link href="/auth/forgot-password">Request a new link</Link>
+                </Button>
+              </div>
+            </form>
+          )}
+        </CardContent>
+      </Card>
+    </div>
+  );
+}
@@ -0,0 +1,196 @@
+"use client";
+
+import { useCallback, useEffect, useState } from "react";
+
+import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card";
+import { Label } from "@/components/ui/label";
+import { Input } from "@/components/ui/input";
+import { Button } from "@/components/ui/button";
+
+type TwoFactorStatus = {
+  enabled: boolean;
+  required: boolean;
+  recoveryCodesRemaining: number;
+};
+
+type Enrollment = { secret: string; otpauthUri: string };
+
+async function post(action: string, body: unknown) {
+  const res = await fetch(`/api/auth/2fa/${action}`, {
+    method: "POST",
+    headers: { "content-type": "application/json" },
+    body: JSON.stringify(body ?? {}),
+  });
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error(data?.message ?? "Request failed");
+  return data;
+}
+
+function RecoveryCodes({ codes }: { codes: string[] }) {
+  return (
+    <div className="grid gap-2 rounded-md border p-4">
+      <p className="text-sm font-medium">Recovery codes</p>
+      <p className="text-sm text-muted-foreground">
+        Each code signs you in once if you lose your authenticator. Store them somewhere safe; they are not shown again.
+      </p>
+      <pre className="grid grid-cols-2 gap-1 font-mono text-sm">
+        {codes.map((c) => (
+          <span key={c}>{c}</span>
+        ))}
+      </pre>
+    </div>
+  );
+}
+
+export function TwoFactorCard() {
+  const [status, setStatus] = useState<TwoFactorStatus | null>(null);
+  const [enrollment, setEnrollment] = useState<Enrollment | null>(null);
+  const [recoveryCodes, setRecoveryCodes] = useState<string[] | null>(null);
+  const [code, setCode] = useState("");
+  const [password, setPassword] = useState("");
+  const [busy, setBusy] = useState(false);
+  const [error, setError] = useState<string | null>(null);
+
+  const load = useCallback(async () => {
+    const res = await fetch("/api/auth/2fa", { cache: "no-store" });
+    const data = await res.json().catch(() => null);
+    if (res.ok && data) {
+      setStatus({
+        enabled: Boolean(data.enabled),
+        required: Boolean(data.required),
+        recoveryCodesRemaining: Number(data.recoveryCodesRemaining ?? 0),
+      });
+    } else {
+      setError(data?.message ?? "Failed to load two-factor status");
+    }
+  }, []);
+
+  useEffect(() => {
+    void load();
+  }, [load]);
+
+  const run = async (fn: () => Promise<void>) => {
+    setError(null);
+    setBusy(true);
+    try {
+      await fn();
+    } catch (e) {
+      setError(e instanceof Error ? e.message : "Request failed");
+    } finally {
+      setBusy(false);
+    }
+  };
+
+  return (
+    <Card>
+      <CardHeader>
+        <CardTitle>Authenticator App</CardTitle>
+        <CardDescription>
+          {status?.enabled
+            ? `Enabled. ${status.recoveryCodesRemaining} recovery codes left.`
+            : status?.required
+              ? "Two-factor authentication is required for admin accounts. Set it up to continue."
+              : "Not enabled."}
+        </CardDescription>
+      </CardHeader>
+      <CardContent className="grid gap-4">
+        {recoveryCodes ? <RecoveryCodes codes={recoveryCodes} /> : null}
+
+        {status && !status.enabled && !enrollment ? (
+          <Button
+            disabled={busy}
+            onClick={() =>
+              run(async () => {
+                setEnrollment(await post("setup", {}));
+              })
+            }
+          >
+            Set up authenticator
+          </Button>
+        ) : null}
+
+        {status && !status.enabled && enrollment ? (
+          <form
+            className="grid gap-4"
+            onSubmit={(e) => {
+              e.preventDefault();
+              void run(async () => {
+                const data = await post("enable", { code });
+                setRecoveryCodes(data.recoveryCodes ?? []);
+                setEnrollment(null);
+                setCode("");
+                await load();
+              });
+            }}
+          >
+            <div className="grid gap-2">
+              <p className="text-sm text-muted-foreground">
+                Add this account to your authenticator app by opening the link or entering the key, then type the
+                6-digit code it shows.
+              </p>
+              <a className="break-all text-sm underline" href={enrollment.otpauthUri}>
+                {enrollment.otpauthUri}
+              </a>
+              <p className="font-mono text-sm">Key: {enrollment.secret}</p>
+            </div>
+            <div className="grid gap-2">
+              <Label htmlFor="enable-code">Code</Label>
+              <Input id="enable-code" inputMode="numeric" autoComplete="one-time-code" value={code} onChange={(e) => setCode(e.target.value)} />
+            </div>
+            <Button type="submit" disabled={busy || !code}>
+              Enable
+            </Button>
+          </form>
+        ) : null}
+
+        {status?.enabled ? (
+          <div className="grid gap-4">
+            <div className="grid gap-2">
+              <Label htmlFor="manage-code">Current code</Label>
+              <Input id="manage-code" inputMode="numeric" autoComplete="one-time-code" value={code} onChange={(e) => setCode(e.target.value)} />
+            </div>
+            <Button
+              variant="outline"
+              disabled={busy || !code}
+              onClick={() =>
+                run(async () => {
+                  const data = await post("recovery-codes", { code });
+                  setRecoveryCodes(data.recoveryCodes ?? []);
+                  setCode("");
+                  await load();
+                })
+              }
+            >
+              Generate new recovery codes
+            </Button>
+            {!status.required ? (
+              <>
+                <div className="grid gap-2">
+                  <Label htmlFor="disable-password">Password</Label>
+                  <Input id="disable-password" type="password" value={password} onChange={(e) => setPassword(e.target.value)} />
+                </div>
+                <Button
+                  variant="destructive"
+                  disabled={busy || !code || !password}
+                  onClick={() =>
+                    run(async () => {
+                      await post("disable", { password, code });
+                      setRecoveryCodes(null);
+                      setCode("");
+                      setPassword("");
+                      await load();
+                    })
+                  }
+                >
+                  Turn off two-factor authentication
+                </Button>
+              </>
+            ) : null}
+          </div>
+        ) : null}
+
+        {error ? <p className="text-sm font-medium text-destructive">{error}</p> : null}
+      </CardContent>
+    </Card>
+  );
+}
@@ -29,6 +29,27 @@ export function LogoutButton(props: LogoutButtonProps) {
 
 	const { children, onClick: _onClick, ...buttonProps } = props;
 
+	// all signs out every session of the account, not just this browser.
+	const logout = (all: boolean) => {
+		setError(null);
+		startTransition(async () => {
+			try {
+				const res = await fetch(all ? "/api/auth/logout?all=1" : "/api/auth/logout", { method: "POST" });
+				if (!res.ok) {
+					const text = await res.text();
+					throw new Error(text || `Logout failed (${res.status})`);
+				}
+				setOpen(false);
+				router.push("/");
+				router.refresh();
+			} catch (err: unknown) {
+				setError(
+					err instanceof Error ? err.message : "Logout failed. Please try again.",
+				);
+			}
+		});
+	};
+
 	return (
 		<AlertDialog
 			open={open}
@@ -66,6 +87,17 @@ export function LogoutButton(props: LogoutButtonProps) {
 							Cancel
 						</Button>
 					</AlertDialogCancel>
+					<Button
+						type="button"
+						variant="outline"
+						disabled={isPending}
+						onClick={(e) => {
+							e.preventDefault();
+							logout(true);
+						}}
+					>
+						Log out all devices
+					</Button>
 					<AlertDialogAction asChild>
 						<Button
 							type="button"
@@ -73,23 +105,7 @@ export function LogoutButton(props: LogoutButtonProps) {
 							disabled={isPending}
 							onClick={(e) => {
 								e.preventDefault();
-								setError(null);
-								startTransition(async () => {
-									try {
-										const res = await fetch("/api/auth/logout", { method: "POST" });
-										if (!res.ok) {
-											const text = await res.text();
-											throw new Error(text || `Logout failed (${res.status})`);
-										}
-										setOpen(false);
-										router.push("/");
-										router.refresh();
-									} catch (err: unknown) {
-										setError(
-											err instanceof Error ? err.message : "Logout failed. Please try again.",
-										);
-									}
-								});
+								logout(false);
 							}}
 						>
 							Logout
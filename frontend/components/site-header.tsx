@@ -33,7 +33,7 @@ export async function SiteHeader() {
 	const token = (await cookies()).get("huhems_token")?.value
 	const role = token ? parseJwtPayload(token)?.role : null
 	const isLoggedIn = Boolean(token)
-	const isAdmin = role === "admin"
+	const isAdmin = Boolean(role) && role !== "student"
 	const isStudent = role === "student"
 
 	return (
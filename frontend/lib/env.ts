@@ -8,3 +8,22 @@ export function getApiBaseUrl(): string {
   // Remove trailing slash if present
   return url.replace(/\/$/, "");
 }
+
+// secureCookies reports whether cookies should be marked Secure. COOKIE_SECURE
+// ("true" or "false") decides when set; otherwise it follows the scheme the
+// browser used, as reported by a TLS-terminating proxy or the request URL.
+export function secureCookies(headers: Headers, url?: string): boolean {
+  const configured = process.env.COOKIE_SECURE?.trim().toLowerCase();
+  if (configured === "true" || configured === "1") return true;
+  if (configured === "false" || configured === "0") return false;
+  const forwardedProto = headers.get("x-forwarded-proto")?.split(",")[0]?.trim().toLowerCase();
+  if (forwardedProto) return forwardedProto === "https";
+  if (url) {
+    try {
+      return new URL(url).protocol === "https:";
+    } catch {
+      return false;
+    }
+  }
+  return false;
+}
@@ -0,0 +1,12 @@
+// getClientHeaders returns the headers identifying the user's browser, to
+// pass on when calling the backend so that it sees the user's IP and user
+// agent rather than ours. The backend believes X-Forwarded-For only from the
+// addresses in its TRUSTED_PROXIES.
+export function getClientHeaders(request: Request): Record<string, string> {
+  const headers: Record<string, string> = {};
+  const userAgent = request.headers.get("user-agent");
+  if (userAgent) headers["user-agent"] = userAgent;
+  const forwardedFor = request.headers.get("x-forwarded-for");
+  if (forwardedFor) headers["x-forwarded-for"] = forwardedFor;
+  return headers;
+}
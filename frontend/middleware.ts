@@ -1,6 +1,16 @@
 import { NextResponse, type NextRequest } from "next/server";
 
+import { getApiBaseUrl, secureCookies } from "@/lib/env";
+import { getClientHeaders } from "@/lib/forward";
+
 const TOKEN_COOKIE = "huhems_token";
+const REFRESH_COOKIE = "huhems_refresh";
+const MUST_CHANGE_COOKIE = "huhems_must_change_password";
+const MFA_SETUP_COOKIE = "huhems_mfa_setup_required";
+
+// Renew the access token this long before it expires, so a request never
+// reaches the backend with a token that is about to lapse.
+const REFRESH_LEEWAY_SECONDS = 30;
 
 function base64UrlDecode(input: string): string {
   const pad = "=".repeat((4 - (input.length % 4)) % 4);
@@ -9,21 +19,124 @@ function base64UrlDecode(input: string): string {
   return atob(base64);
 }
 
-function getRoleFromToken(token: string): string | null {
+// Every staff role (admin, instructor, grader, proctor, auditor or a custom
+// role) uses the admin console; the backend checks permissions per route.
+function isStaffRole(role: string | null): boolean {
+  return Boolean(role) && role !== "student";
+}
+
+function getTokenPayload(token: string): { role?: string; exp?: number } | null {
   const parts = token.split(".");
   if (parts.length !== 3) return null;
   try {
-    const payload = JSON.parse(base64UrlDecode(parts[1])) as { role?: string };
-    return payload.role ?? null;
+    return JSON.parse(base64UrlDecode(parts[1])) as { role?: string; exp?: number };
   } catch {
     return null;
   }
 }
 
-export function middleware(request: NextRequest) {
+function getRoleFromToken(token: string): string | null {
+  return getTokenPayload(token)?.role ?? null;
+}
+
+function needsRefresh(token: string | undefined): boolean {
+  if (!token) return true;
+  const exp = getTokenPayload(token)?.exp;
+  return !exp || exp - Date.now() / 1000 < REFRESH_LEEWAY_SECONDS;
+}
+
+// refreshToken is missing when the backend answered within its grace period
+// for a token another request has just rotated; the browser keeps the refresh
+// token that request received.
+type RefreshedSession = { token: string; refreshToken?: string; refreshMaxAge: number };
+
+// Refreshes in flight, by refresh token. Parallel requests carrying the same
+// expiring token share one backend refresh instead of racing to rotate it.
+const refreshesInFlight = new Map<string, Promise<RefreshedSession | null | undefined>>();
+
+function refreshSessionOnce(request: NextRequest, refreshToken: string): Promise<RefreshedSession | null | undefined> {
+  let pending = refreshesInFlight.get(refreshToken);
+  if (!pending) {
+    pending = refreshSession(request, refreshToken).finally(() => refreshesInFlight.delete(refreshToken));
+    refreshesInFlight.set(refreshToken, pending);
+  }
+  return pending;
+}
+
+// refreshSession trades the refresh token for a new pair. It returns null if
+// the session has ended, and undefined if the backend could not be reached.
+async function refreshSession(request: NextRequest, refreshToken: string): Promise<RefreshedSession | null | undefined> {
+  let res: Response;
+  try {
+    res = await fetch(`${getApiBaseUrl()}/auth/refresh`, {
+      method: "POST",
+      headers: { "content-type": "application/json", ...getClientHeaders(request) },
+      body: JSON.stringify({ refreshToken }),
+      cache: "no-store",
+    });
+  } catch {
+    return undefined;
+  }
+  if (res.status === 400 || res.status === 401) return null;
+  if (!res.ok) return undefined;
+  const data = (await res.json().catch(() => null)) as {
+    token?: string;
+    refreshToken?: string;
+    refreshExpiresAt?: string;
+  } | null;
+  if (!data?.token) return undefined;
+  const expiresAt = data.refreshExpiresAt ? Date.parse(data.refreshExpiresAt) : NaN;
+  const refreshMaxAge = Number.isFinite(expiresAt) ? Math.max(0, Math.floor((expiresAt - Date.now()) / 1000)) : 60 * 60 * 24;
+  return { token: data.token, refreshToken: data.refreshToken || undefined, refreshMaxAge };
+}
+
+export async function middleware(request: NextRequest) {
   const { pathname, searchParams } = request.nextUrl;
-  const token = request.cookies.get(TOKEN_COOKIE)?.value;
-  const isLoggedIn = Boolean(token);
+  let token = request.cookies.get(TOKEN_COOKIE)?.value;
+  const refreshToken = request.cookies.get(REFRESH_COOKIE)?.value;
+
+  // Renew an expiring access token, and pass the new one on to the page or
+  // API route handling this request as well as back to the browser.
+  let refreshed: RefreshedSession | null | undefined;
+  if (refreshToken && needsRefresh(token)) {
+    refreshed = await refreshSessionOnce(request, refreshToken);
+    if (refreshed) {
+      token = refreshed.token;
+      request.cookies.set(TOKEN_COOKIE, refreshed.token);
+      if (refreshed.refreshToken) request.cookies.set(REFRESH_COOKIE, refreshed.refreshToken);
+    } else if (refreshed === null) {
+      token = undefined;
+      request.cookies.delete(TOKEN_COOKIE);
+      request.cookies.delete(REFRESH_COOKIE);
+    }
+  }
+
+  const finish = (res: NextResponse) => {
+    const options = {
+      httpOnly: true,
+      sameSite: "lax" as const,
+      secure: secureCookies(request.headers, request.url),
+      path: "/",
+    };
+    if (refreshed) {
+      res.cookies.set(TOKEN_COOKIE, refreshed.token, { ...options, maxAge: refreshed.refreshMaxAge });
+      if (refreshed.refreshToken) {
+        res.cookies.set(REFRESH_COOKIE, refreshed.refreshToken, { ...options, maxAge: refreshed.refreshMaxAge });
+      }
+    } else if (refreshed === null) {
+      res.cookies.set(TOKEN_COOKIE, "", { ...options, maxAge: 0 });
+      res.cookies.set(REFRESH_COOKIE, "", { ...options, maxAge: 0 });
+    }
+    return res;
+  };
+  const redirect = (url: URL) => finish(NextResponse.redirect(url));
+
+  if (pathname.startsWith("/api")) {
+    return finish(NextResponse.next({ request: { headers: request.headers } }));
+  }
+
+  const exp = token ? getTokenPayload(token)?.exp : undefined;
+  const isLoggedIn = Boolean(token) && (!exp || exp > Date.now() / 1000);
 
   const next = searchParams.get("next");
   const studentLoginUrl = new URL("/auth/login", request.url);
@@ -33,35 +146,47 @@ export function middleware(request: NextRequest) {
     adminLoginUrl.searchParams.set("next", pathname);
   }
 
+  // Until a temporary or expired password is changed the backend only
+  // serves the password change, so keep the user on that page.
+  const mustChangePassword = Boolean(request.cookies.get(MUST_CHANGE_COOKIE)?.value);
+  // Admins who must enroll in two-factor authentication are held the same
+  // way on the security page.
+  const mfaSetupRequired = Boolean(request.cookies.get(MFA_SETUP_COOKIE)?.value);
+
   if (pathname.startsWith("/admin")) {
-    if (!isLoggedIn) return NextResponse.redirect(adminLoginUrl);
+    if (!isLoggedIn) return redirect(adminLoginUrl);
     const role = getRoleFromToken(token!);
-    if (role !== "admin") return NextResponse.redirect(new URL("/", request.url));
+    if (!isStaffRole(role)) return redirect(new URL("/", request.url));
+    if (mustChangePassword && pathname !== "/admin/password") return redirect(new URL("/admin/password", request.url));
+    if (mfaSetupRequired && pathname !== "/admin/security" && pathname !== "/admin/password") {
+      return redirect(new URL("/admin/security", request.url));
+    }
   }
 
   if (pathname.startsWith("/student")) {
-    if (!isLoggedIn) return NextResponse.redirect(studentLoginUrl);
+    if (!isLoggedIn) return redirect(studentLoginUrl);
     const role = getRoleFromToken(token!);
-    if (role !== "student") return NextResponse.redirect(new URL("/", request.url));
+    if (role !== "student") return redirect(new URL("/", request.url));
+    if (mustChangePassword && pathname !== "/student/password") return redirect(new URL("/student/password", request.url));
   }
 
   if (pathname.startsWith("/auth/login") && isLoggedIn) {
     const role = getRoleFromToken(token!);
-    if (role === "admin") return NextResponse.redirect(new URL("/admin", request.url));
-    if (role === "student") return NextResponse.redirect(new URL("/student", request.url));
-    return NextResponse.redirect(new URL("/", request.url));
+    if (isStaffRole(role)) return redirect(new URL("/admin", request.url));
+    if (role === "student") return redirect(new URL("/student", request.url));
+    return redirect(new URL("/", request.url));
   }
 
   if (pathname.startsWith("/auth/admin-login") && isLoggedIn) {
     const role = getRoleFromToken(token!);
-    if (role === "admin") return NextResponse.redirect(new URL("/admin", request.url));
-    if (role === "student") return NextResponse.redirect(new URL("/student", request.url));
-    return NextResponse.redirect(new URL("/", request.url));
+    if (isStaffRole(role)) return redirect(new URL("/admin", request.url));
+    if (role === "student") return redirect(new URL("/student", request.url));
+    return redirect(new URL("/", request.url));
   }
 
-  return NextResponse.next();
+  return finish(NextResponse.next({ request: { headers: request.headers } }));
 }
 
 export const config = {
-  matcher: ["/admin/:path*", "/student/:path*", "/auth/login", "/auth/admin-login"],
+  matcher: ["/admin/:path*", "/student/:path*", "/auth/login", "/auth/admin-login", "/api/:path*"],
 };